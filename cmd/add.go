@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// AddCommand lists the components of "add" comamnd.
+type AddCommand struct {
+	fs      *flag.FlagSet
+	force   bool
+	verbose bool
+	paths   []string
+}
+
+// NewAddCommand creates a new command object.
+func NewAddCommand() *AddCommand {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	cmd := AddCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.force, "f", false, "Allow adding otherwise ignored files")
+	fs.BoolVar(&cmd.force, "force", false, "Allow adding otherwise ignored files")
+	fs.BoolVar(&cmd.verbose, "v", false, "Show the added and removed files")
+	fs.BoolVar(&cmd.verbose, "verbose", false, "Show the added and removed files")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *AddCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *AddCommand) Description() string {
+	return "Add file contents to the index"
+}
+
+// Init initializes and validates the given command.
+func (cmd *AddCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd.paths = cmd.fs.Args()
+	if len(cmd.paths) == 0 {
+		return errors.New("Nothing specified, nothing added.")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *AddCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] <path>...\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *AddCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	ignore, err := repo.NewIgnore()
+	util.Check(err)
+	fs, err := repo.FSConfig()
+	util.Check(err)
+
+	adder := &fileAdder{
+		repo:    repo,
+		index:   index,
+		fs:      fs,
+		ignore:  ignore,
+		force:   cmd.force,
+		verbose: cmd.verbose,
+		tracked: map[string]bool{},
+		dirs:    index.Dirs(),
+		ignored: []string{},
+	}
+	for _, entry := range index.Entries {
+		adder.tracked[entry.Path] = true
+	}
+
+	// Validate all the paths before changing anything.
+	paths := []string{}
+	for _, arg := range cmd.paths {
+		filePath, err := repoPath(repo, arg)
+		util.Check(err)
+		if _, err := os.Lstat(arg); err != nil && !adder.isTracked(filePath) {
+			util.Check(fmt.Errorf("fatal: pathspec '%s' did not match any files", arg))
+		}
+		paths = append(paths, filePath)
+	}
+
+	for _, filePath := range paths {
+		util.Check(adder.add(filePath, true))
+	}
+	util.Check(repo.WriteIndex(index))
+
+	if len(adder.ignored) > 0 {
+		fmt.Println("The following paths are ignored by one of your .gitignore files:")
+		for _, filePath := range adder.ignored {
+			fmt.Println(filePath)
+		}
+		fmt.Println("hint: Use -f if you really want to add them.")
+		os.Exit(1)
+	}
+}
+
+// fileAdder adds the files of the work-tree to the index.
+type fileAdder struct {
+	repo    *git.Repo
+	index   *git.Index
+	fs      git.FSConfig
+	ignore  *git.Ignore
+	force   bool
+	verbose bool
+	tracked map[string]bool
+	dirs    map[string]bool
+	// ignored are the paths given explicitly which are ignored.
+	ignored []string
+}
+
+// isTracked tells if a path is a file or a directory in the index. An empty
+// path is the top of the work-tree.
+func (a *fileAdder) isTracked(filePath string) bool {
+	return filePath == "" || a.tracked[filePath] || a.dirs[filePath]
+}
+
+// add adds a path of the work-tree to the index. The files inside a directory
+// are added recursively, skipping the ignored ones, and the tracked files
+// which are missing from the work-tree are removed from the index.
+func (a *fileAdder) add(filePath string, explicit bool) error {
+	fullPath := filepath.Join(a.repo.WorkTree, filepath.FromSlash(filePath))
+	info, err := os.Lstat(fullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err != nil || (info.IsDir() && a.tracked[filePath]) {
+		// A missing file, or a file replaced by a directory.
+		if a.index.Remove(filePath) && a.verbose {
+			fmt.Printf("remove '%s'\n", filePath)
+		}
+		if err != nil {
+			return a.removeMissing(filePath)
+		}
+	}
+
+	if !a.isTracked(filePath) && !a.force && a.ignore.IsIgnored(filePath, info.IsDir()) {
+		if explicit {
+			a.ignored = append(a.ignored, filePath)
+		}
+		return nil
+	}
+
+	// A directory is walked, unless it is a nested repository.
+	isRepo := false
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(fullPath, ".git")); err == nil && filePath != "" {
+			isRepo = true
+		}
+	}
+	if info.IsDir() && !isRepo {
+		if err := a.removeMissing(filePath); err != nil {
+			return err
+		}
+		infos, err := ioutil.ReadDir(fullPath)
+		if err != nil {
+			return err
+		}
+		for _, child := range infos {
+			if child.Name() == ".git" {
+				continue
+			}
+			if err := a.add(path.Join(filePath, child.Name()), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !a.tracked[filePath] && a.dirs[filePath] {
+		// A directory replaced by a file.
+		if err := a.removeMissing(filePath); err != nil {
+			return err
+		}
+	}
+
+	entry := a.index.Entry(filePath, 0)
+	if entry != nil && entry.StatMatches(info) && !a.index.IsRacy(entry) {
+		return nil
+	}
+	var oldHash string
+	if entry != nil {
+		oldHash = entry.Hash
+	}
+	if err := a.repo.AddFile(a.index, filePath, a.fs); err != nil {
+		return err
+	}
+	if a.verbose && a.index.Entry(filePath, 0).Hash != oldHash {
+		fmt.Printf("add '%s'\n", filePath)
+	}
+	return nil
+}
+
+// removeMissing removes the index entries inside a directory whose files
+// are missing from the work-tree.
+func (a *fileAdder) removeMissing(dir string) error {
+	prefix := dir + "/"
+	if dir == "" {
+		prefix = ""
+	}
+
+	missing := []string{}
+	for _, entry := range a.index.Entries {
+		if !strings.HasPrefix(entry.Path, prefix) {
+			continue
+		}
+		fullPath := filepath.Join(a.repo.WorkTree, filepath.FromSlash(entry.Path))
+		if info, err := os.Lstat(fullPath); err == nil && !info.IsDir() {
+			continue
+		} else if err == nil && entry.Mode&0170000 == 0160000 {
+			continue
+		}
+		missing = append(missing, entry.Path)
+	}
+
+	for _, filePath := range missing {
+		if a.index.Remove(filePath) && a.verbose {
+			fmt.Printf("remove '%s'\n", filePath)
+		}
+	}
+	return nil
+}
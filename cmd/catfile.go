@@ -4,6 +4,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/ssrathi/gogit/git"
 	"github.com/ssrathi/gogit/util"
@@ -89,28 +91,38 @@ func (cmd *CatFileCommand) Execute() {
 	objHash, err := repo.UniqueNameResolve(cmd.revision)
 	util.Check(err)
 
+	// The type and the size come from the header alone, and the data of a
+	// blob is streamed as it may be too large for memory.
+	objRdr, err := repo.ObjectOpen(objHash)
+	util.Check(err)
+	defer objRdr.Close()
+
+	if cmd.getType {
+		fmt.Println(objRdr.Type)
+		return
+	} else if cmd.getSize {
+		fmt.Println(objRdr.Size)
+		return
+	} else if objRdr.Type == "blob" {
+		_, err = io.Copy(os.Stdout, objRdr)
+		util.Check(err)
+		return
+	}
+
 	obj, err := repo.ObjectParse(objHash)
 	util.Check(err)
 
 	var objIntf git.ObjIntf
 	switch obj.ObjType {
-	case "blob":
-		objIntf, err = git.NewBlob(repo, obj)
-		util.Check(err)
 	case "tree":
 		objIntf, err = git.NewTree(repo, obj)
 		util.Check(err)
 	case "commit":
 		objIntf, err = git.NewCommit(repo, obj)
 		util.Check(err)
+	case "tag":
+		objIntf, err = git.NewTag(repo, obj)
+		util.Check(err)
 	}
-
-	// Only one of 'printObj', 'getType' and 'getSize' is provided.
-	if cmd.printObj {
-		fmt.Print(objIntf.Print())
-	} else if cmd.getType {
-		fmt.Println(objIntf.Type())
-	} else if cmd.getSize {
-		fmt.Println(objIntf.DataSize())
-	}
+	fmt.Print(objIntf.Print())
 }
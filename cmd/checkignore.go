@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// CheckIgnoreCommand lists the components of "check-ignore" comamnd.
+type CheckIgnoreCommand struct {
+	fs          *flag.FlagSet
+	verbose     bool
+	quiet       bool
+	nonMatching bool
+	nulTerm     bool
+	stdin       bool
+	noIndex     bool
+	paths       []string
+}
+
+// NewCheckIgnoreCommand creates a new command object.
+func NewCheckIgnoreCommand() *CheckIgnoreCommand {
+	fs := flag.NewFlagSet("check-ignore", flag.ExitOnError)
+	cmd := CheckIgnoreCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.verbose, "v", false, "Show the matching pattern along with each path")
+	fs.BoolVar(&cmd.verbose, "verbose", false, "Show the matching pattern along with each path")
+	fs.BoolVar(&cmd.quiet, "q", false, "Don't output anything, just set the exit status")
+	fs.BoolVar(&cmd.quiet, "quiet", false, "Don't output anything, just set the exit status")
+	fs.BoolVar(&cmd.nonMatching, "n", false, "Show the paths which don't match any pattern")
+	fs.BoolVar(&cmd.nonMatching, "non-matching", false,
+		"Show the paths which don't match any pattern")
+	fs.BoolVar(&cmd.nulTerm, "z", false, "Separate the input and output records with NUL")
+	fs.BoolVar(&cmd.stdin, "stdin", false, "Read the paths from the standard input")
+	fs.BoolVar(&cmd.noIndex, "no-index", false, "Check the tracked paths as well")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *CheckIgnoreCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *CheckIgnoreCommand) Description() string {
+	return "Debug gitignore / exclude files"
+}
+
+// Init initializes and validates the given command.
+func (cmd *CheckIgnoreCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd.paths = cmd.fs.Args()
+	if cmd.stdin {
+		if len(cmd.paths) > 0 {
+			return errors.New("fatal: cannot specify pathnames with --stdin")
+		}
+	} else {
+		if cmd.nulTerm {
+			return errors.New("fatal: -z only makes sense with --stdin")
+		}
+		if len(cmd.paths) == 0 {
+			return errors.New("fatal: no path specified")
+		}
+	}
+
+	if cmd.quiet {
+		if cmd.verbose {
+			return errors.New("fatal: cannot have both --quiet and --verbose")
+		}
+		if len(cmd.paths) > 1 {
+			return errors.New("fatal: --quiet is only valid with a single pathname")
+		}
+	}
+	if cmd.nonMatching && !cmd.verbose {
+		return errors.New("fatal: --non-matching is only valid with --verbose")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *CheckIgnoreCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] <pathname>...\n", cmd.Name())
+	fmt.Printf("   or: %s [<args>] --stdin\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *CheckIgnoreCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	ignore, err := repo.NewIgnore()
+	util.Check(err)
+	tracked := map[string]bool{}
+	if !cmd.noIndex {
+		index, err := repo.ReadIndex()
+		util.Check(err)
+		for _, entry := range index.Entries {
+			tracked[entry.Path] = true
+		}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	numIgnored := 0
+	check := func(arg string) {
+		filePath, err := repoPath(repo, arg)
+		util.Check(err)
+
+		var pattern *git.IgnorePattern
+		if filePath != "" && !tracked[filePath] {
+			isDir := strings.HasSuffix(arg, "/")
+			if info, err := os.Lstat(arg); err == nil && info.IsDir() {
+				isDir = true
+			}
+			pattern = ignore.Match(filePath, isDir)
+		}
+
+		// A negated pattern means that the path is not ignored, which is
+		// shown only in the verbose mode.
+		if pattern != nil && pattern.Negated && !cmd.verbose {
+			pattern = nil
+		}
+		if pattern != nil {
+			numIgnored++
+		}
+		if !cmd.quiet && (pattern != nil || cmd.nonMatching) {
+			cmd.printMatch(out, arg, pattern)
+		}
+	}
+
+	if cmd.stdin {
+		scanner := bufio.NewScanner(os.Stdin)
+		if cmd.nulTerm {
+			scanner.Split(scanNul)
+		}
+		for scanner.Scan() {
+			check(scanner.Text())
+			// The output is flushed for each path, so that the command can
+			// be used interactively.
+			util.Check(out.Flush())
+		}
+		util.Check(scanner.Err())
+	} else {
+		for _, arg := range cmd.paths {
+			check(arg)
+		}
+	}
+	util.Check(out.Flush())
+
+	if numIgnored == 0 {
+		os.Exit(1)
+	}
+}
+
+// printMatch prints a path along with the pattern which matches it, if asked.
+func (cmd *CheckIgnoreCommand) printMatch(out *bufio.Writer, arg string,
+	pattern *git.IgnorePattern) {
+	if !cmd.verbose {
+		if cmd.nulTerm {
+			fmt.Fprintf(out, "%s\x00", arg)
+		} else {
+			fmt.Fprintf(out, "%s\n", quotePath(arg))
+		}
+		return
+	}
+
+	source, line, rule := "", "", ""
+	if pattern != nil {
+		source, line, rule = pattern.Source, fmt.Sprint(pattern.Line), pattern.String()
+	}
+	if cmd.nulTerm {
+		fmt.Fprintf(out, "%s\x00%s\x00%s\x00%s\x00", source, line, rule, arg)
+	} else {
+		fmt.Fprintf(out, "%s:%s:%s\t%s\n", quotePath(source), line, rule, quotePath(arg))
+	}
+}
+
+// repoPath converts a path given on the command line, relative to the current
+// directory, to a path from the top of the work-tree.
+func repoPath(repo *git.Repo, arg string) (string, error) {
+	absPath, err := filepath.Abs(arg)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(repo.WorkTree, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("fatal: %s: '%s' is outside repository at '%s'",
+			arg, arg, repo.WorkTree)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return path.Clean(filepath.ToSlash(rel)), nil
+}
+
+// scanNul is a split function for bufio.Scanner to read NUL terminated
+// records.
+func scanNul(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == 0 {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
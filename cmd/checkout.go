@@ -14,7 +14,12 @@ import (
 type CheckoutCommand struct {
 	fs       *flag.FlagSet
 	path     string
+	force    bool
+	detach   bool
 	revision string
+	paths    []string
+	// dashed tells if the paths are given after a "--".
+	dashed bool
 }
 
 // NewCheckoutCommand creates a new command object.
@@ -23,7 +28,11 @@ func NewCheckoutCommand() *CheckoutCommand {
 		fs: flag.NewFlagSet("checkout", flag.ExitOnError),
 	}
 
-	cmd.fs.StringVar(&cmd.path, "path", ".", "Path to create the files")
+	cmd.fs.StringVar(&cmd.path, "path", "", "Path to create the files of a tree, "+
+		"instead of switching to it")
+	cmd.fs.BoolVar(&cmd.force, "f", false, "Throw away the local changes")
+	cmd.fs.BoolVar(&cmd.force, "force", false, "Throw away the local changes")
+	cmd.fs.BoolVar(&cmd.detach, "detach", false, "Detach HEAD at the given commit")
 	return cmd
 }
 
@@ -44,18 +53,35 @@ func (cmd *CheckoutCommand) Init(args []string) error {
 		return err
 	}
 
-	if cmd.fs.NArg() < 1 {
-		return errors.New("error: Missing <object> argument")
+	// Arguments after a "--" are always paths.
+	rest := cmd.fs.Args()
+	if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+		cmd.paths, cmd.dashed = rest, true
+	} else if len(rest) > 1 && rest[1] == "--" {
+		cmd.revision, cmd.paths, cmd.dashed = rest[0], rest[2:], true
+	} else if len(rest) > 0 {
+		cmd.revision, cmd.paths = rest[0], rest[1:]
 	}
 
-	cmd.revision = cmd.fs.Arg(0)
+	if cmd.dashed && len(cmd.paths) == 0 {
+		return errors.New("error: Missing <path> argument")
+	}
+	if cmd.revision == "" && len(cmd.paths) == 0 {
+		return errors.New("error: Missing <object> argument")
+	}
+	if cmd.path != "" && len(cmd.paths) > 0 {
+		return errors.New("error: -path can't be used with paths")
+	}
 	return nil
 }
 
 // Usage prints the usage string for the end user.
 func (cmd *CheckoutCommand) Usage() {
 	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
-	fmt.Printf("usage: %s [<args>] <object>\n", cmd.Name())
+	fmt.Printf("usage: %s [<args>] <branch>\n", cmd.Name())
+	fmt.Printf("   or: %s [<args>] [--detach] <commit>\n", cmd.Name())
+	fmt.Printf("   or: %s [<tree-ish>] [--] <pathspec>...\n", cmd.Name())
+	fmt.Printf("   or: %s -path <path> <object>\n", cmd.Name())
 	cmd.fs.PrintDefaults()
 }
 
@@ -64,6 +90,36 @@ func (cmd *CheckoutCommand) Execute() {
 	repo, err := git.GetRepo(".")
 	util.Check(err)
 
+	// Only the files of a tree can be created at a path without a work-tree.
+	if cmd.path == "" {
+		util.Check(repo.RequireWorkTree())
+	}
+
+	// Without a "--", the first argument is a path only if it is not a
+	// branch or a commit.
+	if cmd.path == "" && !cmd.dashed {
+		if _, err := repo.TreeResolve(cmd.revision); err != nil {
+			if _, statErr := os.Lstat(cmd.revision); statErr == nil {
+				cmd.revision, cmd.paths = "", append([]string{cmd.revision}, cmd.paths...)
+			}
+		}
+	}
+
+	if len(cmd.paths) > 0 {
+		cmd.checkoutPaths(repo)
+		return
+	}
+
+	if cmd.path == "" {
+		target, err := resolveSwitchTarget(repo, cmd.revision, cmd.detach)
+		if err != nil {
+			util.Check(fmt.Errorf("error: pathspec '%s' did not match any file(s) "+
+				"known to git", cmd.revision))
+		}
+		util.Check(switchBranch(repo, target, cmd.force, cmd.detach))
+		return
+	}
+
 	// Resolve the given revision to a full hash.
 	objHash, err := repo.UniqueNameResolve(cmd.revision)
 	util.Check(err)
@@ -93,3 +149,38 @@ func (cmd *CheckoutCommand) Execute() {
 	err = tree.Checkout(cmd.path)
 	util.Check(err)
 }
+
+// checkoutPaths restores the given paths from the index, or from a tree-ish
+// into both the index and the work-tree. The paths which are not in the
+// source are left alone.
+func (cmd *CheckoutCommand) checkoutPaths(repo *git.Repo) {
+	if cmd.detach {
+		util.Check(errors.New("fatal: '--detach' cannot be used with updating paths"))
+	}
+
+	opts := &git.RestoreOptions{Worktree: true, Overlay: true}
+	if cmd.revision == "" {
+		opts.FromIndex = true
+	} else {
+		treeHash, err := repo.TreeResolve(cmd.revision)
+		if err != nil {
+			util.Check(fmt.Errorf("fatal: invalid reference: %s", cmd.revision))
+		}
+		opts.Source, opts.Staged = treeHash, true
+	}
+	written := restorePaths(repo, cmd.paths, opts)
+
+	// The count tells what happened, when the paths could be mistaken for a
+	// branch.
+	if !cmd.dashed {
+		source := "the index"
+		if !opts.FromIndex {
+			source = opts.Source[:7]
+		}
+		noun := "paths"
+		if written == 1 {
+			noun = "path"
+		}
+		fmt.Printf("Updated %d %s from %s\n", written, noun, source)
+	}
+}
@@ -0,0 +1,529 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// CherryPickCommand lists the components of "cherry-pick" and "revert"
+// comamnds.
+type CherryPickCommand struct {
+	fs *flag.FlagSet
+	// action is "pick" for "cherry-pick", and "revert" for "revert".
+	action       string
+	noCommit     bool
+	recordOrigin bool
+	mainline     int
+	cont         bool
+	skip         bool
+	abort        bool
+	revisions    []string
+}
+
+// NewCherryPickCommand creates a new command object.
+func NewCherryPickCommand() *CherryPickCommand {
+	cmd := newSequencerCommand("cherry-pick", "pick")
+	cmd.fs.BoolVar(&cmd.recordOrigin, "x", false,
+		"Append a line naming the picked commit to the message")
+	return cmd
+}
+
+// NewRevertCommand creates a new command object.
+func NewRevertCommand() *CherryPickCommand {
+	return newSequencerCommand("revert", "revert")
+}
+
+// newSequencerCommand creates the command object shared by "cherry-pick" and
+// "revert".
+func newSequencerCommand(name, action string) *CherryPickCommand {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cmd := CherryPickCommand{
+		fs:     fs,
+		action: action,
+	}
+
+	fs.BoolVar(&cmd.noCommit, "n", false, "Apply the changes without committing them")
+	fs.BoolVar(&cmd.noCommit, "no-commit", false, "Apply the changes without committing them")
+	fs.IntVar(&cmd.mainline, "m", 0, "Parent number of the mainline for a merge commit")
+	fs.IntVar(&cmd.mainline, "mainline", 0, "Parent number of the mainline for a merge commit")
+	fs.BoolVar(&cmd.cont, "continue", false, "Continue after resolving a conflict")
+	fs.BoolVar(&cmd.skip, "skip", false, "Skip the current commit and continue")
+	fs.BoolVar(&cmd.abort, "abort", false, "Cancel the operation and restore HEAD")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *CherryPickCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *CherryPickCommand) Description() string {
+	if cmd.action == "revert" {
+		return "Revert some existing commits"
+	}
+	return "Apply the changes introduced by some existing commits"
+}
+
+// Init initializes and validates the given command.
+func (cmd *CherryPickCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	subcommands := 0
+	for _, set := range []bool{cmd.cont, cmd.skip, cmd.abort} {
+		if set {
+			subcommands++
+		}
+	}
+	if subcommands > 1 {
+		return errors.New("error: Only one of --continue, --skip and --abort can be given")
+	}
+	if subcommands == 1 {
+		if cmd.fs.NArg() != 0 || cmd.noCommit || cmd.recordOrigin || cmd.mainline != 0 {
+			return fmt.Errorf("fatal: %s: --continue, --skip and --abort take no "+
+				"other arguments", cmd.Name())
+		}
+		return nil
+	}
+
+	if cmd.fs.NArg() == 0 {
+		return errors.New("error: At least one <commit> argument is needed")
+	}
+	if cmd.mainline < 0 {
+		return errors.New("error: option 'mainline' expects a number greater than zero")
+	}
+	cmd.revisions = cmd.fs.Args()
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *CherryPickCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] <commit>...\n", cmd.Name())
+	fmt.Printf("   or: %s (--continue | --skip | --abort)\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *CherryPickCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	switch {
+	case cmd.cont:
+		cmd.resume(repo, false)
+	case cmd.skip:
+		cmd.resume(repo, true)
+	case cmd.abort:
+		cmd.abortPick(repo)
+	default:
+		cmd.start(repo)
+	}
+}
+
+// start picks the commits given on the command line. The state of a pick of
+// many commits is kept in ".git/sequencer", so that it can be resumed after a
+// conflict.
+func (cmd *CherryPickCommand) start(repo *git.Repo) {
+	cmd.checkUnmerged(repo)
+	seq, err := repo.ReadSequencer()
+	util.Check(err)
+	if pickAction(repo, seq) != "" {
+		util.Check(fmt.Errorf("error: %s is already in progress\n"+
+			"hint: try \"git %s (--continue | --skip | --abort)\"\n"+
+			"fatal: %s failed", cmd.Name(), cmd.Name(), cmd.Name()))
+	}
+
+	items := []git.SequencerItem{}
+	for _, name := range cmd.revisions {
+		commit, err := resolveCommit(repo, name)
+		if err != nil {
+			util.Check(fmt.Errorf("fatal: bad revision '%s'", name))
+		}
+		items = append(items, git.SequencerItem{Action: cmd.action, Commit: commit})
+	}
+
+	opts := &git.PickOptions{
+		NoCommit:     cmd.noCommit,
+		RecordOrigin: cmd.recordOrigin,
+		Mainline:     cmd.mainline,
+	}
+	if len(items) > 1 {
+		_, headHash, err := repo.Head()
+		util.Check(err)
+		seq = &git.Sequencer{Head: headHash, AbortSafety: headHash, Todo: items, Opts: *opts}
+		util.Check(repo.WriteSequencer(seq))
+	}
+	cmd.pickAll(repo, seq, items, opts)
+}
+
+// resume concludes the commit which stopped at a conflict, or skips it, and
+// then picks the remaining commits if any.
+func (cmd *CherryPickCommand) resume(repo *git.Repo, skip bool) {
+	seq, err := repo.ReadSequencer()
+	util.Check(err)
+	action := pickAction(repo, seq)
+	if action == "" || (skip && action != cmd.action) {
+		what := "cherry-pick or revert"
+		if skip {
+			what = cmd.Name()
+		}
+		util.Check(fmt.Errorf("error: no %s in progress\nfatal: %s failed", what, cmd.Name()))
+	}
+	stopped := pickHead(repo)
+
+	if skip {
+		discardMerge(repo)
+		util.Check(repo.ClearMergeState())
+	} else if stopped != "" {
+		cmd.commitResolved(repo, stopped)
+	}
+
+	if seq == nil {
+		return
+	}
+	items := seq.Todo
+	if len(items) > 0 {
+		items = items[1:]
+	}
+	opts := seq.Opts
+	cmd.pickAll(repo, seq, items, &opts)
+}
+
+// abortPick throws away the changes of the commit which stopped at a
+// conflict, and moves HEAD back to where it was before the first pick.
+func (cmd *CherryPickCommand) abortPick(repo *git.Repo) {
+	seq, err := repo.ReadSequencer()
+	util.Check(err)
+	if pickAction(repo, seq) == "" {
+		util.Check(fmt.Errorf("error: no cherry-pick or revert in progress\n"+
+			"fatal: %s failed", cmd.Name()))
+	}
+
+	discardMerge(repo)
+	util.Check(repo.ClearMergeState())
+	if seq == nil {
+		return
+	}
+
+	// HEAD is not rewound if it was moved by something else than the
+	// picks.
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	if headHash != seq.AbortSafety {
+		fmt.Println("warning: You seem to have moved HEAD. Not rewinding, check your HEAD!")
+	} else if headHash != seq.Head && seq.Head != "" {
+		headTree, err := repo.TreeResolve(headHash)
+		util.Check(err)
+		origTree, err := repo.TreeResolve(seq.Head)
+		util.Check(err)
+		err = repo.CheckoutTree(headTree, origTree, &git.CheckoutOptions{Command: "reset"})
+		if _, ok := err.(*git.CheckoutError); ok {
+			err = fmt.Errorf("%v\nfatal: %s failed", err, cmd.Name())
+		}
+		util.Check(err)
+		util.Check(repo.UpdateRef("HEAD", seq.Head))
+	}
+	util.Check(repo.RemoveSequencer())
+}
+
+// pickAll picks the given commits one after another. The sequencer state (if
+// any) is updated after each of them, and removed at the end.
+func (cmd *CherryPickCommand) pickAll(repo *git.Repo, seq *git.Sequencer,
+	items []git.SequencerItem, opts *git.PickOptions) {
+	config, err := repo.Config()
+	util.Check(err)
+	switch style, _ := config.Get("merge.conflictStyle"); style {
+	case "diff3":
+		opts.Style = git.ConflictDiff3
+	case "zdiff3":
+		opts.Style = git.ConflictZdiff3
+	}
+
+	for len(items) > 0 {
+		if seq != nil {
+			seq.Todo = items
+			util.Check(repo.WriteSequencer(seq))
+		}
+		cmd.pick(repo, items[0], opts)
+		items = items[1:]
+		if seq != nil {
+			_, seq.AbortSafety, err = repo.Head()
+			util.Check(err)
+		}
+	}
+	if seq != nil {
+		util.Check(repo.RemoveSequencer())
+	}
+}
+
+// pick applies (or reverts) the changes of a commit, and commits them unless
+// asked not to. It exits if there are conflicts.
+func (cmd *CherryPickCommand) pick(repo *git.Repo, item git.SequencerItem,
+	opts *git.PickOptions) {
+	branch, headHash, err := repo.Head()
+	util.Check(err)
+	headTree := ""
+	if headHash != "" {
+		headTree, err = repo.TreeResolve(headHash)
+		util.Check(err)
+	}
+
+	cmd.checkUnmerged(repo)
+	index, err := repo.ReadIndex()
+	util.Check(err)
+
+	// Without a commit, the changes are applied on top of the index, which
+	// doesn't need to match HEAD.
+	ours := headTree
+	if opts.NoCommit {
+		ours, err = repo.WriteTree(index.Files())
+		util.Check(err)
+	} else {
+		staged, err := repo.DiffTreeToIndex(headTree, index, nil)
+		util.Check(err)
+		if len(staged) > 0 {
+			util.Check(fmt.Errorf("error: your local changes would be overwritten by %s.\n"+
+				"hint: commit your changes or stash them to proceed.\n"+
+				"fatal: %s failed", cmd.Name(), cmd.Name()))
+		}
+	}
+
+	result, err := repo.PickCommit(ours, item, opts)
+	if err != nil {
+		util.Check(fmt.Errorf("%v\nfatal: %s failed", err, cmd.Name()))
+	}
+	err = repo.CheckoutTree(ours, result.Tree, &git.CheckoutOptions{Command: "merge"})
+	if _, ok := err.(*git.CheckoutError); ok {
+		err = fmt.Errorf("%v\nfatal: %s failed", err, cmd.Name())
+	}
+	util.Check(err)
+	util.Check(recordConflicts(repo, result))
+	for _, message := range result.Messages {
+		fmt.Println(message.Text)
+	}
+
+	msg, err := repo.PickMessage(item, opts)
+	util.Check(err)
+	if !result.Clean() {
+		cmd.stopAtConflict(repo, item, result, msg, opts)
+	}
+	if opts.NoCommit {
+		util.Check(writeStateFile(repo, "MERGE_MSG", msg))
+		return
+	}
+	cmd.commit(repo, item, branch, headHash, headTree, result.Tree, msg)
+}
+
+// stopAtConflict records the state of a commit which couldn't be picked
+// cleanly, so that the user can resolve the conflicts and continue.
+func (cmd *CherryPickCommand) stopAtConflict(repo *git.Repo, item git.SequencerItem,
+	result *git.MergeResult, msg string, opts *git.PickOptions) {
+	msg += "\n# Conflicts:\n"
+	for _, conflictPath := range result.ConflictedPaths() {
+		msg += "#\t" + conflictPath + "\n"
+	}
+	util.Check(writeStateFile(repo, "MERGE_MSG", msg))
+
+	line, err := commitLine(repo, item.Commit)
+	util.Check(err)
+	verb := "apply"
+	if item.Action == "revert" {
+		verb = "revert"
+	}
+	fmt.Printf("error: could not %s %s\n", verb, strings.Replace(line, " ", "... ", 1))
+
+	if opts.NoCommit {
+		fmt.Println("hint: after resolving the conflicts, mark the corrected paths\n" +
+			"hint: with 'git add <paths>' or 'git rm <paths>'")
+		os.Exit(1)
+	}
+	util.Check(writeStateFile(repo, pickHeadFile(item.Action), item.Commit+"\n"))
+	fmt.Printf("hint: After resolving the conflicts, mark them with\n"+
+		"hint: \"git add/rm <pathspec>\", then run\n"+
+		"hint: \"git %[1]s --continue\".\n"+
+		"hint: You can instead skip this commit with \"git %[1]s --skip\".\n"+
+		"hint: To abort and get back to the state before \"git %[1]s\",\n"+
+		"hint: run \"git %[1]s --abort\".\n", cmd.Name())
+	os.Exit(1)
+}
+
+// checkUnmerged fails if there are conflicted paths in the index.
+func (cmd *CherryPickCommand) checkUnmerged(repo *git.Repo) {
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	if !index.Unmerged() {
+		return
+	}
+
+	verb := "Cherry-picking"
+	if cmd.action == "revert" {
+		verb = "Reverting"
+	}
+	util.Check(fmt.Errorf("error: %s is not possible because you have unmerged "+
+		"files.\n"+
+		"hint: Fix them up in the work tree, and then use 'git add/rm <file>'\n"+
+		"hint: as appropriate to mark resolution and make a commit.\n"+
+		"fatal: %s failed", verb, cmd.Name()))
+}
+
+// commitResolved commits the index, once the user has resolved the conflicts
+// of a commit which couldn't be picked cleanly.
+func (cmd *CherryPickCommand) commitResolved(repo *git.Repo, commitHash string) {
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	if index.Unmerged() {
+		var b strings.Builder
+		b.WriteString("error: Committing is not possible because you have unmerged " +
+			"files.\n" +
+			"hint: Fix them up in the work tree, and then use 'git add/rm <file>'\n" +
+			"hint: as appropriate to mark resolution and make a commit.\n" +
+			"fatal: Exiting because of an unresolved conflict.")
+		for i, entry := range index.Entries {
+			if entry.Stage() != 0 && (i == 0 || index.Entries[i-1].Path != entry.Path) {
+				fmt.Fprintf(&b, "\nU\t%s", entry.Path)
+			}
+		}
+		util.Check(errors.New(b.String()))
+	}
+
+	branch, headHash, err := repo.Head()
+	util.Check(err)
+	headTree := ""
+	if headHash != "" {
+		headTree, err = repo.TreeResolve(headHash)
+		util.Check(err)
+	}
+	tree, err := repo.WriteTree(index.Files())
+	util.Check(err)
+
+	// The comments, such as the list of conflicts, are dropped from the
+	// message.
+	msgFile, err := repo.FilePath(false, "MERGE_MSG")
+	util.Check(err)
+	data, err := ioutil.ReadFile(msgFile)
+	util.Check(err)
+	msg := git.CleanupMessage(string(data))
+
+	action := "pick"
+	if stateFileExists(repo, "REVERT_HEAD") {
+		action = "revert"
+	}
+	item := git.SequencerItem{Action: action, Commit: commitHash}
+	cmd.commit(repo, item, branch, headHash, headTree, tree, msg)
+}
+
+// commit makes a commit for a picked (or reverted) commit, and shows its
+// summary. A pick keeps the author of the commit.
+func (cmd *CherryPickCommand) commit(repo *git.Repo, item git.SequencerItem,
+	branch, headHash, headTree, tree, msg string) {
+	if tree == headTree {
+		util.Check(writeStateFile(repo, "MERGE_MSG", msg))
+		util.Check(writeStateFile(repo, pickHeadFile(item.Action), item.Commit+"\n"))
+		util.Check(fmt.Errorf("The previous %s is now empty, possibly due to "+
+			"conflict resolution.\n"+
+			"If you wish to commit it anyway, use:\n\n"+
+			"    git commit --allow-empty\n\n"+
+			"Otherwise, please use 'git %s --skip'", cmd.Name(), cmd.Name()))
+	}
+
+	author := ""
+	if item.Action == "pick" {
+		obj, err := repo.ObjectParse(item.Commit)
+		util.Check(err)
+		picked, err := git.NewCommit(repo, obj)
+		util.Check(err)
+		author = picked.Entries["author"][0]
+	}
+
+	parents := []string{}
+	if headHash != "" {
+		parents = append(parents, headHash)
+	}
+	commit, err := git.NewCommitWithAuthor(repo, tree, parents, author, msg)
+	util.Check(err)
+	commitHash, err := repo.ObjectWrite(commit.Object, true)
+	util.Check(err)
+	util.Check(repo.UpdateRef("HEAD", commitHash))
+	util.Check(repo.ClearMergeState())
+
+	printCommitSummary(repo, branch, commit, commitHash, headTree)
+}
+
+// printCommitSummary shows the branch, the hash and the subject of a new
+// commit, along with its author and date, and the diffstat of its changes.
+// Example: "[master 1e2f3a4] Fix the tests"
+func printCommitSummary(repo *git.Repo, branch string, commit *git.Commit, commitHash,
+	parentTree string) {
+	name := "detached HEAD"
+	if branch != "" {
+		name = shortRef(branch)
+	}
+	if len(commit.Parents()) == 0 {
+		name += " (root-commit)"
+	}
+	fmt.Printf("[%s %s] %s\n", name, commitHash[:7], commit.Subject())
+
+	author, date := commit.Identity("author")
+	if committer, _ := commit.Identity("committer"); author != committer {
+		fmt.Printf(" Author: %s\n", author)
+	}
+	fmt.Printf(" Date: %s\n", date)
+
+	opts := &git.DiffOptions{DetectRenames: true, RenameLimit: git.DefaultRenameLimit}
+	entries, err := repo.DiffTrees(parentTree, commit.TreeHash(), opts)
+	util.Check(err)
+	stats, err := repo.DiffStats(entries)
+	util.Check(err)
+	fmt.Print(git.FormatShortstat(stats))
+	fmt.Print(git.FormatSummary(entries))
+}
+
+// pickAction returns the action of the cherry-pick or the revert in progress,
+// which is "pick" or "revert", or empty if there is none.
+func pickAction(repo *git.Repo, seq *git.Sequencer) string {
+	switch {
+	case stateFileExists(repo, "REVERT_HEAD"):
+		return "revert"
+	case stateFileExists(repo, "CHERRY_PICK_HEAD"):
+		return "pick"
+	case seq != nil && len(seq.Todo) > 0:
+		return seq.Todo[0].Action
+	case seq != nil:
+		return "pick"
+	}
+	return ""
+}
+
+// pickHead returns the commit of a cherry-pick or a revert which stopped at a
+// conflict, if any.
+func pickHead(repo *git.Repo) string {
+	for _, name := range []string{"CHERRY_PICK_HEAD", "REVERT_HEAD"} {
+		stateFile, err := repo.FilePath(false, name)
+		util.Check(err)
+		if data, err := ioutil.ReadFile(stateFile); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// pickHeadFile returns the state file which records the commit of a
+// cherry-pick or a revert stopped at a conflict.
+func pickHeadFile(action string) string {
+	if action == "revert" {
+		return "REVERT_HEAD"
+	}
+	return "CHERRY_PICK_HEAD"
+}
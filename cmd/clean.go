@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// countFlag is a boolean flag which counts the number of times it is given,
+// such as "-f -f".
+type countFlag int
+
+// IsBoolFlag allows the flag to be given without any value.
+func (f *countFlag) IsBoolFlag() bool {
+	return true
+}
+
+// String returns the number of times the flag is given.
+func (f *countFlag) String() string {
+	if f == nil {
+		return "0"
+	}
+	return fmt.Sprint(int(*f))
+}
+
+// Set counts the flag once more, or resets the count if it is turned off.
+func (f *countFlag) Set(value string) error {
+	if value == "false" {
+		*f = 0
+	} else {
+		*f++
+	}
+	return nil
+}
+
+// CleanCommand lists the components of "clean" comamnd.
+type CleanCommand struct {
+	fs          *flag.FlagSet
+	dryRun      bool
+	force       countFlag
+	forceTwice  bool
+	directories bool
+	ignored     bool
+	onlyIgnored bool
+	quiet       bool
+	paths       []string
+}
+
+// NewCleanCommand creates a new command object.
+func NewCleanCommand() *CleanCommand {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	cmd := CleanCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.dryRun, "n", false, "Only show what would be removed")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "Only show what would be removed")
+	fs.Var(&cmd.force, "f", "Remove the files (twice to remove the nested repositories)")
+	fs.Var(&cmd.force, "force", "Remove the files (twice to remove the nested repositories)")
+	fs.BoolVar(&cmd.forceTwice, "ff", false, "Same as \"-f -f\"")
+	fs.BoolVar(&cmd.directories, "d", false, "Remove the untracked directories too")
+	fs.BoolVar(&cmd.ignored, "x", false, "Remove the ignored files too")
+	fs.BoolVar(&cmd.onlyIgnored, "X", false, "Remove only the ignored files")
+	fs.BoolVar(&cmd.quiet, "q", false, "Don't show the files which are removed")
+	fs.BoolVar(&cmd.quiet, "quiet", false, "Don't show the files which are removed")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *CleanCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *CleanCommand) Description() string {
+	return "Remove untracked files from the working tree"
+}
+
+// Init initializes and validates the given command.
+func (cmd *CleanCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd.paths = cmd.fs.Args()
+	if cmd.forceTwice {
+		cmd.force += 2
+	}
+	if cmd.ignored && cmd.onlyIgnored {
+		return errors.New("fatal: -x and -X cannot be used together")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *CleanCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [-q] [-n] [-f | -ff] [-d] [-x | -X] [--] <pathspec>...\n",
+		cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *CleanCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	// Like "git", nothing is removed by mistake unless told otherwise.
+	if !cmd.dryRun && cmd.force == 0 {
+		config, err := repo.Config()
+		util.Check(err)
+		if config.GetBool("clean.requireForce", true) {
+			how := "defaults to"
+			if _, ok := config.Get("clean.requireForce"); ok {
+				how = "set to"
+			}
+			util.Check(fmt.Errorf("fatal: clean.requireForce %s true and neither -i, "+
+				"-n, nor -f given; refusing to clean", how))
+		}
+	}
+
+	// The paths given on the command line select the directories as well.
+	// Without them, only the current directory is cleaned.
+	prefix := cwdPrefix(repo)
+	opts := &git.CleanOptions{
+		Directories:  cmd.directories || len(cmd.paths) > 0,
+		Ignored:      cmd.ignored,
+		OnlyIgnored:  cmd.onlyIgnored,
+		Repositories: cmd.force >= 2,
+	}
+	if len(cmd.paths) > 0 {
+		paths := []string{}
+		for _, arg := range cmd.paths {
+			filePath, err := repoPath(repo, arg)
+			util.Check(err)
+			paths = append(paths, filePath)
+		}
+		opts.Pathspec = git.NewPathspec(paths)
+	} else if prefix != "" {
+		opts.Pathspec = git.NewPathspec([]string{prefix})
+	}
+
+	paths, err := repo.CleanPaths(opts)
+	util.Check(err)
+	for _, filePath := range paths {
+		if cmd.dryRun {
+			fmt.Printf("Would remove %s\n", relativePath(filePath, prefix))
+			continue
+		}
+		util.Check(repo.CleanPath(filePath))
+		if !cmd.quiet {
+			fmt.Printf("Removing %s\n", relativePath(filePath, prefix))
+		}
+	}
+}
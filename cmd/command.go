@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 // Subcommand is an interface that all subcommands must implement.
@@ -17,10 +18,73 @@ type Subcommand interface {
 	Execute()
 }
 
+// dirsFlag is a flag which can be given multiple times, such as "-C".
+type dirsFlag []string
+
+// String returns the directories given so far.
+func (f *dirsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+// Set adds one more directory.
+func (f *dirsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// globalFlags are the options given before the subcommand, which choose the
+// repository to work on.
+type globalFlags struct {
+	dirs     dirsFlag
+	gitDir   string
+	workTree string
+}
+
+// register adds the global options to a flag set.
+func (f *globalFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.dirs, "C", "Run as if started in the given path (can be repeated)")
+	fs.StringVar(&f.gitDir, "git-dir", "", "Set the path to the repository "+
+		"(same as GIT_DIR)")
+	fs.StringVar(&f.workTree, "work-tree", "", "Set the path to the working tree "+
+		"(same as GIT_WORK_TREE)")
+}
+
+// apply changes the current directory as per "-C", and passes the paths of
+// the repository and the work-tree to git.GetRepo by their environment
+// variables, like "git" does. The paths are relative to the directory after
+// the changes.
+func (f *globalFlags) apply() error {
+	for _, dir := range f.dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.Chdir(dir); err != nil {
+			// Example: "No such file or directory"
+			reason := err.Error()
+			if pathErr, ok := err.(*os.PathError); ok {
+				reason = pathErr.Err.Error()
+			}
+			return fmt.Errorf("fatal: cannot change to '%s': %s%s", dir,
+				strings.ToUpper(reason[:1]), reason[1:])
+		}
+	}
+	if f.gitDir != "" {
+		os.Setenv("GIT_DIR", f.gitDir)
+	}
+	if f.workTree != "" {
+		os.Setenv("GIT_WORK_TREE", f.workTree)
+	}
+	return nil
+}
+
 // Execute parses CLI arguments and executes the given subcommand.
 func Execute() {
 	progName := os.Args[0]
-	args := os.Args[1:]
+	global := globalFlags{}
+	global.register(flag.CommandLine)
 
 	// Create an object for each subcommand.
 	cmds := []Subcommand{
@@ -29,7 +93,28 @@ func Execute() {
 		NewCatFileCommand(),
 		NewLsTreeCommand(),
 		NewMkTreeCommand(),
+		NewDiffTreeCommand(),
+		NewDiffCommand(),
+		NewShowCommand(),
+		NewAddCommand(),
+		NewMvCommand(),
+		NewRmCommand(),
+		NewStatusCommand(),
+		NewCheckIgnoreCommand(),
 		NewCheckoutCommand(),
+		NewSwitchCommand(),
+		NewRestoreCommand(),
+		NewMergeFileCommand(),
+		NewMergeCommand(),
+		NewMergeBaseCommand(),
+		NewMergeTreeCommand(),
+		NewCherryPickCommand(),
+		NewRevertCommand(),
+		NewRebaseCommand(),
+		NewStashCommand(),
+		NewCleanCommand(),
+		NewWorktreeCommand(),
+		NewResetCommand(),
 		NewCommitTreeCommand(),
 		NewLogCommand(),
 		NewShowRefCommand(),
@@ -40,7 +125,8 @@ func Execute() {
 	// Prepare the global usage message.
 	flag.Usage = func() {
 		fmt.Printf("gogit - the stupid content tracker\n\n")
-		fmt.Printf("usage: %s <command> [<args>]\n", progName)
+		fmt.Printf("usage: %s [-C <path>] [--git-dir=<path>] [--work-tree=<path>] "+
+			"<command> [<args>]\n", progName)
 		fmt.Println("Valid commands:")
 
 		for _, cmd := range cmds {
@@ -52,10 +138,15 @@ func Execute() {
 	}
 
 	flag.Parse()
+	args := flag.Args()
 	if len(args) < 1 {
 		flag.Usage()
 		return
 	}
+	if err := global.apply(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	subcommand := args[0]
 	for _, cmd := range cmds {
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// DiffCommand lists the components of "diff" comamnd.
+type DiffCommand struct {
+	fs        *flag.FlagSet
+	diffFlags diffFlags
+	cached    bool
+	revisions []string
+}
+
+// NewDiffCommand creates a new command object.
+func NewDiffCommand() *DiffCommand {
+	cmd := &DiffCommand{
+		fs:        flag.NewFlagSet("diff", flag.ExitOnError),
+		diffFlags: diffFlags{defaultRenames: true},
+	}
+
+	cmd.diffFlags.register(cmd.fs)
+	cmd.fs.BoolVar(&cmd.cached, "cached", false,
+		"Compare the index with the given commit (or HEAD)")
+	cmd.fs.BoolVar(&cmd.cached, "staged", false, "Synonym of --cached")
+	return cmd
+}
+
+// Name gives the name of the command.
+func (cmd *DiffCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *DiffCommand) Description() string {
+	return "Show changes between commits, commit and working tree, etc"
+}
+
+// Init initializes and validates the given command.
+func (cmd *DiffCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(expandScoreArgs(args)); err != nil {
+		return err
+	}
+
+	if cmd.fs.NArg() > 2 {
+		return errors.New("error: At most two <commit> arguments are supported")
+	}
+	if cmd.cached && cmd.fs.NArg() > 1 {
+		return errors.New("error: At most one <commit> is supported with --cached")
+	}
+
+	cmd.revisions = cmd.fs.Args()
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *DiffCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] [--cached] [<commit> [<commit>]]\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *DiffCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	var entries []git.DiffEntry
+	switch {
+	case len(cmd.revisions) == 2:
+		oldTree, err := repo.TreeResolve(cmd.revisions[0])
+		util.Check(err)
+		newTree, err := repo.TreeResolve(cmd.revisions[1])
+		util.Check(err)
+
+		entries, err = repo.DiffTrees(oldTree, newTree, cmd.diffFlags.options(repo))
+		util.Check(err)
+	case cmd.cached:
+		// Compare the given commit (or HEAD) with the index.
+		treeHash := ""
+		if len(cmd.revisions) == 1 {
+			treeHash, err = repo.TreeResolve(cmd.revisions[0])
+			util.Check(err)
+		} else if _, headHash, err := repo.Head(); err == nil && headHash != "" {
+			treeHash, err = repo.TreeResolve(headHash)
+			util.Check(err)
+		}
+		index, err := repo.ReadIndex()
+		util.Check(err)
+
+		entries, err = repo.DiffTreeToIndex(treeHash, index, cmd.diffFlags.options(repo))
+		util.Check(err)
+	case len(cmd.revisions) == 1:
+		// Compare the given commit with the work-tree.
+		util.Check(repo.RequireWorkTree())
+		treeHash, err := repo.TreeResolve(cmd.revisions[0])
+		util.Check(err)
+		index, err := repo.ReadIndex()
+		util.Check(err)
+
+		entries, err = repo.DiffTreeToWorktree(treeHash, index, cmd.diffFlags.options(repo))
+		util.Check(err)
+	default:
+		// Compare the index with the work-tree.
+		util.Check(repo.RequireWorkTree())
+		index, err := repo.ReadIndex()
+		util.Check(err)
+
+		var refreshed bool
+		entries, refreshed, err = repo.DiffIndexToWorktree(index)
+		util.Check(err)
+		if refreshed {
+			repo.WriteIndex(index)
+		}
+	}
+
+	output, err := cmd.diffFlags.format(repo, entries, "patch")
+	util.Check(err)
+	fmt.Print(output)
+}
@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+)
+
+// scoreFlag is a boolean flag which optionally takes a similarity score, such
+// as "-M" or "-M=60%".
+type scoreFlag struct {
+	set   bool
+	score int
+}
+
+// IsBoolFlag allows the flag to be given without any value.
+func (f *scoreFlag) IsBoolFlag() bool {
+	return true
+}
+
+// String returns the similarity score of the flag as a percentage.
+func (f *scoreFlag) String() string {
+	if f == nil || !f.set {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", f.score)
+}
+
+// Set parses the value of the flag. Like "git", a value ending with '%' is a
+// percentage, and the other values are the digits of a fraction ("5" is 50%).
+func (f *scoreFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.set = true
+		return nil
+	case "false":
+		f.set = false
+		return nil
+	}
+
+	score := 0
+	if strings.HasSuffix(value, "%") {
+		num, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || num < 0 || num > 100 {
+			return fmt.Errorf("invalid similarity score %q", value)
+		}
+		score = num
+	} else {
+		num, err := strconv.Atoi(value)
+		if err != nil || num < 0 || len(value) > 9 {
+			return fmt.Errorf("invalid similarity score %q", value)
+		}
+		score = num * 100
+		for range value {
+			score /= 10
+		}
+	}
+
+	f.set = true
+	f.score = score
+	return nil
+}
+
+// optionalFlag is a boolean flag which optionally takes a value, such as
+// "--stat" or "--stat=100".
+type optionalFlag struct {
+	set   bool
+	value string
+}
+
+// IsBoolFlag allows the flag to be given without any value.
+func (f *optionalFlag) IsBoolFlag() bool {
+	return true
+}
+
+// String returns the value given to the flag.
+func (f *optionalFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+// Set records that the flag is given, along with its value if any.
+func (f *optionalFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.set, f.value = true, ""
+	case "false":
+		f.set, f.value = false, ""
+	default:
+		f.set, f.value = true, value
+	}
+	return nil
+}
+
+// diffFlags holds the options shared by all the commands which show diffs.
+type diffFlags struct {
+	// defaultRenames enables rename detection as per "diff.renames" even if
+	// it is not asked for. It is set by the commands meant for the end user.
+	defaultRenames bool
+
+	renames      scoreFlag
+	copies       scoreFlag
+	copiesHarder bool
+	noRenames    bool
+	nameOnly     bool
+	nameStatus   bool
+	raw          bool
+	patch        bool
+	stat         optionalFlag
+	numstat      bool
+	shortstat    bool
+	dirstat      optionalFlag
+}
+
+// register adds the diff options to the flags of a command.
+func (f *diffFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.renames, "M",
+		"Detect renames, optionally with a minimum similarity (-M=<n>%)")
+	fs.Var(&f.copies, "C",
+		"Detect copies as well as renames (-C=<n>%)")
+	fs.BoolVar(&f.copiesHarder, "find-copies-harder", false,
+		"Use unmodified files as the source of copies too")
+	fs.BoolVar(&f.noRenames, "no-renames", false,
+		"Turn off rename detection, even if it is enabled by default")
+	fs.BoolVar(&f.nameOnly, "name-only", false,
+		"Show only the names of changed files")
+	fs.BoolVar(&f.nameStatus, "name-status", false,
+		"Show only the names and the status of changed files")
+	fs.BoolVar(&f.raw, "raw", false, "Show the changes in the raw format")
+	fs.BoolVar(&f.patch, "p", false, "Generate a patch")
+	fs.Var(&f.stat, "stat",
+		"Generate a diffstat, optionally limited to a width (--stat=<width>)")
+	fs.BoolVar(&f.numstat, "numstat", false,
+		"Show the added and deleted line counts in a machine readable format")
+	fs.BoolVar(&f.shortstat, "shortstat", false,
+		"Show only the summary line of a diffstat")
+	fs.Var(&f.dirstat, "dirstat",
+		"Show the distribution of changes per directory (--dirstat=files,10,...)")
+}
+
+// options converts the given flags to the options understood by the diff
+// APIs of the repo.
+func (f *diffFlags) options(repo *git.Repo) *git.DiffOptions {
+	opts := &git.DiffOptions{
+		DetectRenames:    f.renames.set || f.copies.set || f.copiesHarder,
+		DetectCopies:     f.copies.set || f.copiesHarder,
+		FindCopiesHarder: f.copiesHarder,
+		RenameScore:      git.DefaultRenameScore,
+		RenameLimit:      git.DefaultRenameLimit,
+	}
+
+	if f.renames.set && f.renames.score > 0 {
+		opts.RenameScore = f.renames.score
+	}
+	if f.copies.set && f.copies.score > 0 {
+		opts.RenameScore = f.copies.score
+	}
+
+	if config, err := repo.Config(); err == nil {
+		opts.RenameLimit = config.GetInt("diff.renameLimit", opts.RenameLimit)
+
+		// "diff.renames" can be a boolean or "copies".
+		if f.defaultRenames {
+			value, _ := config.Get("diff.renames")
+			if value == "copies" || value == "copy" {
+				opts.DetectRenames, opts.DetectCopies = true, true
+			} else if config.GetBool("diff.renames", true) {
+				opts.DetectRenames = true
+			}
+		}
+	} else if f.defaultRenames {
+		opts.DetectRenames = true
+	}
+
+	if f.noRenames {
+		opts.DetectRenames, opts.DetectCopies = false, false
+		opts.FindCopiesHarder = false
+	}
+	return opts
+}
+
+// hasFormat tells if any output format is asked for explicitly.
+func (f *diffFlags) hasFormat() bool {
+	return f.nameOnly || f.nameStatus || f.raw || f.patch || f.stat.set ||
+		f.numstat || f.shortstat || f.dirstat.set
+}
+
+// format returns the changes in all the requested output formats, or in
+// 'defaultFormat' ("raw", "patch" or none) if nothing is requested.
+func (f *diffFlags) format(repo *git.Repo, entries []git.DiffEntry,
+	defaultFormat string) (string, error) {
+	var b strings.Builder
+
+	// The name formats replace all the other formats.
+	if f.nameOnly || f.nameStatus {
+		for _, entry := range entries {
+			if f.nameOnly {
+				fmt.Fprintln(&b, entry.Path())
+			} else {
+				fmt.Fprintln(&b, entry.NameStatus())
+			}
+		}
+		return b.String(), nil
+	}
+
+	raw, patch := f.raw, f.patch
+	if !f.hasFormat() {
+		raw, patch = defaultFormat == "raw", defaultFormat == "patch"
+	}
+	if raw {
+		for _, entry := range entries {
+			fmt.Fprintln(&b, entry.Raw())
+		}
+	}
+
+	if f.stat.set || f.numstat || f.shortstat {
+		stats, err := repo.DiffStats(entries)
+		if err != nil {
+			return "", err
+		}
+
+		if f.numstat {
+			b.WriteString(git.FormatNumstat(stats))
+		}
+		if f.stat.set {
+			width, err := statWidth(f.stat.value)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(git.FormatStat(stats, width))
+		}
+		if f.shortstat && len(entries) > 0 {
+			b.WriteString(git.FormatShortstat(stats))
+		}
+	}
+
+	// Like "git", the directories are shown after the other summaries.
+	if f.dirstat.set {
+		opts, err := dirstatOptions(f.dirstat.value)
+		if err != nil {
+			return "", err
+		}
+		dirstat, err := repo.Dirstat(entries, opts)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(dirstat)
+	}
+
+	if patch {
+		if b.Len() > 0 && len(entries) > 0 {
+			fmt.Fprintln(&b)
+		}
+		for _, entry := range entries {
+			patch, err := repo.Patch(entry, git.DefaultContext)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(patch)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// statWidth returns the width given to "--stat", or the width of the
+// terminal as given by the COLUMNS environment variable, or 80.
+func statWidth(value string) (int, error) {
+	if value == "" {
+		if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+			return width, nil
+		}
+		return 80, nil
+	}
+
+	width, err := strconv.Atoi(value)
+	if err != nil || width <= 0 {
+		return 0, fmt.Errorf("error: invalid --stat width %q", value)
+	}
+	return width, nil
+}
+
+// dirstatOptions parses the comma separated parameters given to "--dirstat",
+// such as "files,10" or "lines,cumulative". A number is the minimum
+// percentage of the changes for a directory, with a single decimal digit.
+func dirstatOptions(value string) (*git.DirstatOptions, error) {
+	opts := &git.DirstatOptions{Permille: git.DefaultDirstatPermille}
+	if value == "" {
+		return opts, nil
+	}
+
+	errs := ""
+	for _, param := range strings.Split(value, ",") {
+		switch {
+		case param == "changes":
+			opts.ByLine, opts.ByFile = false, false
+		case param == "lines":
+			opts.ByLine, opts.ByFile = true, false
+		case param == "files":
+			opts.ByLine, opts.ByFile = false, true
+		case param == "noncumulative":
+			opts.Cumulative = false
+		case param == "cumulative":
+			opts.Cumulative = true
+		case param != "" && param[0] >= '0' && param[0] <= '9':
+			match := dirstatPercentRegex.FindStringSubmatch(param)
+			if match == nil {
+				errs += fmt.Sprintf("  Failed to parse dirstat cut-off percentage '%s'\n", param)
+				continue
+			}
+			percent, _ := strconv.Atoi(match[1])
+			opts.Permille = percent * 10
+			if match[2] != "" {
+				opts.Permille += int(match[2][0] - '0')
+			}
+		default:
+			errs += fmt.Sprintf("  Unknown dirstat parameter '%s'\n", param)
+		}
+	}
+
+	if errs != "" {
+		return nil, fmt.Errorf("fatal: Failed to parse --dirstat/-X option parameter:\n%s", errs)
+	}
+	return opts, nil
+}
+
+// dirstatPercentRegex matches a percentage given to "--dirstat". Only the
+// first decimal digit is used.
+var dirstatPercentRegex = regexp.MustCompile(`^([0-9]+)(?:\.([0-9]*))?$`)
+
+// scoreArgRegex matches the "-M50%" style of arguments.
+var scoreArgRegex = regexp.MustCompile(`^(--?[MC])([0-9]+%?)$`)
+
+// expandScoreArgs rewrites the "git" style "-M50%" arguments as "-M=50%" so
+// that they can be parsed by the flag package.
+func expandScoreArgs(args []string) []string {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			copy(expanded[i:], args[i:])
+			break
+		}
+		expanded[i] = scoreArgRegex.ReplaceAllString(arg, "$1=$2")
+	}
+
+	return expanded
+}
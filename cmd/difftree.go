@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// DiffTreeCommand lists the components of "diff-tree" comamnd.
+type DiffTreeCommand struct {
+	fs        *flag.FlagSet
+	diffFlags diffFlags
+	root      bool
+	revisions []string
+}
+
+// NewDiffTreeCommand creates a new command object.
+func NewDiffTreeCommand() *DiffTreeCommand {
+	cmd := &DiffTreeCommand{
+		fs: flag.NewFlagSet("diff-tree", flag.ExitOnError),
+	}
+
+	cmd.fs.BoolVar(&cmd.root, "root", false,
+		"Show a root commit as a big creation event")
+	cmd.diffFlags.register(cmd.fs)
+	return cmd
+}
+
+// Name gives the name of the command.
+func (cmd *DiffTreeCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *DiffTreeCommand) Description() string {
+	return "Compares the content and mode of blobs found via two tree objects"
+}
+
+// Init initializes and validates the given command.
+func (cmd *DiffTreeCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(expandScoreArgs(args)); err != nil {
+		return err
+	}
+
+	if cmd.fs.NArg() < 1 || cmd.fs.NArg() > 2 {
+		return errors.New("error: One or two <tree-ish> arguments are needed")
+	}
+
+	cmd.revisions = cmd.fs.Args()
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *DiffTreeCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] <tree-ish> [<tree-ish>]\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *DiffTreeCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	var oldTree, newTree string
+	if len(cmd.revisions) == 2 {
+		oldTree, err = repo.TreeResolve(cmd.revisions[0])
+		util.Check(err)
+		newTree, err = repo.TreeResolve(cmd.revisions[1])
+		util.Check(err)
+	} else {
+		// A single commit is compared with its first parent.
+		commitHash, err := repo.UniqueNameResolve(cmd.revisions[0])
+		util.Check(err)
+		obj, err := repo.ObjectParse(commitHash)
+		util.Check(err)
+		if obj.ObjType != "commit" {
+			util.Check(fmt.Errorf("fatal: not a commit object (%s)", commitHash))
+		}
+
+		commit, err := git.NewCommit(repo, obj)
+		util.Check(err)
+		parents := commit.Parents()
+		if len(parents) == 0 && !cmd.root {
+			return
+		}
+		if len(parents) > 0 {
+			oldTree, err = repo.TreeResolve(parents[0])
+			util.Check(err)
+		}
+		newTree = commit.TreeHash()
+		fmt.Println(commitHash)
+	}
+
+	entries, err := repo.DiffTrees(oldTree, newTree, cmd.diffFlags.options(repo))
+	util.Check(err)
+
+	output, err := cmd.diffFlags.format(repo, entries, "raw")
+	util.Check(err)
+	fmt.Print(output)
+}
@@ -65,10 +65,7 @@ func (cmd *HashObjectCommand) Execute() {
 	repo, err := git.GetRepo(".")
 	util.Check(err)
 
-	blob, err := git.NewBlobFromFile(repo, cmd.file)
-	util.Check(err)
-
-	sha1, err := repo.ObjectWrite(blob.Object, cmd.write)
+	sha1, err := git.WriteBlobFromFile(repo, cmd.file, cmd.write)
 	util.Check(err)
 
 	fmt.Println(sha1)
@@ -10,8 +10,11 @@ import (
 
 // InitCommand lists the components of "init" comamnd.
 type InitCommand struct {
-	fs   *flag.FlagSet
-	path string
+	fs       *flag.FlagSet
+	path     string
+	bare     bool
+	branch   string
+	template string
 }
 
 // NewInitCommand creates a new command object.
@@ -21,6 +24,10 @@ func NewInitCommand() *InitCommand {
 	}
 
 	cmd.fs.StringVar(&cmd.path, "path", ".", "Path to create the repository")
+	cmd.fs.BoolVar(&cmd.bare, "bare", false, "Create a bare repository, without a work-tree")
+	cmd.fs.StringVar(&cmd.branch, "b", "", "Name of the initial branch")
+	cmd.fs.StringVar(&cmd.branch, "initial-branch", "", "Name of the initial branch")
+	cmd.fs.StringVar(&cmd.template, "template", "", "Directory to copy the hooks and other files from")
 	return cmd
 }
 
@@ -43,14 +50,27 @@ func (cmd *InitCommand) Init(args []string) error {
 // Usage prints the usage string for the end user.
 func (cmd *InitCommand) Usage() {
 	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
-	fmt.Printf("usage: %s [<args>]\n", cmd.Name())
+	fmt.Printf("usage: %s [--bare] [--template=<dir>] [-b <branch-name>] [-path <dir>]\n",
+		cmd.Name())
 	cmd.fs.PrintDefaults()
 }
 
 // Execute runs the given command till completion.
 func (cmd *InitCommand) Execute() {
-	repo, err := git.NewRepo(cmd.path)
+	opts := &git.InitOptions{
+		Bare:          cmd.bare,
+		InitialBranch: cmd.branch,
+		Template:      cmd.template,
+	}
+	repo, reinit, err := git.InitRepo(cmd.path, opts)
 	util.Check(err)
 
+	if reinit {
+		if cmd.branch != "" {
+			fmt.Printf("warning: re-init: ignored --initial-branch=%s\n", cmd.branch)
+		}
+		fmt.Printf("Reinitialized existing Git repository in %s/\n", repo.GitDir)
+		return
+	}
 	fmt.Printf("Initialized empty Git repository in %s/\n", repo.GitDir)
 }
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ssrathi/gogit/git"
 	"github.com/ssrathi/gogit/util"
@@ -11,18 +14,25 @@ import (
 
 // LogCommand lists the components of "log" comamnd.
 type LogCommand struct {
-	fs       *flag.FlagSet
-	limit    uint
-	revision string
+	fs        *flag.FlagSet
+	diffFlags diffFlags
+	limit     uint
+	follow    bool
+	revision  string
+	path      string
 }
 
 // NewLogCommand creates a new command object.
 func NewLogCommand() *LogCommand {
 	cmd := &LogCommand{
-		fs: flag.NewFlagSet("log", flag.ExitOnError),
+		fs:        flag.NewFlagSet("log", flag.ExitOnError),
+		diffFlags: diffFlags{defaultRenames: true},
 	}
 
 	cmd.fs.UintVar(&cmd.limit, "n", 0, "Limit the number of commits to output")
+	cmd.fs.BoolVar(&cmd.follow, "follow", false,
+		"Continue listing the history of a file beyond renames")
+	cmd.diffFlags.register(cmd.fs)
 	return cmd
 }
 
@@ -34,14 +44,32 @@ func (cmd *LogCommand) Name() string {
 // Init initializes and validates the given command.
 func (cmd *LogCommand) Init(args []string) error {
 	cmd.fs.Usage = cmd.Usage
-	if err := cmd.fs.Parse(args); err != nil {
+	if err := cmd.fs.Parse(expandScoreArgs(args)); err != nil {
 		return err
 	}
 
-	if cmd.fs.NArg() < 1 {
-		cmd.revision = "HEAD"
-	} else {
-		cmd.revision = cmd.fs.Arg(0)
+	// Arguments after a "--" are always paths.
+	rest := cmd.fs.Args()
+	dashed := len(args) > len(rest) && args[len(args)-len(rest)-1] == "--"
+
+	cmd.revision = "HEAD"
+	switch {
+	case len(rest) > 2 || (dashed && len(rest) > 1):
+		return errors.New("error: Only one <revision> and one <path> are supported")
+	case len(rest) == 2:
+		cmd.revision, cmd.path = rest[0], rest[1]
+	case len(rest) == 1 && (dashed || cmd.follow):
+		cmd.path = rest[0]
+	case len(rest) == 1:
+		cmd.revision = rest[0]
+	}
+
+	if cmd.follow && cmd.path == "" {
+		return errors.New("fatal: --follow requires exactly one pathspec")
+	}
+	cmd.path = strings.Trim(filepath.ToSlash(filepath.Clean(cmd.path)), "/")
+	if cmd.path == "." {
+		cmd.path = ""
 	}
 
 	return nil
@@ -55,7 +83,7 @@ func (cmd *LogCommand) Description() string {
 // Usage prints the usage string for the end user.
 func (cmd *LogCommand) Usage() {
 	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
-	fmt.Printf("usage: %s [<args>] [<revision>]\n", cmd.Name())
+	fmt.Printf("usage: %s [<args>] [<revision>] [[--] <path>]\n", cmd.Name())
 	cmd.fs.PrintDefaults()
 }
 
@@ -67,46 +95,140 @@ func (cmd *LogCommand) Execute() {
 	// Resolve the given revision to a full hash.
 	commitHash, err := repo.UniqueNameResolve(cmd.revision)
 	util.Check(err)
+	// An annotated tag is replaced by the commit it points to.
+	_, commitHash, err = repo.PeelObject(commitHash)
+	util.Check(err)
+
+	// Renames are followed only if asked for.
+	opts := cmd.diffFlags.options(repo)
+	followOpts := *opts
+	followOpts.DetectRenames = cmd.follow
 
 	var printed uint
-	for {
+	path := cmd.path
+	for commitHash != "" {
 		obj, err := repo.ObjectParse(commitHash)
 		if err != nil || obj.ObjType != "commit" {
 			fmt.Printf("fatal: not a commit object (%s)\n", commitHash)
 			os.Exit(1)
 		}
-
-		// Print this commit now.
 		commit, err := git.NewCommit(repo, obj)
 		util.Check(err)
-		commitStr, err := commit.PrettyPrint()
-		util.Check(err)
+
+		// Currently, "git log" only follows the first parent. In real "git",
+		// there can be more than one parent in "merge" scenarios.
+		parentHash := ""
+		if parents := commit.Parents(); len(parents) > 0 {
+			parentHash = parents[0]
+		}
+		commitHash = parentHash
+
+		// Skip the commits which don't change the given path. With --follow,
+		// keep following the path by its old name if it was renamed.
+		if path != "" {
+			var changed bool
+			changed, path, err = pathChange(repo, commit, parentHash, path, &followOpts)
+			util.Check(err)
+			if !changed {
+				continue
+			}
+		}
+
+		// Put a new line between two successive commits.
+		if printed > 0 {
+			fmt.Println()
+		}
 
 		// Print the commit msg now. If it doesn't end with a newline, then
 		// add one manually.
+		commitStr, err := commit.PrettyPrint()
+		util.Check(err)
 		fmt.Printf(commitStr)
 		if commitStr[len(commitStr)-1] != byte('\n') {
 			fmt.Println()
 		}
 
+		// Show the changes done by the commit if asked for. Like "git", the
+		// changes of merge commits are not shown.
+		if cmd.diffFlags.hasFormat() && len(commit.Parents()) < 2 {
+			output, err := commitDiff(repo, commit, parentHash, &cmd.diffFlags, opts, "")
+			util.Check(err)
+			if output != "" {
+				fmt.Println()
+				fmt.Print(output)
+			}
+		}
+
 		// See if the user specified limit is reached.
 		printed++
 		if cmd.limit > 0 && printed == cmd.limit {
 			break
 		}
+	}
+}
 
-		// Find the parent list of this commit.
-		parents := commit.Parents()
-		// If there are no more parents (base commit), then stop.
-		if len(parents) == 0 {
-			break
+// commitDiff returns the changes done by a commit when compared with its
+// parent, in the output formats given by 'flags' (or 'defaultFormat').
+func commitDiff(repo *git.Repo, commit *git.Commit, parentHash string,
+	flags *diffFlags, opts *git.DiffOptions, defaultFormat string) (string, error) {
+	parentTree := ""
+	if parentHash != "" {
+		var err error
+		if parentTree, err = repo.TreeResolve(parentHash); err != nil {
+			return "", err
 		}
+	}
 
-		// Currently, "git log" only supports a single parent. In real "git",
-		// there can be more than one parent in "merge" scenarios.
-		commitHash = parents[0]
+	entries, err := repo.DiffTrees(parentTree, commit.TreeHash(), opts)
+	if err != nil {
+		return "", err
+	}
+	return flags.format(repo, entries, defaultFormat)
+}
 
-		// Put a new line between two successive commits.
-		fmt.Println()
+// pathChange checks if a commit changes the given path (a file or a
+// directory) when compared with its parent. If rename detection is enabled in
+// 'opts' and the commit renamed (or copied) the file, then the old name of
+// the file is returned to follow its history further.
+func pathChange(repo *git.Repo, commit *git.Commit, parentHash, path string,
+	opts *git.DiffOptions) (bool, string, error) {
+	parentTree := ""
+	if parentHash != "" {
+		var err error
+		if parentTree, err = repo.TreeResolve(parentHash); err != nil {
+			return false, path, err
+		}
+	}
+
+	// Detecting renames is expensive, so do it only if the path was added.
+	entries, err := repo.DiffTrees(parentTree, commit.TreeHash(), nil)
+	if err != nil {
+		return false, path, err
 	}
+
+	changed, added := false, false
+	for _, entry := range entries {
+		entryPath := entry.Path()
+		if entryPath == path || strings.HasPrefix(entryPath, path+"/") {
+			changed = true
+			added = added || (entryPath == path && entry.Status == git.DiffAdded)
+		}
+	}
+
+	if !added || !opts.DetectRenames {
+		return changed, path, nil
+	}
+
+	entries, err = repo.DiffTrees(parentTree, commit.TreeHash(), opts)
+	if err != nil {
+		return false, path, err
+	}
+	for _, entry := range entries {
+		if entry.New.Path == path &&
+			(entry.Status == git.DiffRenamed || entry.Status == git.DiffCopied) {
+			return true, entry.Old.Path, nil
+		}
+	}
+
+	return changed, path, nil
 }
@@ -0,0 +1,399 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// MergeCommand lists the components of "merge" comamnd.
+type MergeCommand struct {
+	fs       *flag.FlagSet
+	noFF     bool
+	ffOnly   bool
+	squash   bool
+	abort    bool
+	msg      string
+	revision string
+}
+
+// NewMergeCommand creates a new command object.
+func NewMergeCommand() *MergeCommand {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	cmd := MergeCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.noFF, "no-ff", false, "Create a merge commit even for a fast-forward")
+	fs.BoolVar(&cmd.ffOnly, "ff-only", false, "Refuse to merge unless it is a fast-forward")
+	fs.BoolVar(&cmd.squash, "squash", false, "Merge the changes without committing them")
+	fs.BoolVar(&cmd.abort, "abort", false, "Abort the current conflicted merge")
+	fs.StringVar(&cmd.msg, "m", "", "Message of the merge commit")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *MergeCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *MergeCommand) Description() string {
+	return "Join two development histories together"
+}
+
+// Init initializes and validates the given command.
+func (cmd *MergeCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cmd.abort {
+		if cmd.fs.NArg() != 0 {
+			return errors.New("fatal: --abort expects no arguments")
+		}
+		return nil
+	}
+	if cmd.fs.NArg() != 1 {
+		return errors.New("error: Exactly one <commit> argument is needed")
+	}
+	cmd.revision = cmd.fs.Arg(0)
+
+	if cmd.noFF && cmd.ffOnly {
+		return errors.New("fatal: options '--ff-only' and '--no-ff' cannot be used together")
+	}
+	if cmd.noFF && cmd.squash {
+		return errors.New("fatal: options '--squash' and '--no-ff' cannot be used together")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *MergeCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] <commit>\n", cmd.Name())
+	fmt.Printf("   or: %s --abort\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *MergeCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	if cmd.abort {
+		cmd.abortMerge(repo)
+		return
+	}
+
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	if index.Unmerged() {
+		util.Check(errors.New("error: Merging is not possible because you have " +
+			"unmerged files.\n" +
+			"hint: Fix them up in the work tree, and then use 'git add/rm <file>'\n" +
+			"hint: as appropriate to mark resolution and make a commit.\n" +
+			"fatal: Exiting because of an unresolved conflict."))
+	}
+	if stateFileExists(repo, "MERGE_HEAD") {
+		util.Check(errors.New("fatal: You have not concluded your merge (MERGE_HEAD " +
+			"exists).\nPlease, commit your changes before you merge."))
+	}
+
+	branch, headHash, err := repo.Head()
+	util.Check(err)
+	theirs, err := resolveCommit(repo, cmd.revision)
+	if err != nil {
+		util.Check(fmt.Errorf("merge: %s - not something we can merge", cmd.revision))
+	}
+
+	headTree := ""
+	bases := []string{}
+	if headHash != "" {
+		headTree, err = repo.TreeResolve(headHash)
+		util.Check(err)
+		bases, err = repo.MergeBases(headHash, theirs)
+		util.Check(err)
+		util.Check(repo.WriteOrigHead(headHash))
+	}
+
+	isBase := func(hash string) bool {
+		for _, base := range bases {
+			if base == hash {
+				return true
+			}
+		}
+		return false
+	}
+	if isBase(theirs) {
+		fmt.Println("Already up to date.")
+		return
+	}
+	if headHash == "" || (isBase(headHash) && !cmd.noFF) {
+		cmd.fastForward(repo, headHash, headTree, theirs)
+		return
+	}
+	if cmd.ffOnly {
+		util.Check(errors.New("fatal: Not possible to fast-forward, aborting."))
+	}
+	if len(bases) == 0 {
+		util.Check(errors.New("fatal: refusing to merge unrelated histories"))
+	}
+
+	// The merge starts from a clean index, as the conflicts are recorded in
+	// it.
+	staged, err := repo.DiffTreeToIndex(headTree, index, nil)
+	util.Check(err)
+	if len(staged) > 0 {
+		paths := []string{}
+		for _, entry := range staged {
+			paths = append(paths, entry.Path())
+		}
+		fmt.Printf("error: Your local changes to the following files would be "+
+			"overwritten by merge:\n  %s\n", strings.Join(paths, " "))
+		fmt.Println("Merge with strategy ort failed.")
+		os.Exit(2)
+	}
+
+	cmd.threeWayMerge(repo, branch, headHash, headTree, theirs)
+}
+
+// fastForward moves HEAD (or only the index and the work-tree for a squash)
+// to a descendant of it.
+func (cmd *MergeCommand) fastForward(repo *git.Repo, headHash, headTree, theirs string) {
+	theirsTree, err := repo.TreeResolve(theirs)
+	util.Check(err)
+
+	if headHash != "" {
+		fmt.Printf("Updating %s..%s\n", headHash[:7], theirs[:7])
+	}
+	checkoutMerge(repo, headTree, theirsTree)
+	fmt.Println("Fast-forward")
+
+	if cmd.squash {
+		fmt.Println("Squash commit -- not updating HEAD")
+		msg, err := repo.SquashMessage(headHash, theirs)
+		util.Check(err)
+		util.Check(writeStateFile(repo, "SQUASH_MSG", msg))
+	} else {
+		util.Check(repo.UpdateRef("HEAD", theirs))
+	}
+	printMergeStat(repo, headTree, theirsTree)
+}
+
+// threeWayMerge merges a commit into HEAD using their merge bases, and
+// commits the result unless there are conflicts or it is a squash.
+func (cmd *MergeCommand) threeWayMerge(repo *git.Repo, branch, headHash, headTree,
+	theirs string) {
+	config, err := repo.Config()
+	util.Check(err)
+	opts := &git.MergeOptions{OursLabel: "HEAD", TheirsLabel: cmd.revision}
+	switch style, _ := config.Get("merge.conflictStyle"); style {
+	case "diff3":
+		opts.Style = git.ConflictDiff3
+	case "zdiff3":
+		opts.Style = git.ConflictZdiff3
+	}
+
+	result, err := repo.MergeCommits(headHash, theirs, opts)
+	util.Check(err)
+	checkoutMerge(repo, headTree, result.Tree)
+	util.Check(recordConflicts(repo, result))
+	for _, message := range result.Messages {
+		fmt.Println(message.Text)
+	}
+
+	msg := cmd.msg
+	if msg == "" {
+		msg = mergeMessage(repo, cmd.revision, branch)
+	}
+	msg = strings.TrimRight(msg, "\n") + "\n"
+
+	if cmd.squash {
+		squashMsg, err := repo.SquashMessage(headHash, theirs)
+		util.Check(err)
+		util.Check(writeStateFile(repo, "SQUASH_MSG", squashMsg))
+		if result.Clean() {
+			fmt.Println("Automatic merge went well; stopped before committing as requested")
+			fmt.Println("Squash commit -- not updating HEAD")
+			return
+		}
+		fmt.Println("Squash commit -- not updating HEAD")
+		msg = ""
+	}
+
+	if !result.Clean() {
+		msg += "\n# Conflicts:\n"
+		for _, conflictPath := range result.ConflictedPaths() {
+			msg += "#\t" + conflictPath + "\n"
+		}
+		util.Check(writeStateFile(repo, "MERGE_MSG", msg))
+		if !cmd.squash {
+			util.Check(writeStateFile(repo, "MERGE_HEAD", theirs+"\n"))
+			mode := ""
+			if cmd.noFF {
+				mode = "no-ff"
+			}
+			util.Check(writeStateFile(repo, "MERGE_MODE", mode))
+		}
+		fmt.Println("Automatic merge failed; fix conflicts and then commit the result.")
+		os.Exit(1)
+	}
+
+	commit, err := git.NewCommitFromParents(repo, result.Tree, []string{headHash, theirs}, msg)
+	util.Check(err)
+	commitHash, err := repo.ObjectWrite(commit.Object, true)
+	util.Check(err)
+	util.Check(repo.UpdateRef("HEAD", commitHash))
+
+	fmt.Println("Merge made by the 'ort' strategy.")
+	printMergeStat(repo, headTree, result.Tree)
+}
+
+// abortMerge throws away the result of a conflicted merge, and goes back to
+// the state before it. The local changes to the files which were not touched
+// by the merge are kept.
+func (cmd *MergeCommand) abortMerge(repo *git.Repo) {
+	if !stateFileExists(repo, "MERGE_HEAD") {
+		util.Check(errors.New("fatal: There is no merge to abort (MERGE_HEAD missing)."))
+	}
+	discardMerge(repo)
+	util.Check(repo.ClearMergeState())
+}
+
+// discardMerge resets the paths touched by a merge (or a cherry-pick) to HEAD
+// in the index and the work-tree. The merge started from a clean index, so
+// that the paths which differ in the index are the ones touched by it.
+func discardMerge(repo *git.Repo) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	headTree := ""
+	if headHash != "" {
+		headTree, err = repo.TreeResolve(headHash)
+		util.Check(err)
+	}
+
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	paths := []string{}
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			paths = append(paths, entry.Path)
+		}
+	}
+	staged, err := repo.DiffTreeToIndex(headTree, index, nil)
+	util.Check(err)
+	for _, entry := range staged {
+		paths = append(paths, entry.Path())
+	}
+
+	if len(paths) > 0 {
+		opts := &git.RestoreOptions{Source: headTree, Staged: true, Worktree: true}
+		_, err = repo.Restore(git.NewPathspec(paths), opts)
+		util.Check(err)
+	}
+}
+
+// checkoutMerge updates the index and the work-tree to the result of a merge.
+// Like "git", the merge fails with the exit status 2 if any local change would
+// be lost.
+func checkoutMerge(repo *git.Repo, headTree, mergedTree string) {
+	err := repo.CheckoutTree(headTree, mergedTree, &git.CheckoutOptions{Command: "merge"})
+	if _, ok := err.(*git.CheckoutError); ok {
+		fmt.Println(err)
+		fmt.Println("Merge with strategy ort failed.")
+		os.Exit(2)
+	}
+	util.Check(err)
+}
+
+// recordConflicts replaces the index entries of the conflicted paths of a
+// merge with their versions at the stages 1, 2 and 3.
+func recordConflicts(repo *git.Repo, result *git.MergeResult) error {
+	if result.Clean() {
+		return nil
+	}
+
+	index, err := repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	for _, conflictPath := range result.ConflictedPaths() {
+		index.Remove(conflictPath)
+	}
+	for _, stage := range result.Conflicts {
+		entry, err := git.NewIndexEntry(stage.Path, stage.Mode, stage.Hash)
+		if err != nil {
+			return err
+		}
+		entry.SetStage(stage.Stage)
+		index.Add(entry)
+	}
+	return repo.WriteIndex(index)
+}
+
+// mergeMessage returns the default message of a merge commit, which tells the
+// kind of the merged name, and the current branch unless it is the main one.
+// Example: "Merge branch 'topic' into next"
+func mergeMessage(repo *git.Repo, name, branch string) string {
+	kind := "commit"
+	for _, ref := range []struct{ prefix, kind string }{
+		{"refs/heads/", "branch"},
+		{"refs/tags/", "tag"},
+		{"refs/remotes/", "remote-tracking branch"},
+	} {
+		if _, _, err := repo.RefResolve(ref.prefix + name); err == nil {
+			kind = ref.kind
+			break
+		}
+	}
+
+	msg := fmt.Sprintf("Merge %s '%s'", kind, name)
+	switch branch {
+	case "refs/heads/master", "refs/heads/main":
+	case "":
+		msg += " into HEAD"
+	default:
+		msg += " into " + strings.TrimPrefix(branch, "refs/heads/")
+	}
+	return msg
+}
+
+// printMergeStat shows the diffstat of the changes brought in by a merge,
+// along with the summary of the created and deleted files.
+func printMergeStat(repo *git.Repo, oldTree, newTree string) {
+	opts := &git.DiffOptions{DetectRenames: true, RenameLimit: git.DefaultRenameLimit}
+	entries, err := repo.DiffTrees(oldTree, newTree, opts)
+	util.Check(err)
+	stats, err := repo.DiffStats(entries)
+	util.Check(err)
+	width, err := statWidth("")
+	util.Check(err)
+	fmt.Print(git.FormatStat(stats, width))
+	fmt.Print(git.FormatSummary(entries))
+}
+
+// stateFileExists tells if a state file, such as "MERGE_HEAD", is present
+// in the git directory.
+func stateFileExists(repo *git.Repo, name string) bool {
+	stateFile, err := repo.FilePath(false, name)
+	return err == nil && fileExists(stateFile)
+}
+
+// writeStateFile writes a state file, such as "MERGE_MSG", in the git
+// directory.
+func writeStateFile(repo *git.Repo, name, data string) error {
+	stateFile, err := repo.FilePath(false, name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stateFile, []byte(data), 0644)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// MergeBaseCommand lists the components of "merge-base" comamnd.
+type MergeBaseCommand struct {
+	fs         *flag.FlagSet
+	all        bool
+	isAncestor bool
+	octopus    bool
+	forkPoint  bool
+	revisions  []string
+}
+
+// NewMergeBaseCommand creates a new command object.
+func NewMergeBaseCommand() *MergeBaseCommand {
+	fs := flag.NewFlagSet("merge-base", flag.ExitOnError)
+	cmd := MergeBaseCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.all, "a", false, "Output all the merge bases")
+	fs.BoolVar(&cmd.all, "all", false, "Output all the merge bases")
+	fs.BoolVar(&cmd.isAncestor, "is-ancestor", false,
+		"Check if the first commit is an ancestor of the second one")
+	fs.BoolVar(&cmd.octopus, "octopus", false, "Find the merge bases for an n-way merge")
+	fs.BoolVar(&cmd.forkPoint, "fork-point", false,
+		"Find where a commit forked from the reflog of a ref")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *MergeBaseCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *MergeBaseCommand) Description() string {
+	return "Find as good common ancestors as possible for a merge"
+}
+
+// Init initializes and validates the given command.
+func (cmd *MergeBaseCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+	cmd.revisions = cmd.fs.Args()
+
+	modes := 0
+	for _, set := range []bool{cmd.isAncestor, cmd.octopus, cmd.forkPoint} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return errors.New("error: Only one of --is-ancestor, --octopus and " +
+			"--fork-point can be given")
+	}
+
+	switch {
+	case cmd.isAncestor:
+		if cmd.all || len(cmd.revisions) != 2 {
+			return errors.New("fatal: --is-ancestor takes exactly two commits")
+		}
+	case cmd.forkPoint:
+		if cmd.all || len(cmd.revisions) < 1 || len(cmd.revisions) > 2 {
+			return errors.New("fatal: --fork-point takes a ref and an optional commit")
+		}
+	case cmd.octopus:
+		if len(cmd.revisions) < 1 {
+			return errors.New("error: Missing <commit> arguments")
+		}
+	default:
+		if len(cmd.revisions) < 2 {
+			return errors.New("error: At least two <commit> arguments are needed")
+		}
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *MergeBaseCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [-a | --all] <commit> <commit>...\n", cmd.Name())
+	fmt.Printf("   or: %s [-a | --all] --octopus <commit>...\n", cmd.Name())
+	fmt.Printf("   or: %s --is-ancestor <commit> <commit>\n", cmd.Name())
+	fmt.Printf("   or: %s --fork-point <ref> [<commit>]\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *MergeBaseCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	if cmd.forkPoint {
+		cmd.printForkPoint(repo)
+		return
+	}
+
+	commits := []string{}
+	for _, name := range cmd.revisions {
+		commit, err := resolveCommit(repo, name)
+		util.Check(err)
+		commits = append(commits, commit)
+	}
+
+	// The answer of "--is-ancestor" is only in the exit status.
+	if cmd.isAncestor {
+		isAncestor, err := repo.IsAncestor(commits[0], commits[1])
+		util.Check(err)
+		if !isAncestor {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var bases []string
+	if cmd.octopus {
+		bases, err = repo.OctopusMergeBases(commits)
+	} else {
+		bases, err = repo.MergeBasesMany(commits[0], commits[1:])
+	}
+	util.Check(err)
+	if len(bases) == 0 {
+		os.Exit(1)
+	}
+	if !cmd.all {
+		bases = bases[:1]
+	}
+	for _, base := range bases {
+		fmt.Println(base)
+	}
+}
+
+// printForkPoint prints the commit where a commit (HEAD by default) forked
+// from a ref.
+func (cmd *MergeBaseCommand) printForkPoint(repo *git.Repo) {
+	// Like other names, a short name of the ref is matched in the order of
+	// "refs/", "refs/tags/", "refs/heads/" and "refs/remotes/", which are the
+	// shortest first.
+	refs, err := repo.GetRefs(cmd.revisions[0], false)
+	util.Check(err)
+	if len(refs) == 0 {
+		util.Check(fmt.Errorf("fatal: Not a valid object name: '%s'", cmd.revisions[0]))
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return len(refs[i].Name) < len(refs[j].Name)
+	})
+
+	name := "HEAD"
+	if len(cmd.revisions) == 2 {
+		name = cmd.revisions[1]
+	}
+	commit, err := resolveCommit(repo, name)
+	util.Check(err)
+
+	forkPoint, err := repo.ForkPoint(refs[0].Name, commit)
+	util.Check(err)
+	if forkPoint == "" {
+		os.Exit(1)
+	}
+	fmt.Println(forkPoint)
+}
+
+// resolveCommit finds the commit given by a name, peeling the tags if needed.
+func resolveCommit(repo *git.Repo, name string) (string, error) {
+	hash, err := repo.UniqueNameResolve(name)
+	if err != nil {
+		return "", fmt.Errorf("fatal: Not a valid object name %s", name)
+	}
+	obj, hash, err := repo.PeelObject(hash)
+	if err != nil {
+		return "", err
+	}
+	if obj.ObjType != "commit" {
+		return "", fmt.Errorf("fatal: Not a valid commit name %s", name)
+	}
+	return hash, nil
+}
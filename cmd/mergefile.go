@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+)
+
+// labelsFlag is a flag which can be given multiple times, such as "-L".
+type labelsFlag []string
+
+// String returns the labels given so far.
+func (f *labelsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+// Set adds one more label.
+func (f *labelsFlag) Set(value string) error {
+	if len(*f) == 3 {
+		return errors.New("too many labels on the command line")
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// MergeFileCommand lists the components of "merge-file" comamnd.
+type MergeFileCommand struct {
+	fs         *flag.FlagSet
+	stdout     bool
+	diff3      bool
+	zdiff3     bool
+	ours       bool
+	theirs     bool
+	union      bool
+	markerSize int
+	quiet      bool
+	labels     labelsFlag
+	files      []string
+}
+
+// NewMergeFileCommand creates a new command object.
+func NewMergeFileCommand() *MergeFileCommand {
+	fs := flag.NewFlagSet("merge-file", flag.ExitOnError)
+	cmd := MergeFileCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.stdout, "p", false, "Send the results to standard output")
+	fs.BoolVar(&cmd.stdout, "stdout", false, "Send the results to standard output")
+	fs.BoolVar(&cmd.diff3, "diff3", false, "Use a diff3 based merge")
+	fs.BoolVar(&cmd.zdiff3, "zdiff3", false, "Use a zealous diff3 based merge")
+	fs.BoolVar(&cmd.ours, "ours", false, "For conflicts, use our version")
+	fs.BoolVar(&cmd.theirs, "theirs", false, "For conflicts, use their version")
+	fs.BoolVar(&cmd.union, "union", false, "For conflicts, use a union version")
+	fs.IntVar(&cmd.markerSize, "marker-size", git.DefaultMarkerSize,
+		"For conflicts, use this marker size")
+	fs.BoolVar(&cmd.quiet, "q", false, "Do not warn about conflicts")
+	fs.BoolVar(&cmd.quiet, "quiet", false, "Do not warn about conflicts")
+	fs.Var(&cmd.labels, "L", "Set labels for file1/orig-file/file2")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *MergeFileCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *MergeFileCommand) Description() string {
+	return "Run a three-way file merge"
+}
+
+// Init initializes and validates the given command.
+func (cmd *MergeFileCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cmd.fs.NArg() != 3 {
+		return errors.New("error: Exactly 3 files are needed: " +
+			"<file1> <orig-file> <file2>")
+	}
+	cmd.files = cmd.fs.Args()
+
+	favors := 0
+	for _, set := range []bool{cmd.ours, cmd.theirs, cmd.union} {
+		if set {
+			favors++
+		}
+	}
+	if favors > 1 || (cmd.diff3 && cmd.zdiff3) {
+		return errors.New("error: Only one of --ours, --theirs and --union, " +
+			"and one of --diff3 and --zdiff3 can be given")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *MergeFileCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] [-L <name1> [-L <orig> [-L <name2>]]] "+
+		"<file1> <orig-file> <file2>\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *MergeFileCommand) Execute() {
+	// Like "git", the exit status is the number of conflicts, so that any
+	// error exits with 255 instead.
+	fail := func(err error) {
+		if !cmd.quiet {
+			fmt.Println(err)
+		}
+		os.Exit(255)
+	}
+
+	contents := [][]byte{}
+	for _, file := range cmd.files {
+		if _, err := os.Stat(file); err != nil {
+			fail(fmt.Errorf("error: Could not stat %s: %v", file,
+				errors.Unwrap(err)))
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fail(err)
+		}
+		if git.IsBinary(data) {
+			fail(fmt.Errorf("error: Cannot merge binary files: %s", file))
+		}
+		contents = append(contents, data)
+	}
+
+	// The labels default to the names of the files.
+	labels := append(append([]string{}, cmd.labels...), cmd.files[len(cmd.labels):]...)
+	opts := &git.MergeFileOptions{
+		OursLabel:   labels[0],
+		BaseLabel:   labels[1],
+		TheirsLabel: labels[2],
+		MarkerSize:  cmd.markerSize,
+	}
+	switch {
+	case cmd.ours:
+		opts.Favor = git.MergeFavorOurs
+	case cmd.theirs:
+		opts.Favor = git.MergeFavorTheirs
+	case cmd.union:
+		opts.Favor = git.MergeFavorUnion
+	}
+	switch {
+	case cmd.diff3:
+		opts.Style = git.ConflictDiff3
+	case cmd.zdiff3:
+		opts.Style = git.ConflictZdiff3
+	}
+
+	result, conflicts := git.MergeFile(contents[1], contents[0], contents[2], opts)
+	if cmd.stdout {
+		os.Stdout.Write(result)
+	} else if err := ioutil.WriteFile(cmd.files[0], result, 0644); err != nil {
+		fail(err)
+	}
+
+	if conflicts > 127 {
+		conflicts = 127
+	}
+	os.Exit(conflicts)
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// MergeTreeCommand lists the components of "merge-tree" comamnd.
+type MergeTreeCommand struct {
+	fs             *flag.FlagSet
+	writeTree      bool
+	nameOnly       bool
+	nullTerminated bool
+	messages       bool
+	noMessages     bool
+	allowUnrelated bool
+	branch1        string
+	branch2        string
+}
+
+// NewMergeTreeCommand creates a new command object.
+func NewMergeTreeCommand() *MergeTreeCommand {
+	fs := flag.NewFlagSet("merge-tree", flag.ExitOnError)
+	cmd := MergeTreeCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.writeTree, "write-tree", false, "Write the merged tree (default)")
+	fs.BoolVar(&cmd.nameOnly, "name-only", false,
+		"List the names of the conflicted files without modes, hashes and stages")
+	fs.BoolVar(&cmd.nullTerminated, "z", false, "Separate the paths with NUL characters")
+	fs.BoolVar(&cmd.messages, "messages", false, "Show the messages even for a clean merge")
+	fs.BoolVar(&cmd.noMessages, "no-messages", false, "Do not show the messages")
+	fs.BoolVar(&cmd.allowUnrelated, "allow-unrelated-histories", false,
+		"Allow merging histories without a common ancestor")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *MergeTreeCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *MergeTreeCommand) Description() string {
+	return "Perform merge without touching index or working tree"
+}
+
+// Init initializes and validates the given command.
+func (cmd *MergeTreeCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cmd.fs.NArg() != 2 {
+		return errors.New("error: Exactly two <branch> arguments are needed, " +
+			"as only the --write-tree mode is supported")
+	}
+	cmd.branch1, cmd.branch2 = cmd.fs.Arg(0), cmd.fs.Arg(1)
+	if cmd.messages && cmd.noMessages {
+		return errors.New("error: Only one of --messages and --no-messages can be given")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *MergeTreeCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [--write-tree] [<args>] <branch1> <branch2>\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *MergeTreeCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	commits := []string{}
+	for _, name := range []string{cmd.branch1, cmd.branch2} {
+		commit, err := resolveCommit(repo, name)
+		if err != nil {
+			util.Check(fmt.Errorf("merge-tree: %s - not something we can merge", name))
+		}
+		commits = append(commits, commit)
+	}
+
+	if !cmd.allowUnrelated {
+		bases, err := repo.MergeBases(commits[0], commits[1])
+		util.Check(err)
+		if len(bases) == 0 {
+			util.Check(errors.New("fatal: refusing to merge unrelated histories"))
+		}
+	}
+
+	// Only the objects are written. The index and the work-tree are not
+	// needed, so that this works in a bare repository too.
+	opts := &git.MergeOptions{OursLabel: cmd.branch1, TheirsLabel: cmd.branch2}
+	result, err := repo.MergeCommits(commits[0], commits[1], opts)
+	util.Check(err)
+	fmt.Print(cmd.format(result))
+
+	if !result.Clean() {
+		os.Exit(1)
+	}
+}
+
+// format returns the output for the result of a merge: the hash of the merged
+// tree, the conflicted files, and the messages after a blank line.
+func (cmd *MergeTreeCommand) format(result *git.MergeResult) string {
+	var b strings.Builder
+	end, quote := "\n", quotePath
+	if cmd.nullTerminated {
+		end, quote = "\x00", func(path string) string { return path }
+	}
+
+	b.WriteString(result.Tree + end)
+	if cmd.nameOnly {
+		for _, conflictPath := range result.ConflictedPaths() {
+			b.WriteString(quote(conflictPath) + end)
+		}
+	} else {
+		for _, stage := range result.Conflicts {
+			fmt.Fprintf(&b, "%s %s %d\t%s%s", stage.Mode, stage.Hash, stage.Stage,
+				quote(stage.Path), end)
+		}
+	}
+
+	// The messages are shown by default only if there are conflicts.
+	if cmd.noMessages || (!cmd.messages && result.Clean()) {
+		return b.String()
+	}
+	b.WriteString(end)
+	for _, message := range result.Messages {
+		if !cmd.nullTerminated {
+			b.WriteString(message.Text + "\n")
+			continue
+		}
+
+		// Each message is given as the count of its paths, the paths, a
+		// stable kind, and the text.
+		fmt.Fprintf(&b, "%d\x00", len(message.Paths))
+		for _, messagePath := range message.Paths {
+			b.WriteString(messagePath + "\x00")
+		}
+		fmt.Fprintf(&b, "%s\x00%s\n\x00", message.Kind, message.Text)
+	}
+	return b.String()
+}
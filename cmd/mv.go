@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// MvCommand lists the components of "mv" comamnd.
+type MvCommand struct {
+	fs         *flag.FlagSet
+	force      bool
+	skipErrors bool
+	dryRun     bool
+	verbose    bool
+	sources    []string
+	dest       string
+}
+
+// NewMvCommand creates a new command object.
+func NewMvCommand() *MvCommand {
+	fs := flag.NewFlagSet("mv", flag.ExitOnError)
+	cmd := MvCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.force, "f", false, "Move even if the destination exists")
+	fs.BoolVar(&cmd.force, "force", false, "Move even if the destination exists")
+	fs.BoolVar(&cmd.skipErrors, "k", false, "Skip the sources which can't be moved")
+	fs.BoolVar(&cmd.dryRun, "n", false, "Only show what would be moved")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "Only show what would be moved")
+	fs.BoolVar(&cmd.verbose, "v", false, "Show the files which are moved")
+	fs.BoolVar(&cmd.verbose, "verbose", false, "Show the files which are moved")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *MvCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *MvCommand) Description() string {
+	return "Move or rename a file, a directory, or a symlink"
+}
+
+// Init initializes and validates the given command.
+func (cmd *MvCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	args = cmd.fs.Args()
+	if len(args) < 2 {
+		return errors.New("error: Missing <source> or <destination> argument")
+	}
+	cmd.sources, cmd.dest = args[:len(args)-1], args[len(args)-1]
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *MvCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [-v] [-f] [-n] [-k] <source>... <destination>\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *MvCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	// The paths are relative to the top of the work-tree from here on. A
+	// trailing "/" of the destination tells that it must be a directory.
+	sources := []string{}
+	for _, arg := range cmd.sources {
+		filePath, err := repoPath(repo, arg)
+		util.Check(err)
+		sources = append(sources, filePath)
+	}
+	dest, err := repoPath(repo, cmd.dest)
+	util.Check(err)
+	if strings.HasSuffix(cmd.dest, "/") && dest != "" {
+		dest += "/"
+	}
+
+	opts := &git.MoveOptions{
+		Force:      cmd.force,
+		SkipErrors: cmd.skipErrors,
+		DryRun:     cmd.dryRun,
+	}
+	moves, err := repo.MovePaths(sources, dest, opts)
+	util.Check(err)
+
+	prefix := cwdPrefix(repo)
+	if cmd.dryRun {
+		for _, move := range moves {
+			fmt.Printf("Checking rename of '%s' to '%s'\n",
+				relativePath(move.Source, prefix), relativePath(move.Destination, prefix))
+		}
+	}
+	if cmd.dryRun || cmd.verbose {
+		for _, move := range moves {
+			fmt.Printf("Renaming %s to %s\n",
+				relativePath(move.Source, prefix), relativePath(move.Destination, prefix))
+		}
+	}
+}
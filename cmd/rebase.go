@@ -0,0 +1,745 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// rebaseTodoHelp is appended to the todo list given to the user to edit.
+const rebaseTodoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's log message
+# x, exec <command> = run command (the rest of the line) using shell
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+#
+# If you remove a line here THAT COMMIT WILL BE LOST.
+#
+# However, if you remove everything, the rebase will be aborted.
+#
+`
+
+// RebaseCommand lists the components of "rebase" comamnd.
+type RebaseCommand struct {
+	fs          *flag.FlagSet
+	onto        string
+	interactive bool
+	todoFile    string
+	autosquash  bool
+	cont        bool
+	skip        bool
+	abort       bool
+	upstream    string
+	branch      string
+}
+
+// NewRebaseCommand creates a new command object.
+func NewRebaseCommand() *RebaseCommand {
+	fs := flag.NewFlagSet("rebase", flag.ExitOnError)
+	cmd := RebaseCommand{
+		fs: fs,
+	}
+
+	fs.StringVar(&cmd.onto, "onto", "", "Starting point at which to create the new commits")
+	fs.BoolVar(&cmd.interactive, "i", false, "Let the user edit the list of commits to rebase")
+	fs.BoolVar(&cmd.interactive, "interactive", false,
+		"Let the user edit the list of commits to rebase")
+	fs.StringVar(&cmd.todoFile, "todo-file", "",
+		"Read the list of commits to rebase from a file, instead of an editor")
+	fs.BoolVar(&cmd.autosquash, "autosquash", false,
+		"Move the \"fixup!\" and \"squash!\" commits after the commits they fix")
+	fs.BoolVar(&cmd.cont, "continue", false, "Continue after resolving a conflict")
+	fs.BoolVar(&cmd.skip, "skip", false, "Skip the current commit and continue")
+	fs.BoolVar(&cmd.abort, "abort", false, "Abort and check out the original branch")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *RebaseCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *RebaseCommand) Description() string {
+	return "Reapply commits on top of another base tip"
+}
+
+// Init initializes and validates the given command.
+func (cmd *RebaseCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	subcommands := 0
+	for _, set := range []bool{cmd.cont, cmd.skip, cmd.abort} {
+		if set {
+			subcommands++
+		}
+	}
+	if subcommands > 1 {
+		return errors.New("error: Only one of --continue, --skip and --abort can be given")
+	}
+	if subcommands == 1 {
+		if cmd.fs.NArg() != 0 || cmd.onto != "" || cmd.interactive || cmd.todoFile != "" ||
+			cmd.autosquash {
+			return errors.New("fatal: --continue, --skip and --abort take no other arguments")
+		}
+		return nil
+	}
+
+	if cmd.fs.NArg() > 2 {
+		return errors.New("error: At most <upstream> and <branch> arguments can be given")
+	}
+	if cmd.fs.NArg() > 0 {
+		cmd.upstream = cmd.fs.Arg(0)
+	}
+	if cmd.fs.NArg() > 1 {
+		cmd.branch = cmd.fs.Arg(1)
+	}
+	if cmd.todoFile != "" {
+		cmd.interactive = true
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *RebaseCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] [--onto <newbase>] [<upstream> [<branch>]]\n", cmd.Name())
+	fmt.Printf("   or: %s (--continue | --skip | --abort)\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *RebaseCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	state, err := repo.ReadRebaseState()
+	util.Check(err)
+	if (cmd.cont || cmd.skip || cmd.abort) && state == nil {
+		util.Check(errors.New("fatal: No rebase in progress?"))
+	}
+
+	switch {
+	case cmd.cont:
+		cmd.continueRebase(repo, state)
+	case cmd.skip:
+		discardMerge(repo)
+		util.Check(clearRebaseStop(repo, state))
+		cmd.run(repo, state)
+	case cmd.abort:
+		cmd.abortRebase(repo, state)
+	default:
+		if state != nil {
+			util.Check(errors.New("fatal: It seems that there is already a rebase-merge " +
+				"directory, and\nI wonder if you are in the middle of another rebase.  " +
+				"If that is the\ncase, please try\n" +
+				"\tgit rebase (--continue | --abort | --skip)\n" +
+				"If that is not the case, please\n\trm -fr \".git/rebase-merge\"\n" +
+				"and run me again.  I am stopping in case you still have something\n" +
+				"valuable there.\n"))
+		}
+		cmd.start(repo)
+	}
+}
+
+// start sets up a new rebase, and replays the commits.
+func (cmd *RebaseCommand) start(repo *git.Repo) {
+	checkCleanForRebase(repo)
+
+	// The branch to rebase is checked out first, if given.
+	if cmd.branch != "" {
+		target, err := resolveSwitchTarget(repo, cmd.branch, false)
+		util.Check(err)
+		_, headHash, err := repo.Head()
+		util.Check(err)
+		headTree, err := repo.TreeResolve(headHash)
+		util.Check(err)
+		targetTree, err := repo.TreeResolve(target.commit)
+		util.Check(err)
+		opts := &git.CheckoutOptions{Command: "checkout"}
+		util.Check(repo.CheckoutTree(headTree, targetTree, opts))
+		if target.branch != "" {
+			util.Check(repo.SetHead(target.branch))
+		} else {
+			util.Check(repo.SetHead(target.commit))
+		}
+	}
+
+	branch, headHash, err := repo.Head()
+	util.Check(err)
+	if headHash == "" {
+		util.Check(errors.New("fatal: invalid upstream 'HEAD'"))
+	}
+
+	// Without an upstream, the branch is rebased on the branch it tracks,
+	// from the point where it forked from it.
+	upstream, upstreamRef, ontoName := "", "", cmd.upstream
+	if cmd.upstream != "" {
+		if upstream, err = resolveCommit(repo, cmd.upstream); err != nil {
+			util.Check(fmt.Errorf("fatal: invalid upstream '%s'", cmd.upstream))
+		}
+	} else {
+		config, err := repo.Config()
+		util.Check(err)
+		if branch != "" {
+			upstreamRef = repo.Upstream(branch, config)
+		}
+		if branch == "" {
+			util.Check(errors.New("You are not currently on a branch.\n" +
+				"Please specify which branch you want to rebase against.\n" +
+				"See git-rebase(1) for details.\n\n    git rebase '<branch>'\n"))
+		}
+		if upstreamRef == "" {
+			util.Check(fmt.Errorf("There is no tracking information for the current "+
+				"branch.\nPlease specify which branch you want to rebase against.\n"+
+				"See git-rebase(1) for details.\n\n    git rebase '<branch>'\n\n"+
+				"If you wish to set tracking information for this branch you can do so "+
+				"with:\n\n    git branch --set-upstream-to=<remote>/<branch> %s\n",
+				shortRef(branch)))
+		}
+		upstream, _, err = repo.RefResolve(upstreamRef)
+		util.Check(err)
+		ontoName = upstreamRef
+	}
+
+	onto := upstream
+	if cmd.onto != "" {
+		if onto, err = resolveCommit(repo, cmd.onto); err != nil {
+			util.Check(fmt.Errorf("fatal: Does not point to a valid commit '%s'", cmd.onto))
+		}
+		ontoName = cmd.onto
+	}
+	if upstreamRef != "" {
+		forkPoint, err := repo.ForkPoint(upstreamRef, headHash)
+		util.Check(err)
+		if forkPoint != "" {
+			upstream = forkPoint
+		}
+	}
+
+	commits, err := repo.RebaseCommits(upstream, headHash)
+	util.Check(err)
+	items := []git.RebaseItem{}
+	for _, commit := range commits {
+		items = append(items, git.RebaseItem{Command: "pick", Commit: commit})
+	}
+	if cmd.autosquash {
+		items, err = repo.Autosquash(items)
+		util.Check(err)
+	}
+
+	headName := branch
+	if branch == "" {
+		headName = "detached HEAD"
+	}
+	if !cmd.interactive && cmd.isUpToDate(repo, onto, items) {
+		name := "HEAD"
+		if branch != "" {
+			name = shortRef(branch)
+		}
+		fmt.Printf("Current branch %s is up to date.\n", name)
+		return
+	}
+
+	state := &git.RebaseState{
+		HeadName:    headName,
+		Onto:        onto,
+		OrigHead:    headHash,
+		Interactive: cmd.interactive,
+		Todo:        items,
+		Done:        []git.RebaseItem{},
+	}
+	util.Check(repo.WriteRebaseState(state))
+	if cmd.interactive {
+		state.Todo = cmd.editTodo(repo, state, upstream)
+		if len(state.Todo) == 0 {
+			util.Check(repo.RemoveRebaseState())
+			util.Check(errors.New("error: nothing to do"))
+		}
+	}
+
+	// HEAD is detached at the new base, where the commits are replayed.
+	util.Check(repo.WriteOrigHead(headHash))
+	headTree, err := repo.TreeResolve(headHash)
+	util.Check(err)
+	ontoTree, err := repo.TreeResolve(onto)
+	util.Check(err)
+	err = repo.CheckoutTree(headTree, ontoTree, &git.CheckoutOptions{Command: "checkout"})
+	util.Check(err)
+	moveHead(repo, onto, "rebase (start): checkout "+ontoName)
+	cmd.run(repo, state)
+}
+
+// isUpToDate tells if the commits to rebase are already a linear history on
+// top of the new base.
+func (cmd *RebaseCommand) isUpToDate(repo *git.Repo, onto string,
+	items []git.RebaseItem) bool {
+	parent := onto
+	for _, item := range items {
+		obj, err := repo.ObjectParse(item.Commit)
+		util.Check(err)
+		commit, err := git.NewCommit(repo, obj)
+		util.Check(err)
+		if parents := commit.Parents(); len(parents) != 1 || parents[0] != parent {
+			return false
+		}
+		parent = item.Commit
+	}
+
+	// The new base must be in the history being rebased.
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	return parent == headHash
+}
+
+// editTodo lets the user edit the todo list of an interactive rebase, or
+// reads it from the given file, and returns the commands in it.
+func (cmd *RebaseCommand) editTodo(repo *git.Repo, state *git.RebaseState,
+	upstream string) []git.RebaseItem {
+	todoFile, err := repo.FilePath(false, "rebase-merge", "git-rebase-todo")
+	util.Check(err)
+
+	todo, err := repo.FormatRebaseTodo(state.Todo, true)
+	util.Check(err)
+	todo += fmt.Sprintf("\n# Rebase %s..%s onto %s (%d commands)\n", upstream[:7],
+		state.OrigHead[:7], state.Onto[:7], len(state.Todo))
+	todo += rebaseTodoHelp
+	util.Check(ioutil.WriteFile(todoFile, []byte(todo), 0644))
+
+	if cmd.todoFile != "" {
+		data, err := ioutil.ReadFile(cmd.todoFile)
+		util.Check(err)
+		util.Check(ioutil.WriteFile(todoFile, data, 0644))
+	} else {
+		util.Check(editFile(repo, todoFile, true))
+	}
+
+	data, err := ioutil.ReadFile(todoFile)
+	util.Check(err)
+	items, err := repo.ParseRebaseTodo(string(data))
+	if err == nil && len(items) > 0 && (items[0].Command == "squash" ||
+		items[0].Command == "fixup") {
+		err = fmt.Errorf("error: cannot '%s' without a previous commit", items[0].Command)
+	}
+	if err != nil {
+		util.Check(repo.RemoveRebaseState())
+		util.Check(err)
+	}
+	return items
+}
+
+// continueRebase commits the resolution of the conflicts of the commit which
+// stopped, and replays the remaining commits.
+func (cmd *RebaseCommand) continueRebase(repo *git.Repo, state *git.RebaseState) {
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	if index.Unmerged() {
+		for i, entry := range index.Entries {
+			if entry.Stage() != 0 && (i == 0 || index.Entries[i-1].Path != entry.Path) {
+				fmt.Printf("%s: needs merge\n", entry.Path)
+			}
+		}
+		util.Check(errors.New("You must edit all merge conflicts and then\n" +
+			"mark them as resolved using git add"))
+	}
+
+	if state.Stopped != "" && len(state.Done) > 0 {
+		tree, err := repo.WriteTree(index.Files())
+		util.Check(err)
+		item := state.Done[len(state.Done)-1]
+		util.Check(clearRebaseStop(repo, state))
+		cmd.commitItem(repo, state, item, tree, true)
+	}
+	cmd.run(repo, state)
+}
+
+// abortRebase throws away the replayed commits, and checks out the branch as
+// it was before the rebase.
+func (cmd *RebaseCommand) abortRebase(repo *git.Repo, state *git.RebaseState) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	headTree, err := repo.TreeResolve(headHash)
+	util.Check(err)
+	origTree, err := repo.TreeResolve(state.OrigHead)
+	util.Check(err)
+	opts := &git.CheckoutOptions{Force: true, Command: "reset"}
+	util.Check(repo.CheckoutTree(headTree, origTree, opts))
+
+	util.Check(clearRebaseStop(repo, state))
+	if strings.HasPrefix(state.HeadName, "refs/") {
+		util.Check(repo.SetHead(state.HeadName))
+		util.Check(repo.WriteReflog("HEAD", headHash, state.OrigHead,
+			"rebase (abort): returning to "+state.HeadName))
+	} else {
+		moveHead(repo, state.OrigHead, "rebase (abort): returning to "+state.OrigHead)
+	}
+	util.Check(repo.RemoveRebaseState())
+}
+
+// run executes the remaining commands of the todo list, and finishes the
+// rebase. It exits if a command stops.
+func (cmd *RebaseCommand) run(repo *git.Repo, state *git.RebaseState) {
+	for len(state.Todo) > 0 {
+		item := state.Todo[0]
+		state.Todo = state.Todo[1:]
+		state.Done = append(state.Done, item)
+		util.Check(repo.WriteRebaseState(state))
+		fmt.Printf("Rebasing (%d/%d)\r", len(state.Done), len(state.Done)+len(state.Todo))
+
+		switch item.Command {
+		case "drop":
+		case "exec":
+			cmd.execItem(repo, item)
+		default:
+			cmd.pickItem(repo, state, item)
+		}
+	}
+	cmd.finish(repo, state)
+}
+
+// execItem runs the shell command of an "exec" from the top of the
+// work-tree.
+func (cmd *RebaseCommand) execItem(repo *git.Repo, item git.RebaseItem) {
+	fmt.Printf("\r\033[KExecuting: %s\n", item.Arg)
+	shell := exec.Command("sh", "-c", item.Arg)
+	shell.Dir = repo.WorkTree
+	shell.Stdin, shell.Stdout, shell.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := shell.Run(); err != nil {
+		fmt.Printf("warning: execution failed: %s\n"+
+			"You can fix the problem, and then run\n\n"+
+			"  git rebase --continue\n\n\n", item.Arg)
+		os.Exit(1)
+	}
+}
+
+// pickItem replays the commit of a "pick", "reword", "squash" or "fixup"
+// command on top of HEAD. It exits if there are conflicts.
+func (cmd *RebaseCommand) pickItem(repo *git.Repo, state *git.RebaseState,
+	item git.RebaseItem) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	headTree, err := repo.TreeResolve(headHash)
+	util.Check(err)
+
+	obj, err := repo.ObjectParse(item.Commit)
+	util.Check(err)
+	commit, err := git.NewCommit(repo, obj)
+	util.Check(err)
+
+	// A commit whose parent is HEAD is reused as is.
+	if parents := commit.Parents(); item.Command == "pick" && len(parents) == 1 &&
+		parents[0] == headHash {
+		util.Check(repo.CheckoutTree(headTree, commit.TreeHash(),
+			&git.CheckoutOptions{Command: "merge"}))
+		moveHead(repo, item.Commit, "rebase (pick): "+commit.Subject())
+		return
+	}
+
+	config, err := repo.Config()
+	util.Check(err)
+	opts := &git.PickOptions{}
+	switch style, _ := config.Get("merge.conflictStyle"); style {
+	case "diff3":
+		opts.Style = git.ConflictDiff3
+	case "zdiff3":
+		opts.Style = git.ConflictZdiff3
+	}
+
+	pick := git.SequencerItem{Action: "pick", Commit: item.Commit}
+	result, err := repo.PickCommit(headTree, pick, opts)
+	util.Check(err)
+	err = repo.CheckoutTree(headTree, result.Tree, &git.CheckoutOptions{Command: "merge"})
+	if _, ok := err.(*git.CheckoutError); ok {
+		err = fmt.Errorf("%v\nerror: could not apply %s... %s", err, item.Commit[:7],
+			commit.Subject())
+	}
+	util.Check(err)
+	util.Check(recordConflicts(repo, result))
+
+	// Like "git", the messages of the merge are shown only on conflicts.
+	if !result.Clean() {
+		for _, message := range result.Messages {
+			fmt.Println(message.Text)
+		}
+		state.Stopped = item.Commit
+		util.Check(repo.WriteRebaseState(state))
+		msg := strings.TrimRight(commit.Msg, "\n") + "\n\n# Conflicts:\n"
+		for _, conflictPath := range result.ConflictedPaths() {
+			msg += "#\t" + conflictPath + "\n"
+		}
+		util.Check(writeStateFile(repo, "MERGE_MSG", msg))
+		util.Check(writeStateFile(repo, "REBASE_HEAD", item.Commit+"\n"))
+
+		line := fmt.Sprintf("%s... %s", item.Commit[:7], commit.Subject())
+		fmt.Printf("error: could not apply %s\n", line)
+		fmt.Println("hint: Resolve all conflicts manually, mark them as resolved with\n" +
+			"hint: \"git add/rm <conflicted_files>\", then run \"git rebase --continue\".\n" +
+			"hint: You can instead skip this commit: run \"git rebase --skip\".\n" +
+			"hint: To abort and get back to the state before \"git rebase\", run " +
+			"\"git rebase --abort\".")
+		fmt.Printf("Could not apply %s\n", line)
+		os.Exit(1)
+	}
+	cmd.commitItem(repo, state, item, result.Tree, false)
+}
+
+// commitItem commits the tree of a replayed commit on top of HEAD, keeping
+// its author. The commit of a "squash" or a "fixup" is melded into HEAD
+// instead. A commit which becomes empty is dropped.
+func (cmd *RebaseCommand) commitItem(repo *git.Repo, state *git.RebaseState,
+	item git.RebaseItem, tree string, continued bool) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	headTree, err := repo.TreeResolve(headHash)
+	util.Check(err)
+
+	obj, err := repo.ObjectParse(item.Commit)
+	util.Check(err)
+	picked, err := git.NewCommit(repo, obj)
+	util.Check(err)
+
+	action := item.Command
+	if continued {
+		action = "continue"
+	}
+
+	if item.Command == "squash" || item.Command == "fixup" {
+		cmd.meldItem(repo, state, item, tree, action)
+		return
+	}
+
+	// A commit which was empty to begin with is kept.
+	parentTree := ""
+	if parents := picked.Parents(); len(parents) > 0 {
+		parentTree, err = repo.TreeResolve(parents[0])
+		util.Check(err)
+	}
+	if tree == headTree && picked.TreeHash() != parentTree {
+		return
+	}
+
+	// Like "git", a reworded commit is committed first, and then amended with
+	// the new message.
+	parent, author := headHash, picked.Entries["author"][0]
+	msg := strings.TrimRight(picked.Msg, "\n") + "\n"
+	commit, err := git.NewCommitWithAuthor(repo, tree, []string{parent}, author, msg)
+	util.Check(err)
+	commitHash, err := repo.ObjectWrite(commit.Object, true)
+	util.Check(err)
+	moveHead(repo, commitHash, fmt.Sprintf("rebase (%s): %s", action, commit.Subject()))
+	if item.Command == "reword" {
+		msg = editMessage(repo, msg)
+		commit, err = git.NewCommitWithAuthor(repo, tree, []string{parent}, author, msg)
+		util.Check(err)
+		commitHash, err = repo.ObjectWrite(commit.Object, true)
+		util.Check(err)
+		moveHead(repo, commitHash, "rebase (reword): "+commit.Subject())
+	}
+	if continued || item.Command == "reword" {
+		printCommitSummary(repo, "", commit, commitHash, headTree)
+	}
+}
+
+// meldItem melds the tree of the commit of a "squash" or a "fixup" into the
+// commit where the chain of such commands started. The message is edited by
+// the user at the end of the chain, if there is a "squash" in it.
+func (cmd *RebaseCommand) meldItem(repo *git.Repo, state *git.RebaseState,
+	item git.RebaseItem, tree, action string) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	if len(state.Fixups) == 0 {
+		state.FixupBase = headHash
+	}
+	state.Fixups = append(state.Fixups, item)
+
+	obj, err := repo.ObjectParse(state.FixupBase)
+	util.Check(err)
+	base, err := git.NewCommit(repo, obj)
+	util.Check(err)
+
+	squash := false
+	for _, fixup := range state.Fixups {
+		squash = squash || fixup.Command == "squash"
+	}
+	last := len(state.Todo) == 0 || (state.Todo[0].Command != "squash" &&
+		state.Todo[0].Command != "fixup")
+
+	// Like "git", the commits in the middle of the chain keep the comments.
+	msg, err := repo.FixupMessage(state.FixupBase, state.Fixups)
+	util.Check(err)
+	if last && squash {
+		msg = editMessage(repo, msg)
+	} else if last {
+		msg = git.CleanupMessage(msg)
+	}
+
+	commit, err := git.NewCommitWithAuthor(repo, tree, base.Parents(),
+		base.Entries["author"][0], msg)
+	util.Check(err)
+	commitHash, err := repo.ObjectWrite(commit.Object, true)
+	util.Check(err)
+	moveHead(repo, commitHash, fmt.Sprintf("rebase (%s): %s", action, commit.Subject()))
+
+	if last {
+		state.Fixups, state.FixupBase = []git.RebaseItem{}, ""
+		if squash {
+			parentTree := ""
+			if parents := base.Parents(); len(parents) > 0 {
+				parentTree, err = repo.TreeResolve(parents[0])
+				util.Check(err)
+			}
+			printCommitSummary(repo, "", commit, commitHash, parentTree)
+		}
+	}
+	util.Check(repo.WriteRebaseState(state))
+}
+
+// finish points the rebased branch to HEAD, and checks it out again.
+func (cmd *RebaseCommand) finish(repo *git.Repo, state *git.RebaseState) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+
+	name := state.HeadName
+	if strings.HasPrefix(state.HeadName, "refs/") {
+		util.Check(repo.UpdateRef(state.HeadName, headHash))
+		util.Check(repo.WriteReflog(state.HeadName, state.OrigHead, headHash,
+			fmt.Sprintf("rebase (finish): %s onto %s", state.HeadName, state.Onto)))
+		util.Check(repo.SetHead(state.HeadName))
+		util.Check(repo.WriteReflog("HEAD", headHash, headHash,
+			"rebase (finish): returning to "+state.HeadName))
+	}
+	util.Check(repo.RemoveRebaseState())
+	fmt.Printf("\r\033[KSuccessfully rebased and updated %s.\n", name)
+}
+
+// checkCleanForRebase fails if there are local changes in the index or the
+// work-tree, which a rebase would lose.
+func checkCleanForRebase(repo *git.Repo) {
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	changes, refreshed, err := repo.DiffIndexToWorktree(index)
+	util.Check(err)
+	if refreshed {
+		util.Check(repo.WriteIndex(index))
+	}
+	if len(changes) > 0 || index.Unmerged() {
+		util.Check(errors.New("error: cannot rebase: You have unstaged changes.\n" +
+			"error: Please commit or stash them."))
+	}
+
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	headTree := ""
+	if headHash != "" {
+		headTree, err = repo.TreeResolve(headHash)
+		util.Check(err)
+	}
+	staged, err := repo.DiffTreeToIndex(headTree, index, nil)
+	util.Check(err)
+	if len(staged) > 0 {
+		util.Check(errors.New("error: cannot rebase: Your index contains uncommitted " +
+			"changes.\nerror: Please commit or stash them."))
+	}
+}
+
+// clearRebaseStop removes the state of a commit which stopped at a conflict.
+func clearRebaseStop(repo *git.Repo, state *git.RebaseState) error {
+	state.Stopped = ""
+	if err := repo.WriteRebaseState(state); err != nil {
+		return err
+	}
+	rebaseHead, err := repo.FilePath(false, "REBASE_HEAD")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(rebaseHead); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return repo.ClearMergeState()
+}
+
+// moveHead detaches HEAD at a commit, and records the move in its reflog.
+func moveHead(repo *git.Repo, commitHash, msg string) {
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	util.Check(repo.SetHead(commitHash))
+	util.Check(repo.WriteReflog("HEAD", headHash, commitHash, msg))
+}
+
+// editMessage lets the user edit a commit message, and returns it without
+// the comments.
+func editMessage(repo *git.Repo, msg string) string {
+	msgFile, err := repo.FilePath(false, "COMMIT_EDITMSG")
+	util.Check(err)
+	msg += "\n# Please enter the commit message for your changes. Lines starting\n" +
+		"# with '#' will be ignored, and an empty message aborts the commit.\n"
+	util.Check(ioutil.WriteFile(msgFile, []byte(msg), 0644))
+	util.Check(editFile(repo, msgFile, false))
+
+	data, err := ioutil.ReadFile(msgFile)
+	util.Check(err)
+	msg = git.CleanupMessage(string(data))
+	if msg == "" {
+		util.Check(errors.New("Aborting commit due to empty commit message."))
+	}
+	return msg
+}
+
+// editFile opens a file in the editor of the user. The todo list of a rebase
+// can have an editor of its own.
+func editFile(repo *git.Repo, file string, sequence bool) error {
+	config, err := repo.Config()
+	if err != nil {
+		return err
+	}
+
+	editor := ""
+	if sequence {
+		editor = os.Getenv("GIT_SEQUENCE_EDITOR")
+		if editor == "" {
+			editor, _ = config.Get("sequence.editor")
+		}
+	}
+	if editor == "" {
+		editor = os.Getenv("GIT_EDITOR")
+	}
+	if editor == "" {
+		editor, _ = config.Get("core.editor")
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor == "" {
+			editor = os.Getenv(name)
+		}
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	if editor == ":" {
+		return nil
+	}
+
+	// Like "git", the editor is run by the shell, so that it can have
+	// arguments.
+	shell := exec.Command("sh", "-c", editor+` "$@"`, editor, file)
+	shell.Stdin, shell.Stdout, shell.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := shell.Run(); err != nil {
+		return fmt.Errorf("error: There was a problem with the editor '%s'.", editor)
+	}
+	return nil
+}
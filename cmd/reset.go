@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// ResetCommand lists the components of "reset" comamnd.
+type ResetCommand struct {
+	fs    *flag.FlagSet
+	soft  bool
+	mixed bool
+	hard  bool
+	keep  bool
+	quiet bool
+	// mode is "soft", "mixed", "hard" or "keep".
+	mode     string
+	revision string
+	paths    []string
+	// dashed tells if the paths are given after a "--".
+	dashed bool
+}
+
+// NewResetCommand creates a new command object.
+func NewResetCommand() *ResetCommand {
+	fs := flag.NewFlagSet("reset", flag.ExitOnError)
+	cmd := ResetCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.soft, "soft", false, "Reset only HEAD")
+	fs.BoolVar(&cmd.mixed, "mixed", false, "Reset HEAD and the index (default)")
+	fs.BoolVar(&cmd.hard, "hard", false, "Reset HEAD, the index and the working tree")
+	fs.BoolVar(&cmd.keep, "keep", false, "Reset HEAD and the index, but keep the local changes")
+	fs.BoolVar(&cmd.quiet, "q", false, "Be quiet, only report errors")
+	fs.BoolVar(&cmd.quiet, "quiet", false, "Be quiet, only report errors")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *ResetCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *ResetCommand) Description() string {
+	return "Reset current HEAD to the specified state"
+}
+
+// Init initializes and validates the given command.
+func (cmd *ResetCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd.mode = "mixed"
+	modes := 0
+	for mode, set := range map[string]bool{
+		"soft": cmd.soft, "mixed": cmd.mixed, "hard": cmd.hard, "keep": cmd.keep,
+	} {
+		if set {
+			cmd.mode = mode
+			modes++
+		}
+	}
+	if modes > 1 {
+		return errors.New("error: Only one of --soft, --mixed, --hard and --keep " +
+			"can be given")
+	}
+
+	// Arguments after a "--" are always paths.
+	rest := cmd.fs.Args()
+	if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+		cmd.paths, cmd.dashed = rest, true
+	} else {
+		for i, arg := range rest {
+			if arg == "--" {
+				if i > 1 {
+					return errors.New("fatal: Only one <commit> can be given before '--'")
+				}
+				if i == 1 {
+					cmd.revision = rest[0]
+				}
+				cmd.paths, cmd.dashed = rest[i+1:], true
+				break
+			}
+		}
+		if !cmd.dashed {
+			cmd.paths = rest
+		}
+	}
+
+	if cmd.dashed && len(cmd.paths) == 0 && cmd.revision == "" {
+		cmd.dashed = false
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *ResetCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [--soft | --mixed | --hard | --keep] [<commit>]\n", cmd.Name())
+	fmt.Printf("   or: %s [<tree-ish>] [--] <paths>...\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *ResetCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	// Without a "--", the first argument is a commit if it resolves to one,
+	// or else all the arguments are paths.
+	if !cmd.dashed && len(cmd.paths) > 0 {
+		if _, err := repo.UniqueNameResolve(cmd.paths[0]); err == nil {
+			cmd.revision, cmd.paths = cmd.paths[0], cmd.paths[1:]
+		} else if _, err := os.Lstat(cmd.paths[0]); err != nil {
+			util.Check(fmt.Errorf("fatal: ambiguous argument '%s': unknown revision "+
+				"or path not in the working tree.\n"+
+				"Use '--' to separate paths from revisions, like this:\n"+
+				"'git <command> [<revision>...] -- [<file>...]'", cmd.paths[0]))
+		}
+	}
+
+	_, headHash, err := repo.Head()
+	util.Check(err)
+	if cmd.revision == "" {
+		cmd.revision = "HEAD"
+	}
+
+	// An unborn HEAD is reset to an empty tree.
+	commitHash, treeHash := "", ""
+	if cmd.revision != "HEAD" || headHash != "" {
+		commitHash, err = repo.UniqueNameResolve(cmd.revision)
+		if err != nil {
+			util.Check(fmt.Errorf("fatal: Failed to resolve '%s' as a valid revision.",
+				cmd.revision))
+		}
+		treeHash, err = repo.TreeResolve(commitHash)
+		util.Check(err)
+	}
+
+	// Only HEAD can be moved in a bare repo.
+	if cmd.mode == "mixed" && repo.IsBare() {
+		util.Check(errors.New("fatal: mixed reset is not allowed in a bare repository"))
+	} else if cmd.mode != "soft" {
+		util.Check(repo.RequireWorkTree())
+	}
+
+	if len(cmd.paths) > 0 {
+		cmd.resetPaths(repo, treeHash)
+		return
+	}
+
+	obj, commitHash, err := repo.PeelObject(commitHash)
+	if err == nil && obj.ObjType != "commit" {
+		err = fmt.Errorf("fatal: Could not parse object '%s'.", cmd.revision)
+	}
+	util.Check(err)
+	cmd.resetCommit(repo, headHash, commitHash, treeHash)
+}
+
+// resetPaths resets the index entries of the given paths to a tree.
+func (cmd *ResetCommand) resetPaths(repo *git.Repo, treeHash string) {
+	if cmd.mode == "soft" || cmd.mode == "hard" || cmd.mode == "keep" {
+		util.Check(fmt.Errorf("fatal: Cannot do %s reset with paths.", cmd.mode))
+	}
+
+	paths := []string{}
+	for _, arg := range cmd.paths {
+		filePath, err := repoPath(repo, arg)
+		util.Check(err)
+		paths = append(paths, filePath)
+	}
+	util.Check(repo.ResetIndex(treeHash, git.NewPathspec(paths)))
+	cmd.printUnstaged(repo)
+}
+
+// resetCommit moves the current branch (or a detached HEAD) to a commit,
+// and resets the index and the work-tree as per the mode.
+func (cmd *ResetCommand) resetCommit(repo *git.Repo, headHash, commitHash, treeHash string) {
+	_, _, err := repo.RefResolve("MERGE_HEAD")
+	inMerge := err == nil
+	if cmd.mode == "soft" {
+		index, err := repo.ReadIndex()
+		util.Check(err)
+		if index.Unmerged() || inMerge {
+			util.Check(errors.New("fatal: Cannot do a soft reset in the middle of a merge."))
+		}
+	}
+
+	headTree := ""
+	if headHash != "" {
+		headTree, err = repo.TreeResolve(headHash)
+		util.Check(err)
+	}
+
+	switch cmd.mode {
+	case "mixed":
+		util.Check(repo.ResetIndex(treeHash, nil))
+	case "hard":
+		opts := &git.CheckoutOptions{Force: true, Command: "reset"}
+		util.Check(repo.CheckoutTree(headTree, treeHash, opts))
+	case "keep":
+		opts := &git.CheckoutOptions{Command: "reset"}
+		err := repo.CheckoutTree(headTree, treeHash, opts)
+		if checkoutErr, ok := err.(*git.CheckoutError); ok {
+			for _, path := range checkoutErr.Unmerged {
+				fmt.Printf("error: Entry '%s' would be overwritten by merge. "+
+					"Cannot merge.\n", path)
+			}
+			for _, path := range checkoutErr.Changed {
+				fmt.Printf("error: Entry '%s' not uptodate. Cannot merge.\n", path)
+			}
+			for _, path := range checkoutErr.Untracked {
+				fmt.Printf("error: Untracked working tree file '%s' would be "+
+					"overwritten by merge.\n", path)
+			}
+			err = fmt.Errorf("fatal: Could not reset index file to revision '%s'.",
+				cmd.revision)
+		}
+		util.Check(err)
+		util.Check(repo.ResetIndex(treeHash, nil))
+	}
+
+	if headHash != "" {
+		util.Check(repo.WriteOrigHead(headHash))
+	}
+	util.Check(repo.UpdateRef("HEAD", commitHash))
+	util.Check(repo.ClearMergeState())
+
+	if cmd.quiet {
+		return
+	}
+	switch cmd.mode {
+	case "mixed":
+		cmd.printUnstaged(repo)
+	case "hard":
+		line, err := commitLine(repo, commitHash)
+		util.Check(err)
+		fmt.Printf("HEAD is now at %s\n", line)
+	}
+}
+
+// printUnstaged lists the changes in the work-tree which are not in the index.
+func (cmd *ResetCommand) printUnstaged(repo *git.Repo) {
+	if cmd.quiet {
+		return
+	}
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	changes, refreshed, err := repo.DiffIndexToWorktree(index)
+	util.Check(err)
+	if refreshed {
+		util.Check(repo.WriteIndex(index))
+	}
+
+	if len(changes) > 0 {
+		fmt.Println("Unstaged changes after reset:")
+	}
+	for _, change := range changes {
+		fmt.Printf("%c\t%s\n", change.Status, change.Path())
+	}
+}
+
+// fileExists tells if a file is present.
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// RestoreCommand lists the components of "restore" comamnd.
+type RestoreCommand struct {
+	fs       *flag.FlagSet
+	source   string
+	staged   bool
+	worktree bool
+	paths    []string
+}
+
+// NewRestoreCommand creates a new command object.
+func NewRestoreCommand() *RestoreCommand {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	cmd := RestoreCommand{
+		fs: fs,
+	}
+
+	fs.StringVar(&cmd.source, "s", "", "Restore the files from the given tree")
+	fs.StringVar(&cmd.source, "source", "", "Restore the files from the given tree")
+	fs.BoolVar(&cmd.staged, "S", false, "Restore the index")
+	fs.BoolVar(&cmd.staged, "staged", false, "Restore the index")
+	fs.BoolVar(&cmd.worktree, "W", false, "Restore the working tree (default)")
+	fs.BoolVar(&cmd.worktree, "worktree", false, "Restore the working tree (default)")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *RestoreCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *RestoreCommand) Description() string {
+	return "Restore working tree files"
+}
+
+// Init initializes and validates the given command.
+func (cmd *RestoreCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd.paths = cmd.fs.Args()
+	if len(cmd.paths) > 0 && cmd.paths[0] == "--" {
+		cmd.paths = cmd.paths[1:]
+	}
+	if len(cmd.paths) == 0 {
+		return errors.New("fatal: you must specify path(s) to restore")
+	}
+	if !cmd.staged {
+		cmd.worktree = true
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *RestoreCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] [--source=<tree>] [--staged] [--worktree] "+
+		"[--] <pathspec>...\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *RestoreCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	// The files are restored from the index, unless the index itself is
+	// restored, in which case HEAD is the default source.
+	opts := &git.RestoreOptions{
+		Staged:   cmd.staged,
+		Worktree: cmd.worktree,
+	}
+	source := cmd.source
+	if source == "" && cmd.staged {
+		source = "HEAD"
+	}
+	if source == "" {
+		opts.FromIndex = true
+	} else if opts.Source, err = repo.TreeResolve(source); err != nil {
+		util.Check(fmt.Errorf("fatal: could not resolve %s", source))
+	}
+
+	restorePaths(repo, cmd.paths, opts)
+}
+
+// restorePaths restores the paths given on the command line, and reports the
+// ones which didn't match any file. It returns the number of files written to
+// the work-tree.
+func restorePaths(repo *git.Repo, args []string, opts *git.RestoreOptions) int {
+	paths := []string{}
+	for _, arg := range args {
+		filePath, err := repoPath(repo, arg)
+		util.Check(err)
+		paths = append(paths, filePath)
+	}
+
+	pathspec := git.NewPathspec(paths)
+	written, err := repo.Restore(pathspec, opts)
+	if err == git.ErrPathspecUnmatched {
+		for _, i := range pathspec.Unmatched() {
+			fmt.Printf("error: pathspec '%s' did not match any file(s) known to git\n",
+				args[i])
+		}
+		os.Exit(1)
+	}
+	util.Check(err)
+	return written
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// RmCommand lists the components of "rm" comamnd.
+type RmCommand struct {
+	fs        *flag.FlagSet
+	cached    bool
+	recursive bool
+	force     bool
+	dryRun    bool
+	quiet     bool
+	paths     []string
+}
+
+// NewRmCommand creates a new command object.
+func NewRmCommand() *RmCommand {
+	fs := flag.NewFlagSet("rm", flag.ExitOnError)
+	cmd := RmCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.cached, "cached", false, "Remove the files from the index only")
+	fs.BoolVar(&cmd.recursive, "r", false, "Allow removing the directories recursively")
+	fs.BoolVar(&cmd.force, "f", false, "Remove the files even if they have local changes")
+	fs.BoolVar(&cmd.force, "force", false, "Remove the files even if they have local changes")
+	fs.BoolVar(&cmd.dryRun, "n", false, "Only show what would be removed")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "Only show what would be removed")
+	fs.BoolVar(&cmd.quiet, "q", false, "Don't show the files which are removed")
+	fs.BoolVar(&cmd.quiet, "quiet", false, "Don't show the files which are removed")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *RmCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *RmCommand) Description() string {
+	return "Remove files from the working tree and from the index"
+}
+
+// Init initializes and validates the given command.
+func (cmd *RmCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd.paths = cmd.fs.Args()
+	if len(cmd.paths) == 0 {
+		return errors.New("fatal: No pathspec was given. Which files should I remove?")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *RmCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [-f] [-n] [-r] [--cached] [-q] [--] <pathspec>...\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *RmCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	paths := []string{}
+	for _, arg := range cmd.paths {
+		filePath, err := repoPath(repo, arg)
+		util.Check(err)
+		paths = append(paths, filePath)
+	}
+
+	pathspec := git.NewPathspec(paths)
+	opts := &git.RemoveOptions{
+		Cached:    cmd.cached,
+		Recursive: cmd.recursive,
+		Force:     cmd.force,
+		DryRun:    cmd.dryRun,
+	}
+	removed, err := repo.RemovePaths(pathspec, opts)
+	if err == git.ErrPathspecUnmatched {
+		fmt.Printf("fatal: pathspec '%s' did not match any files\n",
+			cmd.paths[pathspec.Unmatched()[0]])
+		os.Exit(1)
+	}
+	util.Check(err)
+
+	if !cmd.quiet {
+		for _, filePath := range removed {
+			fmt.Printf("rm '%s'\n", filePath)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// ShowCommand lists the components of "show" comamnd.
+type ShowCommand struct {
+	fs        *flag.FlagSet
+	diffFlags diffFlags
+	objects   []string
+}
+
+// NewShowCommand creates a new command object.
+func NewShowCommand() *ShowCommand {
+	cmd := &ShowCommand{
+		fs:        flag.NewFlagSet("show", flag.ExitOnError),
+		diffFlags: diffFlags{defaultRenames: true},
+	}
+
+	cmd.diffFlags.register(cmd.fs)
+	return cmd
+}
+
+// Name gives the name of the command.
+func (cmd *ShowCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *ShowCommand) Description() string {
+	return "Show various types of objects"
+}
+
+// Init initializes and validates the given command.
+func (cmd *ShowCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(expandScoreArgs(args)); err != nil {
+		return err
+	}
+
+	cmd.objects = cmd.fs.Args()
+	if len(cmd.objects) == 0 {
+		cmd.objects = []string{"HEAD"}
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *ShowCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] [<object>...]\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *ShowCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	opts := cmd.diffFlags.options(repo)
+	for i, name := range cmd.objects {
+		objHash, err := repo.UniqueNameResolve(name)
+		util.Check(err)
+		obj, err := repo.ObjectParse(objHash)
+		util.Check(err)
+
+		// Put a new line between two successive objects, unless they are
+		// shown as their raw content.
+		if i > 0 && obj.ObjType != "blob" {
+			fmt.Println()
+		}
+
+		// Tags are shown along with the object they point to.
+		for obj.ObjType == "tag" {
+			tag, err := git.NewTag(repo, obj)
+			util.Check(err)
+			fmt.Println(tag.PrettyPrint())
+
+			objHash = tag.Target()
+			obj, err = repo.ObjectParse(objHash)
+			util.Check(err)
+		}
+
+		switch obj.ObjType {
+		case "commit":
+			commit, err := git.NewCommit(repo, obj)
+			util.Check(err)
+			output, err := cmd.showCommit(repo, commit, opts)
+			util.Check(err)
+			fmt.Print(output)
+		case "tree":
+			tree, err := git.NewTree(repo, obj)
+			util.Check(err)
+			fmt.Printf("tree %s\n\n", name)
+			for _, entry := range tree.Entries {
+				if entry.Type() == "tree" {
+					fmt.Printf("%s/\n", entry.Name())
+				} else {
+					fmt.Println(entry.Name())
+				}
+			}
+		case "blob":
+			fmt.Print(string(obj.ObjData))
+		}
+	}
+}
+
+// showCommit returns a commit in the format of "git log", followed by its
+// changes as compared with its first parent. The changes of a merge commit
+// are shown as a combined diff against all its parents.
+func (cmd *ShowCommand) showCommit(repo *git.Repo, commit *git.Commit,
+	opts *git.DiffOptions) (string, error) {
+	var b strings.Builder
+	commitStr, err := commit.PrettyPrint()
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(commitStr)
+	if !strings.HasSuffix(commitStr, "\n") {
+		fmt.Fprintln(&b)
+	}
+
+	parents := commit.Parents()
+	parentHash := ""
+	if len(parents) > 0 {
+		parentHash = parents[0]
+	}
+
+	output := ""
+	onlyPatch := !cmd.diffFlags.hasFormat() ||
+		(cmd.diffFlags.patch && !cmd.diffFlags.stat.set && !cmd.diffFlags.numstat &&
+			!cmd.diffFlags.shortstat && !cmd.diffFlags.dirstat.set &&
+			!cmd.diffFlags.raw && !cmd.diffFlags.nameOnly && !cmd.diffFlags.nameStatus)
+	if len(parents) > 1 && onlyPatch {
+		parentTrees := []string{}
+		for _, parent := range parents {
+			parentTree, err := repo.TreeResolve(parent)
+			if err != nil {
+				return "", err
+			}
+			parentTrees = append(parentTrees, parentTree)
+		}
+		output, err = repo.CombinedDiff(parentTrees, commit.TreeHash(), opts)
+	} else {
+		output, err = commitDiff(repo, commit, parentHash, &cmd.diffFlags, opts, "patch")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// A merge is always followed by a new line, like "git show" does.
+	if output != "" || (len(parents) > 1 && onlyPatch) {
+		fmt.Fprintln(&b)
+		b.WriteString(output)
+	}
+	return b.String(), nil
+}
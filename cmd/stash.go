@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// stashFlags lists the flags allowed with each subcommand of "stash", other
+// than the diff flags of "stash show".
+var stashFlags = map[string][]string{
+	"push":   {"u", "include-untracked", "m", "message"},
+	"apply":  {"index"},
+	"pop":    {"index"},
+	"list":   {},
+	"show":   {},
+	"drop":   {},
+	"clear":  {},
+	"branch": {},
+}
+
+// StashCommand lists the components of "stash" comamnd.
+type StashCommand struct {
+	fs               *flag.FlagSet
+	diffFlags        diffFlags
+	subcommand       string
+	includeUntracked bool
+	message          string
+	index            bool
+	args             []string
+}
+
+// NewStashCommand creates a new command object.
+func NewStashCommand() *StashCommand {
+	fs := flag.NewFlagSet("stash", flag.ExitOnError)
+	cmd := StashCommand{
+		fs:        fs,
+		diffFlags: diffFlags{defaultRenames: true},
+	}
+
+	fs.BoolVar(&cmd.includeUntracked, "u", false,
+		"(push) Stash the untracked files too, and remove them")
+	fs.BoolVar(&cmd.includeUntracked, "include-untracked", false,
+		"(push) Stash the untracked files too, and remove them")
+	fs.StringVar(&cmd.message, "m", "", "(push) Describe the stash entry with a message")
+	fs.StringVar(&cmd.message, "message", "",
+		"(push) Describe the stash entry with a message")
+	fs.BoolVar(&cmd.index, "index", false,
+		"(apply, pop) Restore the changes of the index as well")
+	cmd.diffFlags.register(fs)
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *StashCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *StashCommand) Description() string {
+	return "Stash the changes in a dirty working directory away"
+}
+
+// Init initializes and validates the given command.
+func (cmd *StashCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+
+	// Without a subcommand, the changes are pushed.
+	cmd.subcommand = "push"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd.subcommand, args = args[0], args[1:]
+	}
+	allowed, ok := stashFlags[cmd.subcommand]
+	if !ok {
+		return fmt.Errorf("error: unknown subcommand: `%s'", cmd.subcommand)
+	}
+	if err := cmd.fs.Parse(expandScoreArgs(args)); err != nil {
+		return err
+	}
+	cmd.args = cmd.fs.Args()
+
+	// The diff flags are for "show" only.
+	var err error
+	cmd.fs.Visit(func(f *flag.Flag) {
+		if cmd.subcommand == "show" && !strings.HasPrefix(f.Usage, "(") {
+			return
+		}
+		for _, name := range allowed {
+			if f.Name == name {
+				return
+			}
+		}
+		err = fmt.Errorf("error: unknown option `%s' for 'stash %s'", f.Name, cmd.subcommand)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Other than "push" with its paths, at most a stash entry can be named.
+	maxArgs := 1
+	switch cmd.subcommand {
+	case "push":
+		maxArgs = len(cmd.args)
+	case "list", "clear":
+		maxArgs = 0
+	case "branch":
+		maxArgs = 2
+	}
+	if len(cmd.args) > maxArgs {
+		return fmt.Errorf("error: Too many revisions specified: %s",
+			strings.Join(cmd.args, " "))
+	}
+	if cmd.subcommand == "branch" && len(cmd.args) == 0 {
+		return errors.New("No branch name specified")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *StashCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [push [-u] [-m <message>] [--] [<pathspec>...]]\n", cmd.Name())
+	fmt.Printf("   or: %s (apply | pop) [--index] [<stash>]\n", cmd.Name())
+	fmt.Printf("   or: %s show [-p] [<stash>]\n", cmd.Name())
+	fmt.Printf("   or: %s (list | clear)\n", cmd.Name())
+	fmt.Printf("   or: %s drop [<stash>]\n", cmd.Name())
+	fmt.Printf("   or: %s branch <branchname> [<stash>]\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *StashCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	switch cmd.subcommand {
+	case "push":
+		cmd.push(repo)
+	case "list":
+		entries, err := repo.StashList()
+		util.Check(err)
+		for i, entry := range entries {
+			fmt.Printf("stash@{%d}: %s\n", i, entry.Message)
+		}
+	case "show":
+		cmd.show(repo)
+	case "apply", "pop":
+		cmd.apply(repo, cmd.stashName(0), cmd.index, cmd.subcommand == "pop")
+	case "drop":
+		_, pos, err := repo.ResolveStash(cmd.stashName(0))
+		util.Check(err)
+		cmd.drop(repo, cmd.stashName(0), pos)
+	case "clear":
+		util.Check(repo.ClearStash())
+	case "branch":
+		cmd.branch(repo)
+	}
+}
+
+// stashName returns the argument at a position which names a stash entry, or
+// an empty name for the latest entry.
+func (cmd *StashCommand) stashName(pos int) string {
+	if pos < len(cmd.args) {
+		return cmd.args[pos]
+	}
+	return ""
+}
+
+// push saves the local changes in a new stash entry, and removes them.
+func (cmd *StashCommand) push(repo *git.Repo) {
+	opts := &git.StashOptions{Message: cmd.message, IncludeUntracked: cmd.includeUntracked}
+	if len(cmd.args) > 0 {
+		paths := []string{}
+		for _, arg := range cmd.args {
+			filePath, err := repoPath(repo, arg)
+			util.Check(err)
+			paths = append(paths, filePath)
+		}
+		opts.Pathspec = git.NewPathspec(paths)
+	}
+
+	stashHash, err := repo.PushStash(opts)
+	if err == git.ErrPathspecUnmatched {
+		for _, i := range opts.Pathspec.Unmatched() {
+			fmt.Printf("error: pathspec '%s' did not match any file(s) known to git\n",
+				cmd.args[i])
+		}
+		fmt.Println("Did you forget to 'git add'?")
+		os.Exit(1)
+	}
+	util.Check(err)
+
+	if stashHash == "" {
+		fmt.Println("No local changes to save")
+		return
+	}
+	obj, err := repo.ObjectParse(stashHash)
+	util.Check(err)
+	commit, err := git.NewCommit(repo, obj)
+	util.Check(err)
+	fmt.Printf("Saved working directory and index state %s\n", commit.Subject())
+}
+
+// show prints the changes recorded in a stash entry, as a diffstat unless
+// some other format is asked for.
+func (cmd *StashCommand) show(repo *git.Repo) {
+	stashHash, _, err := repo.ResolveStash(cmd.stashName(0))
+	util.Check(err)
+	stash, err := repo.ParseStash(stashHash)
+	util.Check(err)
+
+	entries, err := repo.DiffTrees(stash.BaseTree, stash.Tree, cmd.diffFlags.options(repo))
+	util.Check(err)
+	if !cmd.diffFlags.hasFormat() {
+		cmd.diffFlags.stat.set = true
+	}
+	output, err := cmd.diffFlags.format(repo, entries, "")
+	util.Check(err)
+	fmt.Print(output)
+}
+
+// apply merges the changes of a stash entry into the index and the
+// work-tree. The changes of the index are restored in the index only if
+// asked for. The entry is dropped after a successful pop.
+func (cmd *StashCommand) apply(repo *git.Repo, name string, restoreIndex, pop bool) {
+	stashHash, pos, err := repo.ResolveStash(name)
+	util.Check(err)
+	stash, err := repo.ParseStash(stashHash)
+	util.Check(err)
+
+	// failed keeps the entry, and exits.
+	failed := func() {
+		if cmd.subcommand == "pop" {
+			fmt.Println("The stash entry is kept in case you need it again.")
+		}
+		os.Exit(1)
+	}
+
+	index, err := repo.ReadIndex()
+	util.Check(err)
+	if index.Unmerged() {
+		for i, entry := range index.Entries {
+			if entry.Stage() != 0 && (i == 0 || index.Entries[i-1].Path != entry.Path) {
+				fmt.Printf("%s: needs merge\n", entry.Path)
+			}
+		}
+		failed()
+	}
+	currentTree, err := repo.WriteTree(index.Files())
+	util.Check(err)
+
+	config, err := repo.Config()
+	util.Check(err)
+	opts := &git.MergeOptions{
+		OursLabel:   "Updated upstream",
+		BaseLabel:   "Stash base",
+		TheirsLabel: "Stashed changes",
+	}
+	switch style, _ := config.Get("merge.conflictStyle"); style {
+	case "diff3":
+		opts.Style = git.ConflictDiff3
+	case "zdiff3":
+		opts.Style = git.ConflictZdiff3
+	}
+
+	// The changes of the index must apply cleanly to the current index.
+	indexTree := ""
+	if restoreIndex && stash.IndexTree != stash.BaseTree {
+		result, err := repo.MergeTrees(stash.BaseTree, currentTree, stash.IndexTree, opts)
+		util.Check(err)
+		if !result.Clean() {
+			fmt.Println("error: conflicts in index. Try without --index.")
+			failed()
+		}
+		indexTree = result.Tree
+	}
+
+	result, err := repo.MergeTrees(stash.BaseTree, currentTree, stash.Tree, opts)
+	util.Check(err)
+	err = repo.CheckoutTree(currentTree, result.Tree, &git.CheckoutOptions{Command: "merge"})
+	if _, ok := err.(*git.CheckoutError); ok {
+		fmt.Println(err)
+		if indexTree != "" {
+			fmt.Println("Index was not unstashed.")
+		}
+		restoreUntracked(repo, stash)
+		printStashStatus(repo)
+		failed()
+	}
+	util.Check(err)
+	util.Check(recordConflicts(repo, result))
+	for _, message := range result.Messages {
+		fmt.Println(message.Text)
+	}
+	if !result.Clean() && indexTree != "" {
+		fmt.Println("Index was not unstashed.")
+	}
+
+	// Only the files added by the stash are left in the index, unless the
+	// index is restored too.
+	if result.Clean() {
+		if indexTree != "" {
+			util.Check(repo.ResetIndex(indexTree, nil))
+		} else {
+			added, err := repo.DiffTrees(currentTree, result.Tree, nil)
+			util.Check(err)
+			util.Check(repo.ResetIndex(currentTree, nil))
+			index, err := repo.ReadIndex()
+			util.Check(err)
+			for _, change := range added {
+				if change.Status != git.DiffAdded {
+					continue
+				}
+				entry, err := git.NewIndexEntry(change.New.Path, change.New.Mode, change.New.Hash)
+				util.Check(err)
+				index.Add(entry)
+			}
+			util.Check(repo.WriteIndex(index))
+		}
+	}
+
+	restored := restoreUntracked(repo, stash)
+	printStashStatus(repo)
+	if !result.Clean() || !restored {
+		failed()
+	}
+	if pop {
+		cmd.drop(repo, name, pos)
+	}
+}
+
+// drop removes the stash entry at a position.
+func (cmd *StashCommand) drop(repo *git.Repo, name string, pos int) {
+	stashHash, _, err := repo.ResolveStash(fmt.Sprint(pos))
+	util.Check(err)
+	util.Check(repo.DropStash(pos))
+
+	// The entry is named as given, if it is named by its reflog.
+	if !strings.HasPrefix(name, "stash@{") {
+		name = fmt.Sprintf("refs/stash@{%d}", pos)
+	}
+	fmt.Printf("Dropped %s (%s)\n", name, stashHash)
+}
+
+// branch creates a branch at the commit where a stash entry was created,
+// switches to it and pops the entry there.
+func (cmd *StashCommand) branch(repo *git.Repo) {
+	name := cmd.args[0]
+	stashHash, _, err := repo.ResolveStash(cmd.stashName(1))
+	util.Check(err)
+	stash, err := repo.ParseStash(stashHash)
+	util.Check(err)
+
+	branch := "refs/heads/" + name
+	if hash, _, err := repo.RefResolve(branch); err == nil && hash != "" {
+		util.Check(fmt.Errorf("fatal: a branch named '%s' already exists", name))
+	}
+	util.Check(repo.UpdateRef(branch, stash.Base))
+	target := &switchTarget{
+		name:    name,
+		branch:  branch,
+		ref:     branch,
+		commit:  stash.Base,
+		created: true,
+	}
+	util.Check(switchBranch(repo, target, false, false))
+	cmd.apply(repo, cmd.stashName(1), true, true)
+}
+
+// restoreUntracked writes the untracked files of a stash entry, and tells
+// if all of them got written.
+func restoreUntracked(repo *git.Repo, stash *git.Stash) bool {
+	err := repo.RestoreUntracked(stash)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return err == nil
+}
+
+// printStashStatus shows the status of the work-tree after applying a stash.
+func printStashStatus(repo *git.Repo) {
+	opts := &git.StatusOptions{
+		Untracked:     "normal",
+		DetectRenames: true,
+		RenameScore:   git.DefaultRenameScore,
+		RenameLimit:   git.DefaultRenameLimit,
+	}
+	status, err := repo.Status(opts)
+	util.Check(err)
+	fmt.Print(formatLongStatus(repo, status, opts, cwdPrefix(repo)))
+}
@@ -0,0 +1,747 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// StatusCommand lists the components of "status" comamnd.
+type StatusCommand struct {
+	fs        *flag.FlagSet
+	short     bool
+	long      bool
+	porcelain optionalFlag
+	branch    bool
+	nulTerm   bool
+	untracked optionalFlag
+	ignored   bool
+	noRenames bool
+	// format is "long", "short" or "porcelain" (v1) or "porcelain-v2".
+	format string
+}
+
+// NewStatusCommand creates a new command object.
+func NewStatusCommand() *StatusCommand {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	cmd := StatusCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.short, "s", false, "Give the output in the short-format")
+	fs.BoolVar(&cmd.short, "short", false, "Give the output in the short-format")
+	fs.BoolVar(&cmd.long, "long", false, "Give the output in the long-format (default)")
+	fs.Var(&cmd.porcelain, "porcelain",
+		"Give the output in a stable format for scripts (--porcelain=v1|v2)")
+	fs.BoolVar(&cmd.branch, "b", false, "Show the branch and tracking info in short-format")
+	fs.BoolVar(&cmd.branch, "branch", false, "Show the branch and tracking info in short-format")
+	fs.BoolVar(&cmd.nulTerm, "z", false, "Terminate entries with NUL, instead of LF")
+	fs.Var(&cmd.untracked, "u", "Show untracked files (-u=no|normal|all)")
+	fs.Var(&cmd.untracked, "untracked-files", "Show untracked files (no|normal|all)")
+	fs.BoolVar(&cmd.ignored, "ignored", false, "Show ignored files as well")
+	fs.BoolVar(&cmd.noRenames, "no-renames", false, "Do not detect renames")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *StatusCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *StatusCommand) Description() string {
+	return "Show the working tree status"
+}
+
+// Init initializes and validates the given command.
+func (cmd *StatusCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+
+	// Allow the "git" style "-uno" argument.
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-u") && len(arg) > 2 && arg[2] != '=' {
+			args[i] = "-u=" + arg[2:]
+		}
+	}
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cmd.fs.NArg() > 0 {
+		return errors.New("error: Pathspecs are not supported")
+	}
+
+	switch cmd.untracked.value {
+	case "", "no", "normal", "all":
+	default:
+		return fmt.Errorf("fatal: Invalid untracked files mode '%s'", cmd.untracked.value)
+	}
+
+	switch {
+	case cmd.porcelain.set:
+		switch cmd.porcelain.value {
+		case "", "v1":
+			cmd.format = "porcelain"
+		case "v2":
+			cmd.format = "porcelain-v2"
+		default:
+			return fmt.Errorf("fatal: unsupported porcelain version '%s'",
+				cmd.porcelain.value)
+		}
+	case cmd.short:
+		cmd.format = "short"
+	case cmd.long:
+		cmd.format = "long"
+	case cmd.nulTerm:
+		cmd.format = "porcelain"
+	default:
+		cmd.format = "long"
+	}
+
+	if cmd.format == "long" && cmd.nulTerm {
+		return errors.New("fatal: -z is not supported with the long-format")
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *StatusCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>]\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *StatusCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+	config, err := repo.Config()
+	util.Check(err)
+
+	opts := &git.StatusOptions{
+		Untracked:   cmd.untracked.value,
+		Ignored:     cmd.ignored,
+		RenameScore: git.DefaultRenameScore,
+	}
+	if !cmd.untracked.set {
+		opts.Untracked, _ = config.Get("status.showUntrackedFiles")
+	}
+	if opts.Untracked == "" {
+		opts.Untracked = "normal"
+		if cmd.untracked.set {
+			opts.Untracked = "all"
+		}
+	}
+	if !cmd.noRenames {
+		opts.DetectRenames = config.GetBool("status.renames",
+			config.GetBool("diff.renames", true))
+	}
+	opts.RenameLimit = config.GetInt("status.renameLimit",
+		config.GetInt("diff.renameLimit", git.DefaultRenameLimit))
+
+	status, err := repo.Status(opts)
+	util.Check(err)
+
+	// Paths are shown relative to the current directory, except for the
+	// porcelain formats.
+	prefix := cwdPrefix(repo)
+
+	switch cmd.format {
+	case "long":
+		fmt.Print(formatLongStatus(repo, status, opts, prefix))
+	case "short":
+		fmt.Print(cmd.formatShortStatus(status, prefix))
+	case "porcelain":
+		fmt.Print(cmd.formatShortStatus(status, ""))
+	case "porcelain-v2":
+		fmt.Print(cmd.formatPorcelainV2Status(status))
+	}
+}
+
+// cwdPrefix returns the path of the current directory relative to the top
+// of the work-tree, or an empty path at the top.
+func cwdPrefix(repo *git.Repo) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(repo.WorkTree, cwd); err == nil && rel != "." {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ""
+}
+
+// shortRef removes the "refs/heads/" or "refs/remotes/" prefix of a reference.
+// Example: "refs/remotes/origin/master" gives "origin/master".
+func shortRef(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+	return ref
+}
+
+// relativePath converts a path from the top of the work-tree to a path from
+// the directory 'prefix'. A trailing '/' of a directory is kept.
+func relativePath(path, prefix string) string {
+	if prefix == "" {
+		return path
+	}
+
+	rel, err := filepath.Rel(filepath.FromSlash("/"+prefix), filepath.FromSlash("/"+path))
+	if err != nil {
+		return path
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(path, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// quotePath quotes a path with unusual characters in the "C" style, like
+// "git" does with the default "core.quotePath" setting.
+func quotePath(path string) string {
+	quote := false
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			quote = true
+			break
+		}
+	}
+	if !quote {
+		return path
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// statusLabels are the descriptions of the changes in the long-format.
+var statusLabels = map[byte]string{
+	git.DiffAdded:    "new file:",
+	git.DiffCopied:   "copied:",
+	git.DiffDeleted:  "deleted:",
+	git.DiffModified: "modified:",
+	git.DiffRenamed:  "renamed:",
+	git.DiffType:     "typechange:",
+}
+
+// unmergedLabels are the descriptions of the conflicts in the long-format.
+var unmergedLabels = map[string]string{
+	"DD": "both deleted:",
+	"AU": "added by us:",
+	"UD": "deleted by them:",
+	"UA": "added by them:",
+	"DU": "deleted by us:",
+	"AA": "both added:",
+	"UU": "both modified:",
+}
+
+// isUnmerged tells if a status entry is a conflicted path.
+func isUnmerged(entry *git.StatusEntry) bool {
+	_, ok := unmergedLabels[string([]byte{entry.Staged, entry.Unstaged})]
+	return ok
+}
+
+// trackingInfo describes how the current branch compares with its upstream,
+// in the long-format.
+func trackingInfo(status *git.Status) string {
+	if status.Upstream == "" || status.Head == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	upstream := shortRef(status.Upstream)
+	commits := func(count int) string {
+		if count == 1 {
+			return "1 commit"
+		}
+		return fmt.Sprintf("%d commits", count)
+	}
+
+	switch {
+	case status.UpstreamGone:
+		fmt.Fprintf(&b, "Your branch is based on '%s', but the upstream is gone.\n", upstream)
+		fmt.Fprintf(&b, "  (use \"git branch --unset-upstream\" to fixup)\n")
+	case status.Ahead == 0 && status.Behind == 0:
+		fmt.Fprintf(&b, "Your branch is up to date with '%s'.\n", upstream)
+	case status.Behind == 0:
+		fmt.Fprintf(&b, "Your branch is ahead of '%s' by %s.\n", upstream, commits(status.Ahead))
+		fmt.Fprintf(&b, "  (use \"git push\" to publish your local commits)\n")
+	case status.Ahead == 0:
+		fmt.Fprintf(&b, "Your branch is behind '%s' by %s, and can be fast-forwarded.\n",
+			upstream, commits(status.Behind))
+		fmt.Fprintf(&b, "  (use \"git pull\" to update your local branch)\n")
+	default:
+		fmt.Fprintf(&b, "Your branch and '%s' have diverged,\n"+
+			"and have %d and %d different commits each, respectively.\n",
+			upstream, status.Ahead, status.Behind)
+		fmt.Fprintf(&b, "  (use \"git pull\" to merge the remote branch into yours)\n")
+	}
+	return b.String()
+}
+
+// formatLongStatus returns the status in the human readable long-format.
+func formatLongStatus(repo *git.Repo, status *git.Status, opts *git.StatusOptions,
+	prefix string) string {
+	rebase, err := repo.ReadRebaseState()
+	util.Check(err)
+
+	var b strings.Builder
+	if status.Branch != "" {
+		fmt.Fprintf(&b, "On branch %s\n", shortRef(status.Branch))
+	} else if rebase != nil {
+		fmt.Fprint(&b, rebaseState(repo, rebase, status))
+	} else {
+		fmt.Fprintf(&b, "HEAD detached at %s\n", status.Head[:7])
+	}
+	if tracking := trackingInfo(status); tracking != "" {
+		fmt.Fprintf(&b, "%s\n", tracking)
+	}
+
+	// The hints to unstage are not shown while a merge is going on.
+	inMerge := false
+	for _, name := range []string{"MERGE_HEAD", "CHERRY_PICK_HEAD", "REVERT_HEAD"} {
+		if _, _, err := repo.RefResolve(name); err == nil {
+			inMerge = true
+		}
+	}
+	if _, _, err := repo.RefResolve("MERGE_HEAD"); err == nil {
+		fmt.Fprint(&b, mergeState(status))
+	}
+	fmt.Fprint(&b, pickState(repo, status))
+
+	if status.Head == "" {
+		fmt.Fprintf(&b, "\nNo commits yet\n\n")
+	}
+
+	staged, unmerged, unstaged := []string{}, []string{}, []string{}
+	hasDeleted, bothDeleted, notDeleted, deleteConflict := false, false, false, false
+	for i := range status.Entries {
+		entry := &status.Entries[i]
+		path := quotePath(relativePath(entry.Path, prefix))
+		if isUnmerged(entry) {
+			code := string([]byte{entry.Staged, entry.Unstaged})
+			unmerged = append(unmerged, fmt.Sprintf("\t%-17s%s", unmergedLabels[code], path))
+			bothDeleted = bothDeleted || code == "DD"
+			notDeleted = notDeleted || code != "DD"
+			deleteConflict = deleteConflict || code == "DU" || code == "UD"
+			continue
+		}
+
+		if entry.Staged != ' ' {
+			line := fmt.Sprintf("\t%-12s%s", statusLabels[entry.Staged], path)
+			if entry.OrigPath != "" {
+				line = fmt.Sprintf("\t%-12s%s -> %s", statusLabels[entry.Staged],
+					quotePath(relativePath(entry.OrigPath, prefix)), path)
+			}
+			staged = append(staged, line)
+		}
+		if entry.Unstaged != ' ' {
+			unstaged = append(unstaged, fmt.Sprintf("\t%-12s%s",
+				statusLabels[entry.Unstaged], path))
+			hasDeleted = hasDeleted || entry.Unstaged == git.DiffDeleted
+		}
+	}
+
+	section := func(title string, hints []string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", title)
+		for _, hint := range hints {
+			fmt.Fprintln(&b, hint)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(&b, line)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	unstageHints := []string{}
+	if !inMerge {
+		unstageHint := "  (use \"git restore --staged <file>...\" to unstage)"
+		if status.Head == "" {
+			unstageHint = "  (use \"git rm --cached <file>...\" to unstage)"
+		}
+		unstageHints = append(unstageHints, unstageHint)
+	}
+	section("Changes to be committed", unstageHints, staged)
+
+	unmergedHints := append([]string{}, unstageHints...)
+	switch {
+	case !bothDeleted && !deleteConflict:
+		unmergedHints = append(unmergedHints, "  (use \"git add <file>...\" to mark resolution)")
+	case bothDeleted && !deleteConflict && !notDeleted:
+		unmergedHints = append(unmergedHints, "  (use \"git rm <file>...\" to mark resolution)")
+	default:
+		unmergedHints = append(unmergedHints,
+			"  (use \"git add/rm <file>...\" as appropriate to mark resolution)")
+	}
+	section("Unmerged paths", unmergedHints, unmerged)
+
+	addHint := "  (use \"git add <file>...\" to update what will be committed)"
+	if hasDeleted {
+		addHint = "  (use \"git add/rm <file>...\" to update what will be committed)"
+	}
+	section("Changes not staged for commit", []string{addHint,
+		"  (use \"git restore <file>...\" to discard changes in working directory)"},
+		unstaged)
+
+	untracked := []string{}
+	for _, path := range status.Untracked {
+		untracked = append(untracked, "\t"+quotePath(relativePath(path, prefix)))
+	}
+	section("Untracked files",
+		[]string{"  (use \"git add <file>...\" to include in what will be committed)"},
+		untracked)
+
+	ignored := []string{}
+	for _, path := range status.Ignored {
+		ignored = append(ignored, "\t"+quotePath(relativePath(path, prefix)))
+	}
+	section("Ignored files",
+		[]string{"  (use \"git add -f <file>...\" to include in what will be committed)"},
+		ignored)
+
+	if opts.Untracked == "no" {
+		fmt.Fprintf(&b, "Untracked files not listed (use -u option to show untracked files)\n")
+	}
+
+	switch {
+	case len(staged) > 0:
+	case len(unstaged) > 0 || len(unmerged) > 0:
+		fmt.Fprintf(&b, "no changes added to commit (use \"git add\" and/or \"git commit -a\")\n")
+	case len(untracked) > 0:
+		fmt.Fprintf(&b, "nothing added to commit but untracked files present "+
+			"(use \"git add\" to track)\n")
+	case status.Head == "":
+		fmt.Fprintf(&b, "nothing to commit (create/copy files and use \"git add\" to track)\n")
+	case opts.Untracked == "no":
+		fmt.Fprintf(&b, "nothing to commit (use -u to show untracked files)\n")
+	default:
+		fmt.Fprintf(&b, "nothing to commit, working tree clean\n")
+	}
+
+	return b.String()
+}
+
+// mergeState describes a merge which is going on, in the long-format.
+func mergeState(status *git.Status) string {
+	for i := range status.Entries {
+		if isUnmerged(&status.Entries[i]) {
+			return "You have unmerged paths.\n" +
+				"  (fix conflicts and run \"git commit\")\n" +
+				"  (use \"git merge --abort\" to abort the merge)\n\n"
+		}
+	}
+	return "All conflicts fixed but you are still merging.\n" +
+		"  (use \"git commit\" to conclude merge)\n\n"
+}
+
+// pickState describes a cherry-pick or a revert which is going on, in the
+// long-format.
+func pickState(repo *git.Repo, status *git.Status) string {
+	seq, err := repo.ReadSequencer()
+	util.Check(err)
+	name, verb := "cherry-pick", "cherry-picking"
+	switch pickAction(repo, seq) {
+	case "":
+		return ""
+	case "revert":
+		name, verb = "revert", "reverting"
+	}
+	commitHash := pickHead(repo)
+
+	var b strings.Builder
+	if seq != nil || commitHash == "" {
+		fmt.Fprintf(&b, "%s%s currently in progress.\n", strings.ToUpper(name[:1]), name[1:])
+	} else {
+		fmt.Fprintf(&b, "You are currently %s commit %s.\n", verb, commitHash[:7])
+	}
+	resolved := true
+	for i := range status.Entries {
+		resolved = resolved && !isUnmerged(&status.Entries[i])
+	}
+	if resolved {
+		fmt.Fprintf(&b, "  (all conflicts fixed: run \"git %s --continue\")\n", name)
+	} else {
+		fmt.Fprintf(&b, "  (fix conflicts and run \"git %s --continue\")\n", name)
+	}
+	fmt.Fprintf(&b, "  (use \"git %s --skip\" to skip this patch)\n", name)
+	fmt.Fprintf(&b, "  (use \"git %[1]s --abort\" to cancel the %[1]s operation)\n\n", name)
+	return b.String()
+}
+
+// rebaseState describes a rebase which is going on, in the long-format. It
+// lists the last commands done and the next ones to do.
+func rebaseState(repo *git.Repo, state *git.RebaseState, status *git.Status) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "interactive rebase in progress; onto %s\n", state.Onto[:7])
+	commands := func(n int) string {
+		if n == 1 {
+			return "1 command"
+		}
+		return fmt.Sprintf("%d commands", n)
+	}
+	list := func(items []git.RebaseItem) {
+		todo, err := repo.FormatRebaseTodo(items, true)
+		util.Check(err)
+		for _, line := range strings.Split(strings.TrimSuffix(todo, "\n"), "\n") {
+			fmt.Fprintf(&b, "   %s\n", line)
+		}
+	}
+
+	if done := state.Done; len(done) > 0 {
+		plural := "s"
+		if len(done) == 1 {
+			plural = ""
+		}
+		fmt.Fprintf(&b, "Last command%s done (%s done):\n", plural, commands(len(done)))
+		if len(done) > 2 {
+			done = done[len(done)-2:]
+		}
+		list(done)
+		if len(state.Done) > 2 {
+			fmt.Fprintf(&b, "  (see more in file .git/rebase-merge/done)\n")
+		}
+	}
+	if todo := state.Todo; len(todo) == 0 {
+		fmt.Fprintf(&b, "No commands remaining.\n")
+	} else {
+		plural := "s"
+		if len(todo) == 1 {
+			plural = ""
+		}
+		fmt.Fprintf(&b, "Next command%s to do (%s remaining command%s):\n", plural,
+			strings.TrimSuffix(commands(len(todo)), " command"+plural), plural)
+		if len(todo) > 2 {
+			todo = todo[:2]
+		}
+		list(todo)
+		fmt.Fprintf(&b, "  (use \"git rebase --edit-todo\" to view and edit)\n")
+	}
+
+	branch := strings.TrimPrefix(state.HeadName, "refs/heads/")
+	if state.Stopped == "" {
+		fmt.Fprintf(&b, "You are currently editing a commit while rebasing branch '%s' "+
+			"on '%s'.\n", branch, state.Onto[:7])
+		fmt.Fprintf(&b, "  (use \"git commit --amend\" to amend the current commit)\n")
+		fmt.Fprintf(&b, "  (use \"git rebase --continue\" once you are satisfied with "+
+			"your changes)\n\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "You are currently rebasing branch '%s' on '%s'.\n", branch,
+		state.Onto[:7])
+	resolved := true
+	for i := range status.Entries {
+		resolved = resolved && !isUnmerged(&status.Entries[i])
+	}
+	if resolved {
+		fmt.Fprintf(&b, "  (all conflicts fixed: run \"git rebase --continue\")\n")
+	} else {
+		fmt.Fprintf(&b, "  (fix conflicts and then run \"git rebase --continue\")\n")
+		fmt.Fprintf(&b, "  (use \"git rebase --skip\" to skip this patch)\n")
+		fmt.Fprintf(&b, "  (use \"git rebase --abort\" to check out the original branch)\n")
+	}
+	fmt.Fprintf(&b, "\n")
+	return b.String()
+}
+
+// branchLine returns the branch and tracking info line of the short-format.
+// Example: "## master...origin/master [ahead 1, behind 2]"
+func branchLine(status *git.Status) string {
+	switch {
+	case status.Branch == "":
+		return "## HEAD (no branch)"
+	case status.Head == "":
+		return "## No commits yet on " + shortRef(status.Branch)
+	case status.Upstream == "":
+		return "## " + shortRef(status.Branch)
+	}
+
+	line := fmt.Sprintf("## %s...%s", shortRef(status.Branch), shortRef(status.Upstream))
+	switch {
+	case status.UpstreamGone:
+		line += " [gone]"
+	case status.Ahead > 0 && status.Behind > 0:
+		line += fmt.Sprintf(" [ahead %d, behind %d]", status.Ahead, status.Behind)
+	case status.Ahead > 0:
+		line += fmt.Sprintf(" [ahead %d]", status.Ahead)
+	case status.Behind > 0:
+		line += fmt.Sprintf(" [behind %d]", status.Behind)
+	}
+	return line
+}
+
+// formatShortStatus returns the status in the short-format, which is also the
+// porcelain v1 format when the paths are not relative to any 'prefix'.
+func (cmd *StatusCommand) formatShortStatus(status *git.Status, prefix string) string {
+	var b strings.Builder
+	term := "\n"
+	if cmd.nulTerm {
+		term = "\x00"
+	}
+	path := func(path string) string {
+		path = relativePath(path, prefix)
+		if cmd.nulTerm {
+			return path
+		}
+		// Unlike the other formats, a path with a space is quoted too.
+		if quoted := quotePath(path); quoted != path || !strings.Contains(path, " ") {
+			return quoted
+		}
+		return "\"" + path + "\""
+	}
+
+	if cmd.branch {
+		b.WriteString(branchLine(status) + term)
+	}
+
+	for _, entry := range status.Entries {
+		fmt.Fprintf(&b, "%c%c ", entry.Staged, entry.Unstaged)
+		switch {
+		case entry.OrigPath == "":
+			b.WriteString(path(entry.Path) + term)
+		case cmd.nulTerm:
+			b.WriteString(path(entry.Path) + term + path(entry.OrigPath) + term)
+		default:
+			b.WriteString(path(entry.OrigPath) + " -> " + path(entry.Path) + term)
+		}
+	}
+	for _, file := range status.Untracked {
+		b.WriteString("?? " + path(file) + term)
+	}
+	for _, file := range status.Ignored {
+		b.WriteString("!! " + path(file) + term)
+	}
+
+	return b.String()
+}
+
+// v2Mode returns a file mode for the porcelain v2 format.
+func v2Mode(file git.FileEntry) string {
+	if file.Mode == "" {
+		return "000000"
+	}
+	return strings.Repeat("0", 6-len(file.Mode)) + file.Mode
+}
+
+// v2Hash returns an object hash for the porcelain v2 format.
+func v2Hash(file git.FileEntry) string {
+	if file.Hash == "" {
+		return strings.Repeat("0", 40)
+	}
+	return file.Hash
+}
+
+// formatPorcelainV2Status returns the status in the porcelain v2 format.
+func (cmd *StatusCommand) formatPorcelainV2Status(status *git.Status) string {
+	var b strings.Builder
+	term := "\n"
+	path := quotePath
+	if cmd.nulTerm {
+		term = "\x00"
+		path = func(path string) string { return path }
+	}
+
+	if cmd.branch {
+		if status.Head == "" {
+			b.WriteString("# branch.oid (initial)" + term)
+		} else {
+			b.WriteString("# branch.oid " + status.Head + term)
+		}
+		if status.Branch == "" {
+			b.WriteString("# branch.head (detached)" + term)
+		} else {
+			b.WriteString("# branch.head " + shortRef(status.Branch) + term)
+		}
+		if status.Upstream != "" {
+			b.WriteString("# branch.upstream " + shortRef(status.Upstream) + term)
+			if !status.UpstreamGone && status.Head != "" {
+				fmt.Fprintf(&b, "# branch.ab +%d -%d%s", status.Ahead, status.Behind, term)
+			}
+		}
+	}
+
+	// The conflicted paths are shown after the other changes.
+	for i := range status.Entries {
+		entry := &status.Entries[i]
+		if isUnmerged(entry) {
+			continue
+		}
+
+		xy := strings.Replace(string([]byte{entry.Staged, entry.Unstaged}), " ", ".", -1)
+		if entry.OrigPath != "" {
+			sep := "\t"
+			if cmd.nulTerm {
+				sep = "\x00"
+			}
+			fmt.Fprintf(&b, "2 %s N... %s %s %s %s %s %c%d %s%s%s%s", xy,
+				v2Mode(entry.Head), v2Mode(entry.Index), v2Mode(entry.Worktree),
+				v2Hash(entry.Head), v2Hash(entry.Index), entry.Staged, entry.Score,
+				path(entry.Path), sep, path(entry.OrigPath), term)
+		} else {
+			fmt.Fprintf(&b, "1 %s N... %s %s %s %s %s %s%s", xy,
+				v2Mode(entry.Head), v2Mode(entry.Index), v2Mode(entry.Worktree),
+				v2Hash(entry.Head), v2Hash(entry.Index), path(entry.Path), term)
+		}
+	}
+	for i := range status.Entries {
+		entry := &status.Entries[i]
+		if !isUnmerged(entry) {
+			continue
+		}
+
+		fmt.Fprintf(&b, "u %c%c N... %s %s %s %s %s %s %s %s%s", entry.Staged, entry.Unstaged,
+			v2Mode(entry.Stages[0]), v2Mode(entry.Stages[1]), v2Mode(entry.Stages[2]),
+			v2Mode(entry.Worktree), v2Hash(entry.Stages[0]), v2Hash(entry.Stages[1]),
+			v2Hash(entry.Stages[2]), path(entry.Path), term)
+	}
+	for _, file := range status.Untracked {
+		b.WriteString("? " + path(file) + term)
+	}
+	for _, file := range status.Ignored {
+		b.WriteString("! " + path(file) + term)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// SwitchCommand lists the components of "switch" comamnd.
+type SwitchCommand struct {
+	fs     *flag.FlagSet
+	force  bool
+	detach bool
+	target string
+}
+
+// NewSwitchCommand creates a new command object.
+func NewSwitchCommand() *SwitchCommand {
+	fs := flag.NewFlagSet("switch", flag.ExitOnError)
+	cmd := SwitchCommand{
+		fs: fs,
+	}
+
+	fs.BoolVar(&cmd.force, "f", false, "Throw away the local changes")
+	fs.BoolVar(&cmd.force, "force", false, "Throw away the local changes")
+	fs.BoolVar(&cmd.force, "discard-changes", false, "Throw away the local changes")
+	fs.BoolVar(&cmd.detach, "detach", false, "Detach HEAD at the given commit")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *SwitchCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *SwitchCommand) Description() string {
+	return "Switch branches"
+}
+
+// Init initializes and validates the given command.
+func (cmd *SwitchCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cmd.fs.NArg() != 1 {
+		return errors.New("fatal: missing branch or commit argument")
+	}
+	cmd.target = cmd.fs.Arg(0)
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *SwitchCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s [<args>] <branch>\n", cmd.Name())
+	fmt.Printf("   or: %s [<args>] --detach <commit>\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *SwitchCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+	util.Check(repo.RequireWorkTree())
+
+	target, err := resolveSwitchTarget(repo, cmd.target, cmd.detach)
+	util.Check(err)
+	if target.branch == "" && !cmd.detach {
+		kind := "commit"
+		if strings.HasPrefix(target.ref, "refs/tags/") {
+			kind = "tag"
+		} else if strings.HasPrefix(target.ref, "refs/remotes/") {
+			kind = "remote branch"
+		}
+		util.Check(fmt.Errorf("fatal: a branch is expected, got %s '%s'\n"+
+			"hint: If you want to detach HEAD at the commit, try again with "+
+			"the --detach option.", kind, cmd.target))
+	}
+
+	util.Check(switchBranch(repo, target, cmd.force, cmd.detach))
+}
+
+// switchTarget is the branch or the commit to switch to.
+type switchTarget struct {
+	name string
+	// branch is the reference of the branch to switch to, or empty to
+	// detach HEAD.
+	branch string
+	// ref is the full reference which the name resolved to, if any.
+	ref    string
+	commit string
+	// created tells if the branch was just created for the switch.
+	created bool
+}
+
+// resolveSwitchTarget finds the branch or the commit given by a name. A name
+// of a local branch is switched to as a branch, unless HEAD is detached on
+// purpose.
+func resolveSwitchTarget(repo *git.Repo, name string, detach bool) (*switchTarget, error) {
+	target := &switchTarget{name: name}
+	if !detach {
+		branch := "refs/heads/" + name
+		if hash, _, err := repo.RefResolve(branch); err == nil && hash != "" {
+			target.branch, target.ref, target.commit = branch, branch, hash
+			return target, nil
+		}
+	}
+
+	hash, err := repo.UniqueNameResolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("fatal: invalid reference: %s", name)
+	}
+	obj, hash, err := repo.PeelObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj.ObjType != "commit" {
+		return nil, fmt.Errorf("fatal: reference is not a tree: %s", name)
+	}
+	target.commit = hash
+
+	for _, prefix := range []string{"refs/tags/", "refs/remotes/"} {
+		if _, _, err := repo.RefResolve(prefix + name); err == nil {
+			target.ref = prefix + name
+		}
+	}
+	return target, nil
+}
+
+// switchBranch updates the index and the work-tree to the commit of a target,
+// and then moves HEAD to it. The local changes are kept unless forced, and
+// are listed after the switch.
+func switchBranch(repo *git.Repo, target *switchTarget, force, detach bool) error {
+	oldBranch, oldHash, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	// A branch can be checked out in one work-tree only.
+	if target.branch != "" && target.branch != oldBranch {
+		wt, err := repo.BranchCheckedOut(target.branch)
+		if err != nil {
+			return err
+		}
+		if wt != nil {
+			return fmt.Errorf("fatal: '%s' is already checked out at '%s'",
+				strings.TrimPrefix(target.branch, "refs/heads/"), wt.Path)
+		}
+	}
+
+	oldTree := ""
+	if oldHash != "" {
+		if oldTree, err = repo.TreeResolve(oldHash); err != nil {
+			return err
+		}
+	}
+	newTree, err := repo.TreeResolve(target.commit)
+	if err != nil {
+		return err
+	}
+
+	opts := &git.CheckoutOptions{Force: force, Command: "checkout"}
+	if err := repo.CheckoutTree(oldTree, newTree, opts); err != nil {
+		return err
+	}
+
+	if !force {
+		index, err := repo.ReadIndex()
+		if err != nil {
+			return err
+		}
+		changes, err := repo.DiffTreeToWorktree(newTree, index, nil)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Printf("%c\t%s\n", change.Status, change.Path())
+		}
+	}
+
+	// Leaving a detached HEAD shows where it was.
+	if oldBranch == "" && oldHash != "" && oldHash != target.commit {
+		line, err := commitLine(repo, oldHash)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Previous HEAD position was %s\n", line)
+	}
+
+	if target.branch == "" {
+		if err := repo.SetHead(target.commit); err != nil {
+			return err
+		}
+		config, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		if oldBranch != "" && !detach && config.GetBool("advice.detachedHead", true) {
+			fmt.Print(detachAdvice(target.name))
+		}
+		line, err := commitLine(repo, target.commit)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD is now at %s\n", line)
+		return nil
+	}
+
+	if err := repo.SetHead(target.branch); err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(target.branch, "refs/heads/")
+	if oldBranch == target.branch {
+		fmt.Printf("Already on '%s'\n", name)
+	} else if target.created {
+		fmt.Printf("Switched to a new branch '%s'\n", name)
+	} else {
+		fmt.Printf("Switched to branch '%s'\n", name)
+	}
+
+	status := &git.Status{Branch: target.branch, Head: target.commit}
+	if err := repo.UpstreamStatus(status); err != nil {
+		return err
+	}
+	fmt.Print(trackingInfo(status))
+	return nil
+}
+
+// commitLine returns the abbreviated hash and the subject of a commit.
+func commitLine(repo *git.Repo, commitHash string) (string, error) {
+	obj, err := repo.ObjectParse(commitHash)
+	if err != nil {
+		return "", err
+	}
+	commit, err := git.NewCommit(repo, obj)
+	if err != nil {
+		return "", err
+	}
+	return commitHash[:7] + " " + commit.Subject(), nil
+}
+
+// detachAdvice returns the advice shown when HEAD gets detached.
+func detachAdvice(name string) string {
+	return fmt.Sprintf("Note: switching to '%s'.\n\n", name) +
+		"You are in 'detached HEAD' state. You can look around, make experimental\n" +
+		"changes and commit them, and you can discard any commits you make in this\n" +
+		"state without impacting any branches by switching back to a branch.\n\n" +
+		"If you want to create a new branch to retain commits you create, you may\n" +
+		"do so (now or later) by using -c with the switch command. Example:\n\n" +
+		"  git switch -c <new-branch-name>\n\n" +
+		"Or undo this operation with:\n\n" +
+		"  git switch -\n\n" +
+		"Turn off this advice by setting config variable advice.detachedHead to false\n\n"
+}
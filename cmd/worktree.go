@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssrathi/gogit/git"
+	"github.com/ssrathi/gogit/util"
+)
+
+// worktreeFlags lists the flags allowed with each subcommand of "worktree",
+// along with the number of arguments it needs.
+var worktreeFlags = map[string][]string{
+	"add":    {"f", "force", "detach", "b", "lock", "reason"},
+	"list":   {"porcelain"},
+	"remove": {"f", "force"},
+	"prune":  {"n", "dry-run", "v", "verbose"},
+	"lock":   {"reason"},
+	"unlock": {},
+}
+
+// WorktreeCommand lists the components of "worktree" comamnd.
+type WorktreeCommand struct {
+	fs         *flag.FlagSet
+	subcommand string
+	force      countFlag
+	detach     bool
+	newBranch  string
+	lock       bool
+	reason     string
+	porcelain  bool
+	dryRun     bool
+	verbose    bool
+	args       []string
+}
+
+// NewWorktreeCommand creates a new command object.
+func NewWorktreeCommand() *WorktreeCommand {
+	fs := flag.NewFlagSet("worktree", flag.ExitOnError)
+	cmd := WorktreeCommand{
+		fs: fs,
+	}
+
+	fs.Var(&cmd.force, "f", "(add, remove) Check out a branch in use, or remove "+
+		"a dirty work-tree (twice for a locked one)")
+	fs.Var(&cmd.force, "force", "(add, remove) Check out a branch in use, or remove "+
+		"a dirty work-tree (twice for a locked one)")
+	fs.BoolVar(&cmd.detach, "detach", false, "(add) Detach HEAD in the new work-tree")
+	fs.StringVar(&cmd.newBranch, "b", "", "(add) Create a new branch for the new work-tree")
+	fs.BoolVar(&cmd.lock, "lock", false, "(add) Keep the new work-tree locked")
+	fs.StringVar(&cmd.reason, "reason", "", "(add, lock) Reason to lock the work-tree")
+	fs.BoolVar(&cmd.porcelain, "porcelain", false, "(list) Give the output in an "+
+		"easy-to-parse format")
+	fs.BoolVar(&cmd.dryRun, "n", false, "(prune) Only show what would be removed")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "(prune) Only show what would be removed")
+	fs.BoolVar(&cmd.verbose, "v", false, "(prune) Show what is removed")
+	fs.BoolVar(&cmd.verbose, "verbose", false, "(prune) Show what is removed")
+	return &cmd
+}
+
+// Name gives the name of the command.
+func (cmd *WorktreeCommand) Name() string {
+	return cmd.fs.Name()
+}
+
+// Description gives the description of the command.
+func (cmd *WorktreeCommand) Description() string {
+	return "Manage multiple working trees"
+}
+
+// Init initializes and validates the given command.
+func (cmd *WorktreeCommand) Init(args []string) error {
+	cmd.fs.Usage = cmd.Usage
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("error: need a subcommand")
+	}
+	cmd.subcommand, args = args[0], args[1:]
+	allowed, ok := worktreeFlags[cmd.subcommand]
+	if !ok {
+		return fmt.Errorf("error: unknown subcommand: `%s'", cmd.subcommand)
+	}
+	if err := cmd.fs.Parse(args); err != nil {
+		return err
+	}
+	cmd.args = cmd.fs.Args()
+
+	var err error
+	cmd.fs.Visit(func(f *flag.Flag) {
+		for _, name := range allowed {
+			if f.Name == name {
+				return
+			}
+		}
+		err = fmt.Errorf("error: unknown option `%s' for 'worktree %s'", f.Name, cmd.subcommand)
+	})
+	if err != nil {
+		return err
+	}
+
+	minArgs, maxArgs := 1, 1
+	switch cmd.subcommand {
+	case "add":
+		maxArgs = 2
+	case "list", "prune":
+		minArgs, maxArgs = 0, 0
+	}
+	if len(cmd.args) < minArgs || len(cmd.args) > maxArgs {
+		return fmt.Errorf("error: wrong number of arguments for 'worktree %s'", cmd.subcommand)
+	}
+
+	if cmd.subcommand == "add" {
+		if cmd.newBranch != "" && cmd.detach {
+			return errors.New("fatal: options '-b' and '--detach' cannot be used together")
+		}
+		if cmd.reason != "" && !cmd.lock {
+			return errors.New("fatal: the option '--reason' requires '--lock'")
+		}
+	}
+	return nil
+}
+
+// Usage prints the usage string for the end user.
+func (cmd *WorktreeCommand) Usage() {
+	fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
+	fmt.Printf("usage: %s add [-f] [--detach] [--lock [--reason <string>]] "+
+		"[-b <new-branch>] <path> [<commit-ish>]\n", cmd.Name())
+	fmt.Printf("   or: %s list [--porcelain]\n", cmd.Name())
+	fmt.Printf("   or: %s lock [--reason <string>] <worktree>\n", cmd.Name())
+	fmt.Printf("   or: %s prune [-n] [-v]\n", cmd.Name())
+	fmt.Printf("   or: %s remove [-f] <worktree>\n", cmd.Name())
+	fmt.Printf("   or: %s unlock <worktree>\n", cmd.Name())
+	cmd.fs.PrintDefaults()
+}
+
+// Execute runs the given command till completion.
+func (cmd *WorktreeCommand) Execute() {
+	repo, err := git.GetRepo(".")
+	util.Check(err)
+
+	switch cmd.subcommand {
+	case "add":
+		cmd.add(repo)
+	case "list":
+		cmd.list(repo)
+	case "prune":
+		pruned, err := repo.PruneWorktrees(cmd.dryRun)
+		util.Check(err)
+		if cmd.dryRun || cmd.verbose {
+			for _, wt := range pruned {
+				fmt.Printf("Removing worktrees/%s: %s\n", wt.ID, wt.PruneReason)
+			}
+		}
+	default:
+		wt, err := repo.FindWorktree(cmd.args[0])
+		util.Check(err)
+		cmd.change(repo, wt)
+	}
+}
+
+// add creates a work-tree. Like "git", a new branch named after the path is
+// created for it if neither a commit nor a branch is given.
+func (cmd *WorktreeCommand) add(repo *git.Repo) {
+	path, commitish := cmd.args[0], "HEAD"
+	if len(cmd.args) > 1 {
+		commitish = cmd.args[1]
+	}
+
+	opts := &git.WorktreeAddOptions{
+		Force:      cmd.force > 0,
+		Lock:       cmd.lock,
+		LockReason: cmd.reason,
+	}
+	if cmd.newBranch != "" {
+		opts.Branch, opts.NewBranch = "refs/heads/"+cmd.newBranch, true
+	} else if !cmd.detach {
+		name := commitish
+		if len(cmd.args) == 1 {
+			name = filepath.Base(path)
+		}
+		_, _, err := repo.RefResolve("refs/heads/" + name)
+		if err == nil {
+			opts.Branch = "refs/heads/" + name
+			commitish = name
+		} else if len(cmd.args) == 1 {
+			opts.Branch, opts.NewBranch = "refs/heads/"+name, true
+		}
+	}
+
+	hash, err := repo.UniqueNameResolve(commitish)
+	if err != nil {
+		util.Check(fmt.Errorf("fatal: invalid reference: %s", commitish))
+	}
+	obj, hash, err := repo.PeelObject(hash)
+	util.Check(err)
+	if obj.ObjType != "commit" {
+		util.Check(fmt.Errorf("fatal: invalid reference: %s", commitish))
+	}
+	opts.Commit = hash
+
+	name := strings.TrimPrefix(opts.Branch, "refs/heads/")
+	if opts.NewBranch {
+		fmt.Printf("Preparing worktree (new branch '%s')\n", name)
+	} else if opts.Branch != "" {
+		fmt.Printf("Preparing worktree (checking out '%s')\n", name)
+	} else {
+		fmt.Printf("Preparing worktree (detached HEAD %s)\n", hash[:7])
+	}
+
+	_, err = repo.AddWorktree(path, opts)
+	util.Check(err)
+	line, err := commitLine(repo, hash)
+	util.Check(err)
+	fmt.Printf("HEAD is now at %s\n", line)
+}
+
+// list shows the work-trees, one per line. The paths are padded to line up
+// the commits.
+func (cmd *WorktreeCommand) list(repo *git.Repo) {
+	worktrees, err := repo.Worktrees()
+	util.Check(err)
+
+	width := 0
+	for _, wt := range worktrees {
+		if len(wt.Path) > width {
+			width = len(wt.Path)
+		}
+	}
+
+	for _, wt := range worktrees {
+		head := wt.Head
+		if head == "" {
+			head = strings.Repeat("0", 40)
+		}
+
+		if cmd.porcelain {
+			fmt.Printf("worktree %s\n", wt.Path)
+			if wt.Bare {
+				fmt.Println("bare")
+			} else {
+				fmt.Printf("HEAD %s\n", head)
+				if wt.Branch == "" {
+					fmt.Println("detached")
+				} else {
+					fmt.Printf("branch %s\n", wt.Branch)
+				}
+			}
+			if wt.Locked {
+				fmt.Println(strings.TrimSpace("locked " + wt.LockReason))
+			}
+			if wt.PruneReason != "" {
+				fmt.Printf("prunable %s\n", wt.PruneReason)
+			}
+			fmt.Println()
+			continue
+		}
+
+		line := fmt.Sprintf("%-*s ", width+1, wt.Path)
+		if wt.Bare {
+			line += "(bare)"
+		} else if wt.Branch == "" {
+			line += head[:7] + " (detached HEAD)"
+		} else {
+			line += head[:7] + " [" + strings.TrimPrefix(wt.Branch, "refs/heads/") + "]"
+		}
+		if wt.Locked {
+			line += " locked"
+		} else if wt.PruneReason != "" {
+			line += " prunable"
+		}
+		fmt.Println(line)
+	}
+}
+
+// change removes, locks or unlocks a linked work-tree.
+func (cmd *WorktreeCommand) change(repo *git.Repo, wt *git.Worktree) {
+	name := cmd.args[0]
+	if wt.IsMain() {
+		if cmd.subcommand == "remove" {
+			util.Check(fmt.Errorf("fatal: '%s' is a main working tree", name))
+		}
+		util.Check(errors.New("fatal: The main working tree cannot be locked or unlocked"))
+	}
+
+	switch cmd.subcommand {
+	case "remove":
+		if wt.Locked && cmd.force < 2 {
+			reason := ";"
+			if wt.LockReason != "" {
+				reason = ", lock reason: " + wt.LockReason
+			}
+			util.Check(fmt.Errorf("fatal: cannot remove a locked working tree%s\n"+
+				"use 'remove -f -f' to override or unlock first", reason))
+		}
+		if cmd.force == 0 {
+			clean, err := repo.WorktreeClean(wt)
+			util.Check(err)
+			if !clean {
+				util.Check(fmt.Errorf("fatal: '%s' contains modified or untracked files, "+
+					"use --force to delete it", name))
+			}
+		}
+		util.Check(repo.RemoveWorktree(wt))
+	case "lock":
+		if wt.Locked && wt.LockReason != "" {
+			util.Check(fmt.Errorf("fatal: '%s' is already locked, reason: %s", name,
+				wt.LockReason))
+		} else if wt.Locked {
+			util.Check(fmt.Errorf("fatal: '%s' is already locked", name))
+		}
+		util.Check(repo.LockWorktree(wt, cmd.reason))
+	case "unlock":
+		if !wt.Locked {
+			util.Check(fmt.Errorf("fatal: '%s' is not locked", name))
+		}
+		util.Check(repo.UnlockWorktree(wt))
+	}
+}
@@ -3,6 +3,7 @@ package git
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 )
 
 // Blob is a git object to represent the data of a single file.
@@ -38,6 +39,22 @@ func NewBlobFromFile(repo *Repo, file string) (*Blob, error) {
 	return &blob, nil
 }
 
+// WriteBlobFromFile hashes a file as a blob, and optionally writes the blob,
+// while reading the file only once and without holding it in memory.
+func WriteBlobFromFile(repo *Repo, file string, write bool) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return repo.ObjectWriteFrom("blob", info.Size(), f, write)
+}
+
 // Print returns a string representation of a blob object.
 func (blob *Blob) Print() string {
 	return string(blob.ObjData)
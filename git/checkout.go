@@ -0,0 +1,526 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CheckoutOptions controls how CheckoutTree updates the index and the
+// work-tree.
+type CheckoutOptions struct {
+	// Force discards the local changes of the index and the work-tree,
+	// instead of refusing to overwrite them.
+	Force bool
+	// Command is the name of the operation shown in the errors, such as
+	// "checkout" or "merge".
+	Command string
+}
+
+// CheckoutError lists the paths whose local changes or untracked files would
+// be lost by a checkout.
+type CheckoutError struct {
+	Command   string
+	Unmerged  []string
+	Changed   []string
+	Untracked []string
+}
+
+// Error returns the messages shown by "git" when it refuses to switch.
+func (e *CheckoutError) Error() string {
+	var b strings.Builder
+	if len(e.Unmerged) > 0 {
+		for _, path := range e.Unmerged {
+			fmt.Fprintf(&b, "%s: needs merge\n", path)
+		}
+		b.WriteString("error: you need to resolve your current index first")
+		return b.String()
+	}
+
+	action := e.Command
+	if e.Command == "checkout" {
+		action = "switch branches"
+	}
+	if len(e.Changed) > 0 {
+		fmt.Fprintf(&b, "error: Your local changes to the following files would "+
+			"be overwritten by %s:\n", e.Command)
+		for _, path := range e.Changed {
+			fmt.Fprintf(&b, "\t%s\n", path)
+		}
+		fmt.Fprintf(&b, "Please commit your changes or stash them before you %s.\n", action)
+	}
+	if len(e.Untracked) > 0 {
+		fmt.Fprintf(&b, "error: The following untracked working tree files would "+
+			"be overwritten by %s:\n", e.Command)
+		for _, path := range e.Untracked {
+			fmt.Fprintf(&b, "\t%s\n", path)
+		}
+		fmt.Fprintf(&b, "Please move or remove them before you %s.\n", action)
+	}
+	b.WriteString("Aborting")
+	return b.String()
+}
+
+// checkout holds the state of a single CheckoutTree operation.
+type checkout struct {
+	repo     *Repo
+	opts     *CheckoutOptions
+	index    *Index
+	fs       FSConfig
+	oldFiles map[string]FileEntry
+	newFiles map[string]FileEntry
+	ignore   *Ignore
+	err      *CheckoutError
+	// removed and updated are the paths to remove from the work-tree and
+	// the files to write to it.
+	removed []string
+	updated []FileEntry
+}
+
+// CheckoutTree switches the index and the work-tree from the tree 'oldTree'
+// (such as the tree of HEAD, or empty if there are no commits yet) to the tree
+// 'newTree'. Only the files which differ between the two trees are written or
+// removed, and the local changes to the other files are kept. Unless forced,
+// nothing is changed if a local change or an untracked file would be
+// overwritten, and a *CheckoutError lists such paths.
+func (r *Repo) CheckoutTree(oldTree, newTree string, opts *CheckoutOptions) error {
+	index, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	fs, err := r.FSConfig()
+	if err != nil {
+		return err
+	}
+
+	co := &checkout{
+		repo:    r,
+		opts:    opts,
+		index:   index,
+		fs:      fs,
+		err:     &CheckoutError{Command: opts.Command},
+		removed: []string{},
+		updated: []FileEntry{},
+	}
+	if co.oldFiles, err = r.treeFileMap(oldTree); err != nil {
+		return err
+	}
+	if co.newFiles, err = r.treeFileMap(newTree); err != nil {
+		return err
+	}
+
+	if opts.Force {
+		err = co.planForced()
+	} else {
+		err = co.plan()
+	}
+	if err != nil {
+		return err
+	}
+	if len(co.err.Unmerged) > 0 || len(co.err.Changed) > 0 || len(co.err.Untracked) > 0 {
+		return co.err
+	}
+
+	if err := co.apply(); err != nil {
+		return err
+	}
+	return r.WriteIndex(index)
+}
+
+// treeFileMap maps the paths of all the files in a tree to them. An empty
+// hash gives an empty map.
+func (r *Repo) treeFileMap(treeHash string) (map[string]FileEntry, error) {
+	files := map[string]FileEntry{}
+	if treeHash == "" {
+		return files, nil
+	}
+
+	treeFiles, err := r.TreeFiles(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range treeFiles {
+		files[file.Path] = file
+	}
+	return files, nil
+}
+
+// paths returns the union of the paths in both the trees and the index,
+// sorted.
+func (co *checkout) paths() []string {
+	seen := map[string]bool{}
+	paths := []string{}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for path := range co.oldFiles {
+		add(path)
+	}
+	for path := range co.newFiles {
+		add(path)
+	}
+	for _, entry := range co.index.Entries {
+		add(entry.Path)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// sameFile tells if two files are the same. An absent file has an empty path.
+func sameFile(a, b FileEntry) bool {
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// plan finds the files to update for a switch which keeps the local changes,
+// and the paths which prevent it.
+func (co *checkout) plan() error {
+	for _, entry := range co.index.Entries {
+		if entry.Stage() != 0 && (len(co.err.Unmerged) == 0 ||
+			co.err.Unmerged[len(co.err.Unmerged)-1] != entry.Path) {
+			co.err.Unmerged = append(co.err.Unmerged, entry.Path)
+		}
+	}
+	if len(co.err.Unmerged) > 0 {
+		return nil
+	}
+
+	for _, path := range co.paths() {
+		oldFile, newFile := co.oldFiles[path], co.newFiles[path]
+		var indexFile FileEntry
+		entry := co.index.Entry(path, 0)
+		if entry != nil {
+			indexFile = entry.FileEntry()
+		}
+
+		// The file is not switched, so its local changes are kept. The
+		// index may already have the new version too.
+		if sameFile(oldFile, newFile) || sameFile(indexFile, newFile) {
+			continue
+		}
+		if !sameFile(indexFile, oldFile) {
+			co.err.Changed = append(co.err.Changed, path)
+			continue
+		}
+
+		if entry != nil {
+			// Nothing is lost by overwriting a missing file.
+			clean, err := co.repo.worktreeClean(co.index, entry, co.fs)
+			if err != nil {
+				return err
+			}
+			if !clean && co.repo.worktreePresent(path) {
+				co.err.Changed = append(co.err.Changed, path)
+				continue
+			}
+		} else {
+			if err := co.verifyAbsent(path); err != nil {
+				return err
+			}
+		}
+
+		if newFile.Path == "" {
+			co.removed = append(co.removed, path)
+		} else {
+			co.updated = append(co.updated, newFile)
+		}
+	}
+
+	return nil
+}
+
+// planForced finds the files to update for a switch which discards all the
+// local changes.
+func (co *checkout) planForced() error {
+	for _, path := range co.paths() {
+		newFile, inNew := co.newFiles[path]
+		if !inNew {
+			co.removed = append(co.removed, path)
+			continue
+		}
+
+		// A file already in the index and the work-tree is not rewritten.
+		entry := co.index.Entry(path, 0)
+		if entry != nil && sameFile(entry.FileEntry(), newFile) {
+			clean, err := co.repo.worktreeClean(co.index, entry, co.fs)
+			if err != nil {
+				return err
+			}
+			if clean {
+				continue
+			}
+		}
+		co.updated = append(co.updated, newFile)
+	}
+
+	return nil
+}
+
+// worktreeClean tells if the work-tree file of an index entry is unchanged.
+// A missing file is not clean.
+func (r *Repo) worktreeClean(index *Index, entry *IndexEntry, fs FSConfig) (bool, error) {
+	file := entry.FileEntry()
+	if modeType(file.Mode) == "160" || entry.SkipWorktree() {
+		return true, nil
+	}
+
+	info, err := os.Lstat(filepath.Join(r.WorkTree, filepath.FromSlash(entry.Path)))
+	if isMissing(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return false, nil
+	}
+	if entry.StatMatches(info) && !index.IsRacy(entry) {
+		return true, nil
+	}
+
+	wtFile, err := r.WorktreeFile(entry.Path)
+	if err != nil {
+		return false, err
+	}
+	wtFile.Mode = fs.indexMode(info, file.Mode)
+	return sameFile(wtFile, file), nil
+}
+
+// worktreePresent tells if there is a file or a directory at a path in the
+// work-tree.
+func (r *Repo) worktreePresent(path string) bool {
+	_, err := os.Lstat(filepath.Join(r.WorkTree, filepath.FromSlash(path)))
+	return err == nil
+}
+
+// isIgnored tells if an untracked path of the work-tree is ignored, and so
+// can be overwritten.
+func (co *checkout) isIgnored(path string, isDir bool) (bool, error) {
+	if co.ignore == nil {
+		ignore, err := co.repo.NewIgnore()
+		if err != nil {
+			return false, err
+		}
+		co.ignore = ignore
+	}
+	return co.ignore.IsIgnored(path, isDir), nil
+}
+
+// verifyAbsent checks that writing a new file at a path doesn't overwrite an
+// untracked file, either at the path itself, at one of its leading
+// directories or inside a directory at the path.
+func (co *checkout) verifyAbsent(filePath string) error {
+	// A file in place of a leading directory is fine only if it is tracked,
+	// as it is then removed by the switch.
+	for dir := path.Dir(filePath); dir != "."; dir = path.Dir(dir) {
+		info, err := os.Lstat(filepath.Join(co.repo.WorkTree, filepath.FromSlash(dir)))
+		if isMissing(err) || (err == nil && info.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if co.index.Entry(dir, 0) != nil {
+			return nil
+		}
+		ignored, err := co.isIgnored(dir, false)
+		if err != nil {
+			return err
+		}
+		if !ignored {
+			co.err.Untracked = append(co.err.Untracked, dir)
+		}
+		return nil
+	}
+
+	fullPath := filepath.Join(co.repo.WorkTree, filepath.FromSlash(filePath))
+	info, err := os.Lstat(fullPath)
+	if isMissing(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		ignored, err := co.isIgnored(filePath, false)
+		if err != nil {
+			return err
+		}
+		if !ignored {
+			co.err.Untracked = append(co.err.Untracked, filePath)
+		}
+		return nil
+	}
+
+	// The tracked files of a directory in the way are removed by the switch,
+	// so only the untracked ones matter.
+	return filepath.Walk(fullPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(co.repo.WorkTree, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if co.index.Entry(rel, 0) != nil {
+			return nil
+		}
+		ignored, err := co.isIgnored(rel, false)
+		if err != nil {
+			return err
+		}
+		if !ignored {
+			co.err.Untracked = append(co.err.Untracked, rel)
+		}
+		return nil
+	})
+}
+
+// apply removes and writes the planned files, and updates their index
+// entries.
+func (co *checkout) apply() error {
+	for _, filePath := range co.removed {
+		co.index.Remove(filePath)
+		if err := co.repo.removeWorktreeFile(filePath); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range co.updated {
+		info, err := co.repo.writeWorktreeFile(file, co.fs)
+		if err != nil {
+			return err
+		}
+
+		entry, err := NewIndexEntry(file.Path, file.Mode, file.Hash)
+		if err != nil {
+			return err
+		}
+		entry.SetStat(info)
+		co.index.Add(entry)
+	}
+
+	return nil
+}
+
+// removeEmptyDirs removes a directory of the work-tree and its parents, as
+// long as they are empty.
+func (r *Repo) removeEmptyDirs(dir string) {
+	for ; dir != "." && dir != ""; dir = path.Dir(dir) {
+		if err := os.Remove(filepath.Join(r.WorkTree, filepath.FromSlash(dir))); err != nil {
+			return
+		}
+	}
+}
+
+// removeWorktreeFile removes a file from the work-tree, along with its leading
+// directories which become empty.
+func (r *Repo) removeWorktreeFile(filePath string) error {
+	fullPath := filepath.Join(r.WorkTree, filepath.FromSlash(filePath))
+	info, err := os.Lstat(fullPath)
+	if isMissing(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only an empty directory of a submodule is removed.
+	if info.IsDir() {
+		os.Remove(fullPath)
+	} else if err := os.Remove(fullPath); err != nil {
+		return err
+	}
+	r.removeEmptyDirs(path.Dir(filePath))
+	return nil
+}
+
+// writeWorktreeFile writes a file to the work-tree, replacing whatever is at
+// its path. The leading directories are created as needed.
+func (r *Repo) writeWorktreeFile(file FileEntry, fs FSConfig) (os.FileInfo, error) {
+	fullPath := filepath.Join(r.WorkTree, filepath.FromSlash(file.Path))
+	if err := r.makeLeadingDirs(file.Path); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(fullPath); err != nil {
+		return nil, err
+	}
+	if err := r.checkoutFile(fullPath, file.Mode, file.Hash, fs); err != nil {
+		return nil, err
+	}
+	return os.Lstat(fullPath)
+}
+
+// checkoutFile creates a file of a tree at a path which is not present. A
+// symlink is written as a plain file with the link target if symlinks are
+// not supported, and a submodule is written as an empty directory.
+func (r *Repo) checkoutFile(fullPath, mode, hash string, fs FSConfig) error {
+	if modeType(mode) == "160" {
+		return os.Mkdir(fullPath, os.ModePerm)
+	}
+
+	if mode == "120000" && fs.symlinks {
+		obj, err := r.ObjectParse(hash)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(filepath.FromSlash(string(obj.ObjData)), fullPath)
+	}
+
+	// Stream the blob into the file, as it may be too large for memory.
+	obj, err := r.ObjectOpen(hash)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	perm := os.FileMode(0644)
+	if mode == "100755" {
+		perm = 0755
+	}
+	file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, obj); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// makeLeadingDirs creates the leading directories of a work-tree path. A file
+// in place of a directory is removed.
+func (r *Repo) makeLeadingDirs(filePath string) error {
+	dir := filepath.Join(r.WorkTree, filepath.FromSlash(path.Dir(filePath)))
+	if err := os.MkdirAll(dir, os.ModePerm); err == nil {
+		return nil
+	}
+
+	parts := strings.Split(path.Dir(filePath), "/")
+	dir = r.WorkTree
+	for _, part := range parts {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if err == nil && info.IsDir() {
+			continue
+		}
+		if err == nil {
+			if err := os.Remove(dir); err != nil {
+				return err
+			}
+		}
+		if err := os.Mkdir(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckoutTree(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitCheckout")
+
+	tree1 := writeTestTree(t, repo, map[string]string{
+		"a": "a\n", "d/x": "x\n", "f": "f\n",
+	})
+	tree2 := writeTestTree(t, repo, map[string]string{
+		"a": "a2\n", "d/x": "x\n", "f/in": "in\n", "n": "new\n",
+	})
+
+	opts := &CheckoutOptions{Command: "checkout"}
+
+	t.Run("Validate checkout into an empty work-tree", func(t *testing.T) {
+		assertEqual(t, repo.CheckoutTree("", tree1, opts), nil)
+		assertEqual(t, readTestFile(t, repo, "a"), "a\n")
+		assertEqual(t, readTestFile(t, repo, "f"), "f\n")
+		assertEqual(t, testIndexPaths(t, repo), []string{"a", "d/x", "f"})
+	})
+
+	t.Run("Validate switch keeping local changes", func(t *testing.T) {
+		writeTestFile(t, repo, "d/x", "local\n")
+		assertEqual(t, repo.CheckoutTree(tree1, tree2, opts), nil)
+		assertEqual(t, readTestFile(t, repo, "a"), "a2\n")
+		assertEqual(t, readTestFile(t, repo, "f/in"), "in\n")
+		assertEqual(t, readTestFile(t, repo, "d/x"), "local\n")
+		assertEqual(t, testIndexPaths(t, repo), []string{"a", "d/x", "f/in", "n"})
+	})
+
+	t.Run("Validate refusing to overwrite local changes", func(t *testing.T) {
+		writeTestFile(t, repo, "a", "local\n")
+		err := repo.CheckoutTree(tree2, tree1, opts)
+		checkoutErr, ok := err.(*CheckoutError)
+		assertEqual(t, ok, true)
+		assertEqual(t, checkoutErr.Changed, []string{"a"})
+		assertEqual(t, readTestFile(t, repo, "f/in"), "in\n")
+	})
+
+	t.Run("Validate forced switch", func(t *testing.T) {
+		force := &CheckoutOptions{Force: true, Command: "checkout"}
+		assertEqual(t, repo.CheckoutTree(tree2, tree1, force), nil)
+		assertEqual(t, readTestFile(t, repo, "a"), "a\n")
+		assertEqual(t, readTestFile(t, repo, "d/x"), "x\n")
+		assertEqual(t, readTestFile(t, repo, "f"), "f\n")
+		assertEqual(t, readTestFile(t, repo, "n"), "")
+		assertEqual(t, testIndexPaths(t, repo), []string{"a", "d/x", "f"})
+	})
+
+	t.Run("Validate refusing to overwrite untracked files", func(t *testing.T) {
+		writeTestFile(t, repo, "n", "untracked\n")
+		err := repo.CheckoutTree(tree1, tree2, opts)
+		checkoutErr, ok := err.(*CheckoutError)
+		assertEqual(t, ok, true)
+		assertEqual(t, checkoutErr.Untracked, []string{"n"})
+		assertEqual(t, readTestFile(t, repo, "n"), "untracked\n")
+	})
+}
+
+func TestCheckoutModes(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitCheckoutModes")
+
+	blob, err := repo.ObjectWrite(NewObject("blob", []byte("echo hi\n")), true)
+	assertEqual(t, err, nil)
+	link, err := repo.ObjectWrite(NewObject("blob", []byte("run.sh")), true)
+	assertEqual(t, err, nil)
+
+	// The commit of a submodule is not present in the repo.
+	subCommit := "4829dd99d36c7444f632a85fa9604a30f9c252d3"
+	tree, err := NewTreeFromInput(repo, "100755 blob "+blob+"\trun.sh\n"+
+		"120000 blob "+link+"\tlink\n"+
+		"160000 commit "+subCommit+"\tsub\n")
+	assertEqual(t, err, nil)
+	assertEqual(t, tree.Entries[2].Type(), "commit")
+
+	outDir := filepath.Join(repo.WorkTree, "out")
+	assertEqual(t, tree.Checkout(outDir), nil)
+
+	info, err := os.Lstat(filepath.Join(outDir, "run.sh"))
+	assertEqual(t, err, nil)
+	assertEqual(t, info.Mode()&0100 != 0, true)
+	target, err := os.Readlink(filepath.Join(outDir, "link"))
+	assertEqual(t, err, nil)
+	assertEqual(t, target, "run.sh")
+	info, err = os.Lstat(filepath.Join(outDir, "sub"))
+	assertEqual(t, err, nil)
+	assertEqual(t, info.IsDir(), true)
+
+	t.Run("Validate add with the modes of the files", func(t *testing.T) {
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		fs, err := repo.FSConfig()
+		assertEqual(t, err, nil)
+		for _, path := range []string{"out/run.sh", "out/link"} {
+			assertEqual(t, repo.AddFile(index, path, fs), nil)
+		}
+		// A new repo has "core.filemode" false, so the executable bit of
+		// the file system is not trusted.
+		assertEqual(t, index.Files(), []FileEntry{
+			{Path: "out/link", Mode: "120000", Hash: link},
+			{Path: "out/run.sh", Mode: "100644", Hash: blob},
+		})
+	})
+}
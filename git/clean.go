@@ -0,0 +1,169 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// CleanOptions lists the choices for removing the untracked files.
+type CleanOptions struct {
+	// Directories removes the untracked directories as a whole. Otherwise
+	// the untracked directories are left alone.
+	Directories bool
+	// Ignored removes the ignored files as well, and OnlyIgnored removes
+	// just them.
+	Ignored     bool
+	OnlyIgnored bool
+	// Repositories removes the nested repositories, which are left alone
+	// otherwise.
+	Repositories bool
+	// Pathspec selects the paths to remove, or all of them if nil.
+	Pathspec *Pathspec
+}
+
+// cleanWalker finds the files and directories of the work-tree to remove.
+type cleanWalker struct {
+	repo        *Repo
+	opts        *CleanOptions
+	ignore      *Ignore
+	tracked     map[string]bool
+	trackedDirs map[string]bool
+}
+
+// CleanPaths returns the untracked paths of the work-tree which are to be
+// removed, in sorted order. The directories to remove as a whole end with a
+// "/".
+func (r *Repo) CleanPaths(opts *CleanOptions) ([]string, error) {
+	index, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := r.NewIgnore()
+	if err != nil {
+		return nil, err
+	}
+	walker := &cleanWalker{
+		repo:        r,
+		opts:        opts,
+		ignore:      ignore,
+		tracked:     map[string]bool{},
+		trackedDirs: index.Dirs(),
+	}
+	for _, entry := range index.Entries {
+		walker.tracked[entry.Path] = true
+	}
+
+	paths, _, err := walker.walk("", opts.Directories)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// walk returns the paths to remove inside a directory of the work-tree, and
+// tells if everything inside it is to be removed. The untracked directories
+// are removed as a whole only if 'directories' is set.
+func (w *cleanWalker) walk(dir string, directories bool) ([]string, bool, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(w.repo.WorkTree, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, false, err
+	}
+
+	ps := w.opts.Pathspec
+	paths, all := []string{}, true
+	for _, info := range infos {
+		filePath := path.Join(dir, info.Name())
+		if info.Name() == ".git" {
+			continue
+		}
+		if w.tracked[filePath] {
+			all = false
+			continue
+		}
+
+		isDir := info.IsDir()
+		ignored := w.ignore.IsIgnored(filePath, isDir)
+		if !isDir {
+			removable := (ignored && (w.opts.Ignored || w.opts.OnlyIgnored)) ||
+				(!ignored && !w.opts.OnlyIgnored)
+			if removable && ps.Match(filePath) {
+				paths = append(paths, filePath)
+			} else {
+				all = false
+			}
+			continue
+		}
+
+		if w.trackedDirs[filePath] {
+			subPaths, _, err := w.walk(filePath, directories)
+			if err != nil {
+				return nil, false, err
+			}
+			paths = append(paths, subPaths...)
+			all = false
+			continue
+		}
+
+		// A nested repository is removed only as a whole, and only if asked
+		// for.
+		gitDir := filepath.Join(w.repo.WorkTree, filepath.FromSlash(filePath), ".git")
+		if _, err := os.Stat(gitDir); err == nil {
+			if w.opts.Repositories && directories && ps.Match(filePath) {
+				paths = append(paths, filePath+"/")
+			} else {
+				all = false
+			}
+			continue
+		}
+
+		// An untracked directory is removed as a whole if everything inside
+		// it is to be removed. Otherwise the paths inside it are removed one
+		// by one. Removing just the ignored files needs something to remove.
+		if directories {
+			if !ps.MatchInside(filePath) {
+				all = false
+				continue
+			}
+			subPaths, subAll, err := w.walk(filePath, true)
+			if err != nil {
+				return nil, false, err
+			}
+			if w.opts.OnlyIgnored && len(subPaths) == 0 {
+				subAll = false
+			}
+			if subAll && ps.Match(filePath) {
+				paths = append(paths, filePath+"/")
+			} else {
+				paths = append(paths, subPaths...)
+				all = false
+			}
+			continue
+		}
+
+		// Without the directories, only the ignored files inside the
+		// directories which have other files are removed.
+		all = false
+		if !w.opts.OnlyIgnored {
+			continue
+		}
+		subPaths, subAll, err := w.walk(filePath, false)
+		if err != nil {
+			return nil, false, err
+		}
+		if !subAll || len(subPaths) == 0 {
+			paths = append(paths, subPaths...)
+		}
+	}
+
+	return paths, all, nil
+}
+
+// CleanPath removes a path of the work-tree, which is a directory to remove
+// as a whole if it ends with a "/".
+func (r *Repo) CleanPath(filePath string) error {
+	return os.RemoveAll(filepath.Join(r.WorkTree, filepath.FromSlash(filePath)))
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitClean")
+
+	commitTestFiles(t, repo, map[string]string{
+		".gitignore": "*.o\nbuild/\n", "a": "a\n", "t/t": "t\n",
+	})
+
+	for _, filePath := range []string{"u", "x.o", "t/v", "t/y.o", "ud/sub/w", "ud/z.o",
+		"build/b", "ionly/q.o", "nested/.git/HEAD"} {
+		writeTestFile(t, repo, filePath, "\n")
+	}
+	assertEqual(t, os.Mkdir(filepath.Join(repo.WorkTree, "emptyd"), 0755), nil)
+
+	t.Run("Validate the paths to clean", func(t *testing.T) {
+		tests := []struct {
+			opts *CleanOptions
+			want []string
+		}{
+			{&CleanOptions{}, []string{"t/v", "u"}},
+			{&CleanOptions{Directories: true}, []string{"emptyd/", "t/v", "u", "ud/sub/"}},
+			{&CleanOptions{Ignored: true}, []string{"t/v", "t/y.o", "u", "x.o"}},
+			{&CleanOptions{OnlyIgnored: true}, []string{"t/y.o", "ud/z.o", "x.o"}},
+			{&CleanOptions{Directories: true, Ignored: true}, []string{
+				"build/", "emptyd/", "ionly/", "t/v", "t/y.o", "u", "ud/", "x.o"}},
+			{&CleanOptions{Directories: true, OnlyIgnored: true}, []string{
+				"build/", "ionly/", "t/y.o", "ud/z.o", "x.o"}},
+			{&CleanOptions{Directories: true, Repositories: true}, []string{
+				"emptyd/", "nested/", "t/v", "u", "ud/sub/"}},
+			{&CleanOptions{Directories: true, Pathspec: NewPathspec([]string{"ud"})},
+				[]string{"ud/sub/"}},
+			{&CleanOptions{Directories: true, Pathspec: NewPathspec([]string{"nested"})},
+				[]string{}},
+		}
+		for _, test := range tests {
+			paths, err := repo.CleanPaths(test.opts)
+			assertEqual(t, err, nil)
+			assertEqual(t, paths, test.want)
+		}
+	})
+
+	t.Run("Validate cleaning the paths", func(t *testing.T) {
+		paths, err := repo.CleanPaths(&CleanOptions{Directories: true})
+		assertEqual(t, err, nil)
+		for _, filePath := range paths {
+			assertEqual(t, repo.CleanPath(filePath), nil)
+		}
+
+		status, err := repo.Status(&StatusOptions{Untracked: "all"})
+		assertEqual(t, err, nil)
+		assertEqual(t, status.Untracked, []string{"nested/"})
+		_, err = os.Stat(filepath.Join(repo.WorkTree, "ud", "z.o"))
+		assertEqual(t, err, nil)
+	})
+}
@@ -0,0 +1,486 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// combinedLine is a single line of a combined diff. It is either a line of the
+// result, marked with '+' for the parents which don't have it, or a line lost
+// from the parents which are marked with '-'.
+type combinedLine struct {
+	text  string
+	marks []byte
+	// lost tells if the line is present only in some of the parents.
+	lost bool
+	// pos is the position of the line in each parent. For the parents which
+	// don't have the line, it is the position of the next line.
+	pos []int
+	// result is the position of the line in the result, or the position of
+	// the next result line for a lost line.
+	result int
+}
+
+// CombinedDiff compares the tree of a merge commit with the trees of all its
+// parents and returns the files which differ from every parent, in the
+// "git diff --cc" format. Hunks in which the result matches any of the
+// parents are left out. Renames are detected against each parent as per the
+// given options, which may be nil.
+func (r *Repo) CombinedDiff(parentTrees []string, treeHash string,
+	opts *DiffOptions) (string, error) {
+	// Find the files which are changed as compared to every parent.
+	changes := map[string][]FileEntry{}
+	results := map[string]FileEntry{}
+	for i, parentTree := range parentTrees {
+		entries, err := r.DiffTrees(parentTree, treeHash, opts)
+		if err != nil {
+			return "", err
+		}
+
+		for _, entry := range entries {
+			path := entry.Path()
+			if i > 0 && len(changes[path]) != i {
+				continue
+			}
+			changes[path] = append(changes[path], entry.Old)
+			results[path] = entry.New
+		}
+	}
+
+	var b strings.Builder
+	for _, path := range sortedKeys(results) {
+		parents := changes[path]
+		if len(parents) != len(parentTrees) {
+			continue
+		}
+		for i := range parents {
+			parents[i].Path = path
+		}
+
+		patch, err := r.combinedPatch(path, parents, results[path])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(patch)
+	}
+
+	return b.String(), nil
+}
+
+// sortedKeys returns the keys of a map of files in a sorted order.
+func sortedKeys(files map[string]FileEntry) []string {
+	keys := []string{}
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// combinedPatch returns the combined diff of a single file, which is empty if
+// no hunk is left and the modes match.
+func (r *Repo) combinedPatch(path string, parents []FileEntry, result FileEntry) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --cc %s\n", path)
+
+	hashes := []string{}
+	modes := []string{}
+	for _, parent := range parents {
+		hashes = append(hashes, shortHash(parent.Hash))
+		modes = append(modes, padMode(parent.Mode))
+	}
+	fmt.Fprintf(&b, "index %s..%s\n", strings.Join(hashes, ","), shortHash(result.Hash))
+	if result.Hash == "" {
+		fmt.Fprintf(&b, "deleted file mode %s\n", strings.Join(modes, ","))
+		return b.String(), nil
+	}
+	modeDiffers := false
+	for _, mode := range modes {
+		if mode != padMode(result.Mode) {
+			fmt.Fprintf(&b, "mode %s..%s\n", strings.Join(modes, ","),
+				padMode(result.Mode))
+			modeDiffers = true
+			break
+		}
+	}
+
+	resultData, err := r.FileData(result)
+	if err != nil {
+		return "", err
+	}
+	parentData := [][]byte{}
+	binary := IsBinary(resultData)
+	for _, parent := range parents {
+		data, err := r.FileData(parent)
+		if err != nil {
+			return "", err
+		}
+		binary = binary || IsBinary(data)
+		parentData = append(parentData, data)
+	}
+	if binary {
+		b.WriteString("Binary files differ\n")
+		return b.String(), nil
+	}
+
+	resultLines := SplitLines(resultData)
+	lines := combineLines(parentData, resultLines)
+	hunks := combinedHunks(lines, resultLines, len(parents), DefaultContext)
+	if len(hunks) == 0 && !modeDiffers {
+		return "", nil
+	}
+
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+	for _, hunk := range hunks {
+		b.WriteString(hunk)
+	}
+	return b.String(), nil
+}
+
+// combineLines diffs every parent against the result and merges the diffs
+// into a single list of result lines and lost lines.
+func combineLines(parentData [][]byte, result []string) []combinedLine {
+	numParents := len(parentData)
+	resultLines := make([]combinedLine, len(result)+1)
+	lost := make([][]combinedLine, len(result)+1)
+	for j := range resultLines {
+		resultLines[j].marks = []byte(strings.Repeat(" ", numParents))
+		resultLines[j].pos = make([]int, numParents)
+		resultLines[j].result = j
+		if j < len(result) {
+			resultLines[j].text = result[j]
+		}
+	}
+
+	for i, data := range parentData {
+		parent := SplitLines(data)
+		resultLines[len(result)].pos[i] = len(parent)
+
+		removed := make([][]int, len(result)+1)
+		for _, edit := range DiffLines(parent, result) {
+			switch edit.Op {
+			case LineEqual:
+				resultLines[edit.New].pos[i] = edit.Old
+			case LineInsert:
+				resultLines[edit.New].marks[i] = '+'
+				resultLines[edit.New].pos[i] = edit.Old
+			case LineDelete:
+				removed[edit.New] = append(removed[edit.New], edit.Old)
+			}
+		}
+		for j := range removed {
+			if len(removed[j]) > 0 {
+				lost[j] = coalesceLost(lost[j], parent, removed[j], i, j, numParents)
+			}
+		}
+	}
+
+	// The lost lines come before the result line at the same position. The
+	// position of a lost line in the parents which don't have it is the
+	// position of the next line of that parent.
+	lines := []combinedLine{}
+	for j := range resultLines {
+		lines = append(lines, lost[j]...)
+		if j < len(result) {
+			lines = append(lines, resultLines[j])
+		}
+	}
+	next := resultLines[len(result)].pos
+	for k := len(lines) - 1; k >= 0; k-- {
+		line := lines[k]
+		for i := range line.pos {
+			if line.marks[i] == ' ' && line.lost {
+				line.pos[i] = next[i]
+			} else {
+				next[i] = line.pos[i]
+			}
+		}
+	}
+
+	return lines
+}
+
+// coalesceLost merges the lines removed from a parent before a result line
+// with the lines lost from the earlier parents there. Like "git", the lines
+// are matched by their longest common subsequence, and the other removed
+// lines are added after the lost lines which precede them.
+func coalesceLost(base []combinedLine, parent []string, removed []int,
+	parentNum, result, numParents int) []combinedLine {
+	lcs := make([][]int, len(base)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(removed)+1)
+	}
+	for i := 1; i <= len(base); i++ {
+		for j := 1; j <= len(removed); j++ {
+			if base[i-1].text == parent[removed[j-1]] {
+				lcs[i][j] = lcs[i-1][j-1] + 1
+			} else if lcs[i][j-1] >= lcs[i-1][j] {
+				lcs[i][j] = lcs[i][j-1]
+			} else {
+				lcs[i][j] = lcs[i-1][j]
+			}
+		}
+	}
+
+	lines := make([]combinedLine, len(base)+len(removed)-lcs[len(base)][len(removed)])
+	k := len(lines)
+	for i, j := len(base), len(removed); i > 0 || j > 0; {
+		k--
+		switch {
+		case i > 0 && j > 0 && base[i-1].text == parent[removed[j-1]]:
+			base[i-1].marks[parentNum] = '-'
+			base[i-1].pos[parentNum] = removed[j-1]
+			lines[k] = base[i-1]
+			i--
+			j--
+		case i == 0 || (j > 0 && lcs[i][j-1] >= lcs[i-1][j]):
+			line := combinedLine{
+				text:   parent[removed[j-1]],
+				marks:  []byte(strings.Repeat(" ", numParents)),
+				lost:   true,
+				pos:    make([]int, numParents),
+				result: result,
+			}
+			line.marks[parentNum] = '-'
+			line.pos[parentNum] = removed[j-1]
+			lines[k] = line
+			j--
+		default:
+			lines[k] = base[i-1]
+			i--
+		}
+	}
+
+	return lines
+}
+
+// combinedHunks groups the changed lines of a combined diff into hunks with
+// the given number of context lines. Like "git diff --cc", the lines lost
+// from the parents go along with the result line which follows them, and a
+// group of changes is dropped if they are all from the same parents, unless
+// they are from all of them. The result is the same as the other parents
+// there.
+func combinedHunks(lines []combinedLine, result []string, numParents, context int) []string {
+	// The lines lost at the end of the file go along with an extra line.
+	n := len(result) + 1
+	first := make([]int, n+1)
+	for j, k := 0, 0; j < n; j++ {
+		first[j] = k
+		for k < len(lines) && lines[k].result == j {
+			k++
+		}
+	}
+	first[n] = len(lines)
+
+	changed := func(line combinedLine) bool {
+		return strings.TrimSpace(string(line.marks)) != ""
+	}
+	added := func(j int) bool {
+		return j < len(result) && changed(lines[first[j+1]-1])
+	}
+	mark := make([]bool, n)
+	for j := range mark {
+		mark[j] = added(j) || (first[j] < first[j+1] && lines[first[j]].lost)
+	}
+
+	// tail leaves out the last line of a group if it only has lost lines, as
+	// they are shown before it.
+	tail := func(begin, end int) int {
+		if begin+1 <= end && !added(end-1) {
+			return end - 1
+		}
+		return end
+	}
+
+	all := strings.Repeat("x", numParents)
+	parents := func(line combinedLine) string {
+		return strings.Map(func(r rune) rune {
+			if r != ' ' {
+				return 'x'
+			}
+			return r
+		}, string(line.marks))
+	}
+	for i := 0; i < n; {
+		if !mark[i] {
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < n {
+			if mark[end] {
+				end++
+				continue
+			}
+			next := tail(i, end) + context
+			if next > n {
+				next = n
+			}
+			for next > end && !mark[next-1] {
+				next--
+			}
+			if next == end {
+				break
+			}
+			end = next
+		}
+
+		same, interesting := "", false
+		for _, line := range lines[first[i]:first[end]] {
+			if !changed(line) {
+				continue
+			}
+			if same == "" {
+				same = parents(line)
+			} else if same != parents(line) {
+				interesting = true
+				break
+			}
+		}
+		if !interesting && same != all {
+			for j := i; j < end; j++ {
+				mark[j] = false
+			}
+		}
+		i = end
+	}
+
+	// Add the context lines, joining the groups with short gaps. The lines
+	// lost before the leading context lines are not shown.
+	noLost := make([]bool, n)
+	i := 0
+	for i < n && !mark[i] {
+		i++
+	}
+	for i < n {
+		for j := i - context; j < i; j++ {
+			if j >= 0 && !mark[j] {
+				noLost[j] = true
+				mark[j] = true
+			}
+		}
+
+		for {
+			j := i
+			for j < n && mark[j] {
+				j++
+			}
+			if j == n {
+				i = n
+				break
+			}
+			k := j
+			for k < n && !mark[k] {
+				k++
+			}
+			j = tail(i, j)
+			if k < j+context {
+				for ; j < k; j++ {
+					mark[j] = true
+				}
+				i = k
+				continue
+			}
+
+			for stop := j + context; j < stop && j < n; j++ {
+				mark[j] = true
+			}
+			i = k
+			break
+		}
+	}
+
+	hunks := []string{}
+	comment := ""
+	for i := 0; i < n; {
+		if !mark[i] {
+			if i < len(result) && isCommentLine(result[i]) {
+				comment = result[i]
+			}
+			i++
+			continue
+		}
+
+		end := i
+		for end < n && mark[end] {
+			end++
+		}
+		shown := []combinedLine{}
+		for j := i; j < end; j++ {
+			for k := first[j]; k < first[j+1]; k++ {
+				if !noLost[j] || !lines[k].lost {
+					shown = append(shown, lines[k])
+				}
+			}
+		}
+		hunks = append(hunks, combinedHunk(lines[first[i]:first[end]], shown,
+			i, comment, numParents))
+		comment = ""
+		i = end
+	}
+
+	return hunks
+}
+
+// isCommentLine tells if a line is shown in the header of the next combined
+// hunk.
+func isCommentLine(line string) bool {
+	if line == "" {
+		return false
+	}
+	c := line[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '$'
+}
+
+// combinedHunk formats a single hunk of a combined diff, made of the given
+// lines from the given result line. Only the shown lines are printed, but
+// the line counts include all of them.
+func combinedHunk(lines, shown []combinedLine, start int, comment string,
+	numParents int) string {
+	ranges := []string{}
+	for i := 0; i < numParents; i++ {
+		count := 0
+		for _, line := range lines {
+			if (line.lost && line.marks[i] == '-') || (!line.lost && line.marks[i] != '+') {
+				count++
+			}
+		}
+		ranges = append(ranges, fmt.Sprintf("-%d,%d", lines[0].pos[i]+1, count))
+	}
+
+	resultCount := 0
+	for _, line := range lines {
+		if !line.lost {
+			resultCount++
+		}
+	}
+
+	var b strings.Builder
+	marker := strings.Repeat("@", numParents+1)
+	fmt.Fprintf(&b, "%s %s +%d,%d %s", marker, strings.Join(ranges, " "),
+		start+1, resultCount, marker)
+
+	// Like "git", the comment is cut to 40 characters and misses its last
+	// non-blank character.
+	if len(comment) > 40 {
+		comment = comment[:40]
+	}
+	if end := strings.IndexByte(comment, '\n'); end >= 0 {
+		comment = comment[:end]
+	}
+	if comment = strings.TrimRight(comment, " \t\v\f\r"); len(comment) > 1 {
+		fmt.Fprintf(&b, " %s", comment[:len(comment)-1])
+	}
+	fmt.Fprintln(&b)
+	for _, line := range shown {
+		b.Write(line.marks)
+		b.WriteString(line.text)
+		if !strings.HasSuffix(line.text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCombinedDiff(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitCombined")
+
+	parent1 := writeTestTree(t, repo, map[string]string{
+		"a.txt": "hi\nx\ny\n",
+		"b.txt": "same\n",
+	})
+	parent2 := writeTestTree(t, repo, map[string]string{
+		"a.txt": "hi\nx\nz\n",
+		"b.txt": "changed\n",
+	})
+	merged := writeTestTree(t, repo, map[string]string{
+		"a.txt": "hi\nx\ny\nz\nmerged\n",
+		"b.txt": "changed\n",
+	})
+
+	// "b.txt" is the same as the second parent, so it is left out.
+	t.Run("Validate combined diff", func(t *testing.T) {
+		got, err := repo.CombinedDiff([]string{parent1, parent2}, merged, nil)
+		assertEqual(t, err, nil)
+		want := "diff --cc a.txt\n" +
+			"index cb31d13,a6bd44e..1299626\n" +
+			"--- a/a.txt\n" +
+			"+++ b/a.txt\n" +
+			"@@@ -1,3 -1,3 +1,5 @@@\n" +
+			"  hi\n" +
+			"  x\n" +
+			" +y\n" +
+			"+ z\n" +
+			"++merged\n"
+		assertEqual(t, got, want)
+	})
+
+	// "a.txt" is renamed to "c.txt" in the second parent, and the merge
+	// keeps the new name with the content of the first parent. It is not a
+	// change from the first parent once the rename is detected.
+	t.Run("Validate combined diff with renames", func(t *testing.T) {
+		renamed := writeTestTree(t, repo, map[string]string{
+			"b.txt": "same\n",
+			"c.txt": "hi\nx\nz\n",
+		})
+		merged := writeTestTree(t, repo, map[string]string{
+			"b.txt": "same\n",
+			"c.txt": "hi\nx\ny\n",
+		})
+
+		got, err := repo.CombinedDiff([]string{parent1, renamed}, merged, nil)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, "diff --cc c.txt\n"+
+			"index 0000000,a6bd44e..cb31d13\n"+
+			"mode 000000,100644..100644\n"+
+			"--- a/c.txt\n"+
+			"+++ b/c.txt\n"+
+			"@@@ -1,0 -1,3 +1,3 @@@\n"+
+			"+ hi\n"+
+			"+ x\n"+
+			" -z\n"+
+			"++y\n")
+
+		opts := &DiffOptions{DetectRenames: true, RenameScore: DefaultRenameScore}
+		got, err = repo.CombinedDiff([]string{parent1, renamed}, merged, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, "")
+	})
+
+	// Only the line changed in both parents is shown. The other lines are
+	// the same as in one of the parents.
+	t.Run("Validate combined diff of lines changed in one parent", func(t *testing.T) {
+		lines := func(changes map[string]string) string {
+			var b strings.Builder
+			for i := 1; i <= 20; i++ {
+				line := strconv.Itoa(i)
+				if change, ok := changes[line]; ok {
+					line = change
+				}
+				b.WriteString(line + "\n")
+			}
+			return b.String()
+		}
+		parent1 := writeTestTree(t, repo, map[string]string{
+			"c.txt": lines(map[string]string{"5": "five"}),
+		})
+		parent2 := writeTestTree(t, repo, map[string]string{
+			"c.txt": lines(map[string]string{"15": "fifteen"}),
+		})
+		merged := writeTestTree(t, repo, map[string]string{
+			"c.txt": lines(map[string]string{"5": "five", "10": "ten", "15": "fifteen"}),
+		})
+
+		got, err := repo.CombinedDiff([]string{parent1, parent2}, merged, nil)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, "diff --cc c.txt\n"+
+			"index fb3ced1,8ac2d19..e6e1f30\n"+
+			"--- a/c.txt\n"+
+			"+++ b/c.txt\n"+
+			"@@@ -7,7 -7,7 +7,7 @@@ fiv\n"+
+			"  7\n"+
+			"  8\n"+
+			"  9\n"+
+			"--10\n"+
+			"++ten\n"+
+			"  11\n"+
+			"  12\n"+
+			"  13\n")
+
+		got, err = repo.CombinedDiff([]string{parent1, merged}, merged, nil)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, "")
+	})
+
+	t.Run("Validate tag parsing and peeling", func(t *testing.T) {
+		blobHash, err := repo.ObjectWrite(NewObject("blob", []byte("hi\n")), true)
+		assertEqual(t, err, nil)
+		data := "object " + blobHash + "\ntype blob\ntag v1\n" +
+			"tagger A U Thor <author@example.com> 1600000000 +0530\n\nrelease\n"
+		tagHash, err := repo.ObjectWrite(NewObject("tag", []byte(data)), true)
+		assertEqual(t, err, nil)
+
+		obj, err := repo.ObjectParse(tagHash)
+		assertEqual(t, err, nil)
+		tag, err := NewTag(repo, obj)
+		assertEqual(t, err, nil)
+		assertEqual(t, tag.Name(), "v1")
+		assertEqual(t, tag.TargetType(), "blob")
+		assertEqual(t, tag.Print(), data)
+		assertEqual(t, tag.PrettyPrint(), "tag v1\n"+
+			"Tagger: A U Thor <author@example.com>\n"+
+			"Date:   Sun Sep 13 17:56:40 2020 +0530\n\nrelease\n")
+
+		peeled, peeledHash, err := repo.PeelObject(tagHash)
+		assertEqual(t, err, nil)
+		assertEqual(t, peeledHash, blobHash)
+		assertEqual(t, peeled.ObjType, "blob")
+	})
+}
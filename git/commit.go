@@ -53,22 +53,37 @@ func NewCommit(repo *Repo, obj *Object) (*Commit, error) {
 // 'parent' hash, and a given commit message.
 // This can be used by CLI commands such as "gogit commit-tree".
 func NewCommitFromParams(repo *Repo, treeHash, parentHash, msg string) (*Commit, error) {
+	parents := []string{}
+	if parentHash != "" {
+		parents = append(parents, parentHash)
+	}
+	return NewCommitFromParents(repo, treeHash, parents, msg)
+}
+
+// NewCommitFromParents builds a commit object using a 'tree', any number of
+// 'parent' hashes (such as two for a merge), and a given commit message.
+func NewCommitFromParents(repo *Repo, treeHash string, parents []string, msg string) (*Commit, error) {
+	return NewCommitWithAuthor(repo, treeHash, parents, "", msg)
+}
+
+// NewCommitWithAuthor builds a commit object like NewCommitFromParents, but
+// keeps the given 'author' value (name, email and time) of another commit,
+// such as the one being cherry-picked. An empty value uses the committer.
+func NewCommitWithAuthor(repo *Repo, treeHash string, parents []string, author, msg string) (*Commit, error) {
 	data := []byte{}
 	data = append(data, []byte("tree "+treeHash+"\n")...)
-	if parentHash != "" {
+	for _, parentHash := range parents {
 		data = append(data, []byte("parent "+parentHash+"\n")...)
 	}
 
-	// Get the current time in <epoch zone-offset> format
-	// Example: 1589530357 -0700
-	cTime := time.Now()
-	timeStamp := strconv.FormatInt(cTime.Unix(), 10) + " " + cTime.Format("-0700")
-
 	// Build author and commiter values
-	authorValue := fmt.Sprintf("%s <%s> %s", AuthorName, AuthorEmail, timeStamp)
+	committerValue := currentIdentity()
+	if author == "" {
+		author = committerValue
+	}
 
-	data = append(data, []byte("author "+authorValue+"\n")...)
-	data = append(data, []byte("committer "+authorValue+"\n")...)
+	data = append(data, []byte("author "+author+"\n")...)
+	data = append(data, []byte("committer "+committerValue+"\n")...)
 	data = append(data, byte('\n'))
 	data = append(data, []byte(msg)...)
 
@@ -76,6 +91,16 @@ func NewCommitFromParams(repo *Repo, treeHash, parentHash, msg string) (*Commit,
 	return NewCommit(repo, obj)
 }
 
+// currentIdentity returns the name and email of the user along with the
+// current time, as recorded in a commit or a reflog entry.
+// Example: "Shyamsunder Rathi <sxxxxxx@gmail.com> 1589530357 -0700"
+func currentIdentity() string {
+	// Get the current time in <epoch zone-offset> format
+	cTime := time.Now()
+	timeStamp := strconv.FormatInt(cTime.Unix(), 10) + " " + cTime.Format("-0700")
+	return fmt.Sprintf("%s <%s> %s", AuthorName, AuthorEmail, timeStamp)
+}
+
 // Type returns the type string of a commit object.
 func (commit *Commit) Type() string {
 	return "commit"
@@ -110,6 +135,48 @@ func (commit *Commit) Author() (name, email string) {
 	return
 }
 
+// CleanupMessage strips the comment lines starting with "#" from a message
+// edited by the user, along with the trailing spaces of the lines and the
+// extra blank lines, like "git commit" does.
+func CleanupMessage(msg string) string {
+	lines := []string{}
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	msg = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	if msg == "" {
+		return ""
+	}
+	return msg + "\n"
+}
+
+// Identity returns the person of an "author" or a "committer" entry of a
+// commit as "name <email>", and the date in the format of "git log".
+func (commit *Commit) Identity(key string) (string, string) {
+	values := commit.Entries[key]
+	if len(values) == 0 {
+		return "", ""
+	}
+	return formatIdentity(values[0])
+}
+
+// Subject returns the first line of the message of a commit.
+func (commit *Commit) Subject() string {
+	msg := strings.TrimLeft(commit.Msg, "\n")
+	if ind := strings.IndexByte(msg, '\n'); ind >= 0 {
+		msg = msg[:ind]
+	}
+	return strings.TrimSpace(msg)
+}
+
 // Print returns a string representation of a commit object.
 func (commit *Commit) Print() string {
 	var b strings.Builder
@@ -143,21 +210,19 @@ func (commit *Commit) PrettyPrint() (string, error) {
 	} else {
 		fmt.Fprintf(&b, "commit %s\n", commitHash)
 	}
-	authorEntry := commit.Entries["author"][0]
-	// Author line is in the following format:
-	// "<name1 name2 ...> <email> <epoch seconds> <timezone>"
-	// Example: "Shyamsunder Rathi <sxxxxxx@gmail.com> 1589619289 -0700"
-	items := strings.Fields(authorEntry)
-	timezone := items[len(items)-1]
-	epoch, _ := strconv.ParseInt(items[len(items)-2], 10, 64)
-	epochTime := time.Unix(epoch, 0)
-	// "git" time format in logs: "Sat May 16 19:26:38 2020 -0700"
-	timeStr := epochTime.Format("Mon Jan 02 15:04:05 2006")
 
-	email := items[len(items)-3]
-	author := strings.Join(items[:len(items)-3], " ")
-	fmt.Fprintf(&b, "Author: %s %s\n", author, email)
-	fmt.Fprintf(&b, "Date:   %s %s\n", timeStr, timezone)
+	// A merge commit lists the short hashes of all its parents.
+	if parents := commit.Parents(); len(parents) > 1 {
+		short := []string{}
+		for _, parent := range parents {
+			short = append(short, parent[:7])
+		}
+		fmt.Fprintf(&b, "Merge: %s\n", strings.Join(short, " "))
+	}
+
+	author, date := formatIdentity(commit.Entries["author"][0])
+	fmt.Fprintf(&b, "Author: %s\n", author)
+	fmt.Fprintf(&b, "Date:   %s\n", date)
 
 	// Print a blank line followed by the commit message.
 	fmt.Fprintln(&b)
@@ -179,6 +244,13 @@ func (commit *Commit) PrettyPrint() (string, error) {
 // ParseData parses a commit object's bytes and prepares a dictionary of its
 // components.
 func (commit *Commit) ParseData() error {
+	commit.Entries, commit.Keys, commit.Msg = parseObjectHeaders(commit.ObjData)
+	return nil
+}
+
+// parseObjectHeaders parses the data of a commit or a tag object into its
+// key-values (along with the order of the keys) and the message.
+func parseObjectHeaders(objData []byte) (entries entryMap, keys []string, msg string) {
 	/* Commit object has the following format:
 	<key1> <value1>\n
 	<key2> <value2 ...>\n
@@ -188,9 +260,11 @@ func (commit *Commit) ParseData() error {
 	...
 	<blank line>
 	<Remaining lines are part of commit-message. */
-	datalen := len(commit.ObjData)
+	entries = entryMap{}
+	keys = []string{}
+	datalen := len(objData)
 	for start := 0; start < datalen; {
-		data := commit.ObjData[start:]
+		data := objData[start:]
 
 		spaceInd := bytes.IndexByte(data, byte(' '))
 		newLenInd := bytes.IndexByte(data, byte('\n'))
@@ -201,7 +275,7 @@ func (commit *Commit) ParseData() error {
 		// Once a blank line is found, remaining lines are part of commit msg.
 		if spaceInd < 0 || newLenInd < spaceInd {
 			// Blank line, so remaining data is part of the commit msg.
-			commit.Msg = string(data[1:])
+			msg = string(data[1:])
 			break
 		}
 
@@ -213,7 +287,7 @@ func (commit *Commit) ParseData() error {
 		end := -1
 		for {
 			end += bytes.IndexByte(data[end+1:], byte('\n')) + 1
-			if data[end+1] != byte(' ') {
+			if end+1 >= len(data) || data[end+1] != byte(' ') {
 				// This is not a continuation line, so stop!
 				break
 			}
@@ -226,17 +300,43 @@ func (commit *Commit) ParseData() error {
 
 		// Save the key for insertion order if not already seen.
 		// All keys with same values appear together in a commit msg.
-		if _, ok := commit.Entries[key]; !ok {
-			commit.Keys = append(commit.Keys, key)
+		if _, ok := entries[key]; !ok {
+			keys = append(keys, key)
 		}
 
 		// There can be multiple values for a single key.
 		// Such as, there can be more than one 'parent' key for a commit.
-		commit.Entries[key] = append(commit.Entries[key], value)
+		entries[key] = append(entries[key], value)
 
 		// Move on to the next key-value pair.
 		start += (end + 1)
 	}
 
-	return nil
+	return entries, keys, msg
+}
+
+// formatIdentity splits an "author", "committer" or "tagger" value into the
+// identity of the person and the time, in the format shown by "git log".
+// Example: ("Shyamsunder Rathi <sxxxxxx@gmail.com>",
+// "Sat May 16 19:26:38 2020 -0700")
+func formatIdentity(value string) (string, string) {
+	// The value is in the following format:
+	// "<name1 name2 ...> <email> <epoch seconds> <timezone>"
+	// Example: "Shyamsunder Rathi <sxxxxxx@gmail.com> 1589619289 -0700"
+	items := strings.Fields(value)
+	if len(items) < 3 {
+		return value, ""
+	}
+	timezone := items[len(items)-1]
+	epoch, _ := strconv.ParseInt(items[len(items)-2], 10, 64)
+
+	// Show the time in the timezone of the person, like "git" does.
+	location := time.Local
+	if zone, err := time.Parse("-0700", timezone); err == nil {
+		_, offset := zone.Zone()
+		location = time.FixedZone("", offset)
+	}
+	timeStr := time.Unix(epoch, 0).In(location).Format("Mon Jan 2 15:04:05 2006")
+
+	return strings.Join(items[:len(items)-2], " "), timeStr + " " + timezone
 }
@@ -0,0 +1,266 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds the key-value pairs read from one or more git configuration
+// files. Keys are stored as "section.name" or "section.subsection.name", with
+// the section and the name in lower case (subsections are case sensitive).
+type Config struct {
+	values map[string][]string
+}
+
+// NewConfig returns an empty configuration.
+func NewConfig() *Config {
+	return &Config{
+		values: map[string][]string{},
+	}
+}
+
+// Config reads the global configuration files of the user followed by the
+// configuration file of the repository. Values in later files take precedence.
+// A repo without a git directory (see NewMemoryRepo) has the global ones only.
+func (r *Repo) Config() (*Config, error) {
+	config := NewConfig()
+	for _, file := range globalConfigFiles() {
+		if err := config.ParseFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if r.GitDir == "" {
+		return config, nil
+	}
+
+	configFile, err := r.FilePath(false, "config")
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ParseFile(configFile); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// globalConfigFiles returns the per-user configuration files in the order in
+// which "git" reads them.
+func globalConfigFiles() []string {
+	files := []string{}
+	xdgHome := os.Getenv("XDG_CONFIG_HOME")
+	home := os.Getenv("HOME")
+	if xdgHome == "" && home != "" {
+		xdgHome = filepath.Join(home, ".config")
+	}
+	if xdgHome != "" {
+		files = append(files, filepath.Join(xdgHome, "git", "config"))
+	}
+	if home != "" {
+		files = append(files, filepath.Join(home, ".gitconfig"))
+	}
+
+	return files
+}
+
+// ParseFile reads a configuration file and adds all its values. A missing
+// file is not an error, as all the configuration files are optional.
+func (c *Config) ParseFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.Parse(data); err != nil {
+		return fmt.Errorf("fatal: bad config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Parse adds all the values given in the git configuration file format.
+//
+//	[section]
+//		name = value
+//	[section "subsection"]
+//		name = "quoted value" ; comment
+func (c *Config) Parse(data []byte) error {
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		// A value can continue on the next line if it ends with a backslash.
+		for strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") &&
+			scanner.Scan() {
+			lineNum++
+			line = line[:len(line)-1] + scanner.Text()
+		}
+
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return fmt.Errorf("line %d: bad section header", lineNum)
+			}
+			section = parseSectionHeader(line[1:end])
+			// A key-value pair may follow the header on the same line.
+			line = strings.TrimSpace(line[end+1:])
+			if line == "" || line[0] == '#' || line[0] == ';' {
+				continue
+			}
+		}
+
+		if section == "" {
+			return fmt.Errorf("line %d: key outside of a section", lineNum)
+		}
+
+		// A name without any value is a boolean "true".
+		name, value := line, "true"
+		if ind := strings.IndexByte(line, '='); ind >= 0 {
+			name = strings.TrimSpace(line[:ind])
+			value = parseConfigValue(line[ind+1:])
+		}
+		key := section + "." + strings.ToLower(name)
+		c.values[key] = append(c.values[key], value)
+	}
+
+	return scanner.Err()
+}
+
+// parseSectionHeader converts 'section "subsection"' or the deprecated
+// 'section.subsection' header into the key prefix used by Config.
+func parseSectionHeader(header string) string {
+	header = strings.TrimSpace(header)
+	if ind := strings.IndexByte(header, '"'); ind >= 0 {
+		name := strings.ToLower(strings.TrimSpace(header[:ind]))
+		sub := strings.TrimSuffix(header[ind+1:], "\"")
+		sub = strings.ReplaceAll(sub, "\\\"", "\"")
+		sub = strings.ReplaceAll(sub, "\\\\", "\\")
+		return name + "." + sub
+	}
+
+	if ind := strings.IndexByte(header, '.'); ind >= 0 {
+		return strings.ToLower(header[:ind]) + header[ind:]
+	}
+	return strings.ToLower(header)
+}
+
+// parseConfigValue strips comments and quotes from a raw value and resolves
+// the escape sequences in it.
+func parseConfigValue(raw string) string {
+	var b strings.Builder
+	quoted := false
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case ch == '"':
+			quoted = !quoted
+		case ch == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			default:
+				b.WriteByte(raw[i])
+			}
+		case (ch == '#' || ch == ';') && !quoted:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return strings.TrimRight(b.String(), " \t")
+}
+
+// normalizeKey lower-cases the section and the name of a configuration key.
+func normalizeKey(key string) string {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first < 0 {
+		return strings.ToLower(key)
+	}
+
+	return strings.ToLower(key[:first]) + key[first:last] +
+		strings.ToLower(key[last:])
+}
+
+// Get returns the last value set for a key, and whether the key is present.
+func (c *Config) Get(key string) (string, bool) {
+	values := c.values[normalizeKey(key)]
+	if len(values) == 0 {
+		return "", false
+	}
+
+	return values[len(values)-1], true
+}
+
+// GetAll returns all the values set for a key, in the order they were read.
+func (c *Config) GetAll(key string) []string {
+	return c.values[normalizeKey(key)]
+}
+
+// GetBool returns the boolean value of a key, or 'def' if the key is not
+// present or is not a valid boolean.
+func (c *Config) GetBool(key string, def bool) bool {
+	value, ok := c.Get(key)
+	if !ok {
+		return def
+	}
+
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0", "":
+		return false
+	}
+	return def
+}
+
+// GetInt returns the integer value of a key, or 'def' if the key is not
+// present or is not a valid integer. A "k", "m" or "g" suffix scales the value
+// by 1024, 1024^2 or 1024^3 respectively.
+func (c *Config) GetInt(key string, def int) int {
+	value, ok := c.Get(key)
+	if !ok || value == "" {
+		return def
+	}
+
+	scale := 1
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		scale = 1024
+	case "m":
+		scale = 1024 * 1024
+	case "g":
+		scale = 1024 * 1024 * 1024
+	}
+	if scale != 1 {
+		value = value[:len(value)-1]
+	}
+
+	num, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return num * scale
+}
@@ -0,0 +1,323 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Diff status letters used by "git diff --name-status" and raw diff output.
+const (
+	DiffAdded    byte = 'A'
+	DiffDeleted  byte = 'D'
+	DiffModified byte = 'M'
+	DiffType     byte = 'T'
+	DiffRenamed  byte = 'R'
+	DiffCopied   byte = 'C'
+)
+
+// FileEntry is a single file (a blob, a symlink or a gitlink) identified by its
+// full path from the top of a tree.
+type FileEntry struct {
+	Path string
+	Mode string
+	Hash string
+}
+
+// DiffEntry is a single file level change between two trees. 'Old' is empty
+// for added files and 'New' is empty for deleted files. 'Score' is the
+// similarity percentage between the two sides of a rename or a copy.
+type DiffEntry struct {
+	Status byte
+	Old    FileEntry
+	New    FileEntry
+	Score  int
+}
+
+// DiffOptions controls how the differences between two trees are computed.
+type DiffOptions struct {
+	// DetectRenames pairs deleted and added files with similar content.
+	DetectRenames bool
+	// DetectCopies also pairs added files with modified files.
+	DetectCopies bool
+	// FindCopiesHarder also pairs added files with unmodified files.
+	FindCopiesHarder bool
+	// RenameScore is the minimum similarity percentage for a rename or a copy.
+	RenameScore int
+	// RenameLimit skips inexact detection if the number of the sources times
+	// the number of the destinations is more than its square, like "git".
+	// Zero means no limit.
+	RenameLimit int
+}
+
+const (
+	// DefaultRenameScore is the similarity percentage used by "-M" and "-C"
+	// if none is given.
+	DefaultRenameScore int = 50
+	// DefaultRenameLimit is used if "diff.renameLimit" is not configured.
+	DefaultRenameLimit int = 1000
+)
+
+// Path returns the path which best describes the change. It is the new path
+// unless the file was deleted.
+func (entry *DiffEntry) Path() string {
+	if entry.New.Path != "" {
+		return entry.New.Path
+	}
+	return entry.Old.Path
+}
+
+// StatusString returns the status letter of the change, followed by the
+// similarity score for renames and copies. Example: "M", "R086".
+func (entry *DiffEntry) StatusString() string {
+	if entry.Status == DiffRenamed || entry.Status == DiffCopied {
+		return fmt.Sprintf("%c%03d", entry.Status, entry.Score)
+	}
+	return string(entry.Status)
+}
+
+// Raw returns the change in the "raw" format of "git diff-tree".
+// Example: ":100644 100644 <old hash> <new hash> R086<tab>old<tab>new"
+func (entry *DiffEntry) Raw() string {
+	oldMode, oldHash := padMode(entry.Old.Mode), entry.Old.Hash
+	newMode, newHash := padMode(entry.New.Mode), entry.New.Hash
+	if oldHash == "" {
+		oldHash = nullHash
+	}
+	if newHash == "" {
+		newHash = nullHash
+	}
+
+	return fmt.Sprintf(":%s %s %s %s %s", oldMode, newMode, oldHash, newHash,
+		entry.NameStatus())
+}
+
+// NameStatus returns the change in the format of "git diff --name-status".
+// Example: "M<tab>path" or "R086<tab>old<tab>new"
+func (entry *DiffEntry) NameStatus() string {
+	if entry.Status == DiffRenamed || entry.Status == DiffCopied {
+		return fmt.Sprintf("%s\t%s\t%s", entry.StatusString(), entry.Old.Path,
+			entry.New.Path)
+	}
+	return fmt.Sprintf("%s\t%s", entry.StatusString(), entry.Path())
+}
+
+// DiffTrees finds all the file level differences between two tree objects.
+// An empty tree hash stands for an empty tree, which makes every file of
+// the other tree an addition (or a deletion).
+func (r *Repo) DiffTrees(oldHash, newHash string, opts *DiffOptions) ([]DiffEntry, error) {
+	entries := []DiffEntry{}
+	if err := r.diffTrees("", oldHash, newHash, &entries); err != nil {
+		return nil, err
+	}
+
+	if opts != nil && (opts.DetectRenames || opts.DetectCopies) {
+		var err error
+		entries, err = r.detectRenames(entries, oldHash, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path() < entries[j].Path()
+	})
+	return entries, nil
+}
+
+// DiffTreeToIndex finds the differences between a tree and the files at
+// stage 0 in the index, which are the changes staged for the next commit.
+func (r *Repo) DiffTreeToIndex(treeHash string, index *Index, opts *DiffOptions) ([]DiffEntry, error) {
+	oldFiles, err := r.TreeFiles(treeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files added with "git add -N" are not staged yet.
+	newFiles := []FileEntry{}
+	for _, entry := range index.Entries {
+		if entry.Stage() == 0 && !entry.IntentToAdd() {
+			newFiles = append(newFiles, entry.FileEntry())
+		}
+	}
+
+	entries := diffFiles(oldFiles, newFiles)
+	if opts != nil && (opts.DetectRenames || opts.DetectCopies) {
+		entries, err = r.detectRenames(entries, treeHash, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path() < entries[j].Path()
+	})
+	return entries, nil
+}
+
+// diffFiles compares two lists of files sorted by their paths.
+func diffFiles(oldFiles, newFiles []FileEntry) []DiffEntry {
+	entries := []DiffEntry{}
+	i, j := 0, 0
+	for i < len(oldFiles) || j < len(newFiles) {
+		switch {
+		case j == len(newFiles) || (i < len(oldFiles) && oldFiles[i].Path < newFiles[j].Path):
+			entries = append(entries, DiffEntry{Status: DiffDeleted, Old: oldFiles[i]})
+			i++
+		case i == len(oldFiles) || newFiles[j].Path < oldFiles[i].Path:
+			entries = append(entries, DiffEntry{Status: DiffAdded, New: newFiles[j]})
+			j++
+		default:
+			oldFile, newFile := oldFiles[i], newFiles[j]
+			if oldFile.Hash != newFile.Hash || oldFile.Mode != newFile.Mode {
+				status := DiffModified
+				if modeType(oldFile.Mode) != modeType(newFile.Mode) {
+					status = DiffType
+				}
+				entries = append(entries, DiffEntry{Status: status, Old: oldFile, New: newFile})
+			}
+			i++
+			j++
+		}
+	}
+
+	return entries
+}
+
+// treeEntryMap parses a tree object and maps the name of each entry to it.
+// An empty hash gives an empty map.
+func (r *Repo) treeEntryMap(treeHash string) (map[string]TreeEntry, error) {
+	entries := map[string]TreeEntry{}
+	if treeHash == "" {
+		return entries, nil
+	}
+
+	obj, err := r.ObjectParse(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := NewTree(r, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range tree.Entries {
+		entries[entry.name] = entry
+	}
+	return entries, nil
+}
+
+// diffTrees recursively compares two trees found at 'prefix' and appends the
+// differences to 'entries'. Identical subtrees are skipped without reading them.
+func (r *Repo) diffTrees(prefix, oldHash, newHash string, entries *[]DiffEntry) error {
+	if oldHash == newHash {
+		return nil
+	}
+
+	oldEntries, err := r.treeEntryMap(oldHash)
+	if err != nil {
+		return err
+	}
+	newEntries, err := r.treeEntryMap(newHash)
+	if err != nil {
+		return err
+	}
+
+	// Walk the union of both the trees in a sorted order.
+	names := []string{}
+	for name := range oldEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := oldEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fullPath := path.Join(prefix, name)
+		oldEntry, inOld := oldEntries[name]
+		newEntry, inNew := newEntries[name]
+		oldIsTree := inOld && oldEntry.objType == "tree"
+		newIsTree := inNew && newEntry.objType == "tree"
+
+		if inOld && inNew && oldEntry.hash == newEntry.hash &&
+			oldEntry.mode == newEntry.mode {
+			continue
+		}
+
+		// Both sides are directories, so compare what is inside them.
+		if oldIsTree && newIsTree {
+			err := r.diffTrees(fullPath, oldEntry.hash, newEntry.hash, entries)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// A file is replaced by a directory or vice versa, or only one side
+		// is present. Handle each side on its own.
+		oldFile := FileEntry{fullPath, oldEntry.mode, oldEntry.hash}
+		newFile := FileEntry{fullPath, newEntry.mode, newEntry.hash}
+		if inOld && inNew && !oldIsTree && !newIsTree {
+			status := DiffModified
+			if modeType(oldEntry.mode) != modeType(newEntry.mode) {
+				status = DiffType
+			}
+			*entries = append(*entries, DiffEntry{Status: status, Old: oldFile, New: newFile})
+			continue
+		}
+
+		if oldIsTree {
+			if err := r.diffTrees(fullPath, oldEntry.hash, "", entries); err != nil {
+				return err
+			}
+		} else if inOld {
+			*entries = append(*entries, DiffEntry{Status: DiffDeleted, Old: oldFile})
+		}
+
+		if newIsTree {
+			if err := r.diffTrees(fullPath, "", newEntry.hash, entries); err != nil {
+				return err
+			}
+		} else if inNew {
+			*entries = append(*entries, DiffEntry{Status: DiffAdded, New: newFile})
+		}
+	}
+
+	return nil
+}
+
+// TreeFiles recursively lists all the files inside a tree object, sorted by
+// their full paths.
+func (r *Repo) TreeFiles(treeHash string) ([]FileEntry, error) {
+	entries := []DiffEntry{}
+	if err := r.diffTrees("", "", treeHash, &entries); err != nil {
+		return nil, err
+	}
+
+	files := make([]FileEntry, len(entries))
+	for i, entry := range entries {
+		files[i] = entry.New
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// nullHash is shown in place of the hash of a missing file.
+const nullHash string = "0000000000000000000000000000000000000000"
+
+// padMode prepends 0s to a file mode to make it 6 characters long.
+func padMode(mode string) string {
+	return strings.Repeat("0", 6-len(mode)) + mode
+}
+
+// modeType returns the kind of file represented by a git file mode, ignoring
+// the permission bits. Example: "100755" and "100644" are both "100".
+func modeType(mode string) string {
+	return padMode(mode)[:3]
+}
@@ -0,0 +1,157 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// nameStatus converts diff entries to their "--name-status" format.
+func nameStatus(entries []DiffEntry) []string {
+	lines := []string{}
+	for _, entry := range entries {
+		lines = append(lines, entry.NameStatus())
+	}
+	return lines
+}
+
+func TestDiffTrees(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitDiff")
+
+	lines := func(from, to int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&b, "line number %d\n", i)
+		}
+		return b.String()
+	}
+
+	oldTree := writeTestTree(t, repo, map[string]string{
+		"a.txt":     lines(1, 100),
+		"b.txt":     lines(200, 300),
+		"c.txt":     "hello\n",
+		"dir/d.txt": lines(400, 500),
+	})
+	newTree := writeTestTree(t, repo, map[string]string{
+		"moved/a.txt": lines(1, 95),
+		"b.txt":       lines(200, 300),
+		"c.txt":       "hello world\n",
+		"dir/e.txt":   lines(400, 500),
+		"f.txt":       lines(200, 300),
+	})
+
+	t.Run("Validate diff without rename detection", func(t *testing.T) {
+		entries, err := repo.DiffTrees(oldTree, newTree, nil)
+		assertEqual(t, err, nil)
+		assertEqual(t, nameStatus(entries), []string{
+			"D\ta.txt", "M\tc.txt", "D\tdir/d.txt", "A\tdir/e.txt",
+			"A\tf.txt", "A\tmoved/a.txt",
+		})
+	})
+
+	t.Run("Validate exact and inexact renames", func(t *testing.T) {
+		opts := &DiffOptions{DetectRenames: true}
+		entries, err := repo.DiffTrees(oldTree, newTree, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, nameStatus(entries), []string{
+			"M\tc.txt", "R100\tdir/d.txt\tdir/e.txt", "A\tf.txt",
+			"R094\ta.txt\tmoved/a.txt",
+		})
+	})
+
+	t.Run("Validate rename similarity threshold", func(t *testing.T) {
+		opts := &DiffOptions{DetectRenames: true, RenameScore: 95}
+		entries, err := repo.DiffTrees(oldTree, newTree, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, nameStatus(entries), []string{
+			"D\ta.txt", "M\tc.txt", "R100\tdir/d.txt\tdir/e.txt", "A\tf.txt",
+			"A\tmoved/a.txt",
+		})
+	})
+
+	t.Run("Validate copies from unmodified files", func(t *testing.T) {
+		opts := &DiffOptions{DetectRenames: true, DetectCopies: true}
+		entries, err := repo.DiffTrees(oldTree, newTree, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, entries[2].StatusString(), "A")
+
+		opts.FindCopiesHarder = true
+		entries, err = repo.DiffTrees(oldTree, newTree, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, nameStatus(entries), []string{
+			"M\tc.txt", "R100\tdir/d.txt\tdir/e.txt", "C100\tb.txt\tf.txt",
+			"R094\ta.txt\tmoved/a.txt",
+		})
+	})
+
+	t.Run("Validate rename limit", func(t *testing.T) {
+		opts := &DiffOptions{DetectRenames: true, RenameLimit: 1}
+		entries, err := repo.DiffTrees(oldTree, newTree, opts)
+		assertEqual(t, err, nil)
+		// Exact renames are still found, but not the inexact ones.
+		assertEqual(t, nameStatus(entries), []string{
+			"D\ta.txt", "M\tc.txt", "R100\tdir/d.txt\tdir/e.txt", "A\tf.txt",
+			"A\tmoved/a.txt",
+		})
+	})
+
+	// The changes are counted in bytes by default.
+	t.Run("Validate dirstat", func(t *testing.T) {
+		oldTree := writeTestTree(t, repo, map[string]string{
+			"d/a": "x\ny\n", "e/b": "1\n", "f/c": "same\n",
+		})
+		newTree := writeTestTree(t, repo, map[string]string{
+			"d/a": "x\nz\n", "e/b": "1\n22222\n", "g/c": "same\n",
+		})
+		entries, err := repo.DiffTrees(oldTree, newTree, &DiffOptions{DetectRenames: true})
+		assertEqual(t, err, nil)
+
+		for opts, want := range map[DirstatOptions]string{
+			{Permille: 30}:               "  40.0% d/\n  60.0% e/\n",
+			{ByLine: true, Permille: 30}: "  66.6% d/\n  33.3% e/\n",
+			{ByFile: true, Permille: 30}: "  50.0% d/\n  50.0% e/\n",
+			{Permille: 500}:              "  60.0% e/\n",
+		} {
+			opts := opts
+			got, err := repo.Dirstat(entries, &opts)
+			assertEqual(t, err, nil)
+			assertEqual(t, got, want)
+		}
+	})
+
+	t.Run("Validate tree files", func(t *testing.T) {
+		files, err := repo.TreeFiles(oldTree)
+		assertEqual(t, err, nil)
+		paths := []string{}
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		assertEqual(t, paths, []string{"a.txt", "b.txt", "c.txt", "dir/d.txt"})
+	})
+}
+
+func TestConfig(t *testing.T) {
+	config := NewConfig()
+	err := config.Parse([]byte(
+		"# comment\n" +
+			"[core]\n" +
+			"\tbare = false\n" +
+			"\tFileMode\n" +
+			"[diff]\n" +
+			"\trenameLimit = 2k ; comment\n" +
+			"[branch \"Main\"]\n" +
+			"\tremote = \"origin # not a comment\"\n"))
+	assertEqual(t, err, nil)
+
+	assertEqual(t, config.GetBool("core.bare", true), false)
+	assertEqual(t, config.GetBool("core.filemode", false), true)
+	assertEqual(t, config.GetInt("diff.renamelimit", 0), 2048)
+	assertEqual(t, config.GetInt("diff.missing", 7), 7)
+
+	remote, ok := config.Get("branch.Main.remote")
+	assertEqual(t, ok, true)
+	assertEqual(t, remote, "origin # not a comment")
+
+	_, ok = config.Get("branch.main.remote")
+	assertEqual(t, ok, false)
+}
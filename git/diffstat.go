@@ -0,0 +1,407 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileStat holds the number of lines added and deleted in a changed file.
+// For binary files, the sizes of both the sides are kept instead.
+type FileStat struct {
+	Path    string
+	Name    string
+	Added   int
+	Deleted int
+	Binary  bool
+	OldSize int
+	NewSize int
+}
+
+// DiffStats counts the added and deleted lines of each change using the line
+// diff engine.
+func (r *Repo) DiffStats(entries []DiffEntry) ([]FileStat, error) {
+	stats := []FileStat{}
+	for _, entry := range entries {
+		stat := FileStat{
+			Path: entry.Path(),
+			Name: entry.Path(),
+		}
+		if entry.Status == DiffRenamed || entry.Status == DiffCopied {
+			stat.Name = renameName(entry.Old.Path, entry.New.Path)
+		}
+
+		if entry.Old.Hash != entry.New.Hash {
+			oldData, err := r.FileData(entry.Old)
+			if err != nil {
+				return nil, err
+			}
+			newData, err := r.FileData(entry.New)
+			if err != nil {
+				return nil, err
+			}
+
+			if IsBinary(oldData) || IsBinary(newData) {
+				stat.Binary = true
+				stat.OldSize, stat.NewSize = len(oldData), len(newData)
+			} else {
+				for _, edit := range DiffLines(SplitLines(oldData), SplitLines(newData)) {
+					switch edit.Op {
+					case LineInsert:
+						stat.Added++
+					case LineDelete:
+						stat.Deleted++
+					}
+				}
+			}
+		}
+
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
+// renameName shows a rename with the common leading and trailing directories
+// of both the names outside of braces. Example: "dir/{a.txt => b.txt}".
+func renameName(oldPath, newPath string) string {
+	// The common prefix ends with a '/'.
+	prefix := 0
+	for i := 0; i < len(oldPath) && i < len(newPath) && oldPath[i] == newPath[i]; i++ {
+		if oldPath[i] == '/' {
+			prefix = i + 1
+		}
+	}
+
+	// The common suffix starts with a '/', and doesn't overlap the prefix.
+	suffix := 0
+	for i := 1; i <= len(oldPath)-prefix && i <= len(newPath)-prefix &&
+		oldPath[len(oldPath)-i] == newPath[len(newPath)-i]; i++ {
+		if oldPath[len(oldPath)-i] == '/' {
+			suffix = i
+		}
+	}
+
+	if prefix+suffix == 0 {
+		return oldPath + " => " + newPath
+	}
+	return fmt.Sprintf("%s{%s => %s}%s", oldPath[:prefix],
+		oldPath[prefix:len(oldPath)-suffix], newPath[prefix:len(newPath)-suffix],
+		oldPath[len(oldPath)-suffix:])
+}
+
+// plural returns the singular or the plural form of a word for a count.
+func plural(count int, word string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, word)
+	}
+	return fmt.Sprintf("%d %ss", count, word)
+}
+
+// FormatShortstat returns the summary line of a diffstat.
+// Example: " 2 files changed, 10 insertions(+), 1 deletion(-)"
+func FormatShortstat(stats []FileStat) string {
+	if len(stats) == 0 {
+		return " 0 files changed\n"
+	}
+
+	added, deleted := 0, 0
+	for _, stat := range stats {
+		added += stat.Added
+		deleted += stat.Deleted
+	}
+
+	summary := " " + plural(len(stats), "file") + " changed"
+	if added > 0 || deleted == 0 {
+		summary += ", " + plural(added, "insertion") + "(+)"
+	}
+	if deleted > 0 || added == 0 {
+		summary += ", " + plural(deleted, "deletion") + "(-)"
+	}
+
+	return summary + "\n"
+}
+
+// FormatSummary returns the condensed summary of the created, deleted and
+// renamed files, and of the mode changes, as shown by "git diff --summary".
+// Example: " create mode 100644 a.txt"
+func FormatSummary(entries []DiffEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		switch entry.Status {
+		case DiffAdded:
+			fmt.Fprintf(&b, " create mode %s %s\n", padMode(entry.New.Mode), entry.New.Path)
+		case DiffDeleted:
+			fmt.Fprintf(&b, " delete mode %s %s\n", padMode(entry.Old.Mode), entry.Old.Path)
+		case DiffRenamed, DiffCopied:
+			kind := "rename"
+			if entry.Status == DiffCopied {
+				kind = "copy"
+			}
+			fmt.Fprintf(&b, " %s %s (%d%%)\n", kind,
+				renameName(entry.Old.Path, entry.New.Path), entry.Score)
+			if entry.Old.Mode != entry.New.Mode {
+				fmt.Fprintf(&b, " mode change %s => %s\n", padMode(entry.Old.Mode),
+					padMode(entry.New.Mode))
+			}
+		default:
+			if entry.Old.Mode != entry.New.Mode {
+				fmt.Fprintf(&b, " mode change %s => %s %s\n", padMode(entry.Old.Mode),
+					padMode(entry.New.Mode), entry.New.Path)
+			}
+		}
+	}
+	return b.String()
+}
+
+// FormatNumstat returns the machine readable diffstat. Each line has the added
+// and deleted line counts and the name of the file separated by tabs. Binary
+// files are shown with "-" counts.
+func FormatNumstat(stats []FileStat) string {
+	var b strings.Builder
+	for _, stat := range stats {
+		if stat.Binary {
+			fmt.Fprintf(&b, "-\t-\t%s\n", stat.Name)
+		} else {
+			fmt.Fprintf(&b, "%d\t%d\t%s\n", stat.Added, stat.Deleted, stat.Name)
+		}
+	}
+
+	return b.String()
+}
+
+// scaleLinear scales a change count to the width of the histogram, keeping
+// any non-zero count visible.
+func scaleLinear(count, width, maxChange int) int {
+	if count == 0 {
+		return 0
+	}
+	return 1 + count*(width-1)/maxChange
+}
+
+// FormatStat returns a diffstat with a histogram of the changes in each file,
+// followed by the summary line. The output fits in 'width' columns, in the
+// same way as "git diff --stat" does.
+func FormatStat(stats []FileStat, width int) string {
+	if len(stats) == 0 {
+		return ""
+	}
+
+	maxLen, maxChange, hasBinary := 0, 0, false
+	for _, stat := range stats {
+		if len(stat.Name) > maxLen {
+			maxLen = len(stat.Name)
+		}
+		if stat.Binary {
+			hasBinary = true
+		} else if stat.Added+stat.Deleted > maxChange {
+			maxChange = stat.Added + stat.Deleted
+		}
+	}
+
+	numberWidth := len(strconv.Itoa(maxChange))
+	if hasBinary && numberWidth < len("Bin") {
+		numberWidth = len("Bin")
+	}
+
+	// Guarantee at least 6 columns for the graph and 16 for the names.
+	if width < 16+6+numberWidth {
+		width = 16 + 6 + numberWidth
+	}
+	graphWidth, nameWidth := maxChange, maxLen
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = width*3/8 - numberWidth - 6
+			if graphWidth < 6 {
+				graphWidth = 6
+			}
+		}
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	var b strings.Builder
+	for _, stat := range stats {
+		// Truncate long names from the left, preferably at a directory.
+		name := stat.Name
+		if len(name) > nameWidth {
+			name = name[len(name)-nameWidth+3:]
+			if slash := strings.IndexByte(name, '/'); slash >= 0 {
+				name = name[slash:]
+			}
+			name = "..." + name
+		}
+		fmt.Fprintf(&b, " %-*s |", nameWidth, name)
+
+		if stat.Binary {
+			fmt.Fprintf(&b, " %*s %d -> %d bytes\n", numberWidth, "Bin",
+				stat.OldSize, stat.NewSize)
+			continue
+		}
+
+		added, deleted := stat.Added, stat.Deleted
+		if graphWidth < maxChange {
+			total := scaleLinear(added+deleted, graphWidth, maxChange)
+			if total < 2 && added > 0 && deleted > 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleLinear(added, graphWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleLinear(deleted, graphWidth, maxChange)
+				added = total - deleted
+			}
+		}
+
+		fmt.Fprintf(&b, " %*d", numberWidth, stat.Added+stat.Deleted)
+		if added+deleted > 0 {
+			fmt.Fprintf(&b, " %s%s", strings.Repeat("+", added),
+				strings.Repeat("-", deleted))
+		}
+		fmt.Fprintln(&b)
+	}
+
+	b.WriteString(FormatShortstat(stats))
+	return b.String()
+}
+
+// DefaultDirstatPermille is the share of the changes, in permille, which a
+// directory needs to be shown by "--dirstat".
+const DefaultDirstatPermille = 30
+
+// DirstatOptions tells how "--dirstat" counts the changes of each file and
+// which directories it shows.
+type DirstatOptions struct {
+	// ByLine counts the added and deleted lines instead of the bytes, with
+	// 64 bytes of a binary file as a line.
+	ByLine bool
+	// ByFile counts one change for each changed file.
+	ByFile bool
+	// Cumulative counts the changes of a shown directory in its parent
+	// directories too.
+	Cumulative bool
+	// Permille is the minimum share of the changes of a shown directory.
+	Permille int
+}
+
+// dirstatFile is the amount of changes to a file, as counted for "--dirstat".
+type dirstatFile struct {
+	path    string
+	changes int
+}
+
+// Dirstat returns the distribution of the changes across directories, in
+// the format of "git diff --dirstat". By default, like "git", the changes of
+// a file are the bytes removed from it plus the bytes added to it.
+func (r *Repo) Dirstat(entries []DiffEntry, opts *DirstatOptions) (string, error) {
+	files := []dirstatFile{}
+	if opts.ByLine {
+		stats, err := r.DiffStats(entries)
+		if err != nil {
+			return "", err
+		}
+		for _, stat := range stats {
+			changes := stat.Added + stat.Deleted
+			if stat.Binary {
+				changes = (stat.OldSize + stat.NewSize + 63) / 64
+			}
+			files = append(files, dirstatFile{stat.Path, changes})
+		}
+		return formatDirstat(files, opts), nil
+	}
+
+	for _, entry := range entries {
+		file := dirstatFile{path: entry.Path()}
+		if entry.Old.Hash != entry.New.Hash {
+			file.changes = 1
+			if !opts.ByFile {
+				var err error
+				file.changes, err = r.damage(entry)
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+		files = append(files, file)
+	}
+	return formatDirstat(files, opts), nil
+}
+
+// damage returns the number of bytes removed from the old side of a change
+// plus the number of bytes added to its new side. Like "git", a file added or
+// deleted is damaged by its whole size, and a changed file is damaged by at
+// least one byte.
+func (r *Repo) damage(entry DiffEntry) (int, error) {
+	data := [][]byte{nil, nil}
+	for i, file := range []FileEntry{entry.Old, entry.New} {
+		if file.Hash == "" {
+			continue
+		}
+		var err error
+		if data[i], err = r.FileData(file); err != nil {
+			return 0, err
+		}
+	}
+	if entry.Old.Hash == "" || entry.New.Hash == "" {
+		return len(data[0]) + len(data[1]), nil
+	}
+
+	src, dst := newSimilaritySignature(data[0]), newSimilaritySignature(data[1])
+	copied, added := countChanges(src, dst)
+	if damage := len(data[0]) - copied + added; damage > 0 {
+		return damage, nil
+	}
+	return 1, nil
+}
+
+// formatDirstat shows each directory with enough of the changes. Unless the
+// changes are cumulative, the changes of a shown directory are not counted
+// again for its parents. Like "git", a directory is not shown if all its
+// changes are from a single subdirectory.
+func formatDirstat(files []dirstatFile, opts *DirstatOptions) string {
+	total := 0
+	for _, file := range files {
+		total += file.changes
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+
+	var b strings.Builder
+	var gather func(base string) int
+	gather = func(base string) int {
+		sum, sources := 0, 0
+		for len(files) > 0 && strings.HasPrefix(files[0].path, base) {
+			if ind := strings.IndexByte(files[0].path[len(base):], '/'); ind >= 0 {
+				sum += gather(files[0].path[:len(base)+ind+1])
+				sources++
+			} else {
+				sum += files[0].changes
+				files = files[1:]
+				sources += 2
+			}
+		}
+
+		if base != "" && sources != 1 && sum > 0 {
+			permille := sum * 1000 / total
+			if permille >= opts.Permille {
+				fmt.Fprintf(&b, "%4d.%01d%% %s\n", permille/10, permille%10, base)
+				if !opts.Cumulative {
+					return 0
+				}
+			}
+		}
+		return sum
+	}
+	gather("")
+
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a repo in a new temporary directory, which is removed
+// at the end of the test.
+func newTestRepo(t *testing.T, prefix string) *Repo {
+	t.Helper()
+
+	dir, err := ioutil.TempDir(os.TempDir(), prefix)
+	assertEqual(t, err, nil)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	repo, err := NewRepo(dir)
+	assertEqual(t, err, nil)
+	return repo
+}
+
+// writeTestTree writes the given files (path to content) as blobs and trees
+// into the repo and returns the hash of the top level tree.
+func writeTestTree(t *testing.T, repo *Repo, files map[string]string) string {
+	t.Helper()
+
+	// Group the files by their top level directory.
+	entries := map[string]string{}
+	subdirs := map[string]map[string]string{}
+	for path, data := range files {
+		if ind := strings.IndexByte(path, '/'); ind >= 0 {
+			dir := path[:ind]
+			if subdirs[dir] == nil {
+				subdirs[dir] = map[string]string{}
+			}
+			subdirs[dir][path[ind+1:]] = data
+			continue
+		}
+
+		hash, err := repo.ObjectWrite(NewObject("blob", []byte(data)), true)
+		assertEqual(t, err, nil)
+		entries[path] = fmt.Sprintf("100644 blob %s\t%s\n", hash, path)
+	}
+	for dir, subFiles := range subdirs {
+		hash := writeTestTree(t, repo, subFiles)
+		entries[dir] = fmt.Sprintf("040000 tree %s\t%s\n", hash, dir)
+	}
+
+	input := ""
+	for _, entry := range entries {
+		input += entry
+	}
+	tree, err := NewTreeFromInput(repo, input)
+	assertEqual(t, err, nil)
+	hash, err := repo.ObjectWrite(tree.Object, true)
+	assertEqual(t, err, nil)
+	return hash
+}
+
+// writeTestCommit writes a commit of a tree with the given parents.
+func writeTestCommit(t *testing.T, repo *Repo, tree string, parents ...string) string {
+	t.Helper()
+
+	commit, err := NewCommitFromParents(repo, tree, parents, "test\n")
+	assertEqual(t, err, nil)
+	hash, err := repo.ObjectWrite(commit.Object, true)
+	assertEqual(t, err, nil)
+	return hash
+}
+
+// commitTestFiles writes the given files (path to content) as a commit on top
+// of HEAD, moves HEAD to it and checks it out, as if the files were added and
+// committed. It returns the hashes of the tree and the commit.
+func commitTestFiles(t *testing.T, repo *Repo, files map[string]string) (string, string) {
+	t.Helper()
+
+	_, head, err := repo.Head()
+	assertEqual(t, err, nil)
+	headTree := ""
+	parents := []string{}
+	if head != "" {
+		headTree, err = repo.TreeResolve(head)
+		assertEqual(t, err, nil)
+		parents = append(parents, head)
+	}
+
+	tree := writeTestTree(t, repo, files)
+	commit := writeTestCommit(t, repo, tree, parents...)
+	assertEqual(t, repo.UpdateRef("HEAD", commit), nil)
+	assertEqual(t, repo.CheckoutTree(headTree, tree, &CheckoutOptions{Command: "checkout"}), nil)
+	return tree, commit
+}
+
+// readTestFile returns the data of a file in the work-tree, or an empty string
+// if the file is missing.
+func readTestFile(t *testing.T, repo *Repo, path string) string {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join(repo.WorkTree, filepath.FromSlash(path)))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// writeTestFile writes a file in the work-tree, along with its directories.
+func writeTestFile(t *testing.T, repo *Repo, path, data string) {
+	t.Helper()
+
+	fullPath := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+	assertEqual(t, os.MkdirAll(filepath.Dir(fullPath), os.ModePerm), nil)
+	assertEqual(t, ioutil.WriteFile(fullPath, []byte(data), 0644), nil)
+}
+
+// stageTestFile adds a file of the work-tree to the index.
+func stageTestFile(t *testing.T, repo *Repo, path string) {
+	t.Helper()
+
+	index, err := repo.ReadIndex()
+	assertEqual(t, err, nil)
+	fs, err := repo.FSConfig()
+	assertEqual(t, err, nil)
+	assertEqual(t, repo.AddFile(index, path, fs), nil)
+	assertEqual(t, repo.WriteIndex(index), nil)
+}
+
+// testFileData returns the data of a file in a tree, or an empty string if the
+// file is missing.
+func testFileData(t *testing.T, repo *Repo, tree, path string) string {
+	t.Helper()
+
+	hash, err := repo.PathResolve(tree, path)
+	if err != nil {
+		return ""
+	}
+	obj, err := repo.ObjectParse(hash)
+	assertEqual(t, err, nil)
+	return string(obj.ObjData)
+}
+
+// testIndexPaths returns the paths of the entries in the index.
+func testIndexPaths(t *testing.T, repo *Repo) []string {
+	t.Helper()
+
+	index, err := repo.ReadIndex()
+	assertEqual(t, err, nil)
+	paths := []string{}
+	for _, entry := range index.Entries {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
@@ -0,0 +1,312 @@
+package git
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnorePattern is a single rule from an ignore file, such as ".gitignore".
+type IgnorePattern struct {
+	// Pattern is the glob pattern without the leading '!' of a negated rule
+	// and the trailing '/' of a directory-only rule.
+	Pattern string
+	// Source is the ignore file and Line is the line number of the rule.
+	Source string
+	Line   int
+	// Negated rules re-include the paths excluded by the earlier rules.
+	Negated bool
+	// DirOnly rules match only directories.
+	DirOnly bool
+	// base is the directory of a ".gitignore" file, relative to the top of
+	// the work-tree. The other ignore files have an empty base.
+	base string
+	// anchored rules (with a '/' other than a trailing one) match the path
+	// relative to 'base', while the others match only the file name.
+	anchored bool
+}
+
+// String returns the rule as it is written in the ignore file.
+func (pattern *IgnorePattern) String() string {
+	rule := pattern.Pattern
+	if pattern.Negated {
+		rule = "!" + rule
+	}
+	if pattern.DirOnly {
+		rule += "/"
+	}
+	return rule
+}
+
+// Ignore tells which paths of the work-tree are ignored as per the
+// ".gitignore" files of their directories, "info/exclude" of the repo and the
+// global excludes file given by "core.excludesFile". The ".gitignore" files
+// are read as the directories are visited.
+type Ignore struct {
+	repo *Repo
+	// dirs maps each visited directory to the rules of its ".gitignore".
+	dirs map[string][]*IgnorePattern
+	// excluded caches the rules which exclude the visited directories.
+	excluded map[string]*IgnorePattern
+	// files are the rules of the repo wide ignore files, in the order of
+	// their precedence.
+	files [][]*IgnorePattern
+}
+
+// NewIgnore loads the repo wide ignore files of a repo.
+func (r *Repo) NewIgnore() (*Ignore, error) {
+	ignore := &Ignore{
+		repo:     r,
+		dirs:     map[string][]*IgnorePattern{},
+		excluded: map[string]*IgnorePattern{},
+		files:    [][]*IgnorePattern{},
+	}
+
+	excludeFile, err := r.FilePath(false, "info", "exclude")
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := readIgnoreFile(excludeFile, r.displayPath(excludeFile), "")
+	if err != nil {
+		return nil, err
+	}
+	ignore.files = append(ignore.files, patterns)
+
+	config, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	globalFile, ok := config.Get("core.excludesFile")
+	if ok {
+		globalFile = expandHome(globalFile)
+	} else {
+		globalFile = globalExcludesFile()
+	}
+	if globalFile != "" {
+		patterns, err := readIgnoreFile(globalFile, globalFile, "")
+		if err != nil {
+			return nil, err
+		}
+		ignore.files = append(ignore.files, patterns)
+	}
+
+	return ignore, nil
+}
+
+// globalExcludesFile returns the default global excludes file, which is
+// "git/ignore" inside the XDG configuration directory.
+func globalExcludesFile() string {
+	if xdgHome := os.Getenv("XDG_CONFIG_HOME"); xdgHome != "" {
+		return filepath.Join(xdgHome, "git", "ignore")
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
+
+// expandHome expands a leading "~/" of a path to the home directory.
+func expandHome(file string) string {
+	if strings.HasPrefix(file, "~/") {
+		return filepath.Join(os.Getenv("HOME"), file[2:])
+	}
+	return file
+}
+
+// displayPath shows a path inside the repo relative to the top of the
+// work-tree, if it is inside the work-tree.
+func (r *Repo) displayPath(file string) string {
+	if r.WorkTree != "" {
+		if rel, err := filepath.Rel(r.WorkTree, file); err == nil &&
+			!strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return file
+}
+
+// readIgnoreFile reads the rules of an ignore file. A missing file has no
+// rules. 'source' is the name of the file to show for its rules.
+func readIgnoreFile(file, source, base string) ([]*IgnorePattern, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return []*IgnorePattern{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseIgnorePatterns(data, source, base), nil
+}
+
+// ParseIgnorePatterns parses the rules of an ignore file, which applies to
+// the directory 'base' (relative to the top of the work-tree).
+func ParseIgnorePatterns(data []byte, source, base string) []*IgnorePattern {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	patterns := []*IgnorePattern{}
+	for i, line := range strings.Split(string(data), "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = trimTrailingSpaces(line)
+
+		pattern := &IgnorePattern{Source: source, Line: i + 1, base: base}
+		if strings.HasPrefix(line, "!") {
+			pattern.Negated = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pattern.DirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		pattern.anchored = strings.Contains(line, "/")
+		pattern.Pattern = line
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// trimTrailingSpaces removes the trailing spaces of a rule, unless they are
+// escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			if end == len(line) {
+				end = i
+			}
+			continue
+		case '\\':
+			i++
+		}
+		end = len(line)
+	}
+
+	if end < len(line) {
+		return line[:end]
+	}
+	return line
+}
+
+// match tells if a path matches a rule.
+func (pattern *IgnorePattern) match(filePath string, isDir bool) bool {
+	if pattern.DirOnly && !isDir {
+		return false
+	}
+
+	if !pattern.anchored {
+		return Wildmatch(pattern.Pattern, path.Base(filePath), false)
+	}
+
+	name := filePath
+	if pattern.base != "" {
+		if !strings.HasPrefix(filePath, pattern.base+"/") {
+			return false
+		}
+		name = filePath[len(pattern.base)+1:]
+	}
+	return Wildmatch(strings.TrimPrefix(pattern.Pattern, "/"), name, true)
+}
+
+// dirPatterns returns the rules of the ".gitignore" file in a directory.
+func (ig *Ignore) dirPatterns(dir string) []*IgnorePattern {
+	if patterns, ok := ig.dirs[dir]; ok {
+		return patterns
+	}
+
+	source := path.Join(dir, ".gitignore")
+	file := filepath.Join(ig.repo.WorkTree, filepath.FromSlash(source))
+	patterns, err := readIgnoreFile(file, source, dir)
+	if err != nil {
+		// An unreadable ".gitignore" is skipped, like "git" does.
+		patterns = []*IgnorePattern{}
+	}
+	ig.dirs[dir] = patterns
+	return patterns
+}
+
+// lastMatch finds the rule which decides if a path is ignored, without
+// looking at its parent directories. The ".gitignore" files of deeper
+// directories take precedence, followed by the repo wide ignore files. In a
+// file, the last matching rule wins.
+func (ig *Ignore) lastMatch(filePath string, isDir bool) *IgnorePattern {
+	dir := path.Dir(filePath)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+
+		patterns := ig.dirPatterns(dir)
+		for i := len(patterns) - 1; i >= 0; i-- {
+			if patterns[i].match(filePath, isDir) {
+				return patterns[i]
+			}
+		}
+		if dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+
+	for _, patterns := range ig.files {
+		for i := len(patterns) - 1; i >= 0; i-- {
+			if patterns[i].match(filePath, isDir) {
+				return patterns[i]
+			}
+		}
+	}
+	return nil
+}
+
+// dirExcluded returns the rule which excludes a directory or any of its
+// parents. A file inside an excluded directory can't be re-included.
+func (ig *Ignore) dirExcluded(dir string) *IgnorePattern {
+	if dir == "" {
+		return nil
+	}
+	if pattern, ok := ig.excluded[dir]; ok {
+		return pattern
+	}
+
+	parent := path.Dir(dir)
+	if parent == "." {
+		parent = ""
+	}
+	pattern := ig.dirExcluded(parent)
+	if pattern == nil {
+		pattern = ig.lastMatch(dir, true)
+		if pattern != nil && pattern.Negated {
+			pattern = nil
+		}
+	}
+
+	ig.excluded[dir] = pattern
+	return pattern
+}
+
+// Match returns the rule which decides if a path (relative to the top of the
+// work-tree) is ignored, or nil if no rule matches. The path is not ignored
+// if the rule is a negated one.
+func (ig *Ignore) Match(filePath string, isDir bool) *IgnorePattern {
+	parent := path.Dir(filePath)
+	if parent == "." {
+		parent = ""
+	}
+	if pattern := ig.dirExcluded(parent); pattern != nil {
+		return pattern
+	}
+	return ig.lastMatch(filePath, isDir)
+}
+
+// IsIgnored tells if a path in the work-tree (relative to its top) is ignored.
+func (ig *Ignore) IsIgnored(filePath string, isDir bool) bool {
+	pattern := ig.Match(filePath, isDir)
+	return pattern != nil && !pattern.Negated
+}
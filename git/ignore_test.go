@@ -0,0 +1,112 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWildmatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		text     string
+		pathname bool
+		want     bool
+	}{
+		{"foo", "foo", true, true},
+		{"foo", "bar", true, false},
+		{"*.c", "a.c", true, true},
+		{"*.c", "dir/a.c", true, false},
+		{"*.c", "dir/a.c", false, true},
+		{"?", "/", true, false},
+		{"?", "/", false, true},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"a/*/b", "a/x/y/b", true, false},
+		{"**/foo", "foo", true, true},
+		{"**/foo", "x/y/foo", true, true},
+		{"foo/**", "foo/x/y", true, true},
+		{"foo/**", "foo", true, false},
+		{"a**b", "a/x/b", true, false},
+		{"[abc]x", "bx", true, true},
+		{"[!abc]x", "bx", true, false},
+		{"[a-c]x", "dx", true, false},
+		{"[]]", "]", true, true},
+		{"[[:digit:]]*", "7up", true, true},
+		{"[[:upper:]]", "a", true, false},
+		{"[x", "[x", true, false},
+		{"\\*", "*", true, true},
+		{"\\*", "a", true, false},
+	}
+
+	for _, test := range tests {
+		got := Wildmatch(test.pattern, test.text, test.pathname)
+		if got != test.want {
+			t.Errorf("Wildmatch(%q, %q, %v) = %v, want %v", test.pattern,
+				test.text, test.pathname, got, test.want)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitIgnore")
+
+	// Don't let the global excludes file of the user affect the test.
+	xdgHome := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("XDG_CONFIG_HOME", xdgHome)
+	os.Setenv("XDG_CONFIG_HOME", repo.WorkTree)
+
+	writeTestFile(t, repo, ".gitignore", "# comment\n*.log\n!keep.log\nbuild/\n/top.txt\n"+
+		"doc/*.txt\nspace\\ \ntrail  \nout/\n!out/keep.txt\n")
+	writeTestFile(t, repo, "sub/.gitignore", "!*.log\n/local\n")
+	writeTestFile(t, repo, ".git/info/exclude", "secret*\n")
+
+	ignore, err := repo.NewIgnore()
+	assertEqual(t, err, nil)
+
+	tests := []struct {
+		path   string
+		isDir  bool
+		source string
+		line   int
+		rule   string
+	}{
+		{"a.log", false, ".gitignore", 2, "*.log"},
+		{"keep.log", false, ".gitignore", 3, "!keep.log"},
+		{"sub/a.log", false, "sub/.gitignore", 1, "!*.log"},
+		{"build", true, ".gitignore", 4, "build/"},
+		{"build", false, "", 0, ""},
+		{"build/x/y.c", false, ".gitignore", 4, "build/"},
+		{"top.txt", false, ".gitignore", 5, "/top.txt"},
+		{"sub/top.txt", false, "", 0, ""},
+		{"doc/a.txt", false, ".gitignore", 6, "doc/*.txt"},
+		{"doc/x/a.txt", false, "", 0, ""},
+		{"space ", false, ".gitignore", 7, "space\\ "},
+		{"trail", false, ".gitignore", 8, "trail"},
+		{"sub/local", false, "sub/.gitignore", 2, "/local"},
+		{"local", false, "", 0, ""},
+		{"secret.txt", false, ".git/info/exclude", 1, "secret*"},
+		// A file inside an excluded directory can't be re-included.
+		{"out/keep.txt", false, ".gitignore", 9, "out/"},
+	}
+
+	for _, test := range tests {
+		pattern := ignore.Match(test.path, test.isDir)
+		if test.rule == "" {
+			if pattern != nil {
+				t.Errorf("Match(%q) = %s, want no match", test.path, pattern)
+			}
+			continue
+		}
+		if pattern == nil {
+			t.Errorf("Match(%q) = nil, want %s", test.path, test.rule)
+			continue
+		}
+		assertEqual(t, pattern.Source, test.source)
+		assertEqual(t, pattern.Line, test.line)
+		assertEqual(t, pattern.String(), test.rule)
+	}
+
+	assertEqual(t, ignore.IsIgnored("a.log", false), true)
+	assertEqual(t, ignore.IsIgnored("keep.log", false), false)
+	assertEqual(t, ignore.IsIgnored("readme", false), false)
+}
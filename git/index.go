@@ -0,0 +1,462 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flags of an index entry.
+const (
+	indexAssumeValid  uint16 = 0x8000
+	indexExtended     uint16 = 0x4000
+	indexStageMask    uint16 = 0x3000
+	indexStageShift   uint16 = 12
+	indexNameMask     uint16 = 0x0fff
+	indexSkipWorktree uint16 = 0x4000
+	indexIntentToAdd  uint16 = 0x2000
+)
+
+// IndexEntry is a file in the index (the staging area), along with the file
+// system stat data of its work-tree file when it was last written to the
+// index. The stat data helps in finding unchanged files without reading them.
+type IndexEntry struct {
+	CTimeSec  uint32
+	CTimeNsec uint32
+	MTimeSec  uint32
+	MTimeNsec uint32
+	Dev       uint32
+	Ino       uint32
+	Mode      uint32
+	UID       uint32
+	GID       uint32
+	Size      uint32
+	Hash      string
+	Flags     uint16
+	ExtFlags  uint16
+	Path      string
+}
+
+// Index is the list of entries in the ".git/index" file, sorted by their
+// paths and stages.
+type Index struct {
+	Version uint32
+	Entries []*IndexEntry
+	// modTime is the modification time of the index file when it was read.
+	modTime time.Time
+}
+
+// NewIndexEntry creates an index entry for a file with the given path, mode
+// and blob hash, at stage 0. It has no stat data till it is set.
+func NewIndexEntry(path, mode, hash string) (*IndexEntry, error) {
+	modeVal, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("fatal: invalid file mode %s for %s", mode, path)
+	}
+
+	return &IndexEntry{
+		Mode: uint32(modeVal),
+		Hash: hash,
+		Path: path,
+	}, nil
+}
+
+// Stage returns the merge stage of an entry. Stage 0 is a normal entry, while
+// stages 1, 2 and 3 are the base, "ours" and "theirs" versions of a file
+// with a merge conflict.
+func (entry *IndexEntry) Stage() int {
+	return int((entry.Flags & indexStageMask) >> indexStageShift)
+}
+
+// SetStage changes the merge stage of an entry.
+func (entry *IndexEntry) SetStage(stage int) {
+	entry.Flags = entry.Flags&^indexStageMask | uint16(stage)<<indexStageShift
+}
+
+// AssumeValid tells if the work-tree file is assumed to be unchanged.
+func (entry *IndexEntry) AssumeValid() bool {
+	return entry.Flags&indexAssumeValid != 0
+}
+
+// SkipWorktree tells if the work-tree file is not checked out on purpose.
+func (entry *IndexEntry) SkipWorktree() bool {
+	return entry.ExtFlags&indexSkipWorktree != 0
+}
+
+// IntentToAdd tells if the file was added with "git add -N".
+func (entry *IndexEntry) IntentToAdd() bool {
+	return entry.ExtFlags&indexIntentToAdd != 0
+}
+
+// FileEntry returns the path, mode and the hash of an index entry.
+func (entry *IndexEntry) FileEntry() FileEntry {
+	return FileEntry{
+		Path: entry.Path,
+		Mode: strconv.FormatUint(uint64(entry.Mode), 8),
+		Hash: entry.Hash,
+	}
+}
+
+// SetStat records the stat data of the work-tree file of an entry.
+func (entry *IndexEntry) SetStat(info os.FileInfo) {
+	stat := fileStat(info)
+	entry.CTimeSec, entry.CTimeNsec = stat.ctimeSec, stat.ctimeNsec
+	entry.MTimeSec, entry.MTimeNsec = stat.mtimeSec, stat.mtimeNsec
+	entry.Dev, entry.Ino = stat.dev, stat.ino
+	entry.UID, entry.GID = stat.uid, stat.gid
+	entry.Size = uint32(info.Size())
+}
+
+// StatMatches tells if the stat data of a work-tree file is the same as the
+// one recorded in its entry. Only the file type is compared from the mode, as
+// the executable bit is checked separately.
+func (entry *IndexEntry) StatMatches(info os.FileInfo) bool {
+	stat := fileStat(info)
+	wtMode, _ := strconv.ParseUint(FileMode(info), 8, 32)
+	return entry.MTimeSec == stat.mtimeSec && entry.MTimeNsec == stat.mtimeNsec &&
+		entry.CTimeSec == stat.ctimeSec && entry.CTimeNsec == stat.ctimeNsec &&
+		entry.Ino == stat.ino && entry.UID == stat.uid && entry.GID == stat.gid &&
+		entry.Size == uint32(info.Size()) && entry.Mode&0170000 == uint32(wtMode)&0170000
+}
+
+// IsRacy tells if an entry's file may have been modified in the same instant
+// in which the index was written. Such a file can't be trusted to be clean
+// based only on its stat data.
+func (index *Index) IsRacy(entry *IndexEntry) bool {
+	if index.modTime.IsZero() {
+		return false
+	}
+
+	sec := uint32(index.modTime.Unix())
+	nsec := uint32(index.modTime.Nanosecond())
+	return entry.MTimeSec > sec || (entry.MTimeSec == sec && entry.MTimeNsec >= nsec)
+}
+
+// find returns the position of the entry with the given path and stage, or
+// the position where it should be inserted.
+func (index *Index) find(path string, stage int) (int, bool) {
+	i := sort.Search(len(index.Entries), func(i int) bool {
+		entry := index.Entries[i]
+		return entry.Path > path || (entry.Path == path && entry.Stage() >= stage)
+	})
+	found := i < len(index.Entries) && index.Entries[i].Path == path &&
+		index.Entries[i].Stage() == stage
+	return i, found
+}
+
+// Entry returns the entry with the given path and stage, or nil.
+func (index *Index) Entry(path string, stage int) *IndexEntry {
+	if i, found := index.find(path, stage); found {
+		return index.Entries[i]
+	}
+	return nil
+}
+
+// Add adds an entry to the index, replacing any existing entry with the same
+// path and stage. Adding a stage 0 entry resolves a conflict, so the entries
+// of all the other stages of the path are removed.
+func (index *Index) Add(entry *IndexEntry) {
+	if entry.Stage() == 0 {
+		index.Remove(entry.Path)
+	}
+
+	i, found := index.find(entry.Path, entry.Stage())
+	if found {
+		index.Entries[i] = entry
+		return
+	}
+	index.Entries = append(index.Entries, nil)
+	copy(index.Entries[i+1:], index.Entries[i:])
+	index.Entries[i] = entry
+}
+
+// Remove removes the entries of all the stages of a path. It returns false
+// if the path is not in the index.
+func (index *Index) Remove(path string) bool {
+	start, _ := index.find(path, 0)
+	end := start
+	for end < len(index.Entries) && index.Entries[end].Path == path {
+		end++
+	}
+
+	index.Entries = append(index.Entries[:start], index.Entries[end:]...)
+	return end > start
+}
+
+// Files returns the files of the index at stage 0, sorted by their paths.
+func (index *Index) Files() []FileEntry {
+	files := []FileEntry{}
+	for _, entry := range index.Entries {
+		if entry.Stage() == 0 {
+			files = append(files, entry.FileEntry())
+		}
+	}
+	return files
+}
+
+// Unmerged tells if there are conflicted entries in the index.
+func (index *Index) Unmerged() bool {
+	for _, entry := range index.Entries {
+		if entry.Stage() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadIndex reads and validates the index file of the repo. A missing index
+// file gives an empty index. Versions 2, 3 and 4 of the index format are
+// supported. Extensions are not kept, as they are optional.
+func (r *Repo) ReadIndex() (*Index, error) {
+	index := &Index{Version: 2, Entries: []*IndexEntry{}}
+	indexFile, err := r.FilePath(false, "index")
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(indexFile)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index.modTime = info.ModTime()
+
+	data, err := ioutil.ReadFile(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.parse(data); err != nil {
+		return nil, fmt.Errorf("fatal: index file corrupt: %v", err)
+	}
+
+	return index, nil
+}
+
+// parse parses the content of an index file.
+func (index *Index) parse(data []byte) error {
+	if len(data) < 12+sha1.Size {
+		return errors.New("file is too short")
+	}
+
+	content, checksum := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if sum := sha1.Sum(content); !bytes.Equal(sum[:], checksum) {
+		return errors.New("bad index file sha1 signature")
+	}
+	if string(content[:4]) != "DIRC" {
+		return errors.New("bad signature")
+	}
+	index.Version = binary.BigEndian.Uint32(content[4:8])
+	if index.Version < 2 || index.Version > 4 {
+		return fmt.Errorf("bad index version %d", index.Version)
+	}
+	count := binary.BigEndian.Uint32(content[8:12])
+
+	pos := 12
+	prevPath := ""
+	for i := uint32(0); i < count; i++ {
+		if pos+62 > len(content) {
+			return errors.New("truncated entry")
+		}
+
+		start := pos
+		fields := make([]uint32, 10)
+		for j := range fields {
+			fields[j] = binary.BigEndian.Uint32(content[pos : pos+4])
+			pos += 4
+		}
+		entry := &IndexEntry{
+			CTimeSec: fields[0], CTimeNsec: fields[1],
+			MTimeSec: fields[2], MTimeNsec: fields[3],
+			Dev: fields[4], Ino: fields[5], Mode: fields[6],
+			UID: fields[7], GID: fields[8], Size: fields[9],
+		}
+		entry.Hash = hex.EncodeToString(content[pos : pos+20])
+		entry.Flags = binary.BigEndian.Uint16(content[pos+20 : pos+22])
+		pos += 22
+
+		if entry.Flags&indexExtended != 0 {
+			if index.Version < 3 || pos+2 > len(content) {
+				return errors.New("bad extended flags")
+			}
+			entry.ExtFlags = binary.BigEndian.Uint16(content[pos : pos+2])
+			pos += 2
+		}
+
+		// Version 4 stores the path as the number of bytes to remove from
+		// the end of the previous path, followed by the new suffix.
+		if index.Version == 4 {
+			strip, n := decodeOffsetVarint(content[pos:])
+			if n == 0 || strip > len(prevPath) {
+				return errors.New("bad path prefix")
+			}
+			pos += n
+			end := bytes.IndexByte(content[pos:], 0)
+			if end < 0 {
+				return errors.New("unterminated path")
+			}
+			entry.Path = prevPath[:len(prevPath)-strip] + string(content[pos:pos+end])
+			pos += end + 1
+		} else {
+			end := bytes.IndexByte(content[pos:], 0)
+			if end < 0 {
+				return errors.New("unterminated path")
+			}
+			entry.Path = string(content[pos : pos+end])
+
+			// Entries are padded with 1-8 NUL bytes to a multiple of 8 bytes.
+			pos = start + (pos+end-start+8)&^7
+		}
+
+		prevPath = entry.Path
+		index.Entries = append(index.Entries, entry)
+	}
+
+	// The extensions which follow the entries are cached data, which can be
+	// ignored unless they are required ones (with an upper case signature).
+	for pos+8 <= len(content) {
+		sig := content[pos : pos+4]
+		size := int(binary.BigEndian.Uint32(content[pos+4 : pos+8]))
+		if sig[0] < 'A' || sig[0] > 'Z' {
+			return fmt.Errorf("index uses %s extension, which we do not understand", sig)
+		}
+		pos += 8 + size
+	}
+
+	return nil
+}
+
+// decodeOffsetVarint decodes a variable length integer in the format used
+// by the index version 4. It returns the value and the number of bytes used.
+func decodeOffsetVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+
+	n := 0
+	c := data[n]
+	n++
+	val := int(c & 127)
+	for c&128 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		val++
+		c = data[n]
+		n++
+		val = (val << 7) + int(c&127)
+	}
+	return val, n
+}
+
+// WriteIndex writes the index to the index file of the repo. The index is
+// written to a lock file first, which is then renamed in place of the index
+// file. It fails if the lock file is already present.
+func (r *Repo) WriteIndex(index *Index) error {
+	var b bytes.Buffer
+	version := uint32(2)
+	for _, entry := range index.Entries {
+		if entry.ExtFlags != 0 {
+			version = 3
+		}
+	}
+
+	b.WriteString("DIRC")
+	binary.Write(&b, binary.BigEndian, version)
+	binary.Write(&b, binary.BigEndian, uint32(len(index.Entries)))
+	for _, entry := range index.Entries {
+		start := b.Len()
+		for _, field := range []uint32{
+			entry.CTimeSec, entry.CTimeNsec, entry.MTimeSec, entry.MTimeNsec,
+			entry.Dev, entry.Ino, entry.Mode, entry.UID, entry.GID, entry.Size,
+		} {
+			binary.Write(&b, binary.BigEndian, field)
+		}
+
+		hash, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(hash) != sha1.Size {
+			return fmt.Errorf("fatal: invalid object %s for '%s'", entry.Hash, entry.Path)
+		}
+		b.Write(hash)
+
+		flags := entry.Flags &^ (indexExtended | indexNameMask)
+		if len(entry.Path) < int(indexNameMask) {
+			flags |= uint16(len(entry.Path))
+		} else {
+			flags |= indexNameMask
+		}
+		if entry.ExtFlags != 0 {
+			flags |= indexExtended
+		}
+		binary.Write(&b, binary.BigEndian, flags)
+		if entry.ExtFlags != 0 {
+			binary.Write(&b, binary.BigEndian, entry.ExtFlags)
+		}
+
+		b.WriteString(entry.Path)
+		padding := 8 - (b.Len()-start)%8
+		b.Write(make([]byte, padding))
+	}
+
+	sum := sha1.Sum(b.Bytes())
+	b.Write(sum[:])
+
+	indexFile, err := r.FilePath(false, "index")
+	if err != nil {
+		return err
+	}
+	lockFile := indexFile + ".lock"
+	fd, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("fatal: Unable to create '%s': File exists.", lockFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fd.Write(b.Bytes())
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(lockFile)
+		return err
+	}
+	if err := os.Rename(lockFile, indexFile); err != nil {
+		os.Remove(lockFile)
+		return err
+	}
+
+	index.Version = version
+	if info, err := os.Stat(indexFile); err == nil {
+		index.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Dirs returns all the leading directories of the paths in the index.
+// Example: "a/b/c.txt" gives "a" and "a/b".
+func (index *Index) Dirs() map[string]bool {
+	dirs := map[string]bool{}
+	for _, entry := range index.Entries {
+		path := entry.Path
+		for i := strings.LastIndexByte(path, '/'); i > 0; i = strings.LastIndexByte(path, '/') {
+			path = path[:i]
+			if dirs[path] {
+				break
+			}
+			dirs[path] = true
+		}
+	}
+	return dirs
+}
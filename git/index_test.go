@@ -0,0 +1,99 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitIndex")
+
+	// addFile writes a file to the work-tree and adds it to the index.
+	addFile := func(index *Index, path, data string) {
+		writeTestFile(t, repo, path, data)
+		fullPath := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+
+		hash, err := repo.ObjectWrite(NewObject("blob", []byte(data)), true)
+		assertEqual(t, err, nil)
+		entry, err := NewIndexEntry(path, "100644", hash)
+		assertEqual(t, err, nil)
+		info, err := os.Lstat(fullPath)
+		assertEqual(t, err, nil)
+		entry.SetStat(info)
+		index.Add(entry)
+	}
+
+	t.Run("Validate an empty index", func(t *testing.T) {
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		assertEqual(t, len(index.Entries), 0)
+	})
+
+	t.Run("Validate index write and read", func(t *testing.T) {
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		addFile(index, "b.txt", "b\n")
+		addFile(index, "a.txt", "a\n")
+		addFile(index, "dir/c.txt", "c\n")
+		assertEqual(t, repo.WriteIndex(index), nil)
+
+		index, err = repo.ReadIndex()
+		assertEqual(t, err, nil)
+		paths := []string{}
+		for _, entry := range index.Entries {
+			paths = append(paths, entry.Path)
+		}
+		assertEqual(t, paths, []string{"a.txt", "b.txt", "dir/c.txt"})
+		assertEqual(t, index.Entry("a.txt", 0).FileEntry(), FileEntry{
+			Path: "a.txt",
+			Mode: "100644",
+			Hash: "78981922613b2afb6025042ff6bd878ac1994e85",
+		})
+		assertEqual(t, index.Dirs(), map[string]bool{"dir": true})
+	})
+
+	t.Run("Validate conflict stages", func(t *testing.T) {
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		for stage := 1; stage <= 3; stage++ {
+			entry, err := NewIndexEntry("b.txt", "100644", emptyBlobHash)
+			assertEqual(t, err, nil)
+			entry.SetStage(stage)
+			index.Add(entry)
+		}
+		assertEqual(t, len(index.Entries), 6)
+		assertEqual(t, index.Unmerged(), true)
+
+		// A stage 0 entry resolves the conflict.
+		entry, err := NewIndexEntry("b.txt", "100644", emptyBlobHash)
+		assertEqual(t, err, nil)
+		index.Add(entry)
+		assertEqual(t, len(index.Entries), 3)
+		assertEqual(t, index.Unmerged(), false)
+		assertEqual(t, index.Remove("b.txt"), true)
+		assertEqual(t, index.Remove("b.txt"), false)
+	})
+
+	t.Run("Validate status", func(t *testing.T) {
+		writeTestFile(t, repo, "a.txt", "changed\n")
+		assertEqual(t, os.Remove(filepath.Join(repo.WorkTree, "b.txt")), nil)
+		writeTestFile(t, repo, "new/sub/f", "")
+		writeTestFile(t, repo, "x.log", "")
+		writeTestFile(t, repo, "dir/d.txt", "")
+		writeTestFile(t, repo, ".gitignore", "*.log\n")
+
+		status, err := repo.Status(&StatusOptions{Untracked: "normal", Ignored: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, status.Branch, "refs/heads/master")
+		assertEqual(t, status.Head, "")
+
+		codes := []string{}
+		for _, entry := range status.Entries {
+			codes = append(codes, string([]byte{entry.Staged, entry.Unstaged})+" "+entry.Path)
+		}
+		assertEqual(t, codes, []string{"AM a.txt", "AD b.txt", "A  dir/c.txt"})
+		assertEqual(t, status.Untracked, []string{".gitignore", "dir/d.txt", "new/"})
+		assertEqual(t, status.Ignored, []string{"x.log"})
+	})
+}
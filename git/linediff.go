@@ -0,0 +1,734 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// LineOp is the operation done on a single line in a line level diff.
+type LineOp byte
+
+// Line operations, represented by the prefix used for them in patches.
+const (
+	LineEqual  LineOp = ' '
+	LineDelete LineOp = '-'
+	LineInsert LineOp = '+'
+)
+
+// LineEdit is a single step of an edit script turning one list of lines into
+// another. 'Old' and 'New' are the 0-based line numbers on each side. For the
+// side where the line is not present, it is the number of the next line.
+type LineEdit struct {
+	Op  LineOp
+	Old int
+	New int
+}
+
+// Hunk is a group of nearby line edits along with their context lines, as
+// shown in a unified diff. The start lines are 1-based.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Edits    []LineEdit
+}
+
+// SplitLines splits data into lines. Each line keeps its trailing newline, so
+// a last line without a newline is different from the same line with one.
+func SplitLines(data []byte) []string {
+	lines := []string{}
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		lines = append(lines, string(data[:end]))
+		data = data[end:]
+	}
+
+	return lines
+}
+
+// IsBinary tells if the given data looks binary. Like "git", any data with a
+// NUL byte in its first 8000 bytes is binary.
+func IsBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// lineDiffer finds the lines deleted from 'a' and inserted in 'b' using the
+// linear space variation of the Myers' O(ND) difference algorithm, the way
+// the xdiff library of "git" does, so that the diffs are the same as its own.
+type lineDiffer struct {
+	a, b     []int
+	deleted  []bool
+	inserted []bool
+	// The furthest reaching forward and backward paths of each diagonal.
+	// The diagonal k is at k+offset.
+	forward  []int
+	backward []int
+	offset   int
+	// maxCost is the edit cost after which the search settles for a path
+	// which is not the shortest.
+	maxCost int
+}
+
+// Tunables of the search of the xdiff library.
+const (
+	heurMinCost = 256
+	snakeCount  = 20
+	heurFactor  = 4
+	maxCostMin  = 256
+	lineMax     = int(^uint(0) >> 1)
+)
+
+// bogoSqrt gives a rough square root of n, as a power of two.
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+// DiffLines finds the shortest edit script which turns the lines of 'a' into
+// the lines of 'b'. Deleted lines are given before the inserted lines in
+// each changed region.
+func DiffLines(a, b []string) []LineEdit {
+	// Compare the lines by small integer ids instead of strings.
+	ids := map[string]int{}
+	toIds := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+
+	aIds, bIds := toIds(a), toIds(b)
+	deleted, inserted := make([]bool, len(a)), make([]bool, len(b))
+
+	// Like "git", the lines of one side which are not in the other side are
+	// taken out before the comparison, as they are changes anyway. This
+	// keeps the diffs the same as its own.
+	aKept, bKept := discardLines(aIds, bIds, deleted, inserted)
+	diags := len(aKept) + len(bKept) + 3
+	d := &lineDiffer{
+		a:        make([]int, len(aKept)),
+		b:        make([]int, len(bKept)),
+		deleted:  make([]bool, len(aKept)),
+		inserted: make([]bool, len(bKept)),
+		forward:  make([]int, diags),
+		backward: make([]int, diags),
+		offset:   len(bKept) + 1,
+		maxCost:  bogoSqrt(diags),
+	}
+	if d.maxCost < maxCostMin {
+		d.maxCost = maxCostMin
+	}
+	for i, line := range aKept {
+		d.a[i] = aIds[line]
+	}
+	for j, line := range bKept {
+		d.b[j] = bIds[line]
+	}
+	d.compare(0, len(d.a), 0, len(d.b), false)
+	for i, line := range aKept {
+		deleted[line] = d.deleted[i]
+	}
+	for j, line := range bKept {
+		inserted[line] = d.inserted[j]
+	}
+
+	compactChanges(aIds, deleted, inserted)
+	compactChanges(bIds, inserted, deleted)
+
+	edits := []LineEdit{}
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && deleted[i]:
+			edits = append(edits, LineEdit{LineDelete, i, j})
+			i++
+		case j < len(b) && inserted[j]:
+			edits = append(edits, LineEdit{LineInsert, i, j})
+			j++
+		default:
+			edits = append(edits, LineEdit{LineEqual, i, j})
+			i++
+			j++
+		}
+	}
+
+	return edits
+}
+
+// discardLines marks the lines of each side which can't match any line of
+// the other side as changed, and returns the positions of the other lines.
+// The common lines at the start and the end are kept. A line with many
+// matches in the other side is discarded too if it is in the middle of the
+// discarded lines, as it is unlikely to be a part of a match.
+func discardLines(a, b []int, deleted, inserted []bool) ([]int, []int) {
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start &&
+		a[len(a)-end-1] == b[len(b)-end-1] {
+		end++
+	}
+
+	counts := func(lines []int) map[int]int {
+		result := map[int]int{}
+		for _, line := range lines {
+			result[line]++
+		}
+		return result
+	}
+	aCounts, bCounts := counts(a), counts(b)
+
+	discard := func(lines []int, otherCounts map[int]int, changed []bool) []int {
+		// Each line of the middle is 0 if it has no matches, 2 if it has too
+		// many of them, and 1 otherwise.
+		limit := 1
+		for n := len(lines); n > 0; n >>= 2 {
+			limit <<= 1
+		}
+		if limit > 1024 {
+			limit = 1024
+		}
+		matches := make([]int, len(lines))
+		for i := start; i < len(lines)-end; i++ {
+			switch count := otherCounts[lines[i]]; {
+			case count == 0:
+				matches[i] = 0
+			case count >= limit:
+				matches[i] = 2
+			default:
+				matches[i] = 1
+			}
+		}
+
+		kept := []int{}
+		for i := 0; i < len(lines); i++ {
+			if i < start || i >= len(lines)-end || matches[i] == 1 ||
+				(matches[i] == 2 && !discardMultimatch(matches, i, start, len(lines)-end-1)) {
+				kept = append(kept, i)
+			} else {
+				changed[i] = true
+			}
+		}
+		return kept
+	}
+
+	return discard(a, bCounts, deleted), discard(b, aCounts, inserted)
+}
+
+// discardMultimatch tells if a line with many matches is to be discarded. It
+// is so if it is surrounded by runs of lines without any matches on both its
+// sides, which are long enough when compared to the lines with many matches
+// in them. 'start' and 'end' are the first and the last lines to look at.
+func discardMultimatch(matches []int, i, start, end int) bool {
+	const window = 100
+	if i-start > window {
+		start = i - window
+	}
+	if end-i > window {
+		end = i + window
+	}
+
+	// run counts the lines without matches and with many matches around
+	// the line, in the given direction.
+	run := func(step int) (int, int) {
+		none, many := 0, 1
+		for r := i + step; r >= start && r <= end; r += step {
+			if matches[r] == 0 {
+				none++
+			} else if matches[r] == 2 {
+				many++
+			} else {
+				break
+			}
+		}
+		return none, many
+	}
+
+	noneBefore, manyBefore := run(-1)
+	if noneBefore == 0 {
+		return false
+	}
+	noneAfter, manyAfter := run(1)
+	if noneAfter == 0 {
+		return false
+	}
+	none, many := noneBefore+noneAfter, manyBefore+manyAfter
+	return many*4 < many+none
+}
+
+// compare marks the deleted and inserted lines in a[aLo:aHi] and b[bLo:bHi].
+// Unless 'needMin' is set, the edit script may not be the shortest one if
+// finding it is too costly.
+func (d *lineDiffer) compare(aLo, aHi, bLo, bHi int, needMin bool) {
+	// Skip the common prefix and suffix.
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		x, y, minLo, minHi := d.split(aLo, aHi, bLo, bHi, needMin)
+		d.compare(aLo, x, bLo, y, minLo)
+		d.compare(x, aHi, y, bHi, minHi)
+	}
+}
+
+// split finds a point of the edit path between a[aLo:aHi] and b[bLo:bHi] by
+// searching forward and backward at the same time, so that the problem can be
+// split in two. The point is where both the searches overlap, or is a point
+// on a good enough path if the search is too costly. It also tells if the
+// shortest path is still needed for each of the halves.
+func (d *lineDiffer) split(aLo, aHi, bLo, bHi int, needMin bool) (int, int, bool, bool) {
+	fwd := func(k int) *int { return &d.forward[k+d.offset] }
+	bwd := func(k int) *int { return &d.backward[k+d.offset] }
+
+	dMin, dMax := aLo-bHi, aHi-bLo
+	fMid, bMid := aLo-bLo, aHi-bHi
+	odd := (fMid-bMid)&1 != 0
+	fMin, fMax := fMid, fMid
+	bMin, bMax := bMid, bMid
+	*fwd(fMid) = aLo
+	*bwd(bMid) = aHi
+
+	for cost := 1; ; cost++ {
+		gotSnake := false
+
+		// Extend the diagonals by one in each direction, as long as they
+		// are inside the box.
+		if fMin > dMin {
+			fMin--
+			*fwd(fMin - 1) = -1
+		} else {
+			fMin++
+		}
+		if fMax < dMax {
+			fMax++
+			*fwd(fMax + 1) = -1
+		} else {
+			fMax--
+		}
+
+		for k := fMax; k >= fMin; k -= 2 {
+			var x int
+			if *fwd(k - 1) >= *fwd(k + 1) {
+				x = *fwd(k - 1) + 1
+			} else {
+				x = *fwd(k + 1)
+			}
+			prev := x
+			y := x - k
+			for x < aHi && y < bHi && d.a[x] == d.b[y] {
+				x++
+				y++
+			}
+			if x-prev > snakeCount {
+				gotSnake = true
+			}
+			*fwd(k) = x
+			if odd && bMin <= k && k <= bMax && *bwd(k) <= x {
+				return x, y, true, true
+			}
+		}
+
+		if bMin > dMin {
+			bMin--
+			*bwd(bMin - 1) = lineMax
+		} else {
+			bMin++
+		}
+		if bMax < dMax {
+			bMax++
+			*bwd(bMax + 1) = lineMax
+		} else {
+			bMax--
+		}
+
+		for k := bMax; k >= bMin; k -= 2 {
+			var x int
+			if *bwd(k - 1) < *bwd(k + 1) {
+				x = *bwd(k - 1)
+			} else {
+				x = *bwd(k + 1) - 1
+			}
+			prev := x
+			y := x - k
+			for x > aLo && y > bLo && d.a[x-1] == d.b[y-1] {
+				x--
+				y--
+			}
+			if prev-x > snakeCount {
+				gotSnake = true
+			}
+			*bwd(k) = x
+			if !odd && fMin <= k && k <= fMax && x <= *fwd(k) {
+				return x, y, true, true
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		// If the cost is high and a long snake is found, then settle for a
+		// diagonal which has gone far enough while staying near the middle.
+		if gotSnake && cost > heurMinCost {
+			best, bestX, bestY := 0, 0, 0
+			for k := fMax; k >= fMin; k -= 2 {
+				dd := k - fMid
+				if dd < 0 {
+					dd = -dd
+				}
+				x := *fwd(k)
+				y := x - k
+				v := (x - aLo) + (y - bLo) - dd
+				if v > heurFactor*cost && v > best &&
+					aLo+snakeCount <= x && x < aHi && bLo+snakeCount <= y && y < bHi {
+					for n := 1; d.a[x-n] == d.b[y-n]; n++ {
+						if n == snakeCount {
+							best, bestX, bestY = v, x, y
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return bestX, bestY, true, false
+			}
+
+			best = 0
+			for k := bMax; k >= bMin; k -= 2 {
+				dd := k - bMid
+				if dd < 0 {
+					dd = -dd
+				}
+				x := *bwd(k)
+				y := x - k
+				v := (aHi - x) + (bHi - y) - dd
+				if v > heurFactor*cost && v > best &&
+					aLo < x && x <= aHi-snakeCount && bLo < y && y <= bHi-snakeCount {
+					for n := 0; d.a[x+n] == d.b[y+n]; n++ {
+						if n == snakeCount-1 {
+							best, bestX, bestY = v, x, y
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return bestX, bestY, false, true
+			}
+		}
+
+		// Enough is enough. Take the furthest reaching path so far.
+		if cost >= d.maxCost {
+			fBest, fBestX := -1, -1
+			for k := fMax; k >= fMin; k -= 2 {
+				x := *fwd(k)
+				if x > aHi {
+					x = aHi
+				}
+				y := x - k
+				if bHi < y {
+					x, y = bHi+k, bHi
+				}
+				if fBest < x+y {
+					fBest, fBestX = x+y, x
+				}
+			}
+
+			bBest, bBestX := lineMax, lineMax
+			for k := bMax; k >= bMin; k -= 2 {
+				x := *bwd(k)
+				if x < aLo {
+					x = aLo
+				}
+				y := x - k
+				if y < bLo {
+					x, y = bLo+k, bLo
+				}
+				if x+y < bBest {
+					bBest, bBestX = x+y, x
+				}
+			}
+
+			if (aHi+bHi)-bBest < fBest-(aLo+bLo) {
+				return fBestX, fBest - fBestX, true, false
+			}
+			return bBestX, bBest - bBestX, false, true
+		}
+	}
+}
+
+// lineGroup is a run of changed lines [start, end) of one side of a diff. An
+// empty group is the position between two unchanged lines.
+type lineGroup struct {
+	start, end int
+}
+
+// compactChanges slides the groups of changed lines of one side of a diff,
+// like "git" does. 'lines' are the lines of the side, 'changed' marks the
+// changed lines in it, and 'other' marks the changed lines of the other side.
+// A group which can be moved across equal lines is moved down as far as
+// possible, merging with the groups it meets, and then back up to line up
+// with a group of changes in the other side, if it can.
+func compactChanges(lines []int, changed, other []bool) {
+	isChanged := func(marks []bool, i int) bool {
+		return i >= 0 && i < len(marks) && marks[i]
+	}
+	// next and previous move a group to the next or the previous group of a
+	// side. They return false at the end of the side.
+	next := func(marks []bool, g *lineGroup) bool {
+		if g.end == len(marks) {
+			return false
+		}
+		g.start = g.end + 1
+		for g.end = g.start; isChanged(marks, g.end); g.end++ {
+		}
+		return true
+	}
+	previous := func(marks []bool, g *lineGroup) bool {
+		if g.start == 0 {
+			return false
+		}
+		g.end = g.start - 1
+		for g.start = g.end; isChanged(marks, g.start-1); g.start-- {
+		}
+		return true
+	}
+	slideDown := func(g *lineGroup) bool {
+		if g.end >= len(lines) || lines[g.start] != lines[g.end] {
+			return false
+		}
+		changed[g.start], changed[g.end] = false, true
+		g.start, g.end = g.start+1, g.end+1
+		for isChanged(changed, g.end) {
+			g.end++
+		}
+		return true
+	}
+	slideUp := func(g *lineGroup) bool {
+		if g.start == 0 || lines[g.start-1] != lines[g.end-1] {
+			return false
+		}
+		changed[g.start-1], changed[g.end-1] = true, false
+		g.start, g.end = g.start-1, g.end-1
+		for isChanged(changed, g.start-1) {
+			g.start--
+		}
+		return true
+	}
+
+	g, og := &lineGroup{}, &lineGroup{}
+	for isChanged(changed, g.end) {
+		g.end++
+	}
+	for isChanged(other, og.end) {
+		og.end++
+	}
+
+	for {
+		if g.end > g.start {
+			earliestEnd, endMatchingOther := 0, -1
+			for {
+				size := g.end - g.start
+				endMatchingOther = -1
+				for slideUp(g) {
+					previous(other, og)
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+				for slideDown(g) {
+					next(other, og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+				if size == g.end-g.start {
+					break
+				}
+			}
+
+			// Line up with the last group of changes in the other side.
+			if g.end != earliestEnd && endMatchingOther != -1 {
+				for og.end == og.start {
+					slideUp(g)
+					previous(other, og)
+				}
+			}
+		}
+
+		if !next(changed, g) {
+			break
+		}
+		next(other, og)
+	}
+}
+
+// MakeHunks groups the changes of an edit script into hunks with up to
+// 'context' unchanged lines around them. Changes separated by no more than
+// twice the context lines are put in the same hunk.
+func MakeHunks(edits []LineEdit, context int) []Hunk {
+	hunks := []Hunk{}
+	for i := 0; i < len(edits); {
+		if edits[i].Op == LineEqual {
+			i++
+			continue
+		}
+
+		// Found a change. Extend the hunk till the gap to the next change is
+		// larger than the context on both its sides.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != LineEqual {
+				end++
+				continue
+			}
+			next := end
+			for next < len(edits) && edits[next].Op == LineEqual {
+				next++
+			}
+			if next == len(edits) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		hunks = append(hunks, newHunk(edits[start:stop]))
+		i = stop
+	}
+
+	return hunks
+}
+
+// newHunk computes the line ranges covered by a group of edits.
+func newHunk(edits []LineEdit) Hunk {
+	hunk := Hunk{
+		Edits:    edits,
+		OldStart: edits[0].Old + 1,
+		NewStart: edits[0].New + 1,
+	}
+	for _, edit := range edits {
+		if edit.Op != LineInsert {
+			hunk.OldLines++
+		}
+		if edit.Op != LineDelete {
+			hunk.NewLines++
+		}
+	}
+
+	// An empty range starts at the line before it, as in "@@ -0,0 +1 @@".
+	if hunk.OldLines == 0 {
+		hunk.OldStart--
+	}
+	if hunk.NewLines == 0 {
+		hunk.NewStart--
+	}
+
+	return hunk
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of a hunk. A count of one line is
+// not shown, like "git" does.
+func (hunk *Hunk) Header() string {
+	rangeStr := func(start, count int) string {
+		if count == 1 {
+			return fmt.Sprintf("%d", start)
+		}
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+
+	return fmt.Sprintf("@@ -%s +%s @@", rangeStr(hunk.OldStart, hunk.OldLines),
+		rangeStr(hunk.NewStart, hunk.NewLines))
+}
+
+// funcLine finds the last line before line number 'end' which looks like the
+// start of a function (a line starting with a letter, '_' or '$'), for the
+// header of a hunk. Like "git", the line is cut to 80 bytes.
+func funcLine(lines []string, end int) string {
+	for i := end - 1; i >= 0; i-- {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if c := line[0]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			c == '_' || c == '$' {
+			if len(line) > 80 {
+				line = line[:80]
+			}
+			return strings.TrimRight(line, " \t\n\v\f\r")
+		}
+	}
+	return ""
+}
+
+// UnifiedDiff returns the hunks of a unified diff between two lists of lines,
+// without any file headers. A line without a trailing newline is followed by
+// the "\ No newline at end of file" marker. The header of each hunk shows the
+// line of the function in which the hunk starts.
+func UnifiedDiff(a, b []string, context int) string {
+	var w strings.Builder
+	for _, hunk := range MakeHunks(DiffLines(a, b), context) {
+		w.WriteString(hunk.Header())
+		if fn := funcLine(a, hunk.Edits[0].Old); fn != "" {
+			w.WriteString(" " + fn)
+		}
+		fmt.Fprintln(&w)
+		for _, edit := range hunk.Edits {
+			line := ""
+			switch edit.Op {
+			case LineInsert:
+				line = b[edit.New]
+			default:
+				line = a[edit.Old]
+			}
+			w.WriteByte(byte(edit.Op))
+			w.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				w.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return w.String()
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	// Validate that the edit script turns the old lines into the new lines.
+	t.Run("Validate edit script", func(t *testing.T) {
+		a := SplitLines([]byte("a\nb\nc\nd\ne\n"))
+		b := SplitLines([]byte("a\nc\nd\nx\ne\nf"))
+		edits := DiffLines(a, b)
+
+		ops := ""
+		result := []string{}
+		for _, edit := range edits {
+			ops += string(edit.Op)
+			switch edit.Op {
+			case LineEqual:
+				result = append(result, a[edit.Old])
+			case LineInsert:
+				result = append(result, b[edit.New])
+			}
+		}
+		assertEqual(t, ops, " -  + +")
+		assertEqual(t, result, b)
+	})
+
+	t.Run("Validate unified diff", func(t *testing.T) {
+		a := SplitLines([]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"))
+		b := SplitLines([]byte("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12"))
+		want := "@@ -1,6 +1,6 @@\n" +
+			" 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+			"@@ -9,4 +9,4 @@\n" +
+			" 9\n 10\n 11\n-12\n+12\n\\ No newline at end of file\n"
+		assertEqual(t, UnifiedDiff(a, b, DefaultContext), want)
+	})
+
+	t.Run("Validate unified diff of a new file", func(t *testing.T) {
+		got := UnifiedDiff(nil, SplitLines([]byte("x\ny\n")), DefaultContext)
+		assertEqual(t, got, "@@ -0,0 +1,2 @@\n+x\n+y\n")
+	})
+
+	t.Run("Validate function line in hunk headers", func(t *testing.T) {
+		a := SplitLines([]byte("func main() {\n\t1\n\t2\n\t3\n\t4\n\t5\n}\n"))
+		b := SplitLines([]byte("func main() {\n\t1\n\t2\n\t3\n\t4\n\tfive\n}\n"))
+		want := "@@ -3,5 +3,5 @@ func main() {\n" +
+			" \t2\n \t3\n \t4\n-\t5\n+\tfive\n }\n"
+		assertEqual(t, UnifiedDiff(a, b, DefaultContext), want)
+	})
+
+	t.Run("Validate sliding of changes", func(t *testing.T) {
+		// Like "git", a change which could be anywhere in a run of equal
+		// lines is put at its end.
+		a := SplitLines([]byte("a\nb\nb\nc\n"))
+		b := SplitLines([]byte("a\nb\nc\n"))
+		assertEqual(t, UnifiedDiff(a, b, 0), "@@ -3 +2,0 @@ b\n-b\n")
+	})
+
+	t.Run("Validate binary detection", func(t *testing.T) {
+		assertEqual(t, IsBinary([]byte("text\n")), false)
+		assertEqual(t, IsBinary([]byte("bin\x00ary")), true)
+	})
+}
+
+func TestDiffStat(t *testing.T) {
+	stats := []FileStat{
+		{Path: "a.txt", Name: "a.txt", Added: 3, Deleted: 1},
+		{Path: "dir/b.txt", Name: renameName("dir/a.txt", "dir/b.txt"), Deleted: 2},
+		{Path: "img.png", Name: "img.png", Binary: true, OldSize: 10, NewSize: 20},
+	}
+
+	t.Run("Validate rename names", func(t *testing.T) {
+		assertEqual(t, renameName("a.txt", "d/a.txt"), "a.txt => d/a.txt")
+		assertEqual(t, renameName("d/a.txt", "d/b.txt"), "d/{a.txt => b.txt}")
+		assertEqual(t, renameName("a/x/f", "b/x/f"), "{a => b}/x/f")
+	})
+
+	t.Run("Validate numstat", func(t *testing.T) {
+		want := "3\t1\ta.txt\n0\t2\tdir/{a.txt => b.txt}\n-\t-\timg.png\n"
+		assertEqual(t, FormatNumstat(stats), want)
+	})
+
+	t.Run("Validate stat", func(t *testing.T) {
+		want := strings.Join([]string{
+			" a.txt                |   4 +++-",
+			" dir/{a.txt => b.txt} |   2 --",
+			" img.png              | Bin 10 -> 20 bytes",
+			" 3 files changed, 3 insertions(+), 3 deletions(-)",
+		}, "\n") + "\n"
+		assertEqual(t, FormatStat(stats, 80), want)
+	})
+
+	t.Run("Validate stat scaled to the width", func(t *testing.T) {
+		wide := []FileStat{{Path: "f", Name: "f", Added: 100, Deleted: 100}}
+		got := FormatStat(wide, 40)
+		line := strings.Split(got, "\n")[0]
+		assertEqual(t, len(line) <= 40, true)
+		assertEqual(t, strings.Count(line, "+"), strings.Count(line, "-"))
+	})
+
+	t.Run("Validate shortstat", func(t *testing.T) {
+		one := []FileStat{{Path: "f", Name: "f", Added: 1}}
+		assertEqual(t, FormatShortstat(one), " 1 file changed, 1 insertion(+)\n")
+	})
+
+	// A directory whose changes are all from one subdirectory is not shown,
+	// even if the changes are cumulative.
+	t.Run("Validate dirstat", func(t *testing.T) {
+		files := func() []dirstatFile {
+			return []dirstatFile{
+				{"top", 1}, {"b/c/1", 5}, {"a/y/1", 3}, {"a/x/2", 4},
+				{"a/x/1", 6}, {"a-b/1", 1},
+			}
+		}
+		got := formatDirstat(files(), &DirstatOptions{Permille: 30})
+		assertEqual(t, got, "   5.0% a-b/\n  50.0% a/x/\n  15.0% a/y/\n  25.0% b/c/\n")
+		got = formatDirstat(files(), &DirstatOptions{Permille: 30, Cumulative: true})
+		assertEqual(t, got, "   5.0% a-b/\n  50.0% a/x/\n  15.0% a/y/\n  65.0% a/\n  25.0% b/c/\n")
+		got = formatDirstat(files(), &DirstatOptions{Permille: 60})
+		assertEqual(t, got, "  50.0% a/x/\n  15.0% a/y/\n  25.0% b/c/\n")
+	})
+}
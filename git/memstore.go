@@ -0,0 +1,172 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryObjectStore is an ObjectStore which keeps the objects in memory, such
+// as for the tests or for the services which don't need them on a disk. It
+// is safe for concurrent use.
+type MemoryObjectStore struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemoryObjectStore returns an empty object store.
+func NewMemoryObjectStore() *MemoryObjectStore {
+	return &MemoryObjectStore{
+		objects: map[string][]byte{},
+	}
+}
+
+// ReadObject returns a copy of the data of an object.
+func (s *MemoryObjectStore) ReadObject(hash string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.objects[hash]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: hash, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// OpenObject returns a reader for the data of an object.
+func (s *MemoryObjectStore) OpenObject(hash string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The reader can't change the data, so it doesn't need a copy.
+	data, ok := s.objects[hash]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: hash, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// WriteObject keeps a copy of the data of an object, unless it already exists.
+func (s *MemoryObjectStore) WriteObject(hash string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.objects[hash]; !ok {
+		s.objects[hash] = append([]byte{}, data...)
+	}
+	return nil
+}
+
+// CreateObject returns a writer which keeps the data of an object in a buffer
+// till it's committed.
+func (s *MemoryObjectStore) CreateObject() (ObjectWriter, error) {
+	return &memoryObjectWriter{store: s}, nil
+}
+
+// FindObjects returns the hashes of the objects starting with a prefix.
+func (s *MemoryObjectStore) FindObjects(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashes := []string{}
+	for hash := range s.objects {
+		if strings.HasPrefix(hash, prefix) {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+// memoryObjectWriter buffers the data of a new object.
+type memoryObjectWriter struct {
+	bytes.Buffer
+	store *MemoryObjectStore
+}
+
+// Commit adds the buffered object to the store.
+func (w *memoryObjectWriter) Commit(hash string) error {
+	return w.store.WriteObject(hash, w.Bytes())
+}
+
+// Close drops the buffered data.
+func (w *memoryObjectWriter) Close() error {
+	w.Reset()
+	return nil
+}
+
+// MemoryRefStore is a RefStore which keeps the references in memory. It is
+// safe for concurrent use.
+type MemoryRefStore struct {
+	mu   sync.RWMutex
+	refs map[string]string
+}
+
+// NewMemoryRefStore returns a reference store with HEAD pointing to the
+// given branch, such as "refs/heads/master", which doesn't exist yet.
+func NewMemoryRefStore(branch string) *MemoryRefStore {
+	return &MemoryRefStore{
+		refs: map[string]string{"HEAD": "ref: " + branch},
+	}
+}
+
+// ReadRef returns the value of a reference.
+func (s *MemoryRefStore) ReadRef(name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.refs[name]
+	if !ok {
+		return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return value, nil
+}
+
+// WriteRef sets the value of a reference.
+func (s *MemoryRefStore) WriteRef(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs[name] = value
+	return nil
+}
+
+// DeleteRef removes a reference.
+func (s *MemoryRefStore) DeleteRef(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.refs, name)
+	return nil
+}
+
+// ListRefs returns the names of the references under "refs/".
+func (s *MemoryRefStore) ListRefs() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := []string{}
+	for name := range s.refs {
+		if strings.HasPrefix(name, "refs/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// NewMemoryRepo returns a repo which keeps its objects and references in
+// memory, without a git directory or a work-tree. HEAD points to the master
+// branch, which doesn't have any commits yet. It is meant for working with
+// the objects and the references only, as the index, the logs and the
+// configuration of a repo need a git directory.
+func NewMemoryRepo() *Repo {
+	return &Repo{
+		Objects: NewMemoryObjectStore(),
+		Refs:    NewMemoryRefStore("refs/heads/master"),
+	}
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMemoryRepo(t *testing.T) {
+	// Nothing is written to the current directory either.
+	dir, err := ioutil.TempDir(os.TempDir(), "testGoGitMemory")
+	assertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+	cwd, err := os.Getwd()
+	assertEqual(t, err, nil)
+	assertEqual(t, os.Chdir(dir), nil)
+	defer os.Chdir(cwd)
+
+	repo := NewMemoryRepo()
+	tree := writeTestTree(t, repo, map[string]string{"a": "a\n", "d/b": "b\n"})
+	root := writeTestCommit(t, repo, tree)
+
+	t.Run("Validate the objects", func(t *testing.T) {
+		obj, err := repo.ObjectParse(root)
+		assertEqual(t, err, nil)
+		assertEqual(t, obj.ObjType, "commit")
+
+		hash, err := repo.PathResolve(tree, "d/b")
+		assertEqual(t, err, nil)
+		obj, err = repo.ObjectParse(hash)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(obj.ObjData), "b\n")
+
+		// Changing the parsed data doesn't change the object.
+		obj.ObjData[0] = 'c'
+		obj, err = repo.ObjectParse(hash)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(obj.ObjData), "b\n")
+
+		_, err = repo.ObjectParse(nullHash)
+		assertEqual(t, os.IsNotExist(err), true)
+		hash, err = repo.UniqueNameResolve(root[:7])
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, root)
+	})
+
+	t.Run("Validate the references", func(t *testing.T) {
+		branch, hash, err := repo.Head()
+		assertEqual(t, err, nil)
+		assertEqual(t, branch, "refs/heads/master")
+		assertEqual(t, hash, "")
+
+		assertEqual(t, repo.UpdateRef("HEAD", root), nil)
+		next := writeTestCommit(t, repo, tree, root)
+		assertEqual(t, repo.UpdateRef("refs/heads/topic", next), nil)
+		refs, err := repo.GetRefs("", true)
+		assertEqual(t, err, nil)
+		assertEqual(t, refs, []RefEntry{
+			{"HEAD", root}, {"refs/heads/master", root}, {"refs/heads/topic", next},
+		})
+
+		assertEqual(t, repo.SetHead("refs/heads/topic"), nil)
+		hash, err = repo.UniqueNameResolve("HEAD")
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, next)
+		bases, err := repo.MergeBases(root, next)
+		assertEqual(t, err, nil)
+		assertEqual(t, bases, []string{root})
+	})
+
+	t.Run("Validate the state references", func(t *testing.T) {
+		assertEqual(t, repo.WriteOrigHead(root), nil)
+		hash, _, err := repo.RefResolve("ORIG_HEAD")
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, root)
+
+		assertEqual(t, repo.Refs.WriteRef("MERGE_HEAD", root), nil)
+		assertEqual(t, repo.ClearMergeState(), nil)
+		_, err = repo.Refs.ReadRef("MERGE_HEAD")
+		assertEqual(t, os.IsNotExist(err), true)
+	})
+
+	files, err := ioutil.ReadDir(dir)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(files), 0)
+}
@@ -0,0 +1,649 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MergeOptions controls how the changes of two sides are merged.
+type MergeOptions struct {
+	// The names of the sides, shown in the conflict markers and the
+	// messages, such as "HEAD" and the name of a branch. The label of the
+	// base is set by MergeCommits.
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+	// Style is the format of the conflicts left in the files.
+	Style ConflictStyle
+}
+
+// MergeStage is a version of a conflicted path, to be recorded in the index at
+// the given stage: 1 for the merge base, 2 for ours and 3 for theirs.
+type MergeStage struct {
+	FileEntry
+	Stage int
+}
+
+// MergeResult is the outcome of merging two trees.
+type MergeResult struct {
+	// Tree is the hash of the merged tree. A conflicted file is in it with
+	// the conflict markers, or as the version of one of the sides.
+	Tree string
+	// Conflicts lists the versions of all the conflicted paths, sorted by
+	// the paths and then the stages.
+	Conflicts []MergeStage
+	// Messages tell which files were merged by content, and the reason of
+	// each conflict, in the order of the paths.
+	Messages []MergeMessage
+}
+
+// MergeMessage is a message about one or more paths of a merge.
+type MergeMessage struct {
+	// Paths are the paths which the message is about, the main one first.
+	Paths []string
+	// Kind is a stable name of the kind of the message, such as
+	// "Auto-merging" or "CONFLICT (contents)".
+	Kind string
+	Text string
+}
+
+// Clean tells if the merge didn't have any conflicts.
+func (res *MergeResult) Clean() bool {
+	return len(res.Conflicts) == 0
+}
+
+// ConflictedPaths returns the paths which have conflicts, sorted.
+func (res *MergeResult) ConflictedPaths() []string {
+	paths := []string{}
+	for i, stage := range res.Conflicts {
+		if i == 0 || res.Conflicts[i-1].Path != stage.Path {
+			paths = append(paths, stage.Path)
+		}
+	}
+	return paths
+}
+
+// MergeCommits merges the trees of two commits using their merge bases. If
+// there are many merge bases, they are merged first into a temporary tree to
+// serve as the base, which is how the "ort" strategy of "git" merges.
+func (r *Repo) MergeCommits(ours, theirs string, opts *MergeOptions) (*MergeResult, error) {
+	bases, err := r.MergeBases(ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeOpts := *opts
+	baseTree := ""
+	switch len(bases) {
+	case 0:
+		mergeOpts.BaseLabel = "empty tree"
+	case 1:
+		mergeOpts.BaseLabel = bases[0][:7]
+		baseTree, err = r.TreeResolve(bases[0])
+	default:
+		mergeOpts.BaseLabel = "merged common ancestors"
+		baseTree, err = r.virtualBase(bases, opts.Style)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	oursTree, err := r.TreeResolve(ours)
+	if err != nil {
+		return nil, err
+	}
+	theirsTree, err := r.TreeResolve(theirs)
+	if err != nil {
+		return nil, err
+	}
+	return r.MergeTrees(baseTree, oursTree, theirsTree, &mergeOpts)
+}
+
+// virtualBase merges many merge bases, one after another, into a single tree.
+// The conflicts are left in the tree with their markers.
+func (r *Repo) virtualBase(bases []string, style ConflictStyle) (string, error) {
+	tree, err := r.TreeResolve(bases[0])
+	if err != nil {
+		return "", err
+	}
+
+	merged := []string{bases[0]}
+	for _, next := range bases[1:] {
+		nextBases, err := r.mergeBases(merged, []string{next})
+		if err != nil {
+			return "", err
+		}
+
+		opts := &MergeOptions{
+			OursLabel:   "Temporary merge branch 1",
+			TheirsLabel: "Temporary merge branch 2",
+			Style:       style,
+		}
+		baseTree := ""
+		switch len(nextBases) {
+		case 0:
+			opts.BaseLabel = "empty tree"
+		case 1:
+			opts.BaseLabel = nextBases[0][:7]
+			baseTree, err = r.TreeResolve(nextBases[0])
+		default:
+			opts.BaseLabel = "merged common ancestors"
+			baseTree, err = r.virtualBase(nextBases, style)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		nextTree, err := r.TreeResolve(next)
+		if err != nil {
+			return "", err
+		}
+		result, err := r.MergeTrees(baseTree, tree, nextTree, opts)
+		if err != nil {
+			return "", err
+		}
+		tree = result.Tree
+		merged = append(merged, next)
+	}
+	return tree, nil
+}
+
+// treeMerge holds the state of a single MergeTrees operation.
+type treeMerge struct {
+	repo *Repo
+	opts *MergeOptions
+	// The files of the three trees, by their paths.
+	base, ours, theirs map[string]FileEntry
+	// result has the files of the merged tree.
+	result    map[string]FileEntry
+	conflicts []MergeStage
+	// messages are kept along with the paths they are about, to be sorted.
+	messages []mergeMessage
+}
+
+// mergeMessage is a message along with the path it is sorted by.
+type mergeMessage struct {
+	path string
+	MergeMessage
+}
+
+// MergeTrees does a three-way merge of the trees 'ours' and 'theirs', whose
+// common ancestor has the tree 'base' (empty if there is none). The files are
+// merged one path at a time: a change on one side is taken as is, and the
+// files changed on both sides are merged by their content. The renames on
+// either side are followed, so that the changes to a renamed file are merged
+// with the renamed file.
+func (r *Repo) MergeTrees(base, ours, theirs string, opts *MergeOptions) (*MergeResult, error) {
+	m := &treeMerge{
+		repo:      r,
+		opts:      opts,
+		result:    map[string]FileEntry{},
+		conflicts: []MergeStage{},
+		messages:  []mergeMessage{},
+	}
+	var err error
+	if m.base, err = r.treeFileMap(base); err != nil {
+		return nil, err
+	}
+	if m.ours, err = r.treeFileMap(ours); err != nil {
+		return nil, err
+	}
+	if m.theirs, err = r.treeFileMap(theirs); err != nil {
+		return nil, err
+	}
+
+	oursRenames, err := r.mergeRenames(base, ours)
+	if err != nil {
+		return nil, err
+	}
+	theirsRenames, err := r.mergeRenames(base, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The renamed files are merged first, and the rest of the paths are
+	// merged as they are.
+	done := map[string]bool{}
+	for _, basePath := range sortedKeys(m.base) {
+		if err := m.mergeRenamed(basePath, oursRenames, theirsRenames, done); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := map[string]bool{}
+	for _, files := range []map[string]FileEntry{m.base, m.ours, m.theirs} {
+		for filePath := range files {
+			if !done[filePath] {
+				paths[filePath] = true
+			}
+		}
+	}
+	sortedPaths := []string{}
+	for filePath := range paths {
+		sortedPaths = append(sortedPaths, filePath)
+	}
+	sort.Strings(sortedPaths)
+	for _, filePath := range sortedPaths {
+		err := m.mergePath(filePath, m.base[filePath], m.ours[filePath], m.theirs[filePath])
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.moveFilesInTheWay()
+
+	files := []FileEntry{}
+	for _, filePath := range sortedKeys(m.result) {
+		files = append(files, m.result[filePath])
+	}
+	tree, err := r.WriteTree(files)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(m.conflicts, func(i, j int) bool {
+		if m.conflicts[i].Path != m.conflicts[j].Path {
+			return m.conflicts[i].Path < m.conflicts[j].Path
+		}
+		return m.conflicts[i].Stage < m.conflicts[j].Stage
+	})
+	sort.SliceStable(m.messages, func(i, j int) bool {
+		return m.messages[i].path < m.messages[j].path
+	})
+	result := &MergeResult{Tree: tree, Conflicts: m.conflicts, Messages: []MergeMessage{}}
+	for _, message := range m.messages {
+		result.Messages = append(result.Messages, message.MergeMessage)
+	}
+	return result, nil
+}
+
+// mergeRenames finds the files of the base which are renamed in a side, and
+// maps their paths to the renamed files.
+func (r *Repo) mergeRenames(base, side string) (map[string]FileEntry, error) {
+	renames := map[string]FileEntry{}
+	if base == "" || side == "" {
+		return renames, nil
+	}
+
+	opts := &DiffOptions{DetectRenames: true, RenameLimit: DefaultRenameLimit}
+	entries, err := r.DiffTrees(base, side, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Status == DiffRenamed {
+			renames[entry.Old.Path] = entry.New
+		}
+	}
+	return renames, nil
+}
+
+// message adds a message of a kind about some paths, which is sorted by the
+// given path.
+func (m *treeMerge) message(filePath, kind string, paths []string, format string,
+	args ...interface{}) {
+	m.messages = append(m.messages, mergeMessage{filePath, MergeMessage{
+		Paths: paths,
+		Kind:  kind,
+		Text:  fmt.Sprintf(format, args...),
+	}})
+}
+
+// conflict records the versions of a conflicted path. The missing versions
+// are skipped.
+func (m *treeMerge) conflict(filePath string, base, ours, theirs FileEntry) {
+	for i, file := range []FileEntry{base, ours, theirs} {
+		if file.Hash != "" {
+			file.Path = filePath
+			m.conflicts = append(m.conflicts, MergeStage{FileEntry: file, Stage: i + 1})
+		}
+	}
+}
+
+// mergeRenamed merges a file of the base which is renamed in either side, and
+// marks the paths it is merged from as done. If both sides renamed the file
+// differently, a rename to a path which the other side also has is not
+// followed.
+func (m *treeMerge) mergeRenamed(basePath string, oursRenames, theirsRenames map[string]FileEntry,
+	done map[string]bool) error {
+	oursFile, oursRenamed := oursRenames[basePath]
+	theirsFile, theirsRenamed := theirsRenames[basePath]
+	sameRename := oursRenamed && theirsRenamed && oursFile.Path == theirsFile.Path
+	if oursRenamed && theirsRenamed && !sameRename {
+		if _, ok := m.theirs[oursFile.Path]; ok {
+			oursRenamed = false
+		}
+		if _, ok := m.ours[theirsFile.Path]; ok {
+			theirsRenamed = false
+		}
+	}
+	if !oursRenamed && !theirsRenamed {
+		return nil
+	}
+
+	baseFile := m.base[basePath]
+	done[basePath] = true
+	switch {
+	case sameRename:
+		done[oursFile.Path] = true
+		return m.mergeContent(oursFile.Path, baseFile, oursFile, theirsFile)
+
+	case oursRenamed && theirsRenamed:
+		done[oursFile.Path], done[theirsFile.Path] = true, true
+		m.message(basePath, "CONFLICT (rename/rename)",
+			[]string{basePath, oursFile.Path, theirsFile.Path},
+			"CONFLICT (rename/rename): %s renamed to %s in %s and to %s in %s.",
+			basePath, oursFile.Path, m.opts.OursLabel, theirsFile.Path, m.opts.TheirsLabel)
+		m.result[oursFile.Path] = oursFile
+		m.result[theirsFile.Path] = theirsFile
+		m.conflict(basePath, baseFile, FileEntry{}, FileEntry{})
+		m.conflict(oursFile.Path, FileEntry{}, oursFile, FileEntry{})
+		m.conflict(theirsFile.Path, FileEntry{}, FileEntry{}, theirsFile)
+		return nil
+
+	case oursRenamed:
+		done[oursFile.Path] = true
+		return m.mergeOneRename(basePath, oursFile, true)
+
+	default:
+		done[theirsFile.Path] = true
+		return m.mergeOneRename(basePath, theirsFile, false)
+	}
+}
+
+// mergeOneRename merges a file of the base renamed by only one side, which is
+// ours if 'ours' is set, with the file of the other side at the base path.
+// Like "git", a rename to a path which the other side also has is followed
+// too: the renamed file is merged first, and then merged with the file of the
+// other side as if both the sides added it.
+func (m *treeMerge) mergeOneRename(basePath string, renamed FileEntry, ours bool) error {
+	label, otherLabel, other := m.opts.OursLabel, m.opts.TheirsLabel, m.theirs
+	if !ours {
+		label, otherLabel, other = m.opts.TheirsLabel, m.opts.OursLabel, m.ours
+	}
+	// sides orders the versions of this side and the other as ours and
+	// theirs.
+	sides := func(file, otherFile FileEntry) (FileEntry, FileEntry) {
+		if ours {
+			return file, otherFile
+		}
+		return otherFile, file
+	}
+
+	baseFile := m.base[basePath]
+	inTheWay, collides := other[renamed.Path]
+	file, ok := other[basePath]
+	oursFile, theirsFile := sides(renamed, file)
+	switch {
+	case ok && !collides:
+		return m.mergeContent(renamed.Path, baseFile, oursFile, theirsFile)
+
+	case ok:
+		// The renamed file is merged at the base path, which is free, and
+		// its conflicts are left to the merge with the file in the way.
+		if err := m.mergeContent(basePath, baseFile, oursFile, theirsFile); err != nil {
+			return err
+		}
+		renamed.Mode, renamed.Hash = m.result[basePath].Mode, m.result[basePath].Hash
+		delete(m.result, basePath)
+		conflicts := m.conflicts[:0]
+		for _, stage := range m.conflicts {
+			if stage.Path != basePath {
+				conflicts = append(conflicts, stage)
+			}
+		}
+		m.conflicts = conflicts
+
+	default:
+		m.message(basePath, "CONFLICT (rename/delete)", []string{renamed.Path, basePath},
+			"CONFLICT (rename/delete): %s renamed to %s in %s, but deleted in %s.",
+			basePath, renamed.Path, label, otherLabel)
+		if !collides {
+			m.result[renamed.Path] = renamed
+			m.conflict(renamed.Path, baseFile, oursFile, theirsFile)
+			return nil
+		}
+	}
+
+	oursFile, theirsFile = sides(renamed, inTheWay)
+	return m.mergeContent(renamed.Path, FileEntry{}, oursFile, theirsFile)
+}
+
+// mergePath merges the versions of a path which is not renamed. A missing
+// version has an empty hash.
+func (m *treeMerge) mergePath(filePath string, base, ours, theirs FileEntry) error {
+	switch {
+	case sameFile(ours, theirs) || sameFile(base, theirs):
+		if ours.Hash != "" {
+			m.result[filePath] = ours
+		}
+	case sameFile(base, ours):
+		if theirs.Hash != "" {
+			m.result[filePath] = theirs
+		}
+
+	case ours.Hash == "":
+		m.message(filePath, "CONFLICT (modify/delete)", []string{filePath},
+			"CONFLICT (modify/delete): %s deleted in %s and modified in %s.  "+
+				"Version %s of %s left in tree.", filePath, m.opts.OursLabel, m.opts.TheirsLabel,
+			m.opts.TheirsLabel, filePath)
+		m.result[filePath] = theirs
+		m.conflict(filePath, base, ours, theirs)
+	case theirs.Hash == "":
+		m.message(filePath, "CONFLICT (modify/delete)", []string{filePath},
+			"CONFLICT (modify/delete): %s deleted in %s and modified in %s.  "+
+				"Version %s of %s left in tree.", filePath, m.opts.TheirsLabel, m.opts.OursLabel,
+			m.opts.OursLabel, filePath)
+		m.result[filePath] = ours
+		m.conflict(filePath, base, ours, theirs)
+
+	default:
+		return m.mergeContent(filePath, base, ours, theirs)
+	}
+	return nil
+}
+
+// mergeContent merges the content and the modes of a file present in both
+// the sides, possibly at other paths, into the given path.
+func (m *treeMerge) mergeContent(filePath string, base, ours, theirs FileEntry) error {
+	hash, clean := ours.Hash, true
+	switch {
+	case ours.Hash == theirs.Hash || base.Hash == theirs.Hash:
+	case base.Hash == ours.Hash:
+		hash = theirs.Hash
+	default:
+		var err error
+		if hash, clean, err = m.mergeBlobs(filePath, base, ours, theirs); err != nil {
+			return err
+		}
+	}
+
+	// The mode changed by one side wins. Different changes of the mode are
+	// a conflict, and ours is kept.
+	mode := ours.Mode
+	if ours.Mode != theirs.Mode {
+		if base.Mode == ours.Mode {
+			mode = theirs.Mode
+		} else if base.Mode != theirs.Mode && clean {
+			m.message(filePath, "CONFLICT (contents)", []string{filePath},
+				"CONFLICT (%s): Merge conflict in %s", conflictKind(base), filePath)
+			clean = false
+		}
+	}
+
+	m.result[filePath] = FileEntry{Path: filePath, Mode: mode, Hash: hash}
+	if !clean {
+		m.conflict(filePath, base, ours, theirs)
+	}
+	return nil
+}
+
+// conflictKind names a conflict of the content of a file, which depends on
+// whether the file is in the base.
+func conflictKind(base FileEntry) string {
+	if base.Hash == "" {
+		return "add/add"
+	}
+	return "content"
+}
+
+// mergeBlobs merges the content of two regular files with a common base, and
+// returns the hash of the merged blob, and whether it is free of conflicts.
+// The conflicts are left in the blob between conflict markers. The files
+// which can't be merged by their content, such as binary files or symlinks,
+// are a conflict, and ours is kept.
+func (m *treeMerge) mergeBlobs(filePath string, base, ours, theirs FileEntry) (string, bool, error) {
+	if modeType(ours.Mode) != "100" || modeType(theirs.Mode) != "100" {
+		m.message(filePath, "CONFLICT (contents)", []string{filePath},
+			"CONFLICT (%s): Merge conflict in %s", conflictKind(base), filePath)
+		return ours.Hash, false, nil
+	}
+
+	data := [][]byte{}
+	for _, file := range []FileEntry{base, ours, theirs} {
+		fileData, err := m.repo.FileData(file)
+		if err != nil {
+			return "", false, err
+		}
+		data = append(data, fileData)
+	}
+	if IsBinary(data[0]) || IsBinary(data[1]) || IsBinary(data[2]) {
+		m.message(filePath, "CONFLICT (binary)", []string{filePath},
+			"warning: Cannot merge binary files: %s (%s vs. %s)",
+			filePath, m.opts.OursLabel, m.opts.TheirsLabel)
+		m.message(filePath, "Auto-merging", []string{filePath}, "Auto-merging %s", filePath)
+		m.message(filePath, "CONFLICT (contents)", []string{filePath},
+			"CONFLICT (%s): Merge conflict in %s", conflictKind(base), filePath)
+		return ours.Hash, false, nil
+	}
+
+	m.message(filePath, "Auto-merging", []string{filePath}, "Auto-merging %s", filePath)
+
+	// The labels have the paths too, if the file is renamed.
+	renamed := (base.Hash != "" && base.Path != filePath) || ours.Path != filePath ||
+		theirs.Path != filePath
+	label := func(name, sidePath string) string {
+		if !renamed {
+			return name
+		}
+		return name + ":" + sidePath
+	}
+	merged, conflicts := MergeFile(data[0], data[1], data[2], &MergeFileOptions{
+		OursLabel:   label(m.opts.OursLabel, ours.Path),
+		BaseLabel:   label(m.opts.BaseLabel, base.Path),
+		TheirsLabel: label(m.opts.TheirsLabel, theirs.Path),
+		Style:       m.opts.Style,
+	})
+	hash, err := m.repo.ObjectWrite(NewObject("blob", merged), true)
+	if err != nil {
+		return "", false, err
+	}
+
+	if conflicts > 0 {
+		m.message(filePath, "CONFLICT (contents)", []string{filePath},
+			"CONFLICT (%s): Merge conflict in %s", conflictKind(base), filePath)
+	}
+	return hash, conflicts == 0, nil
+}
+
+// moveFilesInTheWay moves the merged files whose paths are needed as the
+// directories of other merged files, to a path named after their side.
+func (m *treeMerge) moveFilesInTheWay() {
+	dirs := map[string]bool{}
+	for filePath := range m.result {
+		for dir := path.Dir(filePath); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	for _, filePath := range sortedKeys(m.result) {
+		if !dirs[filePath] {
+			continue
+		}
+
+		file := m.result[filePath]
+		label, stage := m.opts.OursLabel, 2
+		if sameFile(file, m.theirs[filePath]) && !sameFile(file, m.ours[filePath]) {
+			label, stage = m.opts.TheirsLabel, 3
+		}
+		newPath := filePath + "~" + strings.ReplaceAll(label, "/", "_")
+		m.message(filePath, "CONFLICT (file/directory)", []string{newPath, filePath},
+			"CONFLICT (file/directory): directory in the way of %s from %s; "+
+				"moving it to %s instead.", filePath, label, newPath)
+
+		delete(m.result, filePath)
+		file.Path = newPath
+		m.result[newPath] = file
+
+		// The versions of a conflicted file are moved along with it.
+		moved := false
+		for i := range m.conflicts {
+			if m.conflicts[i].Path == filePath {
+				m.conflicts[i].Path, moved = newPath, true
+			}
+		}
+		if !moved {
+			m.conflicts = append(m.conflicts, MergeStage{FileEntry: file, Stage: stage})
+		}
+	}
+}
+
+// SquashMessage returns the message suggested for squashing a merge, which
+// lists the commits reachable from 'theirs' but not from 'head' in the format
+// of "git log", the newest first.
+func (r *Repo) SquashMessage(head, theirs string) (string, error) {
+	headAncestors := map[string]bool{}
+	if head != "" {
+		var err error
+		if headAncestors, err = r.Ancestors(head); err != nil {
+			return "", err
+		}
+	}
+	theirsAncestors, err := r.Ancestors(theirs)
+	if err != nil {
+		return "", err
+	}
+
+	commits := map[string]*Commit{}
+	hashes := []string{}
+	for hash := range theirsAncestors {
+		if headAncestors[hash] {
+			continue
+		}
+		if commits[hash], err = r.commitParse(hash); err != nil {
+			return "", err
+		}
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		iTime, jTime := commitTime(commits[hashes[i]]), commitTime(commits[hashes[j]])
+		if iTime != jTime {
+			return iTime > jTime
+		}
+		return hashes[i] < hashes[j]
+	})
+
+	var b strings.Builder
+	b.WriteString("Squashed commit of the following:\n")
+	for _, hash := range hashes {
+		commit := commits[hash]
+		fmt.Fprintf(&b, "\ncommit %s\n", hash)
+		if parents := commit.Parents(); len(parents) > 1 {
+			short := []string{}
+			for _, parent := range parents {
+				short = append(short, parent[:7])
+			}
+			fmt.Fprintf(&b, "Merge: %s\n", strings.Join(short, " "))
+		}
+		author, date := formatIdentity(commit.Entries["author"][0])
+		fmt.Fprintf(&b, "Author: %s\nDate:   %s\n\n", author, date)
+		for _, line := range strings.Split(strings.TrimRight(commit.Msg, "\n"), "\n") {
+			if line != "" {
+				line = "    " + line
+			}
+			fmt.Fprintln(&b, line)
+		}
+	}
+	return b.String(), nil
+}
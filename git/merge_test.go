@@ -0,0 +1,151 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestMergeTrees(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitMerge")
+
+	opts := &MergeOptions{OursLabel: "HEAD", BaseLabel: "base", TheirsLabel: "side"}
+	messages := func(result *MergeResult) []string {
+		list := []string{}
+		for _, message := range result.Messages {
+			list = append(list, message.Text)
+		}
+		return list
+	}
+	stages := func(result *MergeResult) []string {
+		list := []string{}
+		for _, stage := range result.Conflicts {
+			list = append(list, string('0'+byte(stage.Stage))+" "+stage.Path)
+		}
+		return list
+	}
+
+	base := writeTestTree(t, repo, map[string]string{
+		"a": "1\n2\n3\n4\n5\n", "d": "d\n", "r": "r1\nr2\nr3\nr4\nr5\n",
+	})
+
+	t.Run("Validate a clean merge", func(t *testing.T) {
+		ours := writeTestTree(t, repo, map[string]string{
+			"a": "one\n2\n3\n4\n5\n", "d": "d\n", "r": "r1\nr2\nr3\nr4\nr5\n", "n": "n\n",
+		})
+		theirs := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\nfive\n", "r2": "r1\nr2\nr3\nr4\nr5\n",
+		})
+		result, err := repo.MergeTrees(base, ours, theirs, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, result.Clean(), true)
+		assertEqual(t, messages(result), []string{"Auto-merging a"})
+
+		files, err := repo.treeFileMap(result.Tree)
+		assertEqual(t, err, nil)
+		assertEqual(t, len(files), 3)
+		assertEqual(t, testFileData(t, repo, result.Tree, "a"), "one\n2\n3\n4\nfive\n")
+		assertEqual(t, testFileData(t, repo, result.Tree, "n"), "n\n")
+		assertEqual(t, testFileData(t, repo, result.Tree, "r2"), "r1\nr2\nr3\nr4\nr5\n")
+	})
+
+	t.Run("Validate the changes to a renamed file", func(t *testing.T) {
+		ours := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "r": "r1\nr2\nR3\nr4\nr5\n",
+		})
+		theirs := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "r2": "r1\nr2\nr3\nr4\nR5\n",
+		})
+		result, err := repo.MergeTrees(base, ours, theirs, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, result.Clean(), true)
+		assertEqual(t, testFileData(t, repo, result.Tree, "r2"), "r1\nr2\nR3\nr4\nR5\n")
+		_, err = repo.PathResolve(result.Tree, "r")
+		assertEqual(t, err != nil, true)
+	})
+
+	t.Run("Validate the conflicts", func(t *testing.T) {
+		ours := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\nthree\n4\n5\n", "r": "r1\nr2\nr3\nr4\nr5\n", "n": "ours\n",
+		})
+		theirs := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\nTHREE\n4\n5\n", "d": "d2\n", "n": "theirs\n",
+		})
+		result, err := repo.MergeTrees(base, ours, theirs, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, result.Clean(), false)
+		assertEqual(t, messages(result), []string{
+			"Auto-merging a",
+			"CONFLICT (content): Merge conflict in a",
+			"CONFLICT (modify/delete): d deleted in HEAD and modified in side.  " +
+				"Version side of d left in tree.",
+			"Auto-merging n",
+			"CONFLICT (add/add): Merge conflict in n",
+		})
+		assertEqual(t, stages(result), []string{
+			"1 a", "2 a", "3 a", "1 d", "3 d", "2 n", "3 n",
+		})
+		assertEqual(t, result.ConflictedPaths(), []string{"a", "d", "n"})
+
+		// The conflicted files are in the tree with their conflict markers.
+		assertEqual(t, testFileData(t, repo, result.Tree, "a"),
+			"1\n2\n<<<<<<< HEAD\nthree\n=======\nTHREE\n>>>>>>> side\n4\n5\n")
+		assertEqual(t, testFileData(t, repo, result.Tree, "d"), "d2\n")
+		_, err = repo.PathResolve(result.Tree, "r")
+		assertEqual(t, err != nil, true)
+	})
+
+	t.Run("Validate a rename to a path of the other side", func(t *testing.T) {
+		ours := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "x": "r1\nr2\nR3\nr4\nr5\n",
+		})
+		deleted := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "x": "x\n",
+		})
+		result, err := repo.MergeTrees(base, ours, deleted, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, messages(result), []string{
+			"CONFLICT (rename/delete): r renamed to x in HEAD, but deleted in side.",
+			"Auto-merging x",
+			"CONFLICT (add/add): Merge conflict in x",
+		})
+		assertEqual(t, stages(result), []string{"2 x", "3 x"})
+		assertEqual(t, testFileData(t, repo, result.Tree, "x"),
+			"<<<<<<< HEAD\nr1\nr2\nR3\nr4\nr5\n=======\nx\n>>>>>>> side\n")
+
+		// The changes of the other side to the renamed file are kept.
+		modified := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "r": "r1\nr2\nr3\nr4\nR5\n", "x": "x\n",
+		})
+		result, err = repo.MergeTrees(base, ours, modified, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, messages(result), []string{
+			"Auto-merging r",
+			"Auto-merging x",
+			"CONFLICT (add/add): Merge conflict in x",
+		})
+		assertEqual(t, stages(result), []string{"2 x", "3 x"})
+		assertEqual(t, testFileData(t, repo, result.Tree, "x"),
+			"<<<<<<< HEAD\nr1\nr2\nR3\nr4\nR5\n=======\nx\n>>>>>>> side\n")
+		_, err = repo.PathResolve(result.Tree, "r")
+		assertEqual(t, err != nil, true)
+	})
+
+	t.Run("Validate a file in the way of a directory", func(t *testing.T) {
+		ours := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "r": "r1\nr2\nr3\nr4\nr5\n", "f/g": "g\n",
+		})
+		theirs := writeTestTree(t, repo, map[string]string{
+			"a": "1\n2\n3\n4\n5\n", "d": "d\n", "r": "r1\nr2\nr3\nr4\nr5\n", "f": "f\n",
+		})
+		result, err := repo.MergeTrees(base, ours, theirs, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, messages(result), []string{
+			"CONFLICT (file/directory): directory in the way of f from side; " +
+				"moving it to f~side instead.",
+		})
+		assertEqual(t, result.Messages[0].Kind, "CONFLICT (file/directory)")
+		assertEqual(t, result.Messages[0].Paths, []string{"f~side", "f"})
+		assertEqual(t, stages(result), []string{"3 f~side"})
+		assertEqual(t, testFileData(t, repo, result.Tree, "f~side"), "f\n")
+		assertEqual(t, testFileData(t, repo, result.Tree, "f/g"), "g\n")
+	})
+}
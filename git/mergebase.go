@@ -0,0 +1,343 @@
+package git
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MergeBases returns the best common ancestors of two commits, which are the
+// common ancestors not reachable from any other common ancestor. The newest
+// commits come first. Usually there is only one of them.
+func (r *Repo) MergeBases(ours, theirs string) ([]string, error) {
+	return r.mergeBases([]string{ours}, []string{theirs})
+}
+
+// mergeBases returns the best common ancestors of two groups of commits, where
+// each group is treated as the parents of a single (virtual) commit.
+func (r *Repo) mergeBases(ours, theirs []string) ([]string, error) {
+	commits := map[string]*Commit{}
+	common, paint, err := r.paintDown(ours, theirs, commits)
+	if err != nil {
+		return nil, err
+	}
+
+	// A common ancestor reachable from another one is painted as stale.
+	bases := []string{}
+	for _, hash := range common {
+		if paint[hash]&paintStale == 0 {
+			bases = append(bases, hash)
+		}
+	}
+	if len(bases) > 1 {
+		if bases, err = r.removeRedundant(bases, commits); err != nil {
+			return nil, err
+		}
+	}
+
+	// Like "git", the bases of the same time are in the order they are found.
+	sort.SliceStable(bases, func(i, j int) bool {
+		return commitTime(commits[bases[i]]) > commitTime(commits[bases[j]])
+	})
+	return bases, nil
+}
+
+// The paint of the commits visited by paintDown.
+const (
+	paintOurs = 1 << iota
+	paintTheirs
+	paintStale
+	paintCommon
+)
+
+// paintDown walks the history of two groups of commits like "git" does, the
+// newest commits first, and paints each commit with the groups it is reachable
+// from. A commit reachable from both is a common ancestor, and the commits
+// reachable from it are painted as stale. The walk stops once only the stale
+// commits are left to visit. It returns the common ancestors in the order they
+// are found, and the paint of the visited commits. The parsed commits are kept
+// in 'commits'.
+func (r *Repo) paintDown(ours, theirs []string, commits map[string]*Commit) (
+	[]string, map[string]int, error) {
+	paint := map[string]int{}
+	queue := &commitQueue{}
+	push := func(hash string, flags int) error {
+		paint[hash] |= flags
+		commit, ok := commits[hash]
+		if !ok {
+			var err error
+			if commit, err = r.commitParse(hash); err != nil {
+				return err
+			}
+			commits[hash] = commit
+		}
+		queue.push(hash, commit)
+		return nil
+	}
+	for _, hash := range ours {
+		if err := push(hash, paintOurs); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, hash := range theirs {
+		if err := push(hash, paintTheirs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	common := []string{}
+	for queue.hasUnpainted(paint, paintStale) {
+		hash := queue.pop()
+		flags := paint[hash] & (paintOurs | paintTheirs | paintStale)
+		if flags == paintOurs|paintTheirs {
+			if paint[hash]&paintCommon == 0 {
+				paint[hash] |= paintCommon
+				common = append(common, hash)
+			}
+			flags |= paintStale
+		}
+		for _, parent := range commits[hash].Parents() {
+			if paint[parent]&flags == flags {
+				continue
+			}
+			if err := push(parent, flags); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return common, paint, nil
+}
+
+// removeRedundant removes the commits which are reachable from another one of
+// the given commits, with a walk from each of them down to the others.
+func (r *Repo) removeRedundant(hashes []string, commits map[string]*Commit) ([]string, error) {
+	redundant := map[string]bool{}
+	for _, hash := range hashes {
+		if redundant[hash] {
+			continue
+		}
+		others := []string{}
+		for _, other := range hashes {
+			if other != hash && !redundant[other] {
+				others = append(others, other)
+			}
+		}
+		if len(others) == 0 {
+			break
+		}
+
+		_, paint, err := r.paintDown([]string{hash}, others, commits)
+		if err != nil {
+			return nil, err
+		}
+		if paint[hash]&paintTheirs != 0 {
+			redundant[hash] = true
+		}
+		for _, other := range others {
+			if paint[other]&paintOurs != 0 {
+				redundant[other] = true
+			}
+		}
+	}
+
+	result := []string{}
+	for _, hash := range hashes {
+		if !redundant[hash] {
+			result = append(result, hash)
+		}
+	}
+	return result, nil
+}
+
+// commitQueue is a priority queue of commits, the newest (by the time when it
+// was committed) first. The commits of the same time are in the order they are
+// pushed.
+type commitQueue struct {
+	items []queuedCommit
+	count int
+}
+
+// queuedCommit is a commit in a commitQueue.
+type queuedCommit struct {
+	hash  string
+	time  int64
+	order int
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+func (q *commitQueue) Less(i, j int) bool {
+	if q.items[i].time != q.items[j].time {
+		return q.items[i].time > q.items[j].time
+	}
+	return q.items[i].order < q.items[j].order
+}
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *commitQueue) Push(x interface{}) {
+	q.items = append(q.items, x.(queuedCommit))
+}
+func (q *commitQueue) Pop() interface{} {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+// push adds a commit to the queue.
+func (q *commitQueue) push(hash string, commit *Commit) {
+	q.count++
+	heap.Push(q, queuedCommit{hash: hash, time: commitTime(commit), order: q.count})
+}
+
+// pop removes the newest commit from the queue, and returns its hash.
+func (q *commitQueue) pop() string {
+	return heap.Pop(q).(queuedCommit).hash
+}
+
+// peekTime returns the time of the newest commit in the queue.
+func (q *commitQueue) peekTime() int64 {
+	return q.items[0].time
+}
+
+// hasUnpainted tells if any commit in the queue doesn't have the given paint.
+func (q *commitQueue) hasUnpainted(paint map[string]int, flag int) bool {
+	for _, item := range q.items {
+		if paint[item.hash]&flag == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// commitTime returns the time of a commit (when it was committed) in seconds
+// since the epoch.
+func commitTime(commit *Commit) int64 {
+	items := strings.Fields(commit.Entries["committer"][0])
+	if len(items) < 2 {
+		return 0
+	}
+	seconds, _ := strconv.ParseInt(items[len(items)-2], 10, 64)
+	return seconds
+}
+
+// MergeBasesMany returns the best common ancestors of a commit and a merge of
+// the other commits, as if such a merge commit existed.
+func (r *Repo) MergeBasesMany(commit string, others []string) ([]string, error) {
+	return r.mergeBases([]string{commit}, others)
+}
+
+// OctopusMergeBases returns the best common ancestors of all the commits, for
+// a merge of all of them at once.
+func (r *Repo) OctopusMergeBases(commits []string) ([]string, error) {
+	if len(commits) == 0 {
+		return []string{}, nil
+	}
+
+	// The bases of the commits seen so far are merged with the next commit.
+	bases := []string{commits[0]}
+	for _, next := range commits[1:] {
+		seen := map[string]bool{}
+		nextBases := []string{}
+		for _, base := range bases {
+			found, err := r.MergeBases(next, base)
+			if err != nil {
+				return nil, err
+			}
+			for _, hash := range found {
+				if !seen[hash] {
+					seen[hash] = true
+					nextBases = append(nextBases, hash)
+				}
+			}
+		}
+		bases = nextBases
+	}
+	return r.independent(bases)
+}
+
+// independent removes the commits which are reachable from another one of the
+// given commits.
+func (r *Repo) independent(commits []string) ([]string, error) {
+	return r.removeRedundant(commits, map[string]*Commit{})
+}
+
+// IsAncestor tells if the commit 'ancestor' is reachable from 'commit'. A
+// commit is an ancestor of itself. The history of 'commit' is walked the
+// newest commits first, until the commits are older than 'ancestor'.
+func (r *Repo) IsAncestor(ancestor, commit string) (bool, error) {
+	target, err := r.commitParse(ancestor)
+	if err != nil {
+		return false, err
+	}
+	cutoff := commitTime(target)
+
+	commits := map[string]*Commit{}
+	queue := &commitQueue{}
+	push := func(hash string) error {
+		if _, ok := commits[hash]; ok {
+			return nil
+		}
+		parsed, err := r.commitParse(hash)
+		if err != nil {
+			return err
+		}
+		commits[hash] = parsed
+		queue.push(hash, parsed)
+		return nil
+	}
+	if err := push(commit); err != nil {
+		return false, err
+	}
+	for queue.Len() > 0 && queue.peekTime() >= cutoff {
+		hash := queue.pop()
+		if hash == ancestor {
+			return true, nil
+		}
+		for _, parent := range commits[hash].Parents() {
+			if err := push(parent); err != nil {
+				return false, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// ForkPoint finds the commit at which 'commit' forked from the history of a
+// reference, such as "refs/remotes/origin/master", even if the reference was
+// rewritten since then. The earlier values of the reference are taken from
+// its log. It returns an empty hash if there is no such fork point.
+func (r *Repo) ForkPoint(ref, commit string) (string, error) {
+	entries, err := r.ReadReflog(ref)
+	if err != nil {
+		return "", err
+	}
+
+	// The log has all the values of the reference, starting with the value
+	// before the first change.
+	values := []string{}
+	for i, entry := range entries {
+		if i == 0 && entry.Old != nullHash {
+			values = append(values, entry.Old)
+		}
+		values = append(values, entry.New)
+	}
+	if len(values) == 0 {
+		hash, _, err := r.RefResolve(ref)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, hash)
+	}
+
+	bases, err := r.MergeBasesMany(commit, values)
+	if err != nil || len(bases) != 1 {
+		return "", err
+	}
+
+	// The fork point must be one of the values of the reference.
+	for _, value := range values {
+		if value == bases[0] {
+			return value, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,130 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMergeBases(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitMergeBases")
+	tree := func(data string) string {
+		return writeTestTree(t, repo, map[string]string{"a": data})
+	}
+
+	// Two branches, which have merged each other's first commit (criss-cross).
+	root := writeTestCommit(t, repo, tree("root\n"))
+	side1 := writeTestCommit(t, repo, tree("side1\n"), root)
+	main1 := writeTestCommit(t, repo, tree("main1\n"), root)
+	side2 := writeTestCommit(t, repo, tree("side2\n"), side1, main1)
+	main2 := writeTestCommit(t, repo, tree("main2\n"), main1, side1)
+
+	t.Run("Validate a single merge base", func(t *testing.T) {
+		bases, err := repo.MergeBases(side1, main1)
+		assertEqual(t, err, nil)
+		assertEqual(t, bases, []string{root})
+
+		bases, err = repo.MergeBases(side2, side1)
+		assertEqual(t, err, nil)
+		assertEqual(t, bases, []string{side1})
+	})
+
+	t.Run("Validate many merge bases", func(t *testing.T) {
+		bases, err := repo.MergeBases(side2, main2)
+		assertEqual(t, err, nil)
+		assertEqual(t, len(bases), 2)
+		assertEqual(t, (bases[0] == side1 && bases[1] == main1) ||
+			(bases[0] == main1 && bases[1] == side1), true)
+	})
+
+	t.Run("Validate the ancestors", func(t *testing.T) {
+		for _, test := range []struct {
+			ancestor, commit string
+			want             bool
+		}{
+			{root, main2, true},
+			{side1, main2, true},
+			{main2, main2, true},
+			{side2, main2, false},
+			{main2, root, false},
+		} {
+			isAncestor, err := repo.IsAncestor(test.ancestor, test.commit)
+			assertEqual(t, err, nil)
+			assertEqual(t, isAncestor, test.want)
+		}
+	})
+
+	t.Run("Validate the walk by the commit dates", func(t *testing.T) {
+		dated := func(seconds int, parents ...string) string {
+			data := "tree " + tree("dated\n") + "\n"
+			for _, parent := range parents {
+				data += "parent " + parent + "\n"
+			}
+			data += fmt.Sprintf("author A U Thor <a@b> %d +0000\n"+
+				"committer A U Thor <a@b> %d +0000\n\ntest\n", seconds, seconds)
+			hash, err := repo.ObjectWrite(NewObject("commit", []byte(data)), true)
+			assertEqual(t, err, nil)
+			return hash
+		}
+
+		// The history older than the merge base and the ancestor isn't read,
+		// so the missing parent of 'old' is never needed.
+		old := dated(1000, "1234567890123456789012345678901234567890")
+		base := dated(1500, old)
+		ours := dated(2000, base)
+		theirs := dated(1800, base)
+
+		bases, err := repo.MergeBases(ours, theirs)
+		assertEqual(t, err, nil)
+		assertEqual(t, bases, []string{base})
+
+		isAncestor, err := repo.IsAncestor(theirs, ours)
+		assertEqual(t, err, nil)
+		assertEqual(t, isAncestor, false)
+		isAncestor, err = repo.IsAncestor(base, ours)
+		assertEqual(t, err, nil)
+		assertEqual(t, isAncestor, true)
+	})
+
+	t.Run("Validate the merge bases of many commits", func(t *testing.T) {
+		third := writeTestCommit(t, repo, tree("third\n"), side1)
+
+		// A merge of main1 and third reaches side1 through third, so that
+		// both the parents of side2 are its merge bases.
+		bases, err := repo.MergeBasesMany(side2, []string{main1, third})
+		assertEqual(t, err, nil)
+		assertEqual(t, len(bases), 2)
+
+		bases, err = repo.OctopusMergeBases([]string{side2, main2, third})
+		assertEqual(t, err, nil)
+		assertEqual(t, bases, []string{side1})
+	})
+
+	t.Run("Validate the fork point from the reflog", func(t *testing.T) {
+		// The branch was at side1 before it was rewritten to main1, and a
+		// commit was made on top of side1.
+		topic := writeTestCommit(t, repo, tree("topic\n"), side1)
+		assertEqual(t, repo.UpdateRef("refs/heads/upstream", main1), nil)
+		logFile, err := repo.FilePath(true, "logs", "refs", "heads", "upstream")
+		assertEqual(t, err, nil)
+		reflog := nullHash + " " + side1 + " A U Thor <a@b> 1600000000 +0000\tbranch: Created\n" +
+			side1 + " " + main1 + " A U Thor <a@b> 1600000100 +0000\treset: moving\n"
+		assertEqual(t, ioutil.WriteFile(logFile, []byte(reflog), 0644), nil)
+
+		entries, err := repo.ReadReflog("refs/heads/upstream")
+		assertEqual(t, err, nil)
+		assertEqual(t, len(entries), 2)
+		assertEqual(t, entries[1].Message, "reset: moving")
+
+		forkPoint, err := repo.ForkPoint("refs/heads/upstream", topic)
+		assertEqual(t, err, nil)
+		assertEqual(t, forkPoint, side1)
+
+		// Without the log, the merge base is not a value of the branch.
+		assertEqual(t, os.Remove(logFile), nil)
+		forkPoint, err = repo.ForkPoint("refs/heads/upstream", topic)
+		assertEqual(t, err, nil)
+		assertEqual(t, forkPoint, "")
+	})
+}
@@ -0,0 +1,418 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+)
+
+// MergeFavor chooses how the conflicting lines of a three-way file merge are
+// resolved.
+type MergeFavor int
+
+// Resolutions of conflicts. With MergeFavorNone, the conflicts are left in the
+// result between conflict markers.
+const (
+	MergeFavorNone MergeFavor = iota
+	MergeFavorOurs
+	MergeFavorTheirs
+	MergeFavorUnion
+)
+
+// ConflictStyle chooses how the conflicts are shown in the result of a
+// three-way file merge.
+type ConflictStyle int
+
+// Conflict styles. ConflictDiff3 shows the lines of the base too, and
+// ConflictZdiff3 does so after moving the lines common to both the sides out
+// of the conflict.
+const (
+	ConflictMerge ConflictStyle = iota
+	ConflictDiff3
+	ConflictZdiff3
+)
+
+// DefaultMarkerSize is the length of the conflict markers, like "<<<<<<<".
+const DefaultMarkerSize = 7
+
+// MergeFileOptions controls a three-way file merge done by MergeFile.
+type MergeFileOptions struct {
+	// The labels shown after the conflict markers. They are optional.
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+	Favor       MergeFavor
+	Style       ConflictStyle
+	// MarkerSize is DefaultMarkerSize if it is zero.
+	MarkerSize int
+}
+
+// lineChange is a group of lines in 'a' replaced by a group of lines in 'b'.
+type lineChange struct {
+	aStart, aCount int
+	bStart, bCount int
+}
+
+// diffChanges finds the groups of changed lines between 'a' and 'b'.
+func diffChanges(a, b []string) []lineChange {
+	changes := []lineChange{}
+	edits := DiffLines(a, b)
+	for i := 0; i < len(edits); {
+		if edits[i].Op == LineEqual {
+			i++
+			continue
+		}
+
+		change := lineChange{aStart: edits[i].Old, bStart: edits[i].New}
+		for ; i < len(edits) && edits[i].Op != LineEqual; i++ {
+			if edits[i].Op == LineDelete {
+				change.aCount++
+			} else {
+				change.bCount++
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// mergeChunk is a region of a three-way merge, given by its lines in the base
+// (i0), ours (i1) and theirs (i2). The mode tells which side the result takes
+// the lines from: 1 for ours, 2 for theirs, 3 for both, 4 for either as they
+// are the same, and 0 for a conflict.
+type mergeChunk struct {
+	mode     int
+	i0, chg0 int
+	i1, chg1 int
+	i2, chg2 int
+}
+
+// fileMerger holds the state of a single three-way file merge.
+type fileMerger struct {
+	base, ours, theirs []string
+	opts               *MergeFileOptions
+	chunks             []*mergeChunk
+}
+
+// MergeFile does a three-way merge of the changes done to 'base' by 'ours' and
+// 'theirs', like "git merge-file". It returns the merged content along with
+// the number of conflicts in it. Overlapping changes which are not the same
+// are conflicts, which are resolved as per the favor of the options, or are
+// shown between conflict markers.
+func MergeFile(base, ours, theirs []byte, opts *MergeFileOptions) ([]byte, int) {
+	m := &fileMerger{
+		base:   SplitLines(base),
+		ours:   SplitLines(ours),
+		theirs: SplitLines(theirs),
+		opts:   opts,
+		chunks: []*mergeChunk{},
+	}
+
+	oursChanges := diffChanges(m.base, m.ours)
+	theirsChanges := diffChanges(m.base, m.theirs)
+	if len(oursChanges) == 0 {
+		return append([]byte{}, theirs...), 0
+	}
+	if len(theirsChanges) == 0 {
+		return append([]byte{}, ours...), 0
+	}
+
+	m.mergeChanges(oursChanges, theirsChanges)
+	if opts.Style == ConflictZdiff3 {
+		m.shrinkConflicts()
+	} else if opts.Style == ConflictMerge {
+		m.refineConflicts()
+		m.joinConflicts()
+	}
+	return m.output()
+}
+
+// sameLines tells if the given ranges of two lists of lines are the same.
+func sameLines(a []string, aStart int, b []string, bStart, count int) bool {
+	for i := 0; i < count; i++ {
+		if a[aStart+i] != b[bStart+i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendChunk adds a chunk to the merge. It is combined with the previous
+// chunk if they overlap, in which case it is a conflict unless both are from
+// the same side.
+func (m *fileMerger) appendChunk(c *mergeChunk) {
+	if len(m.chunks) > 0 {
+		last := m.chunks[len(m.chunks)-1]
+		if c.i1 <= last.i1+last.chg1 || c.i2 <= last.i2+last.chg2 {
+			if c.mode != last.mode {
+				last.mode = 0
+			}
+			last.chg0 = c.i0 + c.chg0 - last.i0
+			last.chg1 = c.i1 + c.chg1 - last.i1
+			last.chg2 = c.i2 + c.chg2 - last.i2
+			return
+		}
+	}
+	m.chunks = append(m.chunks, c)
+}
+
+// mergeChanges combines the changes of both the sides into chunks. The
+// changes which touch or overlap each other are conflicts, unless both the
+// sides made the very same change.
+func (m *fileMerger) mergeChanges(oursChanges, theirsChanges []lineChange) {
+	// A change of one side maps to the same lines of the other side, which
+	// are shifted by the earlier changes of that side. 'next' is the next
+	// change of the other side, if any.
+	oursChunk := func(c lineChange, next []lineChange) *mergeChunk {
+		shift := len(m.theirs) - len(m.base)
+		if len(next) > 0 {
+			shift = next[0].bStart - next[0].aStart
+		}
+		return &mergeChunk{mode: 1, i0: c.aStart, chg0: c.aCount,
+			i1: c.bStart, chg1: c.bCount, i2: c.aStart + shift, chg2: c.aCount}
+	}
+	theirsChunk := func(c lineChange, next []lineChange) *mergeChunk {
+		shift := len(m.ours) - len(m.base)
+		if len(next) > 0 {
+			shift = next[0].bStart - next[0].aStart
+		}
+		return &mergeChunk{mode: 2, i0: c.aStart, chg0: c.aCount,
+			i1: c.aStart + shift, chg1: c.aCount, i2: c.bStart, chg2: c.bCount}
+	}
+
+	x1, x2 := oursChanges, theirsChanges
+	for len(x1) > 0 && len(x2) > 0 {
+		c1, c2 := x1[0], x2[0]
+		if c1.aStart+c1.aCount < c2.aStart {
+			m.appendChunk(oursChunk(c1, x2))
+			x1 = x1[1:]
+			continue
+		}
+		if c2.aStart+c2.aCount < c1.aStart {
+			m.appendChunk(theirsChunk(c2, x1))
+			x2 = x2[1:]
+			continue
+		}
+
+		if c1.aStart != c2.aStart || c1.aCount != c2.aCount || c1.bCount != c2.bCount ||
+			!sameLines(m.ours, c1.bStart, m.theirs, c2.bStart, c1.bCount) {
+			// Extend both the sides to cover the union of the base lines.
+			i0, i1, i2 := c1.aStart, c1.bStart, c2.bStart
+			off := c1.aStart - c2.aStart
+			if off > 0 {
+				i0 -= off
+				i1 -= off
+			} else {
+				i2 += off
+			}
+			chg0 := c1.aStart + c1.aCount - i0
+			chg1 := c1.bStart + c1.bCount - i1
+			chg2 := c2.bStart + c2.bCount - i2
+			ffo := off + c1.aCount - c2.aCount
+			if ffo < 0 {
+				chg0 -= ffo
+				chg1 -= ffo
+			} else {
+				chg2 += ffo
+			}
+			m.appendChunk(&mergeChunk{mode: 0, i0: i0, chg0: chg0,
+				i1: i1, chg1: chg1, i2: i2, chg2: chg2})
+		}
+
+		end1, end2 := c1.aStart+c1.aCount, c2.aStart+c2.aCount
+		if end1 >= end2 {
+			x2 = x2[1:]
+		}
+		if end2 >= end1 {
+			x1 = x1[1:]
+		}
+	}
+
+	for _, c := range x1 {
+		m.appendChunk(oursChunk(c, nil))
+	}
+	for _, c := range x2 {
+		m.appendChunk(theirsChunk(c, nil))
+	}
+}
+
+// refineConflicts splits each conflict into the parts where both the sides
+// differ from each other. The lines which both the sides added in the same
+// way are not conflicts.
+func (m *fileMerger) refineConflicts() {
+	chunks := []*mergeChunk{}
+	for _, c := range m.chunks {
+		if c.mode != 0 || c.chg1 == 0 || c.chg2 == 0 {
+			chunks = append(chunks, c)
+			continue
+		}
+
+		changes := diffChanges(m.ours[c.i1:c.i1+c.chg1], m.theirs[c.i2:c.i2+c.chg2])
+		if len(changes) == 0 {
+			c.mode = 4
+			chunks = append(chunks, c)
+			continue
+		}
+		for _, change := range changes {
+			chunks = append(chunks, &mergeChunk{mode: 0,
+				i1: c.i1 + change.aStart, chg1: change.aCount,
+				i2: c.i2 + change.bStart, chg2: change.bCount})
+		}
+	}
+	m.chunks = chunks
+}
+
+// hasAlnum tells if any of the lines has a letter or a digit.
+func hasAlnum(lines []string) bool {
+	for _, line := range lines {
+		for i := 0; i < len(line); i++ {
+			ch := line[i]
+			if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinConflicts combines the conflicts separated by at most three lines, or
+// by lines without any letters or digits, into a single conflict.
+func (m *fileMerger) joinConflicts() {
+	chunks := []*mergeChunk{}
+	for _, c := range m.chunks {
+		if len(chunks) > 0 {
+			last := chunks[len(chunks)-1]
+			begin, end := last.i1+last.chg1, c.i1
+			if last.mode == 0 && c.mode == 0 &&
+				(end-begin <= 3 || !hasAlnum(m.ours[begin:end])) {
+				last.chg1 = c.i1 + c.chg1 - last.i1
+				last.chg2 = c.i2 + c.chg2 - last.i2
+				continue
+			}
+		}
+		chunks = append(chunks, c)
+	}
+	m.chunks = chunks
+}
+
+// shrinkConflicts moves the lines common to both the sides at the start and
+// the end of each conflict out of it.
+func (m *fileMerger) shrinkConflicts() {
+	for _, c := range m.chunks {
+		if c.mode != 0 {
+			continue
+		}
+		for c.chg1 > 0 && c.chg2 > 0 && m.ours[c.i1] == m.theirs[c.i2] {
+			c.i1, c.i2 = c.i1+1, c.i2+1
+			c.chg1, c.chg2 = c.chg1-1, c.chg2-1
+		}
+		for c.chg1 > 0 && c.chg2 > 0 &&
+			m.ours[c.i1+c.chg1-1] == m.theirs[c.i2+c.chg2-1] {
+			c.chg1, c.chg2 = c.chg1-1, c.chg2-1
+		}
+	}
+}
+
+// isCRLF tells if a line ends with CRLF. A missing line, or a last line
+// without any newline gives -1 to tell that it is not known.
+func isCRLF(lines []string, i int) int {
+	if i >= len(lines) {
+		return -1
+	}
+	line := lines[i]
+	if !strings.HasSuffix(line, "\n") {
+		if i == 0 {
+			return -1
+		}
+		line = lines[i-1]
+	}
+	if strings.HasSuffix(line, "\r\n") {
+		return 1
+	}
+	return 0
+}
+
+// needsCR tells if the conflict markers of a chunk need to end with CRLF, as
+// the lines around them do.
+func (m *fileMerger) needsCR(c *mergeChunk) bool {
+	prev := func(i int) int {
+		if i > 0 {
+			return i - 1
+		}
+		return 0
+	}
+	return isCRLF(m.ours, prev(c.i1)) == 1 && isCRLF(m.theirs, prev(c.i2)) == 1 &&
+		isCRLF(m.base, 0) == 1
+}
+
+// output builds the result of the merge, and counts the conflicts in it.
+func (m *fileMerger) output() ([]byte, int) {
+	var b bytes.Buffer
+	size := m.opts.MarkerSize
+	if size <= 0 {
+		size = DefaultMarkerSize
+	}
+
+	// copyLines copies a range of lines, and optionally ends it with a
+	// newline if it is missing.
+	copyLines := func(lines []string, start, count int, addNewline bool, eol string) {
+		for _, line := range lines[start : start+count] {
+			b.WriteString(line)
+		}
+		if addNewline && count > 0 && !strings.HasSuffix(lines[start+count-1], "\n") {
+			b.WriteString(eol)
+		}
+	}
+	marker := func(ch byte, label, eol string) {
+		b.WriteString(strings.Repeat(string(ch), size))
+		if label != "" {
+			b.WriteString(" " + label)
+		}
+		b.WriteString(eol)
+	}
+
+	conflicts := 0
+	next := 0
+	for _, c := range m.chunks {
+		mode := c.mode
+		if mode == 0 {
+			mode = int(m.opts.Favor)
+		}
+
+		switch {
+		case mode == 0:
+			conflicts++
+			eol := "\n"
+			if m.needsCR(c) {
+				eol = "\r\n"
+			}
+			copyLines(m.ours, next, c.i1-next, false, eol)
+			marker('<', m.opts.OursLabel, eol)
+			copyLines(m.ours, c.i1, c.chg1, true, eol)
+			if m.opts.Style != ConflictMerge {
+				marker('|', m.opts.BaseLabel, eol)
+				copyLines(m.base, c.i0, c.chg0, true, eol)
+			}
+			marker('=', "", eol)
+			copyLines(m.theirs, c.i2, c.chg2, true, eol)
+			marker('>', m.opts.TheirsLabel, eol)
+		case mode&3 != 0:
+			copyLines(m.ours, next, c.i1-next, false, "\n")
+			if mode&1 != 0 {
+				eol := "\n"
+				if m.needsCR(c) {
+					eol = "\r\n"
+				}
+				copyLines(m.ours, c.i1, c.chg1, mode&2 != 0, eol)
+			}
+			if mode&2 != 0 {
+				copyLines(m.theirs, c.i2, c.chg2, false, "\n")
+			}
+		default:
+			continue
+		}
+		next = c.i1 + c.chg1
+	}
+	copyLines(m.ours, next, len(m.ours)-next, false, "\n")
+
+	return b.Bytes(), conflicts
+}
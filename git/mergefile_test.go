@@ -0,0 +1,61 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestMergeFile(t *testing.T) {
+	base := []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+	ours := []byte("1\n2\nthree\n4\n5\n6\n7\n8\nnine\n")
+	theirs := []byte("1\n2\nTHREE\n4\n5\n6\n7\n8\n9\n10\n")
+	opts := func(favor MergeFavor, style ConflictStyle) *MergeFileOptions {
+		return &MergeFileOptions{OursLabel: "ours", BaseLabel: "base",
+			TheirsLabel: "theirs", Favor: favor, Style: style}
+	}
+
+	t.Run("Validate a clean merge", func(t *testing.T) {
+		result, conflicts := MergeFile(base, []byte("one\n2\n3\n4\n5\n6\n7\n8\n9\n"),
+			theirs, opts(MergeFavorNone, ConflictMerge))
+		assertEqual(t, conflicts, 0)
+		assertEqual(t, string(result), "one\n2\nTHREE\n4\n5\n6\n7\n8\n9\n10\n")
+	})
+
+	t.Run("Validate conflict markers", func(t *testing.T) {
+		result, conflicts := MergeFile(base, ours, theirs, opts(MergeFavorNone, ConflictMerge))
+		assertEqual(t, conflicts, 2)
+		assertEqual(t, string(result), "1\n2\n"+
+			"<<<<<<< ours\nthree\n=======\nTHREE\n>>>>>>> theirs\n"+
+			"4\n5\n6\n7\n8\n"+
+			"<<<<<<< ours\nnine\n=======\n9\n10\n>>>>>>> theirs\n")
+	})
+
+	t.Run("Validate diff3 style with a marker size", func(t *testing.T) {
+		o := opts(MergeFavorNone, ConflictDiff3)
+		o.MarkerSize = 3
+		result, conflicts := MergeFile(base, ours, []byte("1\n2\nTHREE\n4\n5\n6\n7\n8\n9\n"), o)
+		assertEqual(t, conflicts, 1)
+		assertEqual(t, string(result), "1\n2\n"+
+			"<<< ours\nthree\n||| base\n3\n===\nTHREE\n>>> theirs\n"+
+			"4\n5\n6\n7\n8\nnine\n")
+	})
+
+	t.Run("Validate zdiff3 style", func(t *testing.T) {
+		result, conflicts := MergeFile([]byte("a\n"), []byte("x\nb\ny\n"),
+			[]byte("x\nc\ny\n"), opts(MergeFavorNone, ConflictZdiff3))
+		assertEqual(t, conflicts, 1)
+		assertEqual(t, string(result), "x\n"+
+			"<<<<<<< ours\nb\n||||||| base\na\n=======\nc\n>>>>>>> theirs\ny\n")
+	})
+
+	t.Run("Validate favored merges", func(t *testing.T) {
+		result, conflicts := MergeFile(base, ours, theirs, opts(MergeFavorOurs, ConflictMerge))
+		assertEqual(t, conflicts, 0)
+		assertEqual(t, string(result), "1\n2\nthree\n4\n5\n6\n7\n8\nnine\n")
+
+		result, _ = MergeFile(base, ours, theirs, opts(MergeFavorTheirs, ConflictMerge))
+		assertEqual(t, string(result), "1\n2\nTHREE\n4\n5\n6\n7\n8\n9\n10\n")
+
+		result, _ = MergeFile(base, ours, theirs, opts(MergeFavorUnion, ConflictMerge))
+		assertEqual(t, string(result), "1\n2\nthree\nTHREE\n4\n5\n6\n7\n8\nnine\n9\n10\n")
+	})
+}
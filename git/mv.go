@@ -0,0 +1,189 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MoveOptions lists the choices for moving the files of the index.
+type MoveOptions struct {
+	// Force overwrites an existing file at the destination.
+	Force bool
+	// SkipErrors leaves the sources which can't be moved alone, instead of
+	// failing.
+	SkipErrors bool
+	// DryRun finds the paths to move without moving them.
+	DryRun bool
+}
+
+// Move is a path of the work-tree moved to another, along with its index
+// entries. A directory is moved with all the files inside it.
+type Move struct {
+	Source      string
+	Destination string
+	// files are the moves of the index entries.
+	files []Move
+}
+
+// MovePaths moves files or directories of the index, along with their files
+// in the work-tree, to a destination. The sources are moved inside the
+// destination if it is a directory, which it must be if there are several
+// sources. Nothing is moved if any source can't be moved, unless it is asked
+// to skip them. It returns the moves of the given paths, followed by the
+// moves of the files inside the directories.
+func (r *Repo) MovePaths(sources []string, dest string, opts *MoveOptions) ([]Move, error) {
+	index, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	tracked := index.Dirs()
+
+	// Example: "gogit mv a b c/" moves "a" to "c/a" and "b" to "c/b".
+	destDir := false
+	if info, err := r.worktreeStat(dest); err == nil && info.IsDir() {
+		destDir = true
+	} else if len(sources) > 1 {
+		return nil, fmt.Errorf("fatal: destination '%s' is not a directory", dest)
+	} else if strings.HasSuffix(dest, "/") {
+		return nil, fmt.Errorf("fatal: destination directory does not exist, "+
+			"source=%s, destination=%s", sources[0], dest)
+	}
+	dest = strings.TrimSuffix(dest, "/")
+
+	moves := []Move{}
+	targets := map[string]string{}
+	for _, src := range sources {
+		src = strings.TrimSuffix(src, "/")
+		dst := dest
+		if destDir {
+			dst = path.Join(dest, path.Base(src))
+		}
+		files, err := r.checkMove(index, tracked, src, dst, opts.Force)
+		for _, file := range files {
+			if other, ok := targets[file.Destination]; ok && err == nil {
+				err = fmt.Errorf("fatal: multiple sources for the same target, "+
+					"source=%s, destination=%s", other, file.Destination)
+			}
+		}
+		if err != nil {
+			if opts.SkipErrors {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, file := range files {
+			targets[file.Destination] = file.Source
+		}
+		moves = append(moves, Move{Source: src, Destination: dst, files: files})
+	}
+
+	// Everything is validated before moving anything.
+	if !opts.DryRun {
+		for _, move := range moves {
+			if err := r.applyMove(index, move); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.WriteIndex(index); err != nil {
+			return nil, err
+		}
+	}
+
+	all := append([]Move{}, moves...)
+	for _, move := range moves {
+		if len(move.files) != 1 || move.files[0].Source != move.Source {
+			all = append(all, move.files...)
+		}
+	}
+	return all, nil
+}
+
+// worktreeStat returns the information of a path in the work-tree, without
+// following a symlink.
+func (r *Repo) worktreeStat(filePath string) (os.FileInfo, error) {
+	return os.Lstat(filepath.Join(r.WorkTree, filepath.FromSlash(filePath)))
+}
+
+// checkMove validates moving a source to a destination, and returns the
+// moves of its index entries.
+func (r *Repo) checkMove(index *Index, tracked map[string]bool, src, dst string,
+	force bool) ([]Move, error) {
+	fail := func(reason string) ([]Move, error) {
+		return nil, fmt.Errorf("fatal: %s, source=%s, destination=%s", reason, src, dst)
+	}
+
+	info, err := r.worktreeStat(src)
+	if err != nil {
+		return fail("bad source")
+	}
+
+	if strings.HasPrefix(dst+"/", src+"/") {
+		return fail("can not move directory into itself")
+	}
+
+	// A directory is moved with its files, unless it is a submodule.
+	files := []Move{}
+	if info.IsDir() && index.Entry(src, 0) == nil {
+		if !tracked[src] {
+			return fail("source directory is empty")
+		}
+		for _, entry := range index.Entries {
+			if !strings.HasPrefix(entry.Path, src+"/") {
+				continue
+			}
+			if entry.Stage() != 0 {
+				return fail("conflicted")
+			}
+			files = append(files, Move{
+				Source:      entry.Path,
+				Destination: dst + strings.TrimPrefix(entry.Path, src),
+			})
+		}
+	} else {
+		if index.Entry(src, 0) == nil {
+			for stage := 1; stage <= 3; stage++ {
+				if index.Entry(src, stage) != nil {
+					return fail("conflicted")
+				}
+			}
+			return fail("not under version control")
+		}
+		files = append(files, Move{Source: src, Destination: dst})
+	}
+
+	// Only a file can be overwritten, and only if forced.
+	if dstInfo, err := r.worktreeStat(dst); err == nil {
+		if !force || dstInfo.IsDir() || info.IsDir() {
+			return fail("destination exists")
+		}
+	} else if dir := path.Dir(dst); dir != "." {
+		if dirInfo, err := r.worktreeStat(dir); err != nil || !dirInfo.IsDir() {
+			return fail("destination directory does not exist")
+		}
+	}
+	return files, nil
+}
+
+// applyMove moves a path in the work-tree, and renames the index entries of
+// its files. The stat data of the entries is kept, as the files are only
+// renamed.
+func (r *Repo) applyMove(index *Index, move Move) error {
+	srcPath := filepath.Join(r.WorkTree, filepath.FromSlash(move.Source))
+	dstPath := filepath.Join(r.WorkTree, filepath.FromSlash(move.Destination))
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return err
+	}
+
+	for _, file := range move.files {
+		entry := index.Entry(file.Source, 0)
+		index.Remove(file.Source)
+		moved := *entry
+		moved.Path = file.Destination
+		index.Add(&moved)
+	}
+	return nil
+}
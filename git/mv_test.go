@@ -0,0 +1,61 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMovePaths(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitMv")
+
+	commitTestFiles(t, repo, map[string]string{
+		"a": "a\n", "b": "b\n", "d/e": "e\n", "d/f/g": "g\n",
+	})
+	writeTestFile(t, repo, "u", "u\n")
+	assertEqual(t, os.Mkdir(filepath.Join(repo.WorkTree, "x"), 0755), nil)
+
+	t.Run("Validate refusing the bad moves", func(t *testing.T) {
+		tests := []struct {
+			sources []string
+			dest    string
+			err     string
+		}{
+			{[]string{"a"}, "b", "fatal: destination exists, source=a, destination=b"},
+			{[]string{"u"}, "z", "fatal: not under version control, source=u, destination=z"},
+			{[]string{"zz"}, "z", "fatal: bad source, source=zz, destination=z"},
+			{[]string{"d"}, "d/f", "fatal: can not move directory into itself, " +
+				"source=d, destination=d/f/d"},
+			{[]string{"a", "b"}, "y", "fatal: destination 'y' is not a directory"},
+			{[]string{"a"}, "y/", "fatal: destination directory does not exist, " +
+				"source=a, destination=y/"},
+			{[]string{"x"}, "y", "fatal: source directory is empty, source=x, destination=y"},
+		}
+		for _, test := range tests {
+			_, err := repo.MovePaths(test.sources, test.dest, &MoveOptions{})
+			assertEqual(t, err.Error(), test.err)
+		}
+		assertEqual(t, testIndexPaths(t, repo), []string{"a", "b", "d/e", "d/f/g"})
+	})
+
+	t.Run("Validate moving the paths", func(t *testing.T) {
+		moves, err := repo.MovePaths([]string{"u", "a", "d"}, "x",
+			&MoveOptions{SkipErrors: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, len(moves), 4)
+		assertEqual(t, moves[1].Source, "d")
+		assertEqual(t, moves[1].Destination, "x/d")
+		assertEqual(t, moves[3].Destination, "x/d/f/g")
+		assertEqual(t, testIndexPaths(t, repo), []string{"b", "x/a", "x/d/e", "x/d/f/g"})
+		_, err = os.Stat(filepath.Join(repo.WorkTree, "x", "d", "f", "g"))
+		assertEqual(t, err, nil)
+
+		_, err = repo.MovePaths([]string{"b"}, "x/a", &MoveOptions{Force: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, testIndexPaths(t, repo), []string{"x/a", "x/d/e", "x/d/f/g"})
+		data, err := ioutil.ReadFile(filepath.Join(repo.WorkTree, "x", "a"))
+		assertEqual(t, err, nil)
+		assertEqual(t, string(data), "b\n")
+	})
+}
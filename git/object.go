@@ -1,5 +1,11 @@
 package git
 
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
 // ObjIntf is a common interface shared by all type of git objects.
 type ObjIntf interface {
 	Print() string
@@ -21,3 +27,52 @@ func NewObject(objType string, data []byte) *Object {
 		ObjData: data,
 	}
 }
+
+// ObjectReader streams the data of a git object, without its header, as given
+// by Repo.ObjectOpen. Type and Size come from the header of the object.
+type ObjectReader struct {
+	Type   string
+	Size   int64
+	hash   string
+	left   int64
+	rdr    *bufio.Reader
+	closer io.Closer
+}
+
+// Read reads the data of the object, making sure that it matches the size in
+// its header.
+func (obj *ObjectReader) Read(p []byte) (int, error) {
+	if obj.left == 0 {
+		// Nothing is expected after the data.
+		if _, err := obj.rdr.ReadByte(); err != io.EOF {
+			return 0, obj.badLength(err)
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > obj.left {
+		p = p[:obj.left]
+	}
+	n, err := obj.rdr.Read(p)
+	obj.left -= int64(n)
+	if err == io.EOF {
+		if obj.left > 0 {
+			return n, obj.badLength(err)
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// badLength reports a size mismatch, unless there is another error to report.
+func (obj *ObjectReader) badLength(err error) error {
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return fmt.Errorf("Malformed object %s: bad length", obj.hash)
+}
+
+// Close closes the underlying object store reader.
+func (obj *ObjectReader) Close() error {
+	return obj.closer.Close()
+}
@@ -0,0 +1,175 @@
+package git
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestObjectStream(t *testing.T) {
+	diskRepo := newTestRepo(t, "testGoGitStream")
+	// "want" is the output of 'echo "Hello World" | git hash-object --stdin'
+	want := "557db03de997c86a4a028e1ebd3a1ceb225be238"
+
+	for name, repo := range map[string]*Repo{"disk": diskRepo, "memory": NewMemoryRepo()} {
+		t.Run("Validate streaming the objects in "+name, func(t *testing.T) {
+			data := "Hello World\n"
+			hash, err := repo.ObjectWriteFrom("blob", int64(len(data)), strings.NewReader(data), false)
+			assertEqual(t, err, nil)
+			assertEqual(t, hash, want)
+			_, err = repo.ObjectOpen(hash)
+			assertEqual(t, os.IsNotExist(err), true)
+
+			hash, err = repo.ObjectWriteFrom("blob", int64(len(data)), strings.NewReader(data), true)
+			assertEqual(t, err, nil)
+			assertEqual(t, hash, want)
+			obj, err := repo.ObjectParse(hash)
+			assertEqual(t, err, nil)
+			assertEqual(t, string(obj.ObjData), data)
+
+			rdr, err := repo.ObjectOpen(hash)
+			assertEqual(t, err, nil)
+			assertEqual(t, rdr.Type, "blob")
+			assertEqual(t, rdr.Size, int64(len(data)))
+			got, err := ioutil.ReadAll(rdr)
+			assertEqual(t, err, nil)
+			assertEqual(t, string(got), data)
+			assertEqual(t, rdr.Close(), nil)
+
+			// A reader shorter than the size doesn't write anything.
+			_, err = repo.ObjectWriteFrom("blob", 100, strings.NewReader(data), true)
+			assertEqual(t, err.Error(), "error: short read of blob data: 12 bytes out of 100")
+			hashes, err := repo.objectStore().FindObjects(want[:2])
+			assertEqual(t, err, nil)
+			assertEqual(t, hashes, []string{want})
+		})
+	}
+
+	t.Run("Validate the streaming of a large blob", func(t *testing.T) {
+		data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+		file := filepath.Join(diskRepo.WorkTree, "large")
+		assertEqual(t, ioutil.WriteFile(file, data, 0644), nil)
+
+		hash, err := WriteBlobFromFile(diskRepo, file, true)
+		assertEqual(t, err, nil)
+		wantHash, err := diskRepo.ObjectWrite(NewObject("blob", data), false)
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, wantHash)
+
+		rdr, err := diskRepo.ObjectOpen(hash)
+		assertEqual(t, err, nil)
+		defer rdr.Close()
+		got, err := ioutil.ReadAll(rdr)
+		assertEqual(t, err, nil)
+		assertEqual(t, bytes.Equal(got, data), true)
+
+		// Only the objects are left in the "objects" directory.
+		files, err := ioutil.ReadDir(filepath.Join(diskRepo.GitDir, "objects"))
+		assertEqual(t, err, nil)
+		for _, file := range files {
+			assertEqual(t, file.IsDir(), true)
+		}
+	})
+
+	t.Run("Validate a bad length", func(t *testing.T) {
+		repo := NewMemoryRepo()
+		assertEqual(t, repo.objectStore().WriteObject(nullHash, []byte("blob 3\x00abcd")), nil)
+		rdr, err := repo.ObjectOpen(nullHash)
+		assertEqual(t, err, nil)
+		_, err = ioutil.ReadAll(rdr)
+		assertEqual(t, err.Error(), "Malformed object "+nullHash+": bad length")
+
+		badHash := strings.Repeat("1", 40)
+		assertEqual(t, repo.objectStore().WriteObject(badHash, []byte("blob 5\x00abcd")), nil)
+		rdr, err = repo.ObjectOpen(badHash)
+		assertEqual(t, err, nil)
+		_, err = ioutil.ReadAll(rdr)
+		assertEqual(t, err.Error(), "Malformed object "+badHash+": bad length")
+	})
+}
+
+func TestLooseObjectWrite(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitLoose")
+	obj := NewObject("blob", []byte("Hello World\n"))
+	hash, err := repo.ObjectWrite(obj, true)
+	assertEqual(t, err, nil)
+	dataFile := filepath.Join(repo.GitDir, "objects", hash[0:2], hash[2:])
+
+	t.Run("Validate a read-only object without temporary files", func(t *testing.T) {
+		info, err := os.Stat(dataFile)
+		assertEqual(t, err, nil)
+		assertEqual(t, info.Mode().Perm(), os.FileMode(0444))
+
+		files, err := ioutil.ReadDir(filepath.Dir(dataFile))
+		assertEqual(t, err, nil)
+		assertEqual(t, len(files), 1)
+	})
+
+	t.Run("Validate skipping an existing object", func(t *testing.T) {
+		assertEqual(t, os.Chmod(dataFile, 0644), nil)
+		assertEqual(t, ioutil.WriteFile(dataFile, []byte("kept"), 0644), nil)
+
+		got, err := repo.ObjectWrite(obj, true)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, hash)
+		got, err = repo.ObjectWriteFrom("blob", int64(len(obj.ObjData)),
+			bytes.NewReader(obj.ObjData), true)
+		assertEqual(t, err, nil)
+		assertEqual(t, got, hash)
+
+		data, err := ioutil.ReadFile(dataFile)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(data), "kept")
+		files, err := ioutil.ReadDir(filepath.Join(repo.GitDir, "objects"))
+		assertEqual(t, err, nil)
+		for _, file := range files {
+			assertEqual(t, file.IsDir(), true)
+		}
+	})
+
+	t.Run("Validate the fsync configuration", func(t *testing.T) {
+		for value, want := range map[string]bool{
+			"":                                 false,
+			"[core]\n\tfsync = none":           false,
+			"[core]\n\tfsync = reference":      false,
+			"[core]\n\tfsync = index,objects":  true,
+			"[core]\n\tfsync = loose-object":   true,
+			"[core]\n\tfsync = all":            true,
+			"[core]\n\tfsyncObjectFiles = yes": true,
+
+			// The components are taken in order.
+			"[core]\n\tfsync = all,-loose-object":        false,
+			"[core]\n\tfsync = all,-pack":                true,
+			"[core]\n\tfsync = -objects,loose-object":    true,
+			"[core]\n\tfsync = loose-object,-committed":  false,
+			"[core]\n\tfsync = all,none":                 false,
+			"[core]\n\tfsync = all,none,objects":         true,
+			"[core]\n\tfsync = none,index,-loose-object": false,
+		} {
+			config := NewConfig()
+			assertEqual(t, config.Parse([]byte(value)), nil)
+			assertEqual(t, fsyncLooseObjects(config), want)
+		}
+
+		// The objects are still written when flushed. The setting is read
+		// once, by the first write of a repo.
+		f, err := os.OpenFile(filepath.Join(repo.GitDir, "config"), os.O_APPEND|os.O_WRONLY, 0)
+		assertEqual(t, err, nil)
+		_, err = f.WriteString("[core]\n\tfsync = committed\n")
+		assertEqual(t, err, nil)
+		assertEqual(t, f.Close(), nil)
+		assertEqual(t, repo.loose.fsync, false)
+
+		synced, err := GetRepo(repo.WorkTree)
+		assertEqual(t, err, nil)
+		hash, err := synced.ObjectWrite(NewObject("blob", []byte("synced\n")), true)
+		assertEqual(t, err, nil)
+		assertEqual(t, synced.loose.fsync, true)
+		obj, err := synced.ObjectParse(hash)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(obj.ObjData), "synced\n")
+	})
+}
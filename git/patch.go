@@ -0,0 +1,135 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change in
+// a patch.
+const DefaultContext int = 3
+
+// FileData reads the content of one side of a change. Files are read from the
+// object store, or from the work-tree if their blob is not written yet. A
+// missing side has no content.
+func (r *Repo) FileData(file FileEntry) ([]byte, error) {
+	if file.Hash == "" {
+		return nil, nil
+	}
+	if modeType(file.Mode) == "160" {
+		// The commit of a submodule is not present in this repository.
+		return []byte("Subproject commit " + file.Hash + "\n"), nil
+	}
+
+	obj, err := r.ObjectParse(file.Hash)
+	if err == nil {
+		return obj.ObjData, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	data, _, wtErr := r.ReadWorktreeFile(file.Path)
+	if wtErr != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// shortHash abbreviates an object hash for display. A missing object is
+// shown with 0s.
+func shortHash(hash string) string {
+	if hash == "" {
+		hash = nullHash
+	}
+	return hash[:7]
+}
+
+// Patch returns a change in the "git diff" patch format, with the given
+// number of context lines around each change.
+func (r *Repo) Patch(entry DiffEntry, context int) (string, error) {
+	// A change of the file type is shown as a deletion and an addition.
+	if entry.Status == DiffType {
+		deleted, err := r.Patch(DiffEntry{Status: DiffDeleted, Old: entry.Old}, context)
+		if err != nil {
+			return "", err
+		}
+		added, err := r.Patch(DiffEntry{Status: DiffAdded, New: entry.New}, context)
+		if err != nil {
+			return "", err
+		}
+		return deleted + added, nil
+	}
+
+	var b strings.Builder
+	oldPath, newPath := entry.Old.Path, entry.New.Path
+	if oldPath == "" {
+		oldPath = newPath
+	}
+	if newPath == "" {
+		newPath = oldPath
+	}
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldPath, newPath)
+
+	switch entry.Status {
+	case DiffAdded:
+		fmt.Fprintf(&b, "new file mode %s\n", padMode(entry.New.Mode))
+	case DiffDeleted:
+		fmt.Fprintf(&b, "deleted file mode %s\n", padMode(entry.Old.Mode))
+	case DiffRenamed, DiffCopied:
+		action := "rename"
+		if entry.Status == DiffCopied {
+			action = "copy"
+		}
+		fmt.Fprintf(&b, "similarity index %d%%\n", entry.Score)
+		fmt.Fprintf(&b, "%s from %s\n%s to %s\n", action, oldPath, action, newPath)
+	}
+
+	modeChanged := entry.Old.Mode != "" && entry.New.Mode != "" &&
+		entry.Old.Mode != entry.New.Mode
+	if modeChanged {
+		fmt.Fprintf(&b, "old mode %s\nnew mode %s\n", padMode(entry.Old.Mode),
+			padMode(entry.New.Mode))
+	}
+
+	if entry.Old.Hash == entry.New.Hash {
+		// Only the mode or the name has changed.
+		return b.String(), nil
+	}
+
+	fmt.Fprintf(&b, "index %s..%s", shortHash(entry.Old.Hash), shortHash(entry.New.Hash))
+	if entry.Status != DiffAdded && entry.Status != DiffDeleted && !modeChanged {
+		fmt.Fprintf(&b, " %s", padMode(entry.New.Mode))
+	}
+	fmt.Fprintln(&b)
+
+	oldData, err := r.FileData(entry.Old)
+	if err != nil {
+		return "", err
+	}
+	newData, err := r.FileData(entry.New)
+	if err != nil {
+		return "", err
+	}
+
+	oldName, newName := "a/"+oldPath, "b/"+newPath
+	if entry.Status == DiffAdded {
+		oldName = "/dev/null"
+	}
+	if entry.Status == DiffDeleted {
+		newName = "/dev/null"
+	}
+
+	if IsBinary(oldData) || IsBinary(newData) {
+		fmt.Fprintf(&b, "Binary files %s and %s differ\n", oldName, newName)
+		return b.String(), nil
+	}
+
+	hunks := UnifiedDiff(SplitLines(oldData), SplitLines(newData), context)
+	if hunks != "" {
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n%s", oldName, newName, hunks)
+	}
+
+	return b.String(), nil
+}
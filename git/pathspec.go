@@ -0,0 +1,88 @@
+package git
+
+import (
+	"strings"
+)
+
+// Pathspec selects the paths given on the command line of a command, such as
+// "git reset -- <paths>". Each pattern is a path relative to the top of the
+// work-tree, and selects the path itself or everything inside it if it is a
+// directory. A pattern with glob characters is matched like a shell glob,
+// except that '*' matches a '/' as well.
+type Pathspec struct {
+	patterns []string
+	// matched tells which patterns matched at least one path.
+	matched []bool
+}
+
+// NewPathspec creates a pathspec from patterns relative to the top of the
+// work-tree. An empty pattern (the top itself) selects every path.
+func NewPathspec(patterns []string) *Pathspec {
+	ps := &Pathspec{
+		patterns: make([]string, len(patterns)),
+		matched:  make([]bool, len(patterns)),
+	}
+	for i, pattern := range patterns {
+		ps.patterns[i] = strings.TrimSuffix(pattern, "/")
+	}
+	return ps
+}
+
+// hasGlob tells if a pattern has any glob characters.
+func hasGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
+}
+
+// matchPattern tells if a path is selected by a single pattern.
+func matchPattern(pattern, filePath string) bool {
+	if pattern == "" || pattern == filePath || strings.HasPrefix(filePath, pattern+"/") {
+		return true
+	}
+	return hasGlob(pattern) && Wildmatch(pattern, filePath, false)
+}
+
+// Match tells if a path is selected by any of the patterns. A nil pathspec
+// selects every path.
+func (ps *Pathspec) Match(filePath string) bool {
+	if ps == nil {
+		return true
+	}
+
+	found := false
+	for i, pattern := range ps.patterns {
+		if matchPattern(pattern, filePath) {
+			ps.matched[i] = true
+			found = true
+		}
+	}
+	return found
+}
+
+// Unmatched returns the positions of the patterns which didn't match any of
+// the paths given to Match so far.
+func (ps *Pathspec) Unmatched() []int {
+	unmatched := []int{}
+	if ps == nil {
+		return unmatched
+	}
+	for i := range ps.patterns {
+		if !ps.matched[i] {
+			unmatched = append(unmatched, i)
+		}
+	}
+	return unmatched
+}
+
+// MatchInside tells if any of the patterns can select a path inside a
+// directory. The patterns with glob characters are assumed to.
+func (ps *Pathspec) MatchInside(dir string) bool {
+	if ps == nil {
+		return true
+	}
+	for _, pattern := range ps.patterns {
+		if matchPattern(pattern, dir) || strings.HasPrefix(pattern, dir+"/") || hasGlob(pattern) {
+			return true
+		}
+	}
+	return false
+}
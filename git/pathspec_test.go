@@ -0,0 +1,25 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestPathspec(t *testing.T) {
+	ps := NewPathspec([]string{"d/", "*.go", "nope"})
+
+	assertEqual(t, ps.Match("d"), true)
+	assertEqual(t, ps.Match("d/x"), true)
+	assertEqual(t, ps.Match("dx"), false)
+	assertEqual(t, ps.Match("a/b/main.go"), true)
+	assertEqual(t, ps.Unmatched(), []int{2})
+
+	inside := NewPathspec([]string{"a/b/c"})
+	assertEqual(t, inside.MatchInside("a"), true)
+	assertEqual(t, inside.MatchInside("a/b/c/d"), true)
+	assertEqual(t, inside.MatchInside("a/x"), false)
+
+	var all *Pathspec
+	assertEqual(t, all.Match("a"), true)
+	assertEqual(t, all.MatchInside("a"), true)
+	assertEqual(t, NewPathspec([]string{""}).Match("a/b"), true)
+}
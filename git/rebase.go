@@ -0,0 +1,374 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RebaseItem is a command of the todo list of a rebase.
+type RebaseItem struct {
+	// Command is "pick", "reword", "squash", "fixup", "drop" or "exec".
+	Command string
+	// Commit is the hash of the commit of the command, except for "exec".
+	Commit string
+	// Arg is the shell command of an "exec".
+	Arg string
+}
+
+// rebaseCommands maps the commands of a todo list, and their abbreviations,
+// to the command names.
+var rebaseCommands = map[string]string{
+	"p": "pick", "pick": "pick",
+	"r": "reword", "reword": "reword",
+	"s": "squash", "squash": "squash",
+	"f": "fixup", "fixup": "fixup",
+	"d": "drop", "drop": "drop",
+	"x": "exec", "exec": "exec",
+}
+
+// RebaseState is the state of a rebase in progress, which is kept in the
+// directory ".git/rebase-merge".
+type RebaseState struct {
+	// HeadName is the branch being rebased, such as "refs/heads/topic", or
+	// "detached HEAD".
+	HeadName string
+	// Onto is the commit on which the commits are replayed.
+	Onto string
+	// OrigHead is the commit which HEAD was at before the rebase.
+	OrigHead string
+	// Interactive tells if the todo list was given by the user.
+	Interactive bool
+	// Todo lists the commands which are not done yet, and Done lists the
+	// ones which are done, along with the one being done.
+	Todo []RebaseItem
+	Done []RebaseItem
+	// Stopped is the commit whose pick stopped at a conflict, if any.
+	Stopped string
+	// Fixups are the "squash" and "fixup" commands melded so far into the
+	// commit FixupBase, which was HEAD before the first of them.
+	Fixups    []RebaseItem
+	FixupBase string
+}
+
+// ParseRebaseTodo parses a todo list, with a command on each line. The blank
+// lines and the comments starting with "#" are ignored.
+// Example: "pick 1e2f3a4 Fix the tests"
+func (r *Repo) ParseRebaseTodo(data string) ([]RebaseItem, error) {
+	items := []RebaseItem{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		command, ok := rebaseCommands[fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("error: invalid command '%s'", fields[0])
+		}
+		if command == "exec" {
+			arg := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+			if arg == "" {
+				return nil, fmt.Errorf("error: missing arguments for %s", command)
+			}
+			items = append(items, RebaseItem{Command: command, Arg: arg})
+			continue
+		}
+
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("error: missing arguments for %s", command)
+		}
+		commitHash, err := r.UniqueNameResolve(fields[1])
+		if err == nil {
+			var obj *Object
+			obj, commitHash, err = r.PeelObject(commitHash)
+			if err == nil && obj.ObjType != "commit" {
+				err = fmt.Errorf("error: '%s' is not a commit", fields[1])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error: invalid line: %s", line)
+		}
+		items = append(items, RebaseItem{Command: command, Commit: commitHash})
+	}
+	return items, nil
+}
+
+// FormatRebaseTodo returns a todo list with a line for each command, naming
+// the commits by their abbreviated hashes (or full hashes) and subjects.
+func (r *Repo) FormatRebaseTodo(items []RebaseItem, abbrev bool) (string, error) {
+	var b strings.Builder
+	for _, item := range items {
+		if item.Command == "exec" {
+			fmt.Fprintf(&b, "exec %s\n", item.Arg)
+			continue
+		}
+
+		commit, err := r.commitParse(item.Commit)
+		if err != nil {
+			return "", err
+		}
+		name := item.Commit
+		if abbrev {
+			name = name[:7]
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", item.Command, name, commit.Subject())
+	}
+	return b.String(), nil
+}
+
+// RebaseCommits returns the commits reachable from 'head' but not from
+// 'upstream', which are replayed by a rebase. They are ordered so that each
+// commit comes after its parents, and the merge commits are left out.
+func (r *Repo) RebaseCommits(upstream, head string) ([]string, error) {
+	excluded, err := r.Ancestors(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	// The commits are listed in the post-order of a walk from 'head'.
+	commits := []string{}
+	visited := map[string]bool{}
+	var walk func(hash string) error
+	walk = func(hash string) error {
+		if visited[hash] || excluded[hash] {
+			return nil
+		}
+		visited[hash] = true
+
+		commit, err := r.commitParse(hash)
+		if err != nil {
+			return err
+		}
+		for _, parent := range commit.Parents() {
+			if err := walk(parent); err != nil {
+				return err
+			}
+		}
+		if len(commit.Parents()) <= 1 {
+			commits = append(commits, hash)
+		}
+		return nil
+	}
+
+	if err := walk(head); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// Autosquash moves each commit whose subject starts with "fixup! " or
+// "squash! " right after the commit it refers to, as a "fixup" or a "squash"
+// command. The commit is referred to by the start of its subject, or by its
+// hash.
+func (r *Repo) Autosquash(items []RebaseItem) ([]RebaseItem, error) {
+	subjects := map[string]string{}
+	for _, item := range items {
+		if item.Commit == "" {
+			continue
+		}
+		commit, err := r.commitParse(item.Commit)
+		if err != nil {
+			return nil, err
+		}
+		subjects[item.Commit] = commit.Subject()
+	}
+
+	// Find the target of each fixup, which must come before it.
+	targets := map[int]int{}
+	for i, item := range items {
+		subject, command := subjects[item.Commit], ""
+		for stripped := true; stripped; {
+			stripped = false
+			for _, prefix := range []string{"fixup! ", "squash! "} {
+				if strings.HasPrefix(subject, prefix) {
+					if command == "" {
+						command = strings.TrimSuffix(prefix, "! ")
+					}
+					subject, stripped = strings.TrimPrefix(subject, prefix), true
+				}
+			}
+		}
+		if item.Command != "pick" || command == "" {
+			continue
+		}
+
+		// The exact subject is preferred over the start of a subject, and
+		// over the start of a hash.
+		matches := []func(target string) bool{
+			func(target string) bool { return subjects[target] == subject },
+			func(target string) bool { return strings.HasPrefix(subjects[target], subject) },
+			func(target string) bool {
+				return len(subject) >= 4 && strings.HasPrefix(target, subject)
+			},
+		}
+		for _, match := range matches {
+			for j := 0; j < i && targets[i] == 0; j++ {
+				if items[j].Commit != "" && targets[j] == 0 && match(items[j].Commit) {
+					targets[i] = j + 1
+					items[i].Command = command
+				}
+			}
+		}
+	}
+
+	// Rebuild the list with the fixups after their targets, in their order.
+	sorted := []RebaseItem{}
+	var add func(i int)
+	add = func(i int) {
+		sorted = append(sorted, items[i])
+		for j := range items {
+			if targets[j] == i+1 {
+				add(j)
+			}
+		}
+	}
+	for i := range items {
+		if targets[i] == 0 {
+			add(i)
+		}
+	}
+	return sorted, nil
+}
+
+// FixupMessage returns the message of a commit melded from the commit 'base'
+// and the commits of some "squash" and "fixup" commands, for the user to
+// edit. The messages of the fixups are left as comments.
+func (r *Repo) FixupMessage(base string, fixups []RebaseItem) (string, error) {
+	commit, err := r.commitParse(base)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# This is a combination of %d commits.\n", len(fixups)+1)
+	fmt.Fprintf(&b, "# This is the 1st commit message:\n\n%s\n",
+		strings.TrimRight(commit.Msg, "\n"))
+	for i, item := range fixups {
+		commit, err := r.commitParse(item.Commit)
+		if err != nil {
+			return "", err
+		}
+
+		lines := strings.Split(strings.TrimRight(commit.Msg, "\n"), "\n")
+		if item.Command == "fixup" {
+			fmt.Fprintf(&b, "\n# The commit message #%d will be skipped:\n\n", i+2)
+			for j := range lines {
+				lines[j] = strings.TrimRight("# "+lines[j], " ")
+			}
+		} else {
+			fmt.Fprintf(&b, "\n# This is the commit message #%d:\n\n", i+2)
+			if strings.HasPrefix(lines[0], "squash! ") {
+				lines[0] = "# " + lines[0]
+			}
+		}
+		fmt.Fprintf(&b, "%s\n", strings.Join(lines, "\n"))
+	}
+	return b.String(), nil
+}
+
+// ReadRebaseState reads the state of a rebase in progress. It returns nil if
+// there is none.
+func (r *Repo) ReadRebaseState() (*RebaseState, error) {
+	dir, err := r.DirPath(false, "rebase-merge")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "head-name")); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	state := &RebaseState{}
+	_, err = os.Stat(filepath.Join(dir, "interactive"))
+	state.Interactive = err == nil
+
+	values := map[string]*string{
+		"head-name":   &state.HeadName,
+		"onto":        &state.Onto,
+		"orig-head":   &state.OrigHead,
+		"stopped-sha": &state.Stopped,
+		"fixup-base":  &state.FixupBase,
+	}
+	lists := map[string]*[]RebaseItem{
+		"git-rebase-todo": &state.Todo,
+		"done":            &state.Done,
+		"current-fixups":  &state.Fixups,
+	}
+	for name, value := range values {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		*value = strings.TrimSpace(string(data))
+	}
+	for name, list := range lists {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if *list, err = r.ParseRebaseTodo(string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// WriteRebaseState saves the state of a rebase in progress.
+func (r *Repo) WriteRebaseState(state *RebaseState) error {
+	dir, err := r.DirPath(true, "rebase-merge")
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		"head-name": state.HeadName + "\n",
+		"onto":      state.Onto + "\n",
+		"orig-head": state.OrigHead + "\n",
+		"msgnum":    strconv.Itoa(len(state.Done)) + "\n",
+		"end":       strconv.Itoa(len(state.Done)+len(state.Todo)) + "\n",
+	}
+	lists := map[string][]RebaseItem{
+		"git-rebase-todo": state.Todo,
+		"done":            state.Done,
+		"current-fixups":  state.Fixups,
+	}
+	for name, list := range lists {
+		if files[name], err = r.FormatRebaseTodo(list, false); err != nil {
+			return err
+		}
+	}
+	if state.Interactive {
+		files["interactive"] = ""
+	}
+
+	// The optional files are removed when they are not set.
+	optional := map[string]string{"stopped-sha": state.Stopped, "fixup-base": state.FixupBase}
+	for name, value := range optional {
+		if value != "" {
+			files[name] = value + "\n"
+		} else if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveRebaseState removes the state of a rebase, once it is done or
+// aborted.
+func (r *Repo) RemoveRebaseState() error {
+	dir, err := r.DirPath(false, "rebase-merge")
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,119 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestRebase(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitRebase")
+
+	writeCommit := func(parent, msg string) string {
+		tree := writeTestTree(t, repo, map[string]string{"a": msg})
+		commit, err := NewCommitFromParents(repo, tree, []string{parent}, msg)
+		assertEqual(t, err, nil)
+		hash, err := repo.ObjectWrite(commit.Object, true)
+		assertEqual(t, err, nil)
+		return hash
+	}
+
+	base := writeTestCommit(t, repo, writeTestTree(t, repo, map[string]string{"a": "base"}))
+	one := writeCommit(base, "Add one\n")
+	two := writeCommit(one, "Add two\n")
+	fixup := writeCommit(two, "fixup! Add one\n")
+	squash := writeCommit(fixup, "squash! fixup! "+one[:7]+"\n\nMore\n")
+	merge := writeTestCommit(t, repo, writeTestTree(t, repo, map[string]string{"a": "m"}),
+		squash, base)
+
+	t.Run("Validate the commits to rebase", func(t *testing.T) {
+		commits, err := repo.RebaseCommits(base, merge)
+		assertEqual(t, err, nil)
+		assertEqual(t, commits, []string{one, two, fixup, squash})
+
+		commits, err = repo.RebaseCommits(two, squash)
+		assertEqual(t, err, nil)
+		assertEqual(t, commits, []string{fixup, squash})
+	})
+
+	t.Run("Validate the todo list", func(t *testing.T) {
+		items, err := repo.ParseRebaseTodo("# Comment\np " + one[:7] + " Add one\n\n" +
+			"reword " + two + "\nx make test  \nd " + fixup[:10] + "\n")
+		assertEqual(t, err, nil)
+		assertEqual(t, items, []RebaseItem{
+			{Command: "pick", Commit: one},
+			{Command: "reword", Commit: two},
+			{Command: "exec", Arg: "make test"},
+			{Command: "drop", Commit: fixup},
+		})
+
+		todo, err := repo.FormatRebaseTodo(items, true)
+		assertEqual(t, err, nil)
+		assertEqual(t, todo, "pick "+one[:7]+" Add one\nreword "+two[:7]+" Add two\n"+
+			"exec make test\ndrop "+fixup[:7]+" fixup! Add one\n")
+
+		_, err = repo.ParseRebaseTodo("edit " + one + "\n")
+		assertEqual(t, err != nil, true)
+		_, err = repo.ParseRebaseTodo("pick\n")
+		assertEqual(t, err != nil, true)
+		_, err = repo.ParseRebaseTodo("pick 0000000\n")
+		assertEqual(t, err != nil, true)
+	})
+
+	t.Run("Validate the autosquash", func(t *testing.T) {
+		items := []RebaseItem{}
+		for _, commit := range []string{one, two, fixup, squash} {
+			items = append(items, RebaseItem{Command: "pick", Commit: commit})
+		}
+		items, err := repo.Autosquash(items)
+		assertEqual(t, err, nil)
+		assertEqual(t, items, []RebaseItem{
+			{Command: "pick", Commit: one},
+			{Command: "fixup", Commit: fixup},
+			{Command: "squash", Commit: squash},
+			{Command: "pick", Commit: two},
+		})
+
+		msg, err := repo.FixupMessage(one, items[1:3])
+		assertEqual(t, err, nil)
+		assertEqual(t, msg, "# This is a combination of 3 commits.\n"+
+			"# This is the 1st commit message:\n\nAdd one\n\n"+
+			"# The commit message #2 will be skipped:\n\n# fixup! Add one\n\n"+
+			"# This is the commit message #3:\n\n# squash! fixup! "+one[:7]+"\n\nMore\n")
+		assertEqual(t, CleanupMessage(msg), "Add one\n\nMore\n")
+	})
+
+	t.Run("Validate the rebase state", func(t *testing.T) {
+		state, err := repo.ReadRebaseState()
+		assertEqual(t, err, nil)
+		assertEqual(t, state == nil, true)
+
+		state = &RebaseState{
+			HeadName:    "refs/heads/topic",
+			Onto:        base,
+			OrigHead:    squash,
+			Interactive: true,
+			Todo:        []RebaseItem{{Command: "exec", Arg: "true"}},
+			Done: []RebaseItem{
+				{Command: "pick", Commit: one}, {Command: "fixup", Commit: fixup},
+			},
+			Stopped:   fixup,
+			Fixups:    []RebaseItem{{Command: "fixup", Commit: fixup}},
+			FixupBase: one,
+		}
+		assertEqual(t, repo.WriteRebaseState(state), nil)
+		read, err := repo.ReadRebaseState()
+		assertEqual(t, err, nil)
+		assertEqual(t, read, state)
+
+		// The optional files are removed once they are not set.
+		state.Stopped, state.Fixups, state.FixupBase = "", []RebaseItem{}, ""
+		assertEqual(t, repo.WriteRebaseState(state), nil)
+		read, err = repo.ReadRebaseState()
+		assertEqual(t, err, nil)
+		assertEqual(t, read, state)
+
+		assertEqual(t, repo.RemoveRebaseState(), nil)
+		state, err = repo.ReadRebaseState()
+		assertEqual(t, err, nil)
+		assertEqual(t, state == nil, true)
+	})
+}
@@ -0,0 +1,116 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ReflogEntry is a single change of a reference, as recorded in its log.
+type ReflogEntry struct {
+	Old string
+	New string
+	// Identity is the person who changed the reference, along with the
+	// time. Example: "Shyamsunder Rathi <sxxxxxx@gmail.com> 1589619289 -0700"
+	Identity string
+	Message  string
+}
+
+// ReadReflog reads the log of a reference, such as "refs/heads/master", with
+// the oldest entry first. A reference without a log gives no entries, and so
+// does a repo without a git directory.
+func (r *Repo) ReadReflog(ref string) ([]ReflogEntry, error) {
+	entries := []ReflogEntry{}
+	if r.GitDir == "" {
+		return entries, nil
+	}
+	logFile, err := r.FilePath(false, "logs", ref)
+	if err != nil {
+		return entries, nil
+	}
+	data, err := ioutil.ReadFile(logFile)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		// Each line is in the following format.
+		// <old hash> <new hash> <identity><tab><message>
+		items := strings.SplitN(line, " ", 3)
+		if len(items) != 3 || len(items[0]) != 40 || len(items[1]) != 40 {
+			continue
+		}
+		entry := ReflogEntry{Old: items[0], New: items[1], Identity: items[2]}
+		if ind := strings.IndexByte(items[2], '\t'); ind >= 0 {
+			entry.Identity, entry.Message = items[2][:ind], items[2][ind+1:]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// WriteReflog adds an entry to the log of a reference, such as "HEAD" or
+// "refs/heads/master", which records that it moved from 'oldHash' to
+// 'newHash'. An empty hash is logged as the null hash. Nothing is logged for
+// a repo without a git directory.
+func (r *Repo) WriteReflog(ref, oldHash, newHash, msg string) error {
+	if r.GitDir == "" {
+		return nil
+	}
+	logFile, err := r.FilePath(true, append([]string{"logs"}, strings.Split(ref, "/")...)...)
+	if err != nil {
+		return err
+	}
+	if oldHash == "" {
+		oldHash = nullHash
+	}
+	if newHash == "" {
+		newHash = nullHash
+	}
+
+	fd, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	// A message can't span lines, as each line is an entry.
+	msg = strings.Join(strings.Fields(msg), " ")
+	_, err = fmt.Fprintf(fd, "%s %s %s\t%s\n", oldHash, newHash, currentIdentity(), msg)
+	return err
+}
+
+// rewriteReflog replaces all the entries in the log of a reference.
+func (r *Repo) rewriteReflog(ref string, entries []ReflogEntry) error {
+	if r.GitDir == "" {
+		return nil
+	}
+	logFile, err := r.FilePath(true, append([]string{"logs"}, strings.Split(ref, "/")...)...)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "%s %s %s\t%s\n", entry.Old, entry.New, entry.Identity, entry.Message)
+	}
+	return ioutil.WriteFile(logFile, []byte(b.String()), 0644)
+}
+
+// removeReflog removes the log of a reference, if any.
+func (r *Repo) removeReflog(ref string) error {
+	if r.GitDir == "" {
+		return nil
+	}
+	logFile, err := r.FilePath(false, "logs", ref)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(logFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package git
+
+import (
+	"log"
+	"path"
+	"sort"
+)
+
+// renameSource is a file which may have been renamed or copied to a newly
+// added file. Only deleted files can be renamed. The others can only be copied.
+type renameSource struct {
+	file    FileEntry
+	deleted bool
+	used    bool
+}
+
+// renameMatch is a candidate pairing of a source with a destination.
+type renameMatch struct {
+	src   int
+	dst   int
+	score int
+}
+
+// detectRenames replaces the pairs of deleted and added files in 'entries'
+// with renames (and the added files similar to modified or unmodified files
+// with copies) as per the given options. Identical files are paired first by
+// their blob hash, and the rest by a similarity score of their content.
+func (r *Repo) detectRenames(entries []DiffEntry, oldTree string, opts *DiffOptions) ([]DiffEntry, error) {
+	minScore := opts.RenameScore
+	if minScore <= 0 {
+		minScore = DefaultRenameScore
+	}
+
+	// Collect the sources and the destinations of renames and copies.
+	sources := []*renameSource{}
+	dsts := []int{}
+	for i, entry := range entries {
+		switch entry.Status {
+		case DiffAdded:
+			dsts = append(dsts, i)
+		case DiffDeleted:
+			sources = append(sources, &renameSource{file: entry.Old, deleted: true})
+		case DiffModified:
+			if opts.DetectCopies || opts.FindCopiesHarder {
+				sources = append(sources, &renameSource{file: entry.Old})
+			}
+		}
+	}
+
+	// Unmodified files can be copy sources too, but only if asked for, as it
+	// requires reading the entire old tree.
+	if opts.FindCopiesHarder {
+		changed := map[string]bool{}
+		for _, entry := range entries {
+			changed[entry.Old.Path] = true
+		}
+		files, err := r.TreeFiles(oldTree)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !changed[file.Path] {
+				sources = append(sources, &renameSource{file: file})
+			}
+		}
+	}
+
+	if len(sources) == 0 || len(dsts) == 0 {
+		return entries, nil
+	}
+
+	copies := opts.DetectCopies || opts.FindCopiesHarder
+	paired := map[int]DiffEntry{}
+
+	// Exact renames first. Prefer a source with the same base name if
+	// there are many with the same content.
+	byHash := map[string][]int{}
+	for i, src := range sources {
+		if renameCandidate(src.file) && src.file.Hash != emptyBlobHash {
+			byHash[src.file.Hash] = append(byHash[src.file.Hash], i)
+		}
+	}
+	for _, dst := range dsts {
+		dstFile := entries[dst].New
+		if !renameCandidate(dstFile) {
+			continue
+		}
+
+		best := -1
+		for _, i := range byHash[dstFile.Hash] {
+			src := sources[i]
+			if src.used && !copies || modeType(src.file.Mode) != modeType(dstFile.Mode) {
+				continue
+			}
+			if best < 0 || betterRenameSource(src, sources[best], dstFile) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			paired[dst] = r.pairSource(sources[best], dstFile, 100)
+		}
+	}
+
+	// Inexact renames next, unless there are too many files to compare.
+	remaining := []int{}
+	for _, dst := range dsts {
+		if _, ok := paired[dst]; !ok && regularFile(entries[dst].New) {
+			remaining = append(remaining, dst)
+		}
+	}
+	candidates := []int{}
+	for i, src := range sources {
+		if regularFile(src.file) && (copies || !src.used) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	limit := opts.RenameLimit
+	if limit > 0 && len(remaining)*len(candidates) > limit*limit {
+		log.Printf("Skipping inexact rename detection for %d sources and %d "+
+			"destinations (limit %d)", len(candidates), len(remaining), limit)
+		candidates = nil
+	}
+
+	if len(remaining) > 0 && len(candidates) > 0 {
+		matches := []renameMatch{}
+		signatures := map[string]*similaritySignature{}
+		for _, dst := range remaining {
+			dstSig, err := r.signature(entries[dst].New, signatures)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, i := range candidates {
+				srcSig, err := r.signature(sources[i].file, signatures)
+				if err != nil {
+					return nil, err
+				}
+
+				score := similarity(srcSig, dstSig, minScore)
+				if score >= minScore {
+					matches = append(matches, renameMatch{i, dst, score})
+				}
+			}
+		}
+
+		// Pair the best matches first.
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+		for _, match := range matches {
+			if _, ok := paired[match.dst]; ok {
+				continue
+			}
+			src := sources[match.src]
+			if src.used && !copies {
+				continue
+			}
+			paired[match.dst] = r.pairSource(src, entries[match.dst].New, match.score)
+		}
+	}
+
+	// Replace the paired additions and drop the deletions which are renamed.
+	renamed := map[string]bool{}
+	for _, entry := range paired {
+		if entry.Status == DiffRenamed {
+			renamed[entry.Old.Path] = true
+		}
+	}
+
+	result := []DiffEntry{}
+	for i, entry := range entries {
+		if pair, ok := paired[i]; ok {
+			result = append(result, pair)
+		} else if entry.Status != DiffDeleted || !renamed[entry.Old.Path] {
+			result = append(result, entry)
+		}
+	}
+
+	return result, nil
+}
+
+// pairSource creates a rename (or a copy, if the source can't be renamed
+// anymore) from a source to a destination and marks the source as used.
+func (r *Repo) pairSource(src *renameSource, dst FileEntry, score int) DiffEntry {
+	status := DiffCopied
+	if src.deleted && !src.used {
+		status = DiffRenamed
+	}
+	src.used = true
+
+	return DiffEntry{Status: status, Old: src.file, New: dst, Score: score}
+}
+
+// betterRenameSource tells if 'src' is a better match than 'best' for an exact
+// rename to 'dst'. Unused deleted files with the same base name are preferred.
+func betterRenameSource(src, best *renameSource, dst FileEntry) bool {
+	srcRename := src.deleted && !src.used
+	bestRename := best.deleted && !best.used
+	if srcRename != bestRename {
+		return srcRename
+	}
+
+	base := path.Base(dst.Path)
+	return path.Base(src.file.Path) == base && path.Base(best.file.Path) != base
+}
+
+// renameCandidate tells if a file can be a part of an exact rename. Gitlinks
+// are never renamed.
+func renameCandidate(file FileEntry) bool {
+	return modeType(file.Mode) != "160"
+}
+
+// regularFile tells if a file is a regular file (not a symlink or a gitlink).
+// Only regular files are compared by their content for renames.
+func regularFile(file FileEntry) bool {
+	return modeType(file.Mode) == "100"
+}
+
+// emptyBlobHash is the hash of a blob without any data. Empty files are too
+// common to be considered as renames of each other.
+const emptyBlobHash string = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// similaritySignature summarizes the content of a blob for a similarity
+// estimate. The content is split into lines (or 64 byte chunks of longer
+// lines) and the number of bytes in each distinct chunk is counted. Like
+// "git", the chunks are told apart by a small hash, the carriage returns
+// before the line feeds of a text are left out, and so is a last chunk which
+// is shorter than 64 bytes and doesn't end a line.
+type similaritySignature struct {
+	size   int
+	chunks map[uint64]int
+}
+
+// signature computes (and caches) the similarity signature of a file. The
+// file may be in the work-tree, if its blob is not written yet.
+func (r *Repo) signature(file FileEntry, cache map[string]*similaritySignature) (*similaritySignature, error) {
+	if sig, ok := cache[file.Hash]; ok {
+		return sig, nil
+	}
+
+	data, err := r.FileData(file)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := newSimilaritySignature(data)
+	cache[file.Hash] = sig
+	return sig, nil
+}
+
+// newSimilaritySignature builds the similarity signature of the given data.
+func newSimilaritySignature(data []byte) *similaritySignature {
+	sig := &similaritySignature{
+		size:   len(data),
+		chunks: map[uint64]int{},
+	}
+
+	// The hash of a chunk is the same as that of "git", so that the chunks
+	// which collide are the same.
+	text := !IsBinary(data)
+	var accum1, accum2 uint32
+	n := 0
+	for i, ch := range data {
+		if text && ch == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+
+		old := accum1
+		accum1 = (accum1 << 7) ^ (accum2 >> 25)
+		accum2 = (accum2 << 7) ^ (old >> 25)
+		accum1 += uint32(ch)
+		n++
+		if n == 64 || ch == '\n' {
+			hash := (accum1 + accum2*0x7fffffff) % spanHashBase
+			sig.chunks[uint64(hash)] += n
+			accum1, accum2, n = 0, 0, 0
+		}
+	}
+
+	return sig
+}
+
+// spanHashBase is the number of distinct hashes of the chunks of a signature.
+const spanHashBase = 107927
+
+// countChanges returns the number of bytes of 'src' which are kept in 'dst',
+// and the number of bytes of 'dst' which are added.
+func countChanges(src, dst *similaritySignature) (copied, added int) {
+	for chunk, count := range src.chunks {
+		if dstCount := dst.chunks[chunk]; dstCount < count {
+			copied += dstCount
+		} else {
+			copied += count
+		}
+	}
+	for chunk, count := range dst.chunks {
+		if srcCount := src.chunks[chunk]; srcCount < count {
+			added += count - srcCount
+		}
+	}
+	return copied, added
+}
+
+// similarity returns the percentage of the content which is common in two
+// signatures, relative to the larger of the two. Files whose sizes are too
+// different to reach 'minScore' are not compared at all.
+func similarity(src, dst *similaritySignature, minScore int) int {
+	maxSize, minSize := src.size, dst.size
+	if maxSize < minSize {
+		maxSize, minSize = minSize, maxSize
+	}
+	if minSize == 0 {
+		return 0
+	}
+	if (maxSize-minSize)*100 > maxSize*(100-minScore) {
+		return 0
+	}
+
+	copied, _ := countChanges(src, dst)
+	return copied * 100 / maxSize
+}
@@ -2,12 +2,13 @@
 package git
 
 import (
+	"bufio"
 	"bytes"
-	"compress/zlib"
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -24,6 +25,18 @@ import (
 type Repo struct {
 	GitDir   string
 	WorkTree string
+	// CommonDir is the git directory shared by all the work-trees of the
+	// repo, which has the objects, the refs and the configuration. It is
+	// different from GitDir for a linked work-tree only. Empty means GitDir.
+	CommonDir string
+	// Objects and Refs keep the objects and the references of the repo. They
+	// are the files in the git directory if not set.
+	Objects ObjectStore
+	Refs    RefStore
+
+	// loose is the store of the objects in the git directory, which is kept
+	// to read its settings only once.
+	loose *looseObjectStore
 }
 
 // RefEntry keeps a mapping of a reference object with its associated reference.
@@ -32,55 +45,176 @@ type RefEntry struct {
 	RefHash string
 }
 
-// NewRepo is used by 'gogit init' to create a fresh repo.
-func NewRepo(path string) (*Repo, error) {
+// ErrNoWorkTree tells that a command which needs a work-tree is run in a bare
+// repository.
+var ErrNoWorkTree = errors.New("fatal: this operation must be run in a work tree")
+
+// InitOptions keeps the options to create a repo with "gogit init".
+type InitOptions struct {
+	// Bare creates the repo without a work-tree, with the git directory being
+	// the given path itself.
+	Bare bool
+	// InitialBranch is the branch which HEAD points to. It defaults to
+	// "init.defaultBranch" of the user configuration, or "master".
+	InitialBranch string
+	// Template is a directory whose files are copied into the git directory.
+	// It defaults to $GIT_TEMPLATE_DIR or "init.templateDir".
+	Template string
+}
+
+// InitRepo creates a fresh repo at the given path, which may have files in it
+// already. Running it again for an existing repo is safe. Only the missing
+// files and directories are created, and HEAD is kept as it is. The returned
+// flag tells if the repo already existed.
+func InitRepo(path string, opts *InitOptions) (*Repo, bool, error) {
 	path, _ = filepath.Abs(path)
 	repo := Repo{
 		WorkTree: path,
 		GitDir:   filepath.Join(path, ".git"),
 	}
+	if opts.Bare {
+		repo = Repo{GitDir: path}
+	}
 
-	// Validate that the WorkTree is either empty or it doesn't exist.
-	log.Printf("Creating an empty git repo at path: %q\n", path)
-	if util.IsPathPresent(repo.WorkTree) {
-		// Make sure if it empty.
-		empty, _ := util.IsDirEmpty(repo.WorkTree)
-		if !empty {
-			err := fmt.Errorf("Work-tree %q is not empty", repo.WorkTree)
-			return nil, err
+	log.Printf("Creating a git repo at path: %q bare: %v\n", path, opts.Bare)
+	if util.IsPathPresent(path) {
+		if isDir, _ := util.IsPathDir(path); !isDir {
+			return nil, false, fmt.Errorf("fatal: cannot mkdir %s: File exists", path)
 		}
-	} else {
-		// Create the repo work-tree directory.
-		if err := os.MkdirAll(repo.WorkTree, os.ModePerm); err != nil {
-			return nil, err
+	} else if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, false, err
+	}
+
+	headFile := filepath.Join(repo.GitDir, "HEAD")
+	reinit := util.IsPathPresent(headFile)
+
+	config := NewConfig()
+	for _, file := range globalConfigFiles() {
+		if err := config.ParseFile(file); err != nil {
+			return nil, false, err
 		}
 	}
 
-	// Create needed subdirectories under the .git directory.
-	repo.DirPath(true, "objects")
-	repo.DirPath(true, "refs", "tags")
-	repo.DirPath(true, "refs", "heads")
+	branch := opts.InitialBranch
+	if branch == "" {
+		branch, _ = config.Get("init.defaultBranch")
+	}
+	if branch == "" {
+		branch = "master"
+	}
+	if !checkRefFormat("refs/heads/" + branch) {
+		return nil, false, fmt.Errorf("fatal: invalid initial branch name: '%s'", branch)
+	}
 
-	// Create needed files under the .git directory.
-	description := []byte("Unnamed repository; edit this file 'description' " +
-		"to name the repository.\n")
-	descFile, _ := repo.FilePath(true, "description")
-	if err := ioutil.WriteFile(descFile, description, 0644); err != nil {
-		return nil, err
+	template := opts.Template
+	if template == "" {
+		template = os.Getenv("GIT_TEMPLATE_DIR")
+	}
+	if template == "" {
+		template, _ = config.Get("init.templateDir")
+	}
+	if template != "" {
+		if err := copyTemplate(template, repo.GitDir); err != nil {
+			return nil, false, err
+		}
 	}
 
-	// HEAD file to point to the master branch initially.
-	headRef := []byte("ref: refs/heads/master\n")
-	headFile, _ := repo.FilePath(true, "HEAD")
-	if err := ioutil.WriteFile(headFile, headRef, 0644); err != nil {
-		return nil, err
+	if err := repo.initGitDir(branch); err != nil {
+		return nil, false, err
 	}
 
-	// refs/heads/master doesn't point to any commit in the beginning.
-	masterFile, _ := repo.FilePath(true, "refs", "heads", "master")
-	if err := ioutil.WriteFile(masterFile, []byte(""), 0644); err != nil {
-		return nil, err
+	return &repo, reinit, nil
+}
+
+// NewRepo is used by 'gogit init' to create a fresh repo.
+func NewRepo(path string) (*Repo, error) {
+	repo, _, err := InitRepo(path, &InitOptions{})
+	return repo, err
+}
+
+// NewBareRepo is used by 'gogit init --bare' to create a fresh repo without a
+// work-tree. The git directory is the given path itself.
+func NewBareRepo(path string) (*Repo, error) {
+	repo, _, err := InitRepo(path, &InitOptions{Bare: true})
+	return repo, err
+}
+
+// copyTemplate copies the files of a template directory into the git
+// directory, such as the hooks and "info/exclude". Like "git", the existing
+// files are not overwritten, and the files starting with a "." are skipped.
+func copyTemplate(template, gitDir string) error {
+	if !util.IsPathPresent(template) {
+		log.Printf("Template directory %q is not present\n", template)
+		return nil
+	}
+
+	return filepath.Walk(template, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(template, path)
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(gitDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		if util.IsPathPresent(target) {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// checkRefFormat tells if the given name is a valid reference name, as per
+// the rules of "git check-ref-format".
+func checkRefFormat(name string) bool {
+	if name == "@" || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") ||
+			strings.HasSuffix(component, ".lock") {
+			return false
+		}
 	}
+	return true
+}
+
+// initGitDir lays out the files and directories of a git directory. The
+// existing files are kept as they are, so that it is safe to run it again.
+// HEAD points to the given branch, which is not created till its first
+// commit.
+func (r *Repo) initGitDir(branch string) error {
+	// Create needed subdirectories under the .git directory.
+	r.DirPath(true, "objects")
+	r.DirPath(true, "refs", "tags")
+	r.DirPath(true, "refs", "heads")
 
 	// Write the default git configuration file. We only support few needed
 	// configuration options.
@@ -88,40 +222,77 @@ func NewRepo(path string) (*Repo, error) {
 	defaultConfig := []byte(
 		"[core]\n" +
 			"\trepositoryformatversion = 0\n" +
-			"\tbare = false\n" +
+			"\tbare = " + strconv.FormatBool(r.IsBare()) + "\n" +
 			"\tfilemode = false\n")
-	configFile, _ := repo.FilePath(true, "config")
-	if err := ioutil.WriteFile(configFile, defaultConfig, 0644); err != nil {
-		return nil, err
+
+	// Create needed files under the .git directory.
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"description", []byte("Unnamed repository; edit this file 'description' " +
+			"to name the repository.\n")},
+		{"HEAD", []byte("ref: refs/heads/" + branch + "\n")},
+		{"config", defaultConfig},
+	}
+	for _, file := range files {
+		path, _ := r.FilePath(true, file.name)
+		if util.IsPathPresent(path) {
+			continue
+		}
+		if err := ioutil.WriteFile(path, file.data, 0644); err != nil {
+			return err
+		}
 	}
 
-	// A fresh repo is now cooked. Return it to the caller.
-	return &repo, nil
+	return nil
 }
 
 // GetRepo is used by all commands other than "gogit init" to work on an existing repo.
-// .git directory can be at given path, or can be at any parent up to rootdir.
+// .git directory can be at given path, or can be at any parent up to rootdir. A
+// bare repo (without a work-tree) is found in the same way, as a directory
+// which is a git directory itself. Like "git", the search is controlled by
+// the following environment variables.
+//   - GIT_DIR: the git directory to use, without any search.
+//   - GIT_WORK_TREE: the top of the work-tree, instead of the directory
+//     having the .git directory.
+//   - GIT_CEILING_DIRECTORIES: a list of directories which the search
+//     doesn't go up into.
 func GetRepo(path string) (*Repo, error) {
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		return explicitRepo(gitDir)
+	}
+
+	ceilings := ceilingDirs()
 	for {
 		path, _ = filepath.Abs(path)
 
-		// Check if git directory is present.
+		// Check if git directory is present. A ".git" file points to a git
+		// directory elsewhere.
 		GitDir := filepath.Join(path, ".git")
 		isPresent := util.IsPathPresent(GitDir)
 		isDir, _ := util.IsPathDir(GitDir)
 
+		if isPresent && !isDir {
+			target, err := readGitFile(GitDir)
+			if err != nil {
+				return nil, err
+			}
+			GitDir, isDir = target, true
+		}
 		if isPresent && isDir {
 			// Found the repo.
-			repo := Repo{
-				WorkTree: path,
-				GitDir:   filepath.Join(path, ".git"),
-			}
-			return &repo, nil
+			return openRepo(GitDir, path), nil
+		}
+
+		if isGitDir(path) {
+			log.Printf("Found a bare git repo at path: %q\n", path)
+			return openRepo(path, ""), nil
 		}
 
 		// Find the parent directory of the given path.
 		parent := filepath.Dir(path)
-		if parent == path {
+		if parent == path || ceilings[parent] {
 			// This means 'gogit init' was not done before.
 			err := errors.New("fatal: not a git repository (or any of the " +
 				"parent directories): .git")
@@ -133,13 +304,171 @@ func GetRepo(path string) (*Repo, error) {
 	}
 }
 
+// openRepo returns the repo of a git directory which was found along with its
+// work-tree. GIT_WORK_TREE takes precedence over the work-tree.
+func openRepo(gitDir, workTree string) *Repo {
+	if envWorkTree := os.Getenv("GIT_WORK_TREE"); envWorkTree != "" {
+		workTree, _ = filepath.Abs(envWorkTree)
+	}
+	return &Repo{
+		GitDir:    gitDir,
+		WorkTree:  workTree,
+		CommonDir: readCommonDir(gitDir),
+	}
+}
+
+// explicitRepo returns the repo of a git directory given by GIT_DIR. Without
+// GIT_WORK_TREE, the current directory is the top of the work-tree, unless
+// the repo is configured as bare.
+func explicitRepo(gitDir string) (*Repo, error) {
+	gitDir, _ = filepath.Abs(gitDir)
+	if isDir, err := util.IsPathDir(gitDir); err == nil && !isDir {
+		target, err := readGitFile(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		gitDir = target
+	}
+	if !isGitDir(gitDir) {
+		return nil, fmt.Errorf("fatal: not a git repository: '%s'", os.Getenv("GIT_DIR"))
+	}
+
+	workTree, _ := filepath.Abs(".")
+	config := NewConfig()
+	configFile := filepath.Join(readCommonDir(gitDir), "config")
+	if err := config.ParseFile(configFile); err == nil && config.GetBool("core.bare", false) {
+		workTree = ""
+	}
+	return openRepo(gitDir, workTree), nil
+}
+
+// readGitFile reads a ".git" file, as used by the linked work-trees and the
+// submodules, which points to the actual git directory. The path in it is
+// relative to the directory having the file.
+// Example: "gitdir: ../.git/worktrees/topic"
+func readGitFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimRight(string(data), "\r\n")
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("fatal: invalid gitfile format: %s", path)
+	}
+	gitDir := filepath.FromSlash(strings.TrimPrefix(line, "gitdir: "))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	if !isGitDir(gitDir) {
+		return "", fmt.Errorf("fatal: not a git repository: %s", gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// ceilingDirs returns the absolute directories listed in
+// GIT_CEILING_DIRECTORIES. The others are ignored, like "git" does.
+func ceilingDirs() map[string]bool {
+	dirs := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		if filepath.IsAbs(dir) {
+			dirs[filepath.Clean(dir)] = true
+		}
+	}
+	return dirs
+}
+
+// readCommonDir returns the common git directory of a git directory, as
+// given by its "commondir" file. Only the git directory of a linked work-tree
+// has the file, and the path in it is relative to the git directory.
+// Example: "../.." for ".git/worktrees/topic"
+func readCommonDir(gitDir string) string {
+	data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+
+	commonDir := filepath.FromSlash(strings.TrimRight(string(data), "\r\n"))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// isGitDir tells if a directory is a git directory itself, which is either
+// configured as bare ("core.bare"), or has the HEAD file along with the
+// "objects" and "refs" directories. A linked work-tree finds the directories
+// in its common directory.
+func isGitDir(path string) bool {
+	commonDir := readCommonDir(path)
+	config := NewConfig()
+	if err := config.ParseFile(filepath.Join(commonDir, "config")); err == nil &&
+		config.GetBool("core.bare", false) {
+		return true
+	}
+
+	for _, name := range []string{"objects", "refs"} {
+		if isDir, _ := util.IsPathDir(filepath.Join(commonDir, name)); !isDir {
+			return false
+		}
+	}
+	isDir, err := util.IsPathDir(filepath.Join(path, "HEAD"))
+	return err == nil && !isDir
+}
+
+// IsBare tells if the repo doesn't have a work-tree.
+func (r *Repo) IsBare() bool {
+	return r.WorkTree == ""
+}
+
+// RequireWorkTree returns ErrNoWorkTree if the repo doesn't have a work-tree,
+// for the operations which need one.
+func (r *Repo) RequireWorkTree() error {
+	if r.IsBare() {
+		return ErrNoWorkTree
+	}
+	return nil
+}
+
+// sharedPaths are the entries of a git directory which all the work-trees of
+// a repo share, in their common directory. The others, such as HEAD, the
+// index and "logs/HEAD", belong to each work-tree.
+var sharedPaths = map[string]bool{
+	"branches": true, "config": true, "description": true, "hooks": true,
+	"info": true, "logs": true, "objects": true, "packed-refs": true,
+	"refs": true, "remotes": true, "rr-cache": true, "shallow": true,
+	"worktrees": true,
+}
+
+// baseDir returns the git directory which has the given path (relative to a
+// git directory), which is the common directory for the shared paths.
+func (r *Repo) baseDir(path string) string {
+	if r.CommonDir == "" {
+		return r.GitDir
+	}
+
+	path = filepath.ToSlash(path)
+	for _, own := range []string{"logs/HEAD", "refs/bisect", "refs/worktree", "refs/rewritten"} {
+		if path == own || strings.HasPrefix(path, own+"/") {
+			return r.GitDir
+		}
+	}
+	if sharedPaths[strings.SplitN(path, "/", 2)[0]] {
+		return r.CommonDir
+	}
+	return r.GitDir
+}
+
 // DirPath gets (and optionally creates) a directory path inside .git in the repo.
 // Example: ["objects", "1e", "ab123"] returns ".git/objects/1e/ab123"
 func (r *Repo) DirPath(create bool, paths ...string) (string, error) {
-	paths = append([]string{r.GitDir}, paths...)
-	path := filepath.Join(paths...)
+	rel := filepath.Join(paths...)
+	return dirPath(create, filepath.Join(r.baseDir(rel), rel))
+}
 
-	// Make sure the path is a directory.
+// dirPath makes sure that the given path is a directory, if it is present,
+// and optionally creates it.
+func dirPath(create bool, path string) (string, error) {
 	if util.IsPathPresent(path) {
 		isDir, _ := util.IsPathDir(path)
 		if !isDir {
@@ -165,40 +494,26 @@ func (r *Repo) FilePath(create bool, paths ...string) (string, error) {
 		return "", nil
 	}
 
-	// Last element is the filename.
-	filename := paths[len(paths)-1]
-	paths = paths[:len(paths)-1]
-	dirPath, err := r.DirPath(create, paths...)
-	if err != nil {
+	// The last element is the filename. It may have the directories too,
+	// such as "refs/heads/master". The git directory is chosen by the full
+	// path, as "logs/HEAD" belongs to the work-tree while "logs" is shared.
+	rel := filepath.Join(paths...)
+	path := filepath.Join(r.baseDir(rel), rel)
+	if _, err := dirPath(create, filepath.Dir(path)); err != nil {
 		return "", err
 	}
 
-	return filepath.Join(dirPath, filename), nil
+	return path, nil
 }
 
 // ObjectParse finds the data referred by the given sha1 hash and add the data to the
 // object as per "Git" specifications.
 func (r *Repo) ObjectParse(objHash string) (*Object, error) {
-	dataFile, err := r.FilePath(
-		false, "objects", string(objHash[0:2]), string(objHash[2:]))
+	data, err := r.objectStore().ReadObject(objHash)
 	if err != nil {
 		return nil, err
 	}
 
-	// Read the file data and decompress it.
-	data, err := ioutil.ReadFile(dataFile)
-	if err != nil {
-		return nil, err
-	}
-
-	decompressed := bytes.NewBuffer(data)
-	rdr, err := zlib.NewReader(decompressed)
-	if err != nil {
-		return nil, fmt.Errorf("Malformed object %s: bad data", objHash)
-	}
-	data, _ = ioutil.ReadAll(rdr)
-	rdr.Close()
-
 	// Strip the header from the decompressed data.
 	spaceInd := bytes.IndexByte(data, byte(' '))
 	objType := string(data[0:spaceInd])
@@ -230,43 +545,94 @@ func (r *Repo) ObjectWrite(obj *Object, write bool) (string, error) {
 		return sha1hash, nil
 	}
 
-	// Write the compressed data to a path determined by the sha1 hash.
-	var compressed bytes.Buffer
-	w := zlib.NewWriter(&compressed)
-	w.Write(data)
-	w.Close()
+	if err := r.objectStore().WriteObject(sha1hash, data); err != nil {
+		return "", err
+	}
+
+	// The data is now successfully written as per Git specification.
+	return sha1hash, nil
+}
 
-	dataFile, err := r.FilePath(
-		true, "objects", string(sha1hash[0:2]), string(sha1hash[2:]))
+// ObjectOpen is like ObjectParse, but gives a reader for the data of the
+// object instead of reading all of it in memory. Only the header is read
+// before returning. The reader must be closed by the caller.
+func (r *Repo) ObjectOpen(objHash string) (*ObjectReader, error) {
+	data, err := r.objectStore().OpenObject(objHash)
 	if err != nil {
+		return nil, err
+	}
+
+	rdr := bufio.NewReader(data)
+	header, err := rdr.ReadString('\x00')
+	spaceInd := strings.IndexByte(header, ' ')
+	if err != nil || spaceInd < 0 {
+		data.Close()
+		return nil, fmt.Errorf("Malformed object %s: bad header", objHash)
+	}
+
+	size, err := strconv.ParseInt(header[spaceInd+1:len(header)-1], 10, 64)
+	if err != nil || size < 0 {
+		data.Close()
+		return nil, fmt.Errorf("Malformed object %s: bad length", objHash)
+	}
+
+	obj := &ObjectReader{
+		Type:   header[:spaceInd],
+		Size:   size,
+		hash:   objHash,
+		left:   size,
+		rdr:    rdr,
+		closer: data,
+	}
+	return obj, nil
+}
+
+// ObjectWriteFrom is like ObjectWrite, but takes the data of the object from
+// a reader which must give exactly 'size' bytes. The data is hashed, and also
+// compressed if 'write' is true, in a single pass without holding it in memory.
+func (r *Repo) ObjectWriteFrom(objType string, size int64, rdr io.Reader, write bool) (string, error) {
+	h := sha1.New()
+	out := io.Writer(h)
+	var w ObjectWriter
+	if write {
+		var err error
+		if w, err = r.objectStore().CreateObject(); err != nil {
+			return "", err
+		}
+		defer w.Close()
+		out = io.MultiWriter(h, w)
+	}
+
+	if _, err := fmt.Fprintf(out, "%s %d\x00", objType, size); err != nil {
 		return "", err
 	}
-	if err := ioutil.WriteFile(dataFile, compressed.Bytes(), 0664); err != nil {
+	n, err := io.CopyN(out, rdr, size)
+	if err == io.EOF {
+		return "", fmt.Errorf("error: short read of %s data: %d bytes out of %d",
+			objType, n, size)
+	} else if err != nil {
 		return "", err
 	}
 
-	// The data is now successfully written to a file as per Git specification.
+	sha1hash := hex.EncodeToString(h.Sum(nil))
+	if !write {
+		return sha1hash, nil
+	}
+	if err := w.Commit(sha1hash); err != nil {
+		return "", err
+	}
 	return sha1hash, nil
 }
 
 // RefResolve converts a symbolic reference to its object hash.
 func (r *Repo) RefResolve(path string) (string, string, error) {
 	for {
-		refFile, err := r.FilePath(false, path)
+		ref, err := r.refStore().ReadRef(path)
 		if err != nil {
-			// Not a symbolic reference if some path of this file is not present
+			// Not a symbolic reference if it is not present
 			return "", "", err
 		}
 
-		data, err := ioutil.ReadFile(refFile)
-		if err != nil {
-			// Not a symbolic reference if its file is not present
-			return "", "", err
-		}
-
-		ref := string(data)
-		ref = strings.TrimSuffix(ref, "\n")
-
 		if !strings.HasPrefix(ref, "ref: ") {
 			// It is not a symblic reference.
 			return ref, path, nil
@@ -280,72 +646,57 @@ func (r *Repo) RefResolve(path string) (string, string, error) {
 // GetRefs gets all the references inside the .git directory. This can be
 // used by commands such as "gogit show-ref".
 func (r *Repo) GetRefs(pattern string, getHead bool) ([]RefEntry, error) {
-	// Read all files inside .git/refs and collect them in a list.
-	// If 'Pattern' is given, then filter out all other files.
-	// If 'getHead' is given, then get .git/HEAD as well.
-	refDir, err := r.DirPath(false, "refs")
+	// Read all the references under refs/ and collect them in a list.
+	// If 'Pattern' is given, then filter out all other references.
+	// If 'getHead' is given, then get HEAD as well.
+	names, err := r.refStore().ListRefs()
 	if err != nil {
 		return nil, err
 	}
 
 	refs := []RefEntry{}
-	// Walk function to travese through everything under .git/refs directory.
-	var walkFunc = func(path string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil {
-			log.Printf("Error in accessing path %s (%v)\n", path, err)
-			return walkErr
-		}
-
-		if !info.IsDir() {
-			// Get the relative path from the .git directory.
-			ref := strings.TrimPrefix(path, (r.GitDir)+"/")
-			log.Printf("Working on ref: %s\n", ref)
-
-			if pattern != "" {
-				if !strings.HasSuffix(ref, pattern) {
-					// Given pattern is not applicable to this reference.
-					log.Printf("ref %s doesn't end on pattern %s", ref, pattern)
-					return nil
-				}
-
-				// Find the starting point of the pattern.
-				li := strings.LastIndex(ref, pattern)
-				if li != 0 && ref[li-1] != byte('/') {
-					// Given pattern doesn't match this reference.
-					log.Printf("ref %s doesn't have a separator at index %d\n", ref, li-1)
-					return nil
-				}
+	for _, ref := range names {
+		log.Printf("Working on ref: %s\n", ref)
+
+		if pattern != "" {
+			if !strings.HasSuffix(ref, pattern) {
+				// Given pattern is not applicable to this reference.
+				log.Printf("ref %s doesn't end on pattern %s", ref, pattern)
+				continue
 			}
 
-			// This is a valid reference. It either matched the pattern or
-			// a pattern is not provided.
-			// Get the relative path from the .git directory.
-			log.Printf("Found %s as a valid reference\n", ref)
-			refHash, _, err := r.RefResolve(ref)
-			if err != nil {
-				return err
+			// Find the starting point of the pattern.
+			li := strings.LastIndex(ref, pattern)
+			if li != 0 && ref[li-1] != byte('/') {
+				// Given pattern doesn't match this reference.
+				log.Printf("ref %s doesn't have a separator at index %d\n", ref, li-1)
+				continue
 			}
+		}
 
-			refs = append(refs, RefEntry{ref, refHash})
+		// This is a valid reference. It either matched the pattern or
+		// a pattern is not provided.
+		log.Printf("Found %s as a valid reference\n", ref)
+		refHash, _, err := r.RefResolve(ref)
+		if err != nil {
+			return nil, err
 		}
 
-		return nil
+		refs = append(refs, RefEntry{ref, refHash})
 	}
 
-	err = filepath.Walk(refDir, walkFunc)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get HEAD ref if asked for
+	// Get HEAD ref if asked for. An unborn HEAD doesn't point to any commit
+	// yet, so it is skipped.
 	if getHead {
 		headHash, _, err := r.RefResolve("HEAD")
-		if err != nil {
+		if err != nil && !os.IsNotExist(err) {
 			return nil, err
 		}
 
-		log.Println("Found valid HEAD reference for HEAD")
-		refs = append(refs, RefEntry{"HEAD", headHash})
+		if err == nil {
+			log.Println("Found valid HEAD reference for HEAD")
+			refs = append(refs, RefEntry{"HEAD", headHash})
+		}
 	}
 
 	// Sort the entries (git keeps them sorted for display)
@@ -429,27 +780,13 @@ func (r *Repo) NameResolve(name string) ([]string, error) {
 
 	// If reached here, then 'name' may be a valid short hash matching one or
 	// more full hashes. Collect them all by looking at all files inside '.git/objects'.
-	objectsPath, err := r.DirPath(true, "objects", name[0:2])
-	if err != nil {
-		log.Printf("Objects path not found for name %s (%v)", name, err)
-		return matches, nil
-	}
-
-	// Read all files under this directory and collect all files matching the
-	// remaining hash (after first 2 char).
-	files, err := ioutil.ReadDir(objectsPath)
+	hashes, err := r.objectStore().FindObjects(name)
 	if err != nil {
-		log.Printf("Objects path dir %s access error (%v)", objectsPath, err)
+		log.Printf("Objects not found for name %s (%v)", name, err)
 		return matches, nil
 	}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasPrefix(file.Name(), name[2:]) {
-			matches = append(matches, name[0:2]+file.Name())
-		}
-	}
-
-	return matches, nil
+	return append(matches, hashes...), nil
 }
 
 // UniqueNameResolve converts a given name to a unique valid full object hash.
@@ -461,6 +798,22 @@ func (r *Repo) UniqueNameResolve(name string) (string, error) {
 	errmsg := fmt.Sprintf("fatal: ambiguous argument '%s': unknown revision or "+
 		"path not in the working tree", name)
 
+	// "<revision>:<path>" names the object at a path inside the tree of the
+	// given revision.
+	if ind := strings.IndexByte(name, ':'); ind > 0 {
+		treeHash, err := r.TreeResolve(name[:ind])
+		if err != nil {
+			return "", err
+		}
+
+		objHash, err := r.PathResolve(treeHash, name[ind+1:])
+		if err != nil {
+			return "", fmt.Errorf("fatal: path '%s' does not exist in '%s'",
+				name[ind+1:], name[:ind])
+		}
+		return objHash, nil
+	}
+
 	matches, err := r.NameResolve(name)
 	if err != nil || len(matches) == 0 {
 		log.Printf("Failed to convert name %s to object hash or no matches "+
@@ -514,31 +867,162 @@ func (r *Repo) UpdateRef(ref string, newValue string) error {
 		return fmt.Errorf("fatal: '{%s}' - not a valid SHA1", newValue)
 	}
 
-	// If the 'ref' file is not present, then create it first.
-	refFile, _ := r.FilePath(true, ref)
-	if !util.IsPathPresent(refFile) {
-		fd, err := os.Create(refFile)
-		if err != nil {
-			return err
+	// Follow the symbolic references to the reference to write, which may
+	// not exist yet (such as the branch of an unborn HEAD).
+	refPath := ref
+	for {
+		value, err := r.refStore().ReadRef(refPath)
+		if err != nil || !strings.HasPrefix(value, "ref: ") {
+			break
 		}
-		fd.Close()
+		refPath = strings.TrimSpace(value[len("ref: "):])
 	}
 
-	// Resolve the given reference to its hash and path.
-	refHash, refPath, err := r.RefResolve(ref)
+	log.Printf("UpdateRef - refPath: %q ref: %q newValueHash: %q\n",
+		refPath, ref, newValueHash)
+
+	return r.refStore().WriteRef(refPath, newValueHash)
+}
+
+// TreeResolve resolves a given name to the hash of a tree object. If the name
+// refers to a commit (or a tag of a commit), then the tree of that commit is
+// returned.
+func (r *Repo) TreeResolve(name string) (string, error) {
+	objHash, err := r.UniqueNameResolve(name)
 	if err != nil {
-		log.Printf("RefResolve failed with err: %v", err)
-		return fmt.Errorf("fatal: '{%s}' - not a valid ref", ref)
+		return "", err
 	}
 
-	log.Printf("UpdateRef - refHash: %q refPath: %q ref: %q newValueHash: %q\n",
-		refHash, refPath, ref, newValueHash)
+	obj, objHash, err := r.PeelObject(objHash)
+	if err != nil {
+		return "", err
+	}
 
-	refFile, _ = r.FilePath(false, refPath)
-	err = ioutil.WriteFile(refFile, []byte(newValueHash+"\n"), 0644)
+	switch obj.ObjType {
+	case "tree":
+		return objHash, nil
+	case "commit":
+		commit, err := NewCommit(r, obj)
+		if err != nil {
+			return "", err
+		}
+		return commit.TreeHash(), nil
+	}
+
+	return "", fmt.Errorf("fatal: not a tree object: %s", name)
+}
+
+// PathResolve finds the hash of the object at a given path inside a tree. An
+// empty path gives the tree itself.
+func (r *Repo) PathResolve(treeHash, path string) (string, error) {
+	objHash := treeHash
+	for _, name := range strings.Split(path, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+
+		entries, err := r.treeEntryMap(objHash)
+		if err != nil {
+			return "", err
+		}
+		entry, ok := entries[name]
+		if !ok {
+			return "", fmt.Errorf("fatal: path '%s' does not exist", path)
+		}
+		objHash = entry.hash
+	}
+
+	return objHash, nil
+}
+
+// Head returns the branch reference which HEAD points to, along with the
+// commit hash of HEAD. The reference is empty if HEAD is detached, and the
+// hash is empty if the branch doesn't have any commits yet.
+func (r *Repo) Head() (string, string, error) {
+	head, err := r.refStore().ReadRef("HEAD")
 	if err != nil {
-		return err
+		return "", "", err
+	}
+
+	head = strings.TrimSpace(head)
+	if !strings.HasPrefix(head, "ref: ") {
+		return "", head, nil
+	}
+
+	branch := strings.TrimPrefix(head, "ref: ")
+	hash, _, err := r.RefResolve(branch)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+	return branch, hash, nil
+}
+
+// SetHead points HEAD to a branch reference (such as "refs/heads/master"), or
+// detaches it at a commit if a hash is given.
+func (r *Repo) SetHead(target string) error {
+	if strings.HasPrefix(target, "refs/") {
+		target = "ref: " + target
+	}
+	return r.refStore().WriteRef("HEAD", target)
+}
+
+// WriteOrigHead records a commit in ORIG_HEAD, which is the value of HEAD
+// before a command such as "reset" or "merge" moves it.
+func (r *Repo) WriteOrigHead(commitHash string) error {
+	return r.refStore().WriteRef("ORIG_HEAD", commitHash)
+}
+
+// mergeStateRefs are the pseudo-refs which record a merge, a cherry-pick or a
+// revert in progress. They are kept in the store of the references.
+var mergeStateRefs = []string{
+	"MERGE_HEAD", "AUTO_MERGE", "CHERRY_PICK_HEAD", "REVERT_HEAD",
+}
+
+// mergeStateFiles are the other files of a merge in progress, such as its
+// commit message. They need a git directory, so a repo without one (see
+// NewMemoryRepo) never has them.
+var mergeStateFiles = []string{
+	"MERGE_MSG", "MERGE_MODE", "MERGE_RR", "SQUASH_MSG",
+}
+
+// ClearMergeState removes the state of a merge, a cherry-pick or a revert in
+// progress, if any.
+func (r *Repo) ClearMergeState() error {
+	for _, name := range mergeStateRefs {
+		if err := r.refStore().DeleteRef(name); err != nil {
+			return err
+		}
+	}
+	if r.GitDir == "" {
+		return nil
 	}
 
+	for _, name := range mergeStateFiles {
+		stateFile, err := r.FilePath(false, name)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(stateFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return nil
 }
+
+// Upstream returns the reference of the branch which a local branch (such as
+// "refs/heads/master") tracks, as per the "branch.<name>.remote" and
+// "branch.<name>.merge" configuration. It is empty if nothing is tracked.
+func (r *Repo) Upstream(branch string, config *Config) string {
+	name := strings.TrimPrefix(branch, "refs/heads/")
+	remote, _ := config.Get("branch." + name + ".remote")
+	merge, _ := config.Get("branch." + name + ".merge")
+	if remote == "" || !strings.HasPrefix(merge, "refs/heads/") {
+		return ""
+	}
+
+	// A remote named "." is the local repository itself.
+	if remote == "." {
+		return merge
+	}
+	return "refs/remotes/" + remote + "/" + strings.TrimPrefix(merge, "refs/heads/")
+}
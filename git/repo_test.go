@@ -108,7 +108,7 @@ func setupTestArtifacts() error {
 	return nil
 }
 
-func TestRepo(t *testing.T) {
+func TestMain(m *testing.M) {
 	// Disable internal logs during test runs unless an ENV var is given.
 	if os.Getenv("GOGIT_DBG") != "1" {
 		log.SetOutput(ioutil.Discard)
@@ -117,6 +117,10 @@ func TestRepo(t *testing.T) {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 	}
 
+	os.Exit(m.Run())
+}
+
+func TestRepo(t *testing.T) {
 	// Set up a git repo and create few objects in it for testing.
 	err := setupTestArtifacts()
 	defer os.RemoveAll(repoDir)
@@ -141,11 +145,20 @@ func TestRepo(t *testing.T) {
 		assertEqual(t, util.IsPathPresent(filepath.Join(gitDir, "refs", "heads")), true)
 	})
 
-	// Validate that a repo creation in a non-emptry dir fails.
-	t.Run("Validate non-empty repository creation failure", func(t *testing.T) {
-		_, err := NewRepo(repoDir)
-		want := fmt.Sprintf("Work-tree %q is not empty", repoDir)
-		assertEqual(t, err.Error(), want)
+	// Validate that running init again keeps the existing repo as it is.
+	t.Run("Validate reinitializing a repository", func(t *testing.T) {
+		headFile := filepath.Join(repoDir, ".git", "HEAD")
+		head, err := ioutil.ReadFile(headFile)
+		assertEqual(t, err, nil)
+
+		again, reinit, err := InitRepo(repoDir, &InitOptions{InitialBranch: "other"})
+		assertEqual(t, err, nil)
+		assertEqual(t, reinit, true)
+		assertEqual(t, again.GitDir, repo.GitDir)
+		got, err := ioutil.ReadFile(headFile)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(got), string(head))
+		assertEqual(t, util.IsPathPresent(filepath.Join(repoDir, testFile)), true)
 	})
 
 	// Validate the blob hash from hash-object operation.
@@ -385,3 +398,194 @@ func TestRepo(t *testing.T) {
 		assertEqual(t, err, want)
 	})
 }
+
+func TestBareRepo(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testGoGitBare")
+	assertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	t.Run("Validate bare repository", func(t *testing.T) {
+		bare, err := NewBareRepo(dir)
+		assertEqual(t, err, nil)
+		assertEqual(t, bare.IsBare(), true)
+		assertEqual(t, bare.RequireWorkTree(), ErrNoWorkTree)
+		assertEqual(t, util.IsPathPresent(filepath.Join(dir, ".git")), false)
+		assertEqual(t, util.IsPathPresent(filepath.Join(dir, "HEAD")), true)
+		assertEqual(t, util.IsPathPresent(filepath.Join(dir, "objects")), true)
+
+		config, err := bare.Config()
+		assertEqual(t, err, nil)
+		assertEqual(t, config.GetBool("core.bare", false), true)
+
+		_, reinit, err := InitRepo(dir, &InitOptions{Bare: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, reinit, true)
+	})
+
+	t.Run("Validate finding a bare repository", func(t *testing.T) {
+		found, err := GetRepo(filepath.Join(dir, "refs", "heads"))
+		assertEqual(t, err, nil)
+		assertEqual(t, found.GitDir, dir)
+		assertEqual(t, found.WorkTree, "")
+
+		// The layout is enough, without the configuration.
+		assertEqual(t, os.Remove(filepath.Join(dir, "config")), nil)
+		found, err = GetRepo(dir)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.IsBare(), true)
+
+		_, _, err = found.ReadWorktreeFile("a")
+		assertEqual(t, err, ErrNoWorkTree)
+	})
+
+	// Like "git", the work-tree is not known inside the ".git" directory.
+	t.Run("Validate the git directory of a repository", func(t *testing.T) {
+		nonBare, err := NewRepo(filepath.Join(dir, "nonbare"))
+		assertEqual(t, err, nil)
+		found, err := GetRepo(filepath.Join(nonBare.GitDir, "refs"))
+		assertEqual(t, err, nil)
+		assertEqual(t, found.GitDir, nonBare.GitDir)
+		assertEqual(t, found.IsBare(), true)
+
+		found, err = GetRepo(nonBare.WorkTree)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.WorkTree, nonBare.WorkTree)
+	})
+}
+
+func TestInitRepo(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testGoGitInit")
+	assertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	t.Run("Validate the initial branch", func(t *testing.T) {
+		path := filepath.Join(dir, "branch")
+		assertEqual(t, os.Mkdir(path, 0755), nil)
+		assertEqual(t, ioutil.WriteFile(filepath.Join(path, "a"), []byte("a\n"), 0644), nil)
+
+		repo, reinit, err := InitRepo(path, &InitOptions{InitialBranch: "main"})
+		assertEqual(t, err, nil)
+		assertEqual(t, reinit, false)
+		head, err := ioutil.ReadFile(filepath.Join(repo.GitDir, "HEAD"))
+		assertEqual(t, err, nil)
+		assertEqual(t, string(head), "ref: refs/heads/main\n")
+		assertEqual(t, util.IsPathPresent(filepath.Join(repo.GitDir, "refs", "heads", "main")), false)
+
+		for _, branch := range []string{"a..b", "a b", "x.lock", "x/", ".a"} {
+			_, _, err := InitRepo(filepath.Join(dir, "bad"), &InitOptions{InitialBranch: branch})
+			assertEqual(t, err.Error(), fmt.Sprintf("fatal: invalid initial branch name: '%s'", branch))
+		}
+	})
+
+	t.Run("Validate the first commit of an unborn branch", func(t *testing.T) {
+		repo, err := NewRepo(filepath.Join(dir, "unborn"))
+		assertEqual(t, err, nil)
+		tree := writeTestTree(t, repo, map[string]string{"a": "a\n"})
+		commit := writeTestCommit(t, repo, tree)
+		assertEqual(t, repo.UpdateRef("HEAD", commit), nil)
+
+		hash, refPath, err := repo.RefResolve("HEAD")
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, commit)
+		assertEqual(t, refPath, "refs/heads/master")
+	})
+
+	t.Run("Validate copying a template", func(t *testing.T) {
+		template := filepath.Join(dir, "template")
+		assertEqual(t, os.MkdirAll(filepath.Join(template, "hooks"), 0755), nil)
+		assertEqual(t, os.MkdirAll(filepath.Join(template, "info"), 0755), nil)
+		hook := filepath.Join(template, "hooks", "pre-commit")
+		assertEqual(t, ioutil.WriteFile(hook, []byte("#!/bin/sh\n"), 0755), nil)
+		exclude := filepath.Join(template, "info", "exclude")
+		assertEqual(t, ioutil.WriteFile(exclude, []byte("*.o\n"), 0644), nil)
+		hidden := filepath.Join(template, ".hidden")
+		assertEqual(t, ioutil.WriteFile(hidden, []byte("x\n"), 0644), nil)
+
+		repo, _, err := InitRepo(filepath.Join(dir, "templated"), &InitOptions{Template: template})
+		assertEqual(t, err, nil)
+		info, err := os.Stat(filepath.Join(repo.GitDir, "hooks", "pre-commit"))
+		assertEqual(t, err, nil)
+		assertEqual(t, info.Mode().Perm()&0100 != 0, true)
+		data, err := ioutil.ReadFile(filepath.Join(repo.GitDir, "info", "exclude"))
+		assertEqual(t, err, nil)
+		assertEqual(t, string(data), "*.o\n")
+		assertEqual(t, util.IsPathPresent(filepath.Join(repo.GitDir, ".hidden")), false)
+
+		// The existing files are kept on reinitializing the repo.
+		localExclude := filepath.Join(repo.GitDir, "info", "exclude")
+		assertEqual(t, ioutil.WriteFile(localExclude, []byte("*.a\n"), 0644), nil)
+		_, _, err = InitRepo(repo.WorkTree, &InitOptions{Template: template})
+		assertEqual(t, err, nil)
+		data, err = ioutil.ReadFile(localExclude)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(data), "*.a\n")
+	})
+}
+
+func TestGetRepo(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testGoGitGetRepo")
+	assertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	mainRepo, err := NewRepo(filepath.Join(dir, "main"))
+	assertEqual(t, err, nil)
+	deep := filepath.Join(mainRepo.WorkTree, "a", "b")
+	assertEqual(t, os.MkdirAll(deep, 0755), nil)
+
+	t.Run("Validate a gitfile", func(t *testing.T) {
+		linked := filepath.Join(dir, "linked")
+		assertEqual(t, os.Mkdir(linked, 0755), nil)
+		gitFile := filepath.Join(linked, ".git")
+		assertEqual(t, ioutil.WriteFile(gitFile, []byte("gitdir: ../main/.git\n"), 0644), nil)
+
+		found, err := GetRepo(linked)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.GitDir, mainRepo.GitDir)
+		assertEqual(t, found.WorkTree, linked)
+
+		assertEqual(t, ioutil.WriteFile(gitFile, []byte("junk\n"), 0644), nil)
+		_, err = GetRepo(linked)
+		assertEqual(t, err.Error(), "fatal: invalid gitfile format: "+gitFile)
+
+		assertEqual(t, ioutil.WriteFile(gitFile, []byte("gitdir: nope\n"), 0644), nil)
+		_, err = GetRepo(linked)
+		assertEqual(t, err.Error(), "fatal: not a git repository: "+filepath.Join(linked, "nope"))
+	})
+
+	t.Run("Validate the environment variables", func(t *testing.T) {
+		defer os.Unsetenv("GIT_DIR")
+		defer os.Unsetenv("GIT_WORK_TREE")
+
+		os.Setenv("GIT_WORK_TREE", deep)
+		found, err := GetRepo(mainRepo.WorkTree)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.GitDir, mainRepo.GitDir)
+		assertEqual(t, found.WorkTree, deep)
+
+		os.Setenv("GIT_DIR", mainRepo.GitDir)
+		found, err = GetRepo(dir)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.GitDir, mainRepo.GitDir)
+		assertEqual(t, found.WorkTree, deep)
+
+		os.Setenv("GIT_DIR", dir)
+		_, err = GetRepo(".")
+		assertEqual(t, err.Error(), fmt.Sprintf("fatal: not a git repository: '%s'", dir))
+	})
+
+	t.Run("Validate the ceiling directories", func(t *testing.T) {
+		defer os.Unsetenv("GIT_CEILING_DIRECTORIES")
+
+		os.Setenv("GIT_CEILING_DIRECTORIES", "relative"+string(filepath.ListSeparator)+
+			filepath.Join(mainRepo.WorkTree, "a"))
+		_, err := GetRepo(deep)
+		assertEqual(t, err != nil, true)
+
+		// The search starts from a ceiling directory itself.
+		os.Setenv("GIT_CEILING_DIRECTORIES", deep)
+		found, err := GetRepo(deep)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.WorkTree, mainRepo.WorkTree)
+	})
+}
@@ -0,0 +1,45 @@
+package git
+
+// ResetIndex sets the index entries of the paths selected by a pathspec to
+// the files of a tree, which is empty if the hash is empty. The paths which
+// are not in the tree are removed from the index. All the paths are reset if
+// the pathspec is nil. The stat data of the unchanged entries is kept, so
+// that their work-tree files are still known to be clean.
+func (r *Repo) ResetIndex(treeHash string, pathspec *Pathspec) error {
+	index, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	files, err := r.treeFileMap(treeHash)
+	if err != nil {
+		return err
+	}
+
+	// Remove the selected entries which are not in the tree, along with
+	// the conflict stages of all the selected paths.
+	entries := []*IndexEntry{}
+	for _, entry := range index.Entries {
+		if !pathspec.Match(entry.Path) {
+			entries = append(entries, entry)
+			continue
+		}
+		file, ok := files[entry.Path]
+		if ok && entry.Stage() == 0 && sameFile(entry.FileEntry(), file) {
+			entries = append(entries, entry)
+		}
+	}
+	index.Entries = entries
+
+	for filePath, file := range files {
+		if !pathspec.Match(filePath) || index.Entry(filePath, 0) != nil {
+			continue
+		}
+		entry, err := NewIndexEntry(filePath, file.Mode, file.Hash)
+		if err != nil {
+			return err
+		}
+		index.Add(entry)
+	}
+
+	return r.WriteIndex(index)
+}
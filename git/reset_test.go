@@ -0,0 +1,47 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestResetIndex(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitReset")
+
+	tree1 := writeTestTree(t, repo, map[string]string{
+		"a": "a\n", "d/x": "x\n", "d/y": "y\n",
+	})
+	tree2 := writeTestTree(t, repo, map[string]string{
+		"a": "a2\n", "d/x": "x2\n", "n": "new\n",
+	})
+	assertEqual(t, repo.CheckoutTree("", tree1, &CheckoutOptions{Command: "reset"}), nil)
+
+	indexFiles := func() map[string]string {
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		files := map[string]string{}
+		for _, file := range index.Files() {
+			files[file.Path] = file.Hash
+		}
+		return files
+	}
+	files1 := indexFiles()
+
+	t.Run("Validate reset of the selected paths", func(t *testing.T) {
+		assertEqual(t, repo.ResetIndex(tree2, NewPathspec([]string{"d"})), nil)
+		files := indexFiles()
+		assertEqual(t, files["a"], files1["a"])
+		assertEqual(t, files["d/x"] != files1["d/x"], true)
+		_, ok := files["d/y"]
+		assertEqual(t, ok, false)
+		_, ok = files["n"]
+		assertEqual(t, ok, false)
+
+		// The work-tree is not changed.
+		assertEqual(t, readTestFile(t, repo, "d/y"), "y\n")
+	})
+
+	t.Run("Validate reset of all the paths", func(t *testing.T) {
+		assertEqual(t, repo.ResetIndex(tree1, nil), nil)
+		assertEqual(t, indexFiles(), files1)
+	})
+}
@@ -0,0 +1,156 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrPathspecUnmatched tells that some patterns of a pathspec didn't match
+// any path. They are given by Pathspec.Unmatched.
+var ErrPathspecUnmatched = errors.New("error: pathspec did not match any file(s) known to git")
+
+// RestoreOptions lists the choices for restoring the paths of a pathspec.
+type RestoreOptions struct {
+	// Source is the hash of the tree to restore the paths from. It is used
+	// only if FromIndex is not set, and is an empty tree if empty.
+	Source string
+	// FromIndex restores the work-tree files from the index.
+	FromIndex bool
+	// Staged restores the index entries, and Worktree restores the files.
+	Staged   bool
+	Worktree bool
+	// Overlay keeps the paths which are not in the source. Otherwise they
+	// are removed.
+	Overlay bool
+}
+
+// Restore sets the index entries and/or the work-tree files of the paths
+// selected by a pathspec to their content in a source, such as a tree or the
+// index. It returns the number of files written to the work-tree. Nothing is
+// changed if any of the patterns don't match a path, in which case
+// ErrPathspecUnmatched is returned.
+func (r *Repo) Restore(pathspec *Pathspec, opts *RestoreOptions) (int, error) {
+	index, err := r.ReadIndex()
+	if err != nil {
+		return 0, err
+	}
+	fs, err := r.FSConfig()
+	if err != nil {
+		return 0, err
+	}
+
+	files := map[string]FileEntry{}
+	if opts.FromIndex {
+		for _, file := range index.Files() {
+			files[file.Path] = file
+		}
+	} else if files, err = r.treeFileMap(opts.Source); err != nil {
+		return 0, err
+	}
+
+	// The paths of the index are candidates too, unless the paths which
+	// are not in the source are kept anyway.
+	seen := map[string]bool{}
+	paths := []string{}
+	for filePath := range files {
+		seen[filePath] = true
+	}
+	if opts.FromIndex || !opts.Overlay {
+		for _, entry := range index.Entries {
+			seen[entry.Path] = true
+		}
+	}
+	for filePath := range seen {
+		if pathspec.Match(filePath) {
+			paths = append(paths, filePath)
+		}
+	}
+	sort.Strings(paths)
+	if len(pathspec.Unmatched()) > 0 {
+		return 0, ErrPathspecUnmatched
+	}
+
+	if opts.FromIndex {
+		unmerged := []string{}
+		for _, filePath := range paths {
+			if index.Entry(filePath, 0) == nil {
+				unmerged = append(unmerged, fmt.Sprintf("error: path '%s' is unmerged",
+					filePath))
+			}
+		}
+		if len(unmerged) > 0 {
+			return 0, errors.New(strings.Join(unmerged, "\n"))
+		}
+	}
+
+	written := 0
+	for _, filePath := range paths {
+		file, ok := files[filePath]
+		if opts.Staged {
+			if err := restoreEntry(index, filePath, file, ok); err != nil {
+				return 0, err
+			}
+		}
+		if !opts.Worktree {
+			continue
+		}
+
+		if !ok {
+			if err := r.removeWorktreeFile(filePath); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		// A clean file of the same content is not rewritten, and the stat
+		// data of a rewritten one is recorded.
+		entry := index.Entry(filePath, 0)
+		if entry != nil && !sameFile(entry.FileEntry(), file) {
+			entry = nil
+		}
+		if entry != nil {
+			clean, err := r.worktreeClean(index, entry, fs)
+			if err != nil {
+				return 0, err
+			}
+			if clean {
+				continue
+			}
+		}
+
+		info, err := r.writeWorktreeFile(file, fs)
+		if err != nil {
+			return 0, err
+		}
+		written++
+		if entry != nil {
+			entry.SetStat(info)
+		}
+	}
+
+	return written, r.WriteIndex(index)
+}
+
+// restoreEntry sets the index entry of a path to a file, or removes it if the
+// file is not present in the source. An unchanged entry is kept as is, along
+// with its stat data.
+func restoreEntry(index *Index, filePath string, file FileEntry, present bool) error {
+	entry := index.Entry(filePath, 0)
+	if present && entry != nil && sameFile(entry.FileEntry(), file) {
+		return nil
+	}
+
+	// Remove all the stages of the path.
+	index.Remove(filePath)
+	if !present {
+		return nil
+	}
+	entry, err := NewIndexEntry(filePath, file.Mode, file.Hash)
+	if err != nil {
+		return err
+	}
+	index.Add(entry)
+	return nil
+}
@@ -0,0 +1,57 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitRestore")
+
+	tree1 := writeTestTree(t, repo, map[string]string{
+		"a": "a\n", "d/x": "x\n", "d/y": "y\n",
+	})
+	tree2 := writeTestTree(t, repo, map[string]string{
+		"a": "a2\n", "d/x": "x2\n",
+	})
+	assertEqual(t, repo.CheckoutTree("", tree1, &CheckoutOptions{Command: "checkout"}), nil)
+
+	t.Run("Validate restore from the index", func(t *testing.T) {
+		writeTestFile(t, repo, "a", "local\n")
+		writeTestFile(t, repo, "d/x", "local\n")
+		written, err := repo.Restore(NewPathspec([]string{"a"}),
+			&RestoreOptions{FromIndex: true, Worktree: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, written, 1)
+		assertEqual(t, readTestFile(t, repo, "a"), "a\n")
+		assertEqual(t, readTestFile(t, repo, "d/x"), "local\n")
+	})
+
+	t.Run("Validate unmatched pathspec", func(t *testing.T) {
+		ps := NewPathspec([]string{"d/*", "nope"})
+		_, err := repo.Restore(ps, &RestoreOptions{FromIndex: true, Worktree: true})
+		assertEqual(t, err, ErrPathspecUnmatched)
+		assertEqual(t, ps.Unmatched(), []int{1})
+		assertEqual(t, readTestFile(t, repo, "d/x"), "local\n")
+	})
+
+	t.Run("Validate overlay restore from a tree", func(t *testing.T) {
+		_, err := repo.Restore(NewPathspec([]string{"d"}),
+			&RestoreOptions{Source: tree2, Staged: true, Worktree: true, Overlay: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, readTestFile(t, repo, "d/x"), "x2\n")
+		assertEqual(t, readTestFile(t, repo, "d/y"), "y\n")
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		assertEqual(t, index.Entry("d/y", 0) != nil, true)
+	})
+
+	t.Run("Validate restore removing the paths not in the tree", func(t *testing.T) {
+		_, err := repo.Restore(NewPathspec([]string{"d"}),
+			&RestoreOptions{Source: tree2, Worktree: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, readTestFile(t, repo, "d/y"), "")
+		index, err := repo.ReadIndex()
+		assertEqual(t, err, nil)
+		assertEqual(t, index.Entry("d/y", 0) != nil, true)
+	})
+}
@@ -0,0 +1,59 @@
+package git
+
+// Ancestors returns the hashes of all the commits reachable from a commit
+// through any of the parents, including the commit itself.
+func (r *Repo) Ancestors(commitHash string) (map[string]bool, error) {
+	ancestors := map[string]bool{}
+	pending := []string{commitHash}
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if ancestors[hash] {
+			continue
+		}
+		ancestors[hash] = true
+
+		commit, err := r.commitParse(hash)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, commit.Parents()...)
+	}
+
+	return ancestors, nil
+}
+
+// commitParse parses the commit object with the given hash.
+func (r *Repo) commitParse(commitHash string) (*Commit, error) {
+	obj, err := r.ObjectParse(commitHash)
+	if err != nil {
+		return nil, err
+	}
+	return NewCommit(r, obj)
+}
+
+// AheadBehind counts the commits which are reachable from 'local' but not
+// from 'upstream', and the ones reachable from 'upstream' but not 'local'.
+func (r *Repo) AheadBehind(local, upstream string) (int, int, error) {
+	localCommits, err := r.Ancestors(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	upstreamCommits, err := r.Ancestors(upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ahead, behind := 0, 0
+	for hash := range localCommits {
+		if !upstreamCommits[hash] {
+			ahead++
+		}
+	}
+	for hash := range upstreamCommits {
+		if !localCommits[hash] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
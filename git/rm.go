@@ -0,0 +1,186 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RemoveOptions lists the choices for removing the paths of a pathspec from
+// the index and the work-tree.
+type RemoveOptions struct {
+	// Cached removes the paths from the index only, keeping the files.
+	Cached bool
+	// Recursive allows a pattern to select the files inside a directory.
+	Recursive bool
+	// Force removes the files even if they have local changes.
+	Force bool
+	// DryRun finds the paths to remove without removing them.
+	DryRun bool
+}
+
+// RemoveError lists the files whose local changes would be lost by removing
+// them.
+type RemoveError struct {
+	// Both are the files whose index entries differ from both the file and
+	// HEAD.
+	Both []string
+	// Staged are the files whose index entries differ from HEAD.
+	Staged []string
+	// Changed are the files which differ from their index entries.
+	Changed []string
+}
+
+// Error returns the messages shown by "git" when it refuses to remove files.
+func (e *RemoveError) Error() string {
+	lines := []string{}
+	list := func(paths []string, one, many, hint string) {
+		if len(paths) == 0 {
+			return
+		}
+		if len(paths) == 1 {
+			lines = append(lines, "error: the following file "+one)
+		} else {
+			lines = append(lines, "error: the following files "+many)
+		}
+		for _, filePath := range paths {
+			lines = append(lines, "    "+filePath)
+		}
+		lines = append(lines, hint)
+	}
+
+	list(e.Both, "has staged content different from both the\nfile and the HEAD:",
+		"have staged content different from both the\nfile and the HEAD:",
+		"(use -f to force removal)")
+	list(e.Staged, "has changes staged in the index:",
+		"have changes staged in the index:",
+		"(use --cached to keep the file, or -f to force removal)")
+	list(e.Changed, "has local modifications:", "have local modifications:",
+		"(use --cached to keep the file, or -f to force removal)")
+	return strings.Join(lines, "\n")
+}
+
+// RemovePaths removes the files selected by a pathspec from the index, and
+// from the work-tree unless only the index is asked for. Nothing is removed
+// if some pattern doesn't match a file of the index (ErrPathspecUnmatched),
+// or if some file has local changes which would be lost (*RemoveError). It
+// returns the paths of the removed files.
+func (r *Repo) RemovePaths(pathspec *Pathspec, opts *RemoveOptions) ([]string, error) {
+	index, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	// A pattern selecting just the files inside a directory needs the
+	// recursive option.
+	paths := []string{}
+	exact := make([]bool, len(pathspec.patterns))
+	for i, entry := range index.Entries {
+		if i > 0 && index.Entries[i-1].Path == entry.Path {
+			continue
+		}
+		if !pathspec.Match(entry.Path) {
+			continue
+		}
+		paths = append(paths, entry.Path)
+		for j, pattern := range pathspec.patterns {
+			if pattern == entry.Path || (hasGlob(pattern) && matchPattern(pattern, entry.Path)) {
+				exact[j] = true
+			}
+		}
+	}
+	if unmatched := pathspec.Unmatched(); len(unmatched) > 0 {
+		return nil, ErrPathspecUnmatched
+	}
+	if !opts.Recursive {
+		for j, pattern := range pathspec.patterns {
+			if !exact[j] {
+				if pattern == "" {
+					pattern = "."
+				}
+				return nil, fmt.Errorf("fatal: not removing '%s' recursively without -r",
+					pattern)
+			}
+		}
+	}
+
+	if !opts.Force {
+		if err := r.checkRemove(index, paths, opts.Cached); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DryRun {
+		return paths, nil
+	}
+
+	for _, filePath := range paths {
+		index.Remove(filePath)
+	}
+	if err := r.WriteIndex(index); err != nil {
+		return nil, err
+	}
+	if !opts.Cached {
+		for _, filePath := range paths {
+			if err := r.removeWorktreeFile(filePath); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return paths, nil
+}
+
+// checkRemove finds the files whose local changes would be lost by removing
+// them. Only the changes which are not in the work-tree file matter if the
+// file is kept.
+func (r *Repo) checkRemove(index *Index, paths []string, cached bool) error {
+	fs, err := r.FSConfig()
+	if err != nil {
+		return err
+	}
+	_, headHash, err := r.Head()
+	if err != nil {
+		return err
+	}
+	headFiles := map[string]FileEntry{}
+	if headHash != "" {
+		headTree, err := r.TreeResolve(headHash)
+		if err != nil {
+			return err
+		}
+		if headFiles, err = r.treeFileMap(headTree); err != nil {
+			return err
+		}
+	}
+
+	removeErr := &RemoveError{}
+	for _, filePath := range paths {
+		// An unmerged path is removed as a whole.
+		entry := index.Entry(filePath, 0)
+		if entry == nil {
+			continue
+		}
+		headFile, ok := headFiles[filePath]
+		staged := !ok || !sameFile(headFile, entry.FileEntry())
+		changed := false
+		if r.worktreePresent(filePath) {
+			clean, err := r.worktreeClean(index, entry, fs)
+			if err != nil {
+				return err
+			}
+			changed = !clean
+		}
+
+		switch {
+		case staged && changed:
+			removeErr.Both = append(removeErr.Both, filePath)
+		case cached:
+		case staged:
+			removeErr.Staged = append(removeErr.Staged, filePath)
+		case changed:
+			removeErr.Changed = append(removeErr.Changed, filePath)
+		}
+	}
+	if len(removeErr.Both)+len(removeErr.Staged)+len(removeErr.Changed) > 0 {
+		return removeErr
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemovePaths(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitRm")
+
+	commitTestFiles(t, repo, map[string]string{
+		"a": "a\n", "b": "b\n", "c": "c\n", "d/e": "e\n", "d/f/g": "g\n",
+	})
+
+	// "a" has local changes, "b" has staged changes, and "c" has both.
+	writeTestFile(t, repo, "a", "a2\n")
+	writeTestFile(t, repo, "b", "b2\n")
+	stageTestFile(t, repo, "b")
+	writeTestFile(t, repo, "c", "c2\n")
+	stageTestFile(t, repo, "c")
+	writeTestFile(t, repo, "c", "c3\n")
+
+	t.Run("Validate refusing to lose the changes", func(t *testing.T) {
+		_, err := repo.RemovePaths(NewPathspec([]string{"a", "b", "c"}), &RemoveOptions{})
+		assertEqual(t, err, &RemoveError{Both: []string{"c"}, Staged: []string{"b"},
+			Changed: []string{"a"}})
+
+		opts := &RemoveOptions{Cached: true}
+		_, err = repo.RemovePaths(NewPathspec([]string{"a", "b", "c"}), opts)
+		assertEqual(t, err, &RemoveError{Both: []string{"c"}})
+		assertEqual(t, testIndexPaths(t, repo), []string{"a", "b", "c", "d/e", "d/f/g"})
+	})
+
+	t.Run("Validate the patterns to remove", func(t *testing.T) {
+		ps := NewPathspec([]string{"a", "nope"})
+		_, err := repo.RemovePaths(ps, &RemoveOptions{})
+		assertEqual(t, err, ErrPathspecUnmatched)
+		assertEqual(t, ps.Unmatched(), []int{1})
+
+		_, err = repo.RemovePaths(NewPathspec([]string{"d"}), &RemoveOptions{})
+		assertEqual(t, err.Error(), "fatal: not removing 'd' recursively without -r")
+
+		paths, err := repo.RemovePaths(NewPathspec([]string{"d/*"}), &RemoveOptions{DryRun: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, paths, []string{"d/e", "d/f/g"})
+	})
+
+	t.Run("Validate removing the paths", func(t *testing.T) {
+		paths, err := repo.RemovePaths(NewPathspec([]string{"a", "b"}),
+			&RemoveOptions{Cached: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, paths, []string{"a", "b"})
+		_, err = os.Stat(filepath.Join(repo.WorkTree, "a"))
+		assertEqual(t, err, nil)
+
+		paths, err = repo.RemovePaths(NewPathspec([]string{"c", "d"}),
+			&RemoveOptions{Recursive: true, Force: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, paths, []string{"c", "d/e", "d/f/g"})
+		assertEqual(t, testIndexPaths(t, repo), []string{})
+		_, err = os.Stat(filepath.Join(repo.WorkTree, "d"))
+		assertEqual(t, os.IsNotExist(err), true)
+	})
+}
@@ -0,0 +1,259 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SequencerItem is a commit to pick or to revert.
+type SequencerItem struct {
+	// Action is "pick" or "revert".
+	Action string
+	Commit string
+}
+
+// PickOptions controls how the changes of a commit are picked or reverted.
+type PickOptions struct {
+	// Mainline is the number of the parent (starting from 1) against which
+	// the changes of a merge commit are taken.
+	Mainline int
+	// RecordOrigin adds a line naming the picked commit to the message.
+	RecordOrigin bool
+	// NoCommit applies the changes only to the index and the work-tree.
+	NoCommit bool
+	// Style is the format of the conflicts left in the files.
+	Style ConflictStyle
+}
+
+// Sequencer is the state of a cherry-pick or a revert of many commits. It is
+// kept in the directory ".git/sequencer", so that the remaining commits can
+// be picked after the user resolves a conflict.
+type Sequencer struct {
+	// Head is the commit which HEAD was at before the first pick.
+	Head string
+	// AbortSafety is the commit which HEAD was at after the last pick. An
+	// abort doesn't rewind HEAD if it has moved since.
+	AbortSafety string
+	// Todo lists the commits which are not done yet. The first one is the
+	// commit being picked, if it stopped at a conflict.
+	Todo []SequencerItem
+	Opts PickOptions
+}
+
+// ReadSequencer reads the state of a cherry-pick or a revert in progress. It
+// returns nil if there is none.
+func (r *Repo) ReadSequencer() (*Sequencer, error) {
+	dir, err := r.DirPath(false, "sequencer")
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "todo"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The todo file has a line for each commit, with the action, the
+	// abbreviated hash of the commit and its subject.
+	// Example: "pick 1e2f3a4 Fix the tests"
+	seq := &Sequencer{Todo: []SequencerItem{}}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || (fields[0] != "pick" && fields[0] != "revert") {
+			return nil, fmt.Errorf("error: invalid line in %s/todo: %s", dir, line)
+		}
+		commitHash, err := r.UniqueNameResolve(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		seq.Todo = append(seq.Todo, SequencerItem{Action: fields[0], Commit: commitHash})
+	}
+
+	for _, file := range []struct {
+		name  string
+		value *string
+	}{{"head", &seq.Head}, {"abort-safety", &seq.AbortSafety}} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		*file.value = strings.TrimSpace(string(data))
+	}
+
+	// The options are kept in the git configuration file format.
+	config := NewConfig()
+	if err := config.ParseFile(filepath.Join(dir, "opts")); err != nil {
+		return nil, err
+	}
+	seq.Opts.NoCommit = config.GetBool("options.no-commit", false)
+	seq.Opts.RecordOrigin = config.GetBool("options.record-origin", false)
+	seq.Opts.Mainline = config.GetInt("options.mainline", 0)
+	return seq, nil
+}
+
+// WriteSequencer saves the state of a cherry-pick or a revert in progress.
+func (r *Repo) WriteSequencer(seq *Sequencer) error {
+	dir, err := r.DirPath(true, "sequencer")
+	if err != nil {
+		return err
+	}
+
+	var todo strings.Builder
+	for _, item := range seq.Todo {
+		commit, err := r.commitParse(item.Commit)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&todo, "%s %s %s\n", item.Action, item.Commit[:7], commit.Subject())
+	}
+
+	opts := ""
+	if seq.Opts.NoCommit {
+		opts += "\tno-commit = true\n"
+	}
+	if seq.Opts.RecordOrigin {
+		opts += "\trecord-origin = true\n"
+	}
+	if seq.Opts.Mainline != 0 {
+		opts += "\tmainline = " + strconv.Itoa(seq.Opts.Mainline) + "\n"
+	}
+
+	files := map[string]string{
+		"head":         seq.Head + "\n",
+		"abort-safety": seq.AbortSafety + "\n",
+		"todo":         todo.String(),
+	}
+	if opts != "" {
+		files["opts"] = "[options]\n" + opts
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveSequencer removes the state of a cherry-pick or a revert, once it is
+// done or aborted.
+func (r *Repo) RemoveSequencer() error {
+	dir, err := r.DirPath(false, "sequencer")
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// pickParent returns the parent of a commit whose changes are picked, which
+// is empty for a root commit. A merge commit needs the mainline parent.
+func pickParent(commitHash string, commit *Commit, mainline int) (string, error) {
+	parents := commit.Parents()
+	switch {
+	case mainline > len(parents):
+		return "", fmt.Errorf("error: commit %s does not have parent %d",
+			commitHash, mainline)
+	case mainline > 0:
+		return parents[mainline-1], nil
+	case len(parents) > 1:
+		return "", fmt.Errorf("error: commit %s is a merge but no -m option was given.",
+			commitHash)
+	case len(parents) == 1:
+		return parents[0], nil
+	}
+	return "", nil
+}
+
+// PickCommit applies the changes made by a commit to the tree 'ours' (such
+// as the tree of HEAD), or reverses them for a revert. This is a three-way
+// merge with the parent of the commit as the base for a pick, and the commit
+// itself as the base for a revert.
+func (r *Repo) PickCommit(ours string, item SequencerItem, opts *PickOptions) (*MergeResult, error) {
+	commit, err := r.commitParse(item.Commit)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := pickParent(item.Commit, commit, opts.Mainline)
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := ""
+	if parent != "" {
+		if parentTree, err = r.TreeResolve(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	// The commit is named with its subject in the conflict markers.
+	// Example: "1e2f3a4 (Fix the tests)"
+	label := fmt.Sprintf("%s (%s)", item.Commit[:7], commit.Subject())
+	mergeOpts := &MergeOptions{
+		OursLabel:   "HEAD",
+		BaseLabel:   "parent of " + label,
+		TheirsLabel: label,
+		Style:       opts.Style,
+	}
+	base, theirs := parentTree, commit.TreeHash()
+	if item.Action == "revert" {
+		base, theirs = theirs, base
+		mergeOpts.BaseLabel, mergeOpts.TheirsLabel = mergeOpts.TheirsLabel, mergeOpts.BaseLabel
+	}
+	return r.MergeTrees(base, ours, theirs, mergeOpts)
+}
+
+// PickMessage returns the message of the commit made by picking or reverting
+// a commit. A pick keeps the message of the commit, and a revert names it.
+func (r *Repo) PickMessage(item SequencerItem, opts *PickOptions) (string, error) {
+	commit, err := r.commitParse(item.Commit)
+	if err != nil {
+		return "", err
+	}
+
+	if item.Action == "revert" {
+		msg := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s", commit.Subject(),
+			item.Commit)
+		if parents := commit.Parents(); opts.Mainline > 0 && len(parents) > 1 {
+			msg += fmt.Sprintf(", reversing\nchanges made to %s", parents[opts.Mainline-1])
+		}
+		return msg + ".\n", nil
+	}
+
+	msg := strings.TrimRight(commit.Msg, "\n") + "\n"
+	if !opts.RecordOrigin {
+		return msg, nil
+	}
+
+	// The origin is added to the trailers at the end of the message, if
+	// there are any, or else as a new paragraph.
+	if !endsWithTrailers(msg) {
+		msg += "\n"
+	}
+	return msg + fmt.Sprintf("(cherry picked from commit %s)\n", item.Commit), nil
+}
+
+// endsWithTrailers tells if the last paragraph of a message, other than the
+// subject, has only trailer lines such as "Signed-off-by: name <email>".
+func endsWithTrailers(msg string) bool {
+	paragraphs := strings.Split(strings.TrimSpace(msg), "\n\n")
+	if len(paragraphs) < 2 {
+		return false
+	}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if strings.HasPrefix(line, "(cherry picked from commit ") {
+			continue
+		}
+		ind := strings.Index(line, ": ")
+		if ind <= 0 || strings.ContainsAny(line[:ind], " \t") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestPickCommit(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitPick")
+
+	writeCommit := func(tree, parent, author, msg string) string {
+		commit, err := NewCommitWithAuthor(repo, tree, []string{parent}, author, msg)
+		assertEqual(t, err, nil)
+		hash, err := repo.ObjectWrite(commit.Object, true)
+		assertEqual(t, err, nil)
+		return hash
+	}
+
+	base := writeTestCommit(t, repo, writeTestTree(t, repo, map[string]string{
+		"a": "1\n2\n3\n4\n5\n",
+	}))
+	author := "A U Thor <author@example.com> 1600000000 +0000"
+	picked := writeCommit(writeTestTree(t, repo, map[string]string{
+		"a": "1\n2\n3\n4\nfive\n",
+	}), base, author, "Change five\n\nSigned-off-by: A U Thor <author@example.com>\n")
+	ours := writeTestTree(t, repo, map[string]string{"a": "one\n2\n3\n4\n5\n"})
+
+	t.Run("Validate a pick", func(t *testing.T) {
+		item := SequencerItem{Action: "pick", Commit: picked}
+		result, err := repo.PickCommit(ours, item, &PickOptions{})
+		assertEqual(t, err, nil)
+		assertEqual(t, result.Clean(), true)
+		assertEqual(t, testFileData(t, repo, result.Tree, "a"), "one\n2\n3\n4\nfive\n")
+
+		// The origin is added to the trailers.
+		msg, err := repo.PickMessage(item, &PickOptions{RecordOrigin: true})
+		assertEqual(t, err, nil)
+		assertEqual(t, msg, "Change five\n\nSigned-off-by: A U Thor <author@example.com>\n"+
+			"(cherry picked from commit "+picked+")\n")
+
+		commit, err := NewCommitWithAuthor(repo, result.Tree, []string{base}, author, msg)
+		assertEqual(t, err, nil)
+		assertEqual(t, commit.Entries["author"][0], author)
+	})
+
+	t.Run("Validate a revert", func(t *testing.T) {
+		item := SequencerItem{Action: "revert", Commit: picked}
+		theirs := writeTestTree(t, repo, map[string]string{"a": "one\n2\n3\n4\nfive\n"})
+		result, err := repo.PickCommit(theirs, item, &PickOptions{})
+		assertEqual(t, err, nil)
+		assertEqual(t, result.Clean(), true)
+		assertEqual(t, testFileData(t, repo, result.Tree, "a"), "one\n2\n3\n4\n5\n")
+
+		msg, err := repo.PickMessage(item, &PickOptions{})
+		assertEqual(t, err, nil)
+		assertEqual(t, msg, "Revert \"Change five\"\n\nThis reverts commit "+picked+".\n")
+	})
+
+	t.Run("Validate a conflicted pick", func(t *testing.T) {
+		conflicted := writeTestTree(t, repo, map[string]string{"a": "1\n2\n3\n4\nFIVE\n"})
+		item := SequencerItem{Action: "pick", Commit: picked}
+		result, err := repo.PickCommit(conflicted, item, &PickOptions{})
+		assertEqual(t, err, nil)
+		assertEqual(t, result.ConflictedPaths(), []string{"a"})
+		assertEqual(t, testFileData(t, repo, result.Tree, "a"), "1\n2\n3\n4\n<<<<<<< HEAD\nFIVE\n"+
+			"=======\nfive\n>>>>>>> "+picked[:7]+" (Change five)\n")
+	})
+
+	t.Run("Validate a merge commit needs the mainline", func(t *testing.T) {
+		merge := writeTestCommit(t, repo, ours, base, picked)
+		item := SequencerItem{Action: "pick", Commit: merge}
+		_, err := repo.PickCommit(ours, item, &PickOptions{})
+		assertEqual(t, err != nil, true)
+		_, err = repo.PickCommit(ours, item, &PickOptions{Mainline: 3})
+		assertEqual(t, err != nil, true)
+
+		// The changes against the second parent are picked.
+		result, err := repo.PickCommit(ours, item, &PickOptions{Mainline: 2})
+		assertEqual(t, err, nil)
+		assertEqual(t, testFileData(t, repo, result.Tree, "a"), "one\n2\n3\n4\n5\n")
+	})
+
+	t.Run("Validate the sequencer state", func(t *testing.T) {
+		seq, err := repo.ReadSequencer()
+		assertEqual(t, err, nil)
+		assertEqual(t, seq == nil, true)
+
+		seq = &Sequencer{
+			Head:        base,
+			AbortSafety: picked,
+			Todo: []SequencerItem{
+				{Action: "pick", Commit: picked}, {Action: "revert", Commit: base},
+			},
+			Opts: PickOptions{RecordOrigin: true, Mainline: 1},
+		}
+		assertEqual(t, repo.WriteSequencer(seq), nil)
+		read, err := repo.ReadSequencer()
+		assertEqual(t, err, nil)
+		assertEqual(t, read, seq)
+
+		assertEqual(t, repo.RemoveSequencer(), nil)
+		seq, err = repo.ReadSequencer()
+		assertEqual(t, err, nil)
+		assertEqual(t, seq == nil, true)
+	})
+}
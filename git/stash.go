@@ -0,0 +1,366 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stashRef is the reference of the latest stash entry. The older entries are
+// kept in its log.
+const stashRef = "refs/stash"
+
+// ErrNoStash tells that there are no stash entries.
+var ErrNoStash = errors.New("No stash entries found.")
+
+// StashOptions lists the choices for saving the local changes in a stash.
+type StashOptions struct {
+	// Message replaces the default message, which names the commit of HEAD.
+	Message string
+	// IncludeUntracked saves (and removes) the untracked files as well.
+	IncludeUntracked bool
+	// Pathspec selects the paths to save, or all of them if nil.
+	Pathspec *Pathspec
+}
+
+// Stash is a stash entry. Its commit records the work-tree, and has the
+// commit of HEAD, the commit of the index and the commit of the untracked
+// files (if any) as its parents.
+type Stash struct {
+	Commit string
+	// Base is the commit of HEAD when the changes were stashed.
+	Base          string
+	BaseTree      string
+	IndexTree     string
+	Tree          string
+	UntrackedTree string
+}
+
+// CreateStash saves the local changes of the index and the work-tree as the
+// commits of a stash entry, without changing anything. It returns the hash
+// of the stash commit, or an empty hash if there are no changes.
+func (r *Repo) CreateStash(opts *StashOptions) (string, error) {
+	branch, headHash, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	if headHash == "" {
+		return "", errors.New("You do not have the initial commit yet")
+	}
+	head, err := r.commitParse(headHash)
+	if err != nil {
+		return "", err
+	}
+
+	index, err := r.ReadIndex()
+	if err != nil {
+		return "", err
+	}
+	fs, err := r.FSConfig()
+	if err != nil {
+		return "", err
+	}
+	if index.Unmerged() {
+		paths := []string{}
+		for i, entry := range index.Entries {
+			if entry.Stage() != 0 && (i == 0 || index.Entries[i-1].Path != entry.Path) {
+				paths = append(paths, entry.Path+": needs merge")
+			}
+		}
+		return "", errors.New(strings.Join(paths, "\n"))
+	}
+
+	// Find the selected changes. A pathspec must match some path of the
+	// index, or some untracked file to save.
+	staged, err := r.DiffTreeToIndex(head.TreeHash(), index, nil)
+	if err != nil {
+		return "", err
+	}
+	unstaged, _, err := r.DiffIndexToWorktree(index)
+	if err != nil {
+		return "", err
+	}
+	status := &Status{}
+	if opts.IncludeUntracked {
+		if err := r.untrackedFiles(index, &StatusOptions{Untracked: "all"}, status); err != nil {
+			return "", err
+		}
+	}
+	for _, entry := range index.Entries {
+		opts.Pathspec.Match(entry.Path)
+	}
+	changed, untracked := false, []string{}
+	for _, entry := range append(staged, unstaged...) {
+		changed = changed || opts.Pathspec.Match(entry.Path())
+	}
+	for _, filePath := range status.Untracked {
+		// The nested repositories are left alone.
+		if !strings.HasSuffix(filePath, "/") && opts.Pathspec.Match(filePath) {
+			untracked = append(untracked, filePath)
+		}
+	}
+	if len(opts.Pathspec.Unmatched()) > 0 {
+		return "", ErrPathspecUnmatched
+	}
+	if !changed && len(untracked) == 0 {
+		return "", nil
+	}
+
+	// The commits are described by the branch and the commit of HEAD.
+	// Example: "master: 1e2f3a4 Fix the tests"
+	desc := "(no branch)"
+	if branch != "" {
+		desc = strings.TrimPrefix(branch, "refs/heads/")
+	}
+	desc += fmt.Sprintf(": %s %s", headHash[:7], head.Subject())
+
+	writeCommit := func(files []FileEntry, parents []string, msg string) (string, error) {
+		treeHash, err := r.WriteTree(files)
+		if err != nil {
+			return "", err
+		}
+		commit, err := NewCommitFromParents(r, treeHash, parents, msg)
+		if err != nil {
+			return "", err
+		}
+		return r.ObjectWrite(commit.Object, true)
+	}
+
+	indexCommit, err := writeCommit(index.Files(), []string{headHash}, "index on "+desc+"\n")
+	if err != nil {
+		return "", err
+	}
+	parents := []string{headHash, indexCommit}
+	if len(untracked) > 0 {
+		files := &Index{}
+		for _, filePath := range untracked {
+			if err := r.AddFile(files, filePath, fs); err != nil {
+				return "", err
+			}
+		}
+		untrackedCommit, err := writeCommit(files.Files(), nil,
+			"untracked files on "+desc+"\n")
+		if err != nil {
+			return "", err
+		}
+		parents = append(parents, untrackedCommit)
+	}
+
+	// The work-tree is the index along with the selected unstaged changes.
+	worktree := &Index{Entries: append([]*IndexEntry{}, index.Entries...)}
+	for _, entry := range unstaged {
+		if !opts.Pathspec.Match(entry.Path()) {
+			continue
+		}
+		if entry.Status == DiffDeleted {
+			worktree.Remove(entry.Path())
+		} else if err := r.AddFile(worktree, entry.Path(), fs); err != nil {
+			return "", err
+		}
+	}
+	msg := "WIP on " + desc + "\n"
+	if opts.Message != "" {
+		msg = fmt.Sprintf("On %s: %s\n", desc[:strings.Index(desc, ": ")], opts.Message)
+	}
+	return writeCommit(worktree.Files(), parents, msg)
+}
+
+// PushStash saves the local changes in a new stash entry, and then removes
+// them, along with the untracked files which got saved. It returns the hash
+// of the stash commit, or an empty hash if there are no changes to save.
+func (r *Repo) PushStash(opts *StashOptions) (string, error) {
+	stashHash, err := r.CreateStash(opts)
+	if err != nil || stashHash == "" {
+		return "", err
+	}
+	stash, err := r.ParseStash(stashHash)
+	if err != nil {
+		return "", err
+	}
+	commit, err := r.commitParse(stashHash)
+	if err != nil {
+		return "", err
+	}
+	if err := r.StoreStash(stashHash, commit.Subject()); err != nil {
+		return "", err
+	}
+
+	// Only the selected paths are reset to HEAD, if a pathspec is given.
+	if opts.Pathspec == nil {
+		forced := &CheckoutOptions{Force: true, Command: "reset"}
+		if err := r.CheckoutTree(stash.BaseTree, stash.BaseTree, forced); err != nil {
+			return "", err
+		}
+	} else {
+		restore := &RestoreOptions{Source: stash.BaseTree, Staged: true, Worktree: true}
+		if _, err := r.Restore(opts.Pathspec, restore); err != nil &&
+			err != ErrPathspecUnmatched {
+			return "", err
+		}
+	}
+
+	if stash.UntrackedTree != "" {
+		files, err := r.treeFileMap(stash.UntrackedTree)
+		if err != nil {
+			return "", err
+		}
+		for filePath := range files {
+			if err := r.removeWorktreeFile(filePath); err != nil {
+				return "", err
+			}
+		}
+	}
+	return stashHash, nil
+}
+
+// StoreStash makes a stash commit the latest stash entry, with a message
+// for its log.
+func (r *Repo) StoreStash(commitHash, msg string) error {
+	oldHash, _, _ := r.RefResolve(stashRef)
+	if err := r.refStore().WriteRef(stashRef, commitHash); err != nil {
+		return err
+	}
+	return r.WriteReflog(stashRef, oldHash, commitHash, msg)
+}
+
+// StashList returns the log entries of the stash entries, with the latest
+// one ("stash@{0}") first.
+func (r *Repo) StashList() ([]ReflogEntry, error) {
+	entries, err := r.ReadReflog(stashRef)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// ResolveStash finds the stash entry given by a name, such as "stash@{1}" or
+// just "1". The latest entry is used if the name is empty. It returns the
+// hash of the stash commit, and the position of the entry.
+func (r *Repo) ResolveStash(name string) (string, int, error) {
+	entries, err := r.StashList()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(entries) == 0 {
+		return "", 0, ErrNoStash
+	}
+
+	pos := strings.TrimPrefix(name, "refs/")
+	if strings.HasPrefix(pos, "stash@{") && strings.HasSuffix(pos, "}") {
+		pos = pos[len("stash@{") : len(pos)-1]
+	}
+	if name == "" || name == "stash" || name == stashRef {
+		pos = "0"
+	}
+	n, err := strconv.Atoi(pos)
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("error: %s is not a valid reference", name)
+	}
+	if n >= len(entries) {
+		ref := stashRef
+		if ind := strings.Index(name, "@{"); ind > 0 {
+			ref = name[:ind]
+		}
+		return "", 0, fmt.Errorf("fatal: log for '%s' only has %d entries", ref, len(entries))
+	}
+	return entries[n].New, n, nil
+}
+
+// ParseStash reads the commits of a stash entry.
+func (r *Repo) ParseStash(commitHash string) (*Stash, error) {
+	commit, err := r.commitParse(commitHash)
+	if err != nil {
+		return nil, err
+	}
+	parents := commit.Parents()
+	if len(parents) < 2 || len(parents) > 3 {
+		return nil, fmt.Errorf("error: '%s' is not a stash-like commit", commitHash)
+	}
+
+	stash := &Stash{Commit: commitHash, Base: parents[0], Tree: commit.TreeHash()}
+	trees := []*string{&stash.BaseTree, &stash.IndexTree, &stash.UntrackedTree}
+	for i, parent := range parents {
+		if *trees[i], err = r.TreeResolve(parent); err != nil {
+			return nil, err
+		}
+	}
+	return stash, nil
+}
+
+// DropStash removes the stash entry at a position. The stash reference is
+// removed along with the last entry.
+func (r *Repo) DropStash(n int) error {
+	entries, err := r.ReadReflog(stashRef)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return ErrNoStash
+	}
+	if n < 0 || n >= len(entries) {
+		return fmt.Errorf("fatal: log for 'stash' only has %d entries", len(entries))
+	}
+	if len(entries) == 1 {
+		return r.ClearStash()
+	}
+
+	// The log is rewritten so that each entry starts from the previous one.
+	pos := len(entries) - 1 - n
+	entries = append(entries[:pos], entries[pos+1:]...)
+	if pos < len(entries) {
+		entries[pos].Old = nullHash
+		if pos > 0 {
+			entries[pos].Old = entries[pos-1].New
+		}
+	}
+	if err := r.rewriteReflog(stashRef, entries); err != nil {
+		return err
+	}
+	return r.refStore().WriteRef(stashRef, entries[len(entries)-1].New)
+}
+
+// ClearStash removes all the stash entries.
+func (r *Repo) ClearStash() error {
+	if err := r.refStore().DeleteRef(stashRef); err != nil {
+		return err
+	}
+	return r.removeReflog(stashRef)
+}
+
+// RestoreUntracked writes the untracked files saved in a stash entry to the
+// work-tree. The files which are present already are left alone, and listed
+// in the returned error.
+func (r *Repo) RestoreUntracked(stash *Stash) error {
+	files, err := r.treeFileMap(stash.UntrackedTree)
+	if err != nil {
+		return err
+	}
+	fs, err := r.FSConfig()
+	if err != nil {
+		return err
+	}
+	paths := []string{}
+	for filePath := range files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, filePath := range paths {
+		if r.worktreePresent(filePath) {
+			fmt.Fprintf(&b, "%s already exists, no checkout\n", filePath)
+		} else if _, err := r.writeWorktreeFile(files[filePath], fs); err != nil {
+			return err
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString("error: could not restore untracked files from stash")
+		return errors.New(b.String())
+	}
+	return nil
+}
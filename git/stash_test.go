@@ -0,0 +1,114 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestStash(t *testing.T) {
+	repo := newTestRepo(t, "testGoGitStash")
+	_, base := commitTestFiles(t, repo, map[string]string{"a": "a\n", "b": "b\n"})
+
+	t.Run("Validate no changes to stash", func(t *testing.T) {
+		stashHash, err := repo.PushStash(&StashOptions{})
+		assertEqual(t, err, nil)
+		assertEqual(t, stashHash, "")
+
+		_, _, err = repo.ResolveStash("")
+		assertEqual(t, err, ErrNoStash)
+	})
+
+	t.Run("Validate a stash push", func(t *testing.T) {
+		writeTestFile(t, repo, "a", "a2\n")
+		stageTestFile(t, repo, "a")
+		writeTestFile(t, repo, "a", "a3\n")
+		writeTestFile(t, repo, "u", "u\n")
+
+		stashHash, err := repo.PushStash(&StashOptions{IncludeUntracked: true})
+		assertEqual(t, err, nil)
+		stash, err := repo.ParseStash(stashHash)
+		assertEqual(t, err, nil)
+		assertEqual(t, stash.Base, base)
+		assertEqual(t, testFileData(t, repo, stash.IndexTree, "a"), "a2\n")
+		assertEqual(t, testFileData(t, repo, stash.Tree, "a"), "a3\n")
+		assertEqual(t, testFileData(t, repo, stash.UntrackedTree, "u"), "u\n")
+
+		// The changes are removed, along with the untracked file.
+		assertEqual(t, readTestFile(t, repo, "a"), "a\n")
+		assertEqual(t, readTestFile(t, repo, "u"), "")
+		status, err := repo.Status(&StatusOptions{Untracked: "all"})
+		assertEqual(t, err, nil)
+		assertEqual(t, len(status.Entries), 0)
+
+		entries, err := repo.StashList()
+		assertEqual(t, err, nil)
+		assertEqual(t, len(entries), 1)
+		assertEqual(t, entries[0].Message, "WIP on master: "+base[:7]+" test")
+	})
+
+	t.Run("Validate a stash push with a pathspec", func(t *testing.T) {
+		writeTestFile(t, repo, "a", "a4\n")
+		writeTestFile(t, repo, "b", "b4\n")
+		ps := NewPathspec([]string{"b", "nope"})
+		_, err := repo.PushStash(&StashOptions{Pathspec: ps})
+		assertEqual(t, err, ErrPathspecUnmatched)
+		assertEqual(t, ps.Unmatched(), []int{1})
+
+		opts := &StashOptions{Message: "only b", Pathspec: NewPathspec([]string{"b"})}
+		stashHash, err := repo.PushStash(opts)
+		assertEqual(t, err, nil)
+		stash, err := repo.ParseStash(stashHash)
+		assertEqual(t, err, nil)
+		assertEqual(t, testFileData(t, repo, stash.Tree, "a"), "a\n")
+		assertEqual(t, testFileData(t, repo, stash.Tree, "b"), "b4\n")
+		assertEqual(t, stash.UntrackedTree, "")
+		assertEqual(t, readTestFile(t, repo, "a"), "a4\n")
+		assertEqual(t, readTestFile(t, repo, "b"), "b\n")
+
+		hash, pos, err := repo.ResolveStash("stash@{0}")
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, stashHash)
+		assertEqual(t, pos, 0)
+		_, pos, err = repo.ResolveStash("1")
+		assertEqual(t, err, nil)
+		assertEqual(t, pos, 1)
+		_, _, err = repo.ResolveStash("stash@{2}")
+		assertEqual(t, err != nil, true)
+		_, _, err = repo.ResolveStash("foo")
+		assertEqual(t, err != nil, true)
+
+		entries, err := repo.StashList()
+		assertEqual(t, err, nil)
+		assertEqual(t, entries[0].Message, "On master: only b")
+	})
+
+	t.Run("Validate restoring the untracked files", func(t *testing.T) {
+		hash, _, err := repo.ResolveStash("1")
+		assertEqual(t, err, nil)
+		stash, err := repo.ParseStash(hash)
+		assertEqual(t, err, nil)
+
+		assertEqual(t, repo.RestoreUntracked(stash), nil)
+		assertEqual(t, readTestFile(t, repo, "u"), "u\n")
+		assertEqual(t, repo.RestoreUntracked(stash) != nil, true)
+	})
+
+	t.Run("Validate dropping the stash entries", func(t *testing.T) {
+		latest, _, err := repo.ResolveStash("0")
+		assertEqual(t, err, nil)
+		assertEqual(t, repo.DropStash(1), nil)
+		entries, err := repo.StashList()
+		assertEqual(t, err, nil)
+		assertEqual(t, len(entries), 1)
+		assertEqual(t, entries[0].New, latest)
+		assertEqual(t, entries[0].Old, nullHash)
+		hash, _, err := repo.RefResolve("refs/stash")
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, latest)
+
+		assertEqual(t, repo.DropStash(0), nil)
+		_, _, err = repo.ResolveStash("")
+		assertEqual(t, err, ErrNoStash)
+		_, _, err = repo.RefResolve("refs/stash")
+		assertEqual(t, err != nil, true)
+	})
+}
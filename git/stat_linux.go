@@ -0,0 +1,32 @@
+package git
+
+import (
+	"os"
+	"syscall"
+)
+
+// statData is the part of the stat data of a file which is kept in the index.
+type statData struct {
+	ctimeSec, ctimeNsec uint32
+	mtimeSec, mtimeNsec uint32
+	dev, ino            uint32
+	uid, gid            uint32
+}
+
+// fileStat extracts the stat data of a file, as it is kept in the index.
+func fileStat(info os.FileInfo) statData {
+	stat := statData{
+		mtimeSec:  uint32(info.ModTime().Unix()),
+		mtimeNsec: uint32(info.ModTime().Nanosecond()),
+	}
+
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		stat.ctimeSec, stat.ctimeNsec = stat.mtimeSec, stat.mtimeNsec
+		return stat
+	}
+	stat.ctimeSec, stat.ctimeNsec = uint32(sys.Ctim.Sec), uint32(sys.Ctim.Nsec)
+	stat.dev, stat.ino = uint32(sys.Dev), uint32(sys.Ino)
+	stat.uid, stat.gid = sys.Uid, sys.Gid
+	return stat
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package git
+
+import (
+	"os"
+)
+
+// statData is the part of the stat data of a file which is kept in the index.
+type statData struct {
+	ctimeSec, ctimeNsec uint32
+	mtimeSec, mtimeNsec uint32
+	dev, ino            uint32
+	uid, gid            uint32
+}
+
+// fileStat extracts the stat data of a file, as it is kept in the index. Only
+// the modification time is portable, so it is used as the change time too.
+func fileStat(info os.FileInfo) statData {
+	stat := statData{
+		mtimeSec:  uint32(info.ModTime().Unix()),
+		mtimeNsec: uint32(info.ModTime().Nanosecond()),
+	}
+	stat.ctimeSec, stat.ctimeNsec = stat.mtimeSec, stat.mtimeNsec
+	return stat
+}
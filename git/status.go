@@ -0,0 +1,375 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// StatusEntry is a path which differs between HEAD, the index and the
+// work-tree. 'Staged' and 'Unstaged' are the status letters of the changes
+// between HEAD and the index, and between the index and the work-tree (' ' if
+// there is no change). For a conflicted path, both the letters tell which
+// sides have the file (such as "UU" or "AA"), like "git status --short".
+type StatusEntry struct {
+	Path     string
+	Staged   byte
+	Unstaged byte
+	Head     FileEntry
+	Index    FileEntry
+	Worktree FileEntry
+	// OrigPath is the path in HEAD for a staged rename or copy.
+	OrigPath string
+	Score    int
+	// Stages are the base, "ours" and "theirs" versions of a conflicted path.
+	Stages [3]FileEntry
+}
+
+// Status is the state of the work-tree and the index as compared to HEAD.
+type Status struct {
+	// Branch is the reference of the current branch, or empty if HEAD is
+	// detached.
+	Branch string
+	// Head is the commit hash of HEAD, or empty if there are no commits yet.
+	Head string
+	// Upstream is the reference tracked by the current branch, if any.
+	Upstream     string
+	UpstreamGone bool
+	Ahead        int
+	Behind       int
+	Entries      []StatusEntry
+	Untracked    []string
+	Ignored      []string
+}
+
+// StatusOptions controls what is collected by Status.
+type StatusOptions struct {
+	// Untracked is "no", "normal" (directories without any tracked files are
+	// shown as a whole) or "all" (every file is shown).
+	Untracked string
+	// Ignored collects the ignored files as well.
+	Ignored bool
+	// DetectRenames pairs the staged deletions and additions.
+	DetectRenames bool
+	RenameScore   int
+	RenameLimit   int
+}
+
+// unmergedCodes maps the stages present for a conflicted path to its status.
+var unmergedCodes = map[[3]bool]string{
+	{true, false, false}: "DD",
+	{false, true, false}: "AU",
+	{true, true, false}:  "UD",
+	{false, false, true}: "UA",
+	{true, false, true}:  "DU",
+	{false, true, true}:  "AA",
+	{true, true, true}:   "UU",
+}
+
+// Status compares HEAD, the index and the work-tree. The stat data refreshed
+// while comparing the work-tree is written back to the index if possible.
+func (r *Repo) Status(opts *StatusOptions) (*Status, error) {
+	status := &Status{
+		Entries:   []StatusEntry{},
+		Untracked: []string{},
+		Ignored:   []string{},
+	}
+
+	var err error
+	status.Branch, status.Head, err = r.Head()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.UpstreamStatus(status); err != nil {
+		return nil, err
+	}
+
+	headTree := ""
+	if status.Head != "" {
+		if headTree, err = r.TreeResolve(status.Head); err != nil {
+			return nil, err
+		}
+	}
+	index, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	diffOpts := &DiffOptions{
+		DetectRenames: opts.DetectRenames,
+		RenameScore:   opts.RenameScore,
+		RenameLimit:   opts.RenameLimit,
+	}
+	staged, err := r.DiffTreeToIndex(headTree, index, diffOpts)
+	if err != nil {
+		return nil, err
+	}
+	unstaged, refreshed, err := r.DiffIndexToWorktree(index)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]*StatusEntry{}
+	getEntry := func(path string) *StatusEntry {
+		if entries[path] == nil {
+			entries[path] = &StatusEntry{Path: path, Staged: ' ', Unstaged: ' '}
+		}
+		return entries[path]
+	}
+	for _, change := range staged {
+		entry := getEntry(change.Path())
+		entry.Staged = change.Status
+		entry.Head, entry.Index = change.Old, change.New
+		if change.Status == DiffRenamed || change.Status == DiffCopied {
+			entry.OrigPath, entry.Score = change.Old.Path, change.Score
+		}
+	}
+	for _, change := range unstaged {
+		entry := getEntry(change.Path())
+		entry.Unstaged = change.Status
+		if change.Status != DiffAdded {
+			entry.Index = change.Old
+		}
+		entry.Worktree = change.New
+	}
+
+	if err := r.unmergedStatus(index, headTree, getEntry); err != nil {
+		return nil, err
+	}
+
+	// Fill the sides of each entry which are the same as the other sides.
+	for _, entry := range entries {
+		if entry.Staged == ' ' && entry.Index.Hash != "" {
+			entry.Head = entry.Index
+		}
+		if entry.Unstaged == ' ' && entry.Staged != DiffDeleted {
+			entry.Worktree = entry.Index
+		}
+	}
+
+	for _, entry := range entries {
+		status.Entries = append(status.Entries, *entry)
+	}
+	sort.Slice(status.Entries, func(i, j int) bool {
+		return status.Entries[i].Path < status.Entries[j].Path
+	})
+
+	if opts.Untracked != "no" {
+		if err := r.untrackedFiles(index, opts, status); err != nil {
+			return nil, err
+		}
+		sort.Strings(status.Untracked)
+		sort.Strings(status.Ignored)
+	}
+
+	// Refreshing the index is optional, so it is fine if it is locked.
+	if refreshed {
+		r.WriteIndex(index)
+	}
+	return status, nil
+}
+
+// UpstreamStatus finds the upstream of the branch of a status and counts the
+// commits in which they differ. Only 'Branch' and 'Head' need to be set.
+func (r *Repo) UpstreamStatus(status *Status) error {
+	if status.Branch == "" {
+		return nil
+	}
+	config, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	status.Upstream = r.Upstream(status.Branch, config)
+	if status.Upstream == "" {
+		return nil
+	}
+	upstreamHash, _, err := r.RefResolve(status.Upstream)
+	if err != nil || upstreamHash == "" {
+		status.UpstreamGone = true
+		return nil
+	}
+	if status.Head == "" {
+		return nil
+	}
+
+	status.Ahead, status.Behind, err = r.AheadBehind(status.Head, upstreamHash)
+	return err
+}
+
+// unmergedStatus adds the conflicted paths of the index to the status.
+func (r *Repo) unmergedStatus(index *Index, headTree string,
+	getEntry func(string) *StatusEntry) error {
+	stages := map[string]*StatusEntry{}
+	present := map[string][3]bool{}
+	for _, indexEntry := range index.Entries {
+		stage := indexEntry.Stage()
+		if stage == 0 {
+			continue
+		}
+
+		path := indexEntry.Path
+		entry := getEntry(path)
+		entry.Stages[stage-1] = indexEntry.FileEntry()
+		stages[path] = entry
+		sides := present[path]
+		sides[stage-1] = true
+		present[path] = sides
+	}
+
+	for path, entry := range stages {
+		code := unmergedCodes[present[path]]
+		entry.Staged, entry.Unstaged = code[0], code[1]
+		entry.Index = FileEntry{}
+
+		if headTree != "" {
+			if hash, err := r.PathResolve(headTree, path); err == nil {
+				entry.Head = FileEntry{Path: path, Mode: entry.Stages[1].Mode, Hash: hash}
+			}
+		}
+		if wtFile, err := r.WorktreeFile(path); err == nil {
+			entry.Worktree = wtFile
+		} else if !isMissing(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// untrackedWalker collects the untracked and ignored files of the work-tree.
+type untrackedWalker struct {
+	repo        *Repo
+	opts        *StatusOptions
+	ignore      *Ignore
+	tracked     map[string]bool
+	trackedDirs map[string]bool
+}
+
+// untrackedFiles collects the files of the work-tree which are not in the
+// index, along with the ignored ones if asked for.
+func (r *Repo) untrackedFiles(index *Index, opts *StatusOptions, status *Status) error {
+	ignore, err := r.NewIgnore()
+	if err != nil {
+		return err
+	}
+	walker := &untrackedWalker{
+		repo:        r,
+		opts:        opts,
+		ignore:      ignore,
+		tracked:     map[string]bool{},
+		trackedDirs: index.Dirs(),
+	}
+	for _, entry := range index.Entries {
+		walker.tracked[entry.Path] = true
+	}
+
+	untracked, ignored, err := walker.walk("")
+	if err != nil {
+		return err
+	}
+	status.Untracked = untracked
+	if opts.Ignored {
+		status.Ignored = ignored
+	}
+	return nil
+}
+
+// walk returns the untracked and the ignored files inside a directory of the
+// work-tree.
+func (w *untrackedWalker) walk(dir string) ([]string, []string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(w.repo.WorkTree, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	untracked, ignored := []string{}, []string{}
+	for _, info := range infos {
+		filePath := path.Join(dir, info.Name())
+		if info.Name() == ".git" || w.tracked[filePath] {
+			continue
+		}
+
+		// An ignored directory with tracked files inside is still walked, to
+		// find the ignored files in it.
+		isDir := info.IsDir()
+		if w.ignore.IsIgnored(filePath, isDir) && !(isDir && w.trackedDirs[filePath]) {
+			if !isDir {
+				ignored = append(ignored, filePath)
+				continue
+			}
+
+			// An ignored directory is shown as a whole, unless all the
+			// files are asked for. It is not shown at all if it is empty.
+			files, err := w.allFiles(filePath)
+			if err != nil {
+				return nil, nil, err
+			}
+			if w.opts.Untracked == "all" {
+				ignored = append(ignored, files...)
+			} else if len(files) > 0 {
+				ignored = append(ignored, filePath+"/")
+			}
+			continue
+		}
+		if !isDir {
+			untracked = append(untracked, filePath)
+			continue
+		}
+
+		// A nested repository is shown as a whole, as its files are not
+		// tracked here.
+		gitDir := filepath.Join(w.repo.WorkTree, filepath.FromSlash(filePath), ".git")
+		if _, err := os.Stat(gitDir); err == nil {
+			untracked = append(untracked, filePath+"/")
+			continue
+		}
+
+		files, ignoredFiles, err := w.walk(filePath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Without any tracked files inside, a directory is shown as a whole
+		// if it has any untracked files, or as ignored if it has only the
+		// ignored files.
+		if w.opts.Untracked != "all" && !w.trackedDirs[filePath] {
+			if len(files) > 0 {
+				untracked = append(untracked, filePath+"/")
+				ignored = append(ignored, ignoredFiles...)
+			} else if len(ignoredFiles) > 0 {
+				ignored = append(ignored, filePath+"/")
+			}
+			continue
+		}
+		untracked = append(untracked, files...)
+		ignored = append(ignored, ignoredFiles...)
+	}
+
+	return untracked, ignored, nil
+}
+
+// allFiles returns all the files inside a directory of the work-tree.
+func (w *untrackedWalker) allFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(w.repo.WorkTree, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, info := range infos {
+		filePath := path.Join(dir, info.Name())
+		if !info.IsDir() {
+			files = append(files, filePath)
+			continue
+		}
+		subFiles, err := w.allFiles(filePath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, subFiles...)
+	}
+	return files, nil
+}
@@ -0,0 +1,396 @@
+package git
+
+import (
+	"bufio"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ssrathi/gogit/util"
+)
+
+// ObjectStore keeps the objects of a repo by their hashes. The data of an
+// object is given with its header, as "<type> <size>\x00<content>".
+type ObjectStore interface {
+	// ReadObject returns the data of an object. A missing object gives an
+	// error for which os.IsNotExist is true.
+	ReadObject(hash string) ([]byte, error)
+	// OpenObject is like ReadObject, but streams the data of the object
+	// instead of holding all of it in memory.
+	OpenObject(hash string) (io.ReadCloser, error)
+	// WriteObject stores the data of an object. An object which already
+	// exists is kept as it is, as its data can only be the same.
+	WriteObject(hash string, data []byte) error
+	// CreateObject returns a writer for the data of a new object, whose hash
+	// is only given once all of it is written.
+	CreateObject() (ObjectWriter, error)
+	// FindObjects returns the hashes of all the objects starting with the
+	// given prefix of at least 2 characters.
+	FindObjects(prefix string) ([]string, error)
+}
+
+// ObjectWriter takes the data of a new object. Commit stores the object under
+// its hash, while Close without a Commit discards it.
+type ObjectWriter interface {
+	io.Writer
+	Commit(hash string) error
+	Close() error
+}
+
+// RefStore keeps the references of a repo, such as HEAD, the branches and the
+// tags. The value of a reference is either a hash, or "ref: <name>" for a
+// symbolic reference.
+type RefStore interface {
+	// ReadRef returns the value of a reference. A missing reference gives
+	// an error for which os.IsNotExist is true.
+	ReadRef(name string) (string, error)
+	// WriteRef sets the value of a reference, creating it if needed.
+	WriteRef(name, value string) error
+	// DeleteRef removes a reference. Removing a missing one is not an error.
+	DeleteRef(name string) error
+	// ListRefs returns the names of all the references under "refs/",
+	// sorted by name.
+	ListRefs() ([]string, error)
+}
+
+// objectStore returns the store of the objects, which is the "objects"
+// directory of the git directory unless another one is set.
+func (r *Repo) objectStore() ObjectStore {
+	if r.Objects != nil {
+		return r.Objects
+	}
+	if r.loose == nil {
+		r.loose = &looseObjectStore{repo: r}
+	}
+	return r.loose
+}
+
+// refStore returns the store of the references, which is the files in the
+// git directory unless another one is set.
+func (r *Repo) refStore() RefStore {
+	if r.Refs != nil {
+		return r.Refs
+	}
+	return &fileRefStore{repo: r}
+}
+
+// looseObjectStore keeps each object in a zlib compressed file, named by its
+// hash under the "objects" directory. Example: "objects/55/7db03de..."
+type looseObjectStore struct {
+	repo *Repo
+
+	// fsync tells whether the new objects are flushed to the disk, as read
+	// once from the configuration on the first write.
+	fsyncOnce sync.Once
+	fsync     bool
+	fsyncErr  error
+}
+
+// ReadObject reads and decompresses the file of an object.
+func (s *looseObjectStore) ReadObject(hash string) ([]byte, error) {
+	rdr, err := s.OpenObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+	return ioutil.ReadAll(rdr)
+}
+
+// OpenObject opens the file of an object and decompresses it as it's read.
+func (s *looseObjectStore) OpenObject(hash string) (io.ReadCloser, error) {
+	dataFile, err := s.repo.FilePath(false, "objects", hash[0:2], hash[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(dataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rdr, err := zlib.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Malformed object %s: bad data", hash)
+	}
+	return &looseObjectReader{ReadCloser: rdr, file: file, hash: hash}, nil
+}
+
+// WriteObject compresses an object into a temporary file next to its file,
+// and moves it in place. An existing object is not written again.
+func (s *looseObjectStore) WriteObject(hash string, data []byte) error {
+	if s.hasObject(hash) {
+		return nil
+	}
+
+	w, err := s.newWriter("objects", hash[0:2])
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Commit(hash)
+}
+
+// CreateObject compresses the data of an object into a temporary file in the
+// "objects" directory, which is moved to the file of the object on a commit.
+func (s *looseObjectStore) CreateObject() (ObjectWriter, error) {
+	return s.newWriter("objects")
+}
+
+// hasObject tells whether the file of an object exists.
+func (s *looseObjectStore) hasObject(hash string) bool {
+	dataFile, err := s.repo.FilePath(false, "objects", hash[0:2], hash[2:])
+	return err == nil && util.IsPathPresent(dataFile)
+}
+
+// newWriter creates a writer to a temporary file in the given directory, which
+// must be on the same file system as the objects to move it in place.
+func (s *looseObjectStore) newWriter(paths ...string) (*looseObjectWriter, error) {
+	s.fsyncOnce.Do(func() {
+		config, err := s.repo.Config()
+		if err == nil {
+			s.fsync = fsyncLooseObjects(config)
+		}
+		s.fsyncErr = err
+	})
+	if s.fsyncErr != nil {
+		return nil, s.fsyncErr
+	}
+
+	dir, err := s.repo.DirPath(true, paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile(dir, "tmp_obj_")
+	if err != nil {
+		return nil, err
+	}
+	return &looseObjectWriter{
+		Writer: zlib.NewWriter(file),
+		store:  s,
+		file:   file,
+		fsync:  s.fsync,
+	}, nil
+}
+
+// fsyncLooseObjects tells whether the files of the new objects are flushed to
+// the disk before moving them in place. It's set by the "loose-object"
+// component of "core.fsync" or by the components which include it, or by the
+// older "core.fsyncObjectFiles". Like "git", the loose objects are not flushed
+// by default. The components are taken in order: a component with a leading
+// '-' removes it, and "none" removes all the components before it.
+func fsyncLooseObjects(config *Config) bool {
+	if config.GetBool("core.fsyncObjectFiles", false) {
+		return true
+	}
+
+	value, _ := config.Get("core.fsync")
+	fsync := false
+	for _, component := range strings.Split(value, ",") {
+		component = strings.TrimSpace(component)
+		if component == "none" {
+			fsync = false
+			continue
+		}
+		switch strings.TrimPrefix(component, "-") {
+		case "loose-object", "objects", "committed", "added", "all":
+			fsync = !strings.HasPrefix(component, "-")
+		}
+	}
+	return fsync
+}
+
+// looseObjectReader decompresses the file of an object, closing both of them
+// at the end.
+type looseObjectReader struct {
+	io.ReadCloser
+	file *os.File
+	hash string
+}
+
+// Read reports a corrupt file as bad data of the object.
+func (rdr *looseObjectReader) Read(p []byte) (int, error) {
+	n, err := rdr.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("Malformed object %s: bad data", rdr.hash)
+	}
+	return n, err
+}
+
+// Close closes the decompressor and the file.
+func (rdr *looseObjectReader) Close() error {
+	rdr.ReadCloser.Close()
+	return rdr.file.Close()
+}
+
+// looseObjectWriter compresses the data of a new object into a temporary file.
+type looseObjectWriter struct {
+	*zlib.Writer
+	store *looseObjectStore
+	file  *os.File
+	fsync bool
+	done  bool
+}
+
+// Commit flushes the temporary file to the disk if configured, makes it
+// read-only and moves it to the file of the object. If the object already
+// exists, the temporary file is removed instead.
+func (w *looseObjectWriter) Commit(hash string) error {
+	if w.store.hasObject(hash) {
+		return w.Close()
+	}
+	if err := w.commit(hash); err != nil {
+		w.Close()
+		return err
+	}
+	w.done = true
+	return nil
+}
+
+// commit writes the temporary file out and moves it in place.
+func (w *looseObjectWriter) commit(hash string) error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+
+	if w.fsync {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(w.file.Name(), 0444); err != nil {
+		return err
+	}
+
+	dataFile, err := w.store.repo.FilePath(true, "objects", hash[0:2], hash[2:])
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(w.file.Name(), dataFile); err != nil {
+		return err
+	}
+	if !w.fsync {
+		return nil
+	}
+
+	// The new entry of the directory has to reach the disk too.
+	dir, err := os.Open(filepath.Dir(dataFile))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Close removes the temporary file, unless it's already committed.
+func (w *looseObjectWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.file.Close()
+	return os.Remove(w.file.Name())
+}
+
+// FindObjects lists the files in the directory of the first 2 characters of
+// the prefix.
+func (s *looseObjectStore) FindObjects(prefix string) ([]string, error) {
+	hashes := []string{}
+	objectsPath, err := s.repo.DirPath(false, "objects", prefix[0:2])
+	if err != nil {
+		return hashes, nil
+	}
+	files, err := ioutil.ReadDir(objectsPath)
+	if os.IsNotExist(err) {
+		return hashes, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), prefix[2:]) {
+			hashes = append(hashes, prefix[0:2]+file.Name())
+		}
+	}
+	return hashes, nil
+}
+
+// fileRefStore keeps each reference in a file of the same name, relative to
+// the git directory. Example: "refs/heads/master"
+type fileRefStore struct {
+	repo *Repo
+}
+
+// ReadRef reads the file of a reference.
+func (s *fileRefStore) ReadRef(name string) (string, error) {
+	refFile, err := s.repo.FilePath(false, name)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(refFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// WriteRef writes the file of a reference, along with its directories.
+func (s *fileRefStore) WriteRef(name, value string) error {
+	refFile, err := s.repo.FilePath(true, name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(refFile, []byte(value+"\n"), 0644)
+}
+
+// DeleteRef removes the file of a reference.
+func (s *fileRefStore) DeleteRef(name string) error {
+	refFile, err := s.repo.FilePath(false, name)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(refFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ListRefs walks through the "refs" directory.
+func (s *fileRefStore) ListRefs() ([]string, error) {
+	refDir, err := s.repo.DirPath(false, "refs")
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	err = filepath.Walk(refDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(filepath.Dir(refDir), path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,117 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tag is an annotated tag object with a map of "tag" entries, the tag message
+// and a git object.
+type Tag struct {
+	Repository *Repo
+	*Object
+	Entries entryMap
+	// Keep the keys to maintain the insertion order.
+	Keys []string
+	Msg  string
+}
+
+// NewTag creates a new tag object by parsing a Object.
+func NewTag(repo *Repo, obj *Object) (*Tag, error) {
+	if obj.ObjType != "tag" {
+		return nil, fmt.Errorf("Malformed object: bad type %s", obj.ObjType)
+	}
+
+	tag := Tag{
+		Repository: repo,
+		Object:     obj,
+	}
+	tag.Entries, tag.Keys, tag.Msg = parseObjectHeaders(obj.ObjData)
+
+	if len(tag.Entries["object"]) == 0 || len(tag.Entries["type"]) == 0 {
+		return nil, fmt.Errorf("Malformed object: tag without a target object")
+	}
+	return &tag, nil
+}
+
+// Type returns the type string of a tag object.
+func (tag *Tag) Type() string {
+	return "tag"
+}
+
+// DataSize returns the size of the data of a tag object.
+func (tag *Tag) DataSize() int {
+	return len(tag.ObjData)
+}
+
+// Target returns the hash of the object which is tagged.
+func (tag *Tag) Target() string {
+	return tag.Entries["object"][0]
+}
+
+// TargetType returns the type of the object which is tagged.
+func (tag *Tag) TargetType() string {
+	return tag.Entries["type"][0]
+}
+
+// Name returns the name of the tag.
+func (tag *Tag) Name() string {
+	if names := tag.Entries["tag"]; len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// Print returns a string representation of a tag object.
+func (tag *Tag) Print() string {
+	var b strings.Builder
+	for _, key := range tag.Keys {
+		for _, val := range tag.Entries[key] {
+			fmt.Fprintf(&b, "%s %s\n", key, val)
+		}
+	}
+
+	fmt.Fprintln(&b)
+	b.WriteString(tag.Msg)
+	return b.String()
+}
+
+// PrettyPrint prints a tag object in a human readable format, similar to what
+// is shown by "git show" output.
+func (tag *Tag) PrettyPrint() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "tag %s\n", tag.Name())
+	if taggers := tag.Entries["tagger"]; len(taggers) > 0 {
+		tagger, date := formatIdentity(taggers[0])
+		fmt.Fprintf(&b, "Tagger: %s\n", tagger)
+		fmt.Fprintf(&b, "Date:   %s\n", date)
+	}
+
+	fmt.Fprintln(&b)
+	b.WriteString(tag.Msg)
+	if tag.Msg != "" && !strings.HasSuffix(tag.Msg, "\n") {
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
+
+// PeelObject parses the object with the given hash. If it is a tag, then the
+// tagged object is parsed instead, till an object other than a tag is found.
+// The hash of the peeled object is returned along with it.
+func (r *Repo) PeelObject(objHash string) (*Object, string, error) {
+	for {
+		obj, err := r.ObjectParse(objHash)
+		if err != nil {
+			return nil, "", err
+		}
+		if obj.ObjType != "tag" {
+			return obj, objHash, nil
+		}
+
+		tag, err := NewTag(r, obj)
+		if err != nil {
+			return nil, "", err
+		}
+		objHash = tag.Target()
+	}
+}
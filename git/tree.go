@@ -4,11 +4,9 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 )
 
@@ -20,6 +18,16 @@ type TreeEntry struct {
 	name    string
 }
 
+// Name returns the name of a tree entry.
+func (entry TreeEntry) Name() string {
+	return entry.name
+}
+
+// Type returns the object type of a tree entry.
+func (entry TreeEntry) Type() string {
+	return entry.objType
+}
+
 // Tree is a object with a list of "tree" entries and a git object.
 type Tree struct {
 	Repository *Repo
@@ -124,17 +132,13 @@ func (tree *Tree) ParseData() error {
 		// Next 20 bytes form the entry sha1 hash. It is in binary.
 		entryHash := hex.EncodeToString(data[nameInd+1 : nameInd+21])
 
-		// Get the type of each hash for printing.
-		obj, err := tree.Repository.ObjectParse(entryHash)
-		if err != nil {
-			return err
-		}
-
-		// Prepare a new TreeEntry object and push it to the list.
+		// Prepare a new TreeEntry object and push it to the list. The type of
+		// each entry is known from its mode, as the commit of a submodule
+		// (gitlink) is not present in this repo.
 		entry := TreeEntry{
 			mode:    entryMode,
 			hash:    entryHash,
-			objType: obj.ObjType,
+			objType: modeObjectType(entryMode),
 			name:    entryName,
 		}
 		tree.Entries = append(tree.Entries, entry)
@@ -151,47 +155,121 @@ func (tree *Tree) ParseData() error {
 	return nil
 }
 
+// modeObjectType returns the type of the object referred by a tree entry with
+// the given mode.
+func modeObjectType(mode string) string {
+	switch modeType(mode) {
+	case "040":
+		return "tree"
+	case "160":
+		return "commit"
+	}
+	return "blob"
+}
+
 // Checkout recreates an entire worktree in a given path by recursively reading
-// the blobs and trees inside this tree object.
+// the blobs and trees inside this tree object. Symlinks and executable files
+// are created as such, and submodules are created as empty directories.
 func (tree *Tree) Checkout(path string) error {
+	fs, err := tree.Repository.FSConfig()
+	if err != nil {
+		return err
+	}
+	return tree.checkout(path, fs)
+}
+
+// checkout recreates the tree in a path with the given file system settings.
+func (tree *Tree) checkout(path string, fs FSConfig) error {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return err
+	}
+
 	for _, entry := range tree.Entries {
 		createPath := filepath.Join(path, entry.name)
-		obj, err := tree.Repository.ObjectParse(entry.hash)
-		if err != nil {
-			return err
-		}
-
-		if entry.objType == "tree" {
-			// handle a tree object
-			if err := os.Mkdir(createPath, os.ModePerm); err != nil {
+		if entry.objType != "tree" {
+			// Replace a file left by an earlier checkout, if any.
+			if err := os.Remove(createPath); err != nil && !os.IsNotExist(err) {
 				return err
 			}
-
-			// Recurse on the tree object.
-			tree, err := NewTree(tree.Repository, obj)
+			err := tree.Repository.checkoutFile(createPath, entry.mode, entry.hash, fs)
 			if err != nil {
 				return err
 			}
+			continue
+		}
 
-			err = tree.Checkout(createPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			// handle a blob object
-			blob, err := NewBlob(tree.Repository, obj)
-			if err != nil {
-				return err
-			}
+		// handle a tree object
+		if err := os.MkdirAll(createPath, os.ModePerm); err != nil {
+			return err
+		}
 
-			// Mode is in the format 100xxx. Strip the 100 from it.
-			mode, _ := strconv.ParseInt(entry.mode[3:], 8, 32)
-			err = ioutil.WriteFile(createPath, blob.ObjData, os.FileMode(mode))
-			if err != nil {
-				return err
-			}
+		// Recurse on the tree object.
+		obj, err := tree.Repository.ObjectParse(entry.hash)
+		if err != nil {
+			return err
+		}
+		subTree, err := NewTree(tree.Repository, obj)
+		if err != nil {
+			return err
+		}
+		if err := subTree.checkout(createPath, fs); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// WriteTree writes the tree objects holding the given files, with one tree
+// for each directory, and returns the hash of the top level tree.
+func (r *Repo) WriteTree(files []FileEntry) (string, error) {
+	// Group the files by the first component of their paths. The files of
+	// a sub-directory are kept with the rest of their paths.
+	blobs := map[string]FileEntry{}
+	dirs := map[string][]FileEntry{}
+	for _, file := range files {
+		if ind := strings.IndexByte(file.Path, '/'); ind >= 0 {
+			name := file.Path[:ind]
+			file.Path = file.Path[ind+1:]
+			dirs[name] = append(dirs[name], file)
+		} else {
+			blobs[file.Path] = file
+		}
+	}
+
+	// Git sorts the entries of a tree as if the names of the directories
+	// end with a "/".
+	entries := []TreeEntry{}
+	for name, file := range blobs {
+		entries = append(entries, TreeEntry{mode: file.Mode, hash: file.Hash, name: name})
+	}
+	for name, dirFiles := range dirs {
+		hash, err := r.WriteTree(dirFiles)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, TreeEntry{mode: "40000", hash: hash,
+			objType: "tree", name: name})
+	}
+	sortKey := func(entry TreeEntry) string {
+		if entry.objType == "tree" {
+			return entry.name + "/"
+		}
+		return entry.name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortKey(entries[i]) < sortKey(entries[j])
+	})
+
+	data := []byte{}
+	for _, entry := range entries {
+		byteHash, err := hex.DecodeString(entry.hash)
+		if err != nil {
+			return "", err
+		}
+		data = append(data, []byte(strings.TrimLeft(entry.mode, "0")+" "+entry.name)...)
+		data = append(data, byte('\x00'))
+		data = append(data, byteHash...)
+	}
+	return r.ObjectWrite(NewObject("tree", data), true)
+}
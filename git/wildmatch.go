@@ -0,0 +1,238 @@
+package git
+
+// Results of matching a glob pattern against a text.
+const (
+	wildMatch = iota
+	wildNoMatch
+	// wildAbortAll stops trying any more positions for a '*', as the text is
+	// too short for the rest of the pattern.
+	wildAbortAll
+	// wildAbortToStarStar stops trying any more positions for a '*', till a
+	// "**" which can match across directories is reached.
+	wildAbortToStarStar
+)
+
+// Wildmatch matches a text with a glob pattern in the same way as "git" does
+// for pathspecs and ignore rules. '*' and '?' don't match a '/' if 'pathname'
+// is true, while "**" matches across directories as in "a/**/b".
+func Wildmatch(pattern, text string, pathname bool) bool {
+	return dowild(pattern, text, pathname) == wildMatch
+}
+
+// dowild is the recursive matcher behind Wildmatch.
+func dowild(pattern, text string, pathname bool) int {
+	p, t := 0, 0
+	for ; p < len(pattern); p, t = p+1, t+1 {
+		pCh := pattern[p]
+		if t == len(text) && pCh != '*' {
+			return wildAbortAll
+		}
+
+		switch pCh {
+		case '\\':
+			// A backslash escapes the next character.
+			p++
+			if p == len(pattern) {
+				return wildNoMatch
+			}
+			if text[t] != pattern[p] {
+				return wildNoMatch
+			}
+
+		case '?':
+			if pathname && text[t] == '/' {
+				return wildNoMatch
+			}
+
+		case '*':
+			matchSlash := !pathname
+			p++
+			if p < len(pattern) && pattern[p] == '*' {
+				prev := p - 2
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+
+				if pathname && (prev < 0 || pattern[prev] == '/') &&
+					(p == len(pattern) || pattern[p] == '/' ||
+						(pattern[p] == '\\' && p+1 < len(pattern) && pattern[p+1] == '/')) {
+					// "**/" may match no directories at all.
+					if p < len(pattern) && pattern[p] == '/' &&
+						dowild(pattern[p+1:], text[t:], pathname) == wildMatch {
+						return wildMatch
+					}
+					matchSlash = true
+				} else if !pathname {
+					matchSlash = true
+				}
+			}
+
+			if p == len(pattern) {
+				// A trailing "**" matches everything, and a trailing '*'
+				// matches only if there are no more directories.
+				if !matchSlash && indexByte(text[t:], '/') >= 0 {
+					return wildNoMatch
+				}
+				return wildMatch
+			} else if !matchSlash && pattern[p] == '/' {
+				// "*/" matches the rest of the current directory.
+				slash := indexByte(text[t:], '/')
+				if slash < 0 {
+					return wildNoMatch
+				}
+				t += slash
+				continue
+			}
+
+			for ; t < len(text); t++ {
+				matched := dowild(pattern[p:], text[t:], pathname)
+				if matched != wildNoMatch {
+					if !matchSlash || matched != wildAbortToStarStar {
+						return matched
+					}
+				} else if !matchSlash && text[t] == '/' {
+					return wildAbortToStarStar
+				}
+			}
+			return wildAbortAll
+
+		case '[':
+			matched, next, ok := matchClass(pattern, p, text[t])
+			if !ok {
+				return wildAbortAll
+			}
+			if !matched || (pathname && text[t] == '/') {
+				return wildNoMatch
+			}
+			p = next
+
+		default:
+			if text[t] != pCh {
+				return wildNoMatch
+			}
+		}
+	}
+
+	if t < len(text) {
+		return wildNoMatch
+	}
+	return wildMatch
+}
+
+// indexByte returns the position of a byte in a string, or -1.
+func indexByte(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// charClasses are the named character classes allowed in a bracket
+// expression, such as "[[:digit:]]".
+var charClasses = map[string]func(c byte) bool{
+	"alnum": func(c byte) bool { return isAlpha(c) || isDigit(c) },
+	"alpha": isAlpha,
+	"blank": func(c byte) bool { return c == ' ' || c == '\t' },
+	"cntrl": func(c byte) bool { return c < 0x20 || c == 0x7f },
+	"digit": isDigit,
+	"graph": func(c byte) bool { return c > 0x20 && c < 0x7f },
+	"lower": func(c byte) bool { return c >= 'a' && c <= 'z' },
+	"print": func(c byte) bool { return c >= 0x20 && c < 0x7f },
+	"punct": func(c byte) bool {
+		return c > 0x20 && c < 0x7f && !isAlpha(c) && !isDigit(c)
+	},
+	"space": func(c byte) bool {
+		return c == ' ' || (c >= '\t' && c <= '\r')
+	},
+	"upper":  func(c byte) bool { return c >= 'A' && c <= 'Z' },
+	"xdigit": func(c byte) bool { return isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f') },
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// matchClass matches a character with the bracket expression starting at
+// pattern[start] (a '['). It returns if the character matched, the position
+// of the closing ']' and false if the expression is malformed.
+func matchClass(pattern string, start int, c byte) (bool, int, bool) {
+	p := start + 1
+	if p == len(pattern) {
+		return false, 0, false
+	}
+	negated := pattern[p] == '!' || pattern[p] == '^'
+	if negated {
+		p++
+	}
+
+	matched := false
+	var prev byte
+	for first := true; first || p < len(pattern) && pattern[p] != ']'; first = false {
+		if p == len(pattern) {
+			return false, 0, false
+		}
+
+		ch := pattern[p]
+		switch {
+		case ch == '\\':
+			p++
+			if p == len(pattern) {
+				return false, 0, false
+			}
+			ch = pattern[p]
+			matched = matched || c == ch
+
+		case ch == '-' && prev != 0 && p+1 < len(pattern) && pattern[p+1] != ']':
+			p++
+			ch = pattern[p]
+			if ch == '\\' {
+				p++
+				if p == len(pattern) {
+					return false, 0, false
+				}
+				ch = pattern[p]
+			}
+			matched = matched || (c <= ch && c >= prev)
+			ch = 0
+
+		case ch == '[' && p+1 < len(pattern) && pattern[p+1] == ':':
+			end := p + 2
+			for end < len(pattern) && pattern[end] != ']' {
+				end++
+			}
+			if end == len(pattern) {
+				return false, 0, false
+			}
+			if end-p-2 < 1 || pattern[end-1] != ':' {
+				// Not a "[:class:]", so the '[' is an ordinary character.
+				matched = matched || c == '['
+				break
+			}
+
+			class, ok := charClasses[pattern[p+2:end-1]]
+			if !ok {
+				return false, 0, false
+			}
+			matched = matched || class(c)
+			p = end
+			ch = 0
+
+		default:
+			matched = matched || c == ch
+		}
+
+		prev = ch
+		p++
+	}
+
+	if p == len(pattern) {
+		return false, 0, false
+	}
+	return matched != negated, p, true
+}
@@ -0,0 +1,306 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// FileMode returns the git file mode for the given file information.
+// Example: "100644" for a regular file and "120000" for a symlink.
+func FileMode(info os.FileInfo) string {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return "120000"
+	case info.IsDir():
+		return "40000"
+	case info.Mode()&0111 != 0:
+		return "100755"
+	}
+	return "100644"
+}
+
+// FSConfig tells which file properties of the work-tree can be trusted, as
+// per the "core.fileMode" and "core.symlinks" settings.
+type FSConfig struct {
+	fileMode bool
+	symlinks bool
+}
+
+// FSConfig reads the file system settings of the repo. It's read once for an
+// operation on many files, such as AddFile for each of them.
+func (r *Repo) FSConfig() (FSConfig, error) {
+	config, err := r.Config()
+	if err != nil {
+		return FSConfig{}, err
+	}
+	return FSConfig{
+		fileMode: config.GetBool("core.filemode", true),
+		symlinks: config.GetBool("core.symlinks", true),
+	}, nil
+}
+
+// indexMode returns the mode to record for a work-tree file, given the mode of
+// its current index entry (if any). Without a trusted executable bit, a file
+// keeps the mode of its entry (or is not executable if it is new). Without
+// symlinks, a plain file stays a symlink if its entry is one.
+func (fs FSConfig) indexMode(info os.FileInfo, entryMode string) string {
+	mode := FileMode(info)
+	if modeType(mode) != "100" {
+		return mode
+	}
+	if !fs.symlinks && entryMode == "120000" {
+		return entryMode
+	}
+	if !fs.fileMode {
+		if modeType(entryMode) == "100" {
+			return entryMode
+		}
+		return "100644"
+	}
+	return mode
+}
+
+// ReadWorktreeFile reads the content of a file in the work-tree, given by its
+// path relative to the top of the work-tree. The content of a symlink is the
+// path it points to, like "git" stores it.
+func (r *Repo) ReadWorktreeFile(path string) ([]byte, os.FileInfo, error) {
+	if err := r.RequireWorkTree(); err != nil {
+		return nil, nil, err
+	}
+
+	fullPath := filepath.Join(r.WorkTree, filepath.FromSlash(path))
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []byte(filepath.ToSlash(target)), info, nil
+	}
+
+	data, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, info, nil
+}
+
+// WorktreeFile computes the mode and the blob hash of a file in the work-tree,
+// without writing the blob. The path is relative to the top of the work-tree.
+func (r *Repo) WorktreeFile(path string) (FileEntry, error) {
+	data, info, err := r.ReadWorktreeFile(path)
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	hash, err := r.ObjectWrite(NewObject("blob", data), false)
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	return FileEntry{Path: path, Mode: FileMode(info), Hash: hash}, nil
+}
+
+// DiffTreeToWorktree compares the files of a tree with the same files in the
+// work-tree. Files which are not present in the tree are compared only if
+// they are added to the given index, as the rest are not tracked.
+func (r *Repo) DiffTreeToWorktree(treeHash string, index *Index, opts *DiffOptions) ([]DiffEntry, error) {
+	files, err := r.TreeFiles(treeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []DiffEntry{}
+	inTree := map[string]bool{}
+	for _, file := range files {
+		inTree[file.Path] = true
+		if modeType(file.Mode) == "160" {
+			// Submodules are not compared with their work-trees.
+			continue
+		}
+
+		wtFile, err := r.WorktreeFile(file.Path)
+		if isMissing(err) {
+			entries = append(entries, DiffEntry{Status: DiffDeleted, Old: file})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if wtFile.Hash == file.Hash && wtFile.Mode == file.Mode {
+			continue
+		}
+		status := DiffModified
+		if modeType(wtFile.Mode) != modeType(file.Mode) {
+			status = DiffType
+		}
+		entries = append(entries, DiffEntry{Status: status, Old: file, New: wtFile})
+	}
+
+	if index != nil {
+		added, err := r.worktreeAdditions(index, inTree)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, added...)
+	}
+
+	if opts != nil && (opts.DetectRenames || opts.DetectCopies) {
+		entries, err = r.detectRenames(entries, treeHash, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path() < entries[j].Path()
+	})
+	return entries, nil
+}
+
+// worktreeAdditions returns the work-tree files of the index entries which
+// are not in the given set of paths, as added files.
+func (r *Repo) worktreeAdditions(index *Index, exclude map[string]bool) ([]DiffEntry, error) {
+	entries := []DiffEntry{}
+	for _, file := range index.Files() {
+		if exclude[file.Path] || modeType(file.Mode) == "160" {
+			continue
+		}
+		wtFile, err := r.WorktreeFile(file.Path)
+		if isMissing(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, DiffEntry{Status: DiffAdded, New: wtFile})
+	}
+
+	return entries, nil
+}
+
+// DiffIndexToWorktree compares the files at stage 0 in the index with the
+// work-tree, which gives the changes not staged yet. A file is not read if
+// its stat data matches its index entry. The stat data of the files which are
+// found unchanged anyway is refreshed in the index, and the returned flag
+// tells if any entry got refreshed.
+func (r *Repo) DiffIndexToWorktree(index *Index) ([]DiffEntry, bool, error) {
+	fs, err := r.FSConfig()
+	if err != nil {
+		return nil, false, err
+	}
+
+	entries := []DiffEntry{}
+	refreshed := false
+	for _, entry := range index.Entries {
+		file := entry.FileEntry()
+		if entry.Stage() != 0 || entry.AssumeValid() || entry.SkipWorktree() ||
+			modeType(file.Mode) == "160" {
+			continue
+		}
+
+		info, err := os.Lstat(filepath.Join(r.WorkTree, filepath.FromSlash(entry.Path)))
+		if isMissing(err) || (err == nil && info.IsDir()) {
+			entries = append(entries, DiffEntry{Status: DiffDeleted, Old: file})
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		// A file added with "git add -N" is shown as a new file.
+		if entry.IntentToAdd() {
+			wtFile, err := r.WorktreeFile(entry.Path)
+			if err != nil {
+				return nil, false, err
+			}
+			entries = append(entries, DiffEntry{Status: DiffAdded, New: wtFile})
+			continue
+		}
+
+		if entry.StatMatches(info) && !index.IsRacy(entry) {
+			continue
+		}
+
+		wtFile, err := r.WorktreeFile(entry.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		wtFile.Mode = fs.indexMode(info, file.Mode)
+
+		if wtFile.Hash == file.Hash && wtFile.Mode == file.Mode {
+			entry.SetStat(info)
+			refreshed = true
+			continue
+		}
+		status := DiffModified
+		if modeType(wtFile.Mode) != modeType(file.Mode) {
+			status = DiffType
+		}
+		entries = append(entries, DiffEntry{Status: status, Old: file, New: wtFile})
+	}
+
+	return entries, refreshed, nil
+}
+
+// isMissing tells if an error is due to a missing file, or a missing
+// directory in its path.
+func isMissing(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// AddFile adds the current content of a work-tree file to the index, along
+// with writing its blob. The mode is recorded as per the "core.fileMode" and
+// "core.symlinks" settings, and a directory with a nested repository is added
+// as a submodule at the commit of its HEAD.
+func (r *Repo) AddFile(index *Index, path string, fs FSConfig) error {
+	fullPath := filepath.Join(r.WorkTree, filepath.FromSlash(path))
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+
+	var entry *IndexEntry
+	if info.IsDir() {
+		subRepo := &Repo{WorkTree: fullPath, GitDir: filepath.Join(fullPath, ".git")}
+		_, hash, err := subRepo.Head()
+		if err != nil || hash == "" {
+			return fmt.Errorf("error: '%s/' does not have a commit checked out", path)
+		}
+		if entry, err = NewIndexEntry(path, "160000", hash); err != nil {
+			return err
+		}
+	} else {
+		data, _, err := r.ReadWorktreeFile(path)
+		if err != nil {
+			return err
+		}
+		hash, err := r.ObjectWrite(NewObject("blob", data), true)
+		if err != nil {
+			return err
+		}
+
+		oldMode := ""
+		if oldEntry := index.Entry(path, 0); oldEntry != nil {
+			oldMode = oldEntry.FileEntry().Mode
+		}
+		entry, err = NewIndexEntry(path, fs.indexMode(info, oldMode), hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	entry.SetStat(info)
+	index.Add(entry)
+	return nil
+}
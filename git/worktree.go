@@ -0,0 +1,432 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ssrathi/gogit/util"
+)
+
+// Worktree is a work-tree of a repo, which is either the main work-tree or a
+// linked one added by "gogit worktree add". A linked work-tree has its own
+// HEAD and index in an administrative directory under "worktrees" of the
+// common git directory, and a ".git" file pointing to that directory.
+type Worktree struct {
+	// ID names the administrative directory of a linked work-tree. It is
+	// empty for the main work-tree.
+	ID     string
+	Path   string
+	GitDir string
+	// Branch is the reference of the branch checked out, or empty if HEAD is
+	// detached. Head is the commit hash of HEAD, or empty if there are no
+	// commits yet.
+	Branch string
+	Head   string
+	Bare   bool
+	Locked bool
+	// LockReason is given by "gogit worktree lock --reason", if any.
+	LockReason string
+	// PruneReason tells why a linked work-tree is stale, such as when its
+	// directory is deleted without "gogit worktree remove". It is empty if
+	// the work-tree is in use.
+	PruneReason string
+}
+
+// WorktreeAddOptions controls what is checked out by AddWorktree.
+type WorktreeAddOptions struct {
+	// Branch is the branch to check out, such as "refs/heads/topic". HEAD is
+	// detached at Commit if it is empty.
+	Branch string
+	// NewBranch creates Branch at Commit first.
+	NewBranch bool
+	Commit    string
+	// Force allows checking out a branch which is checked out in another
+	// work-tree already.
+	Force      bool
+	Lock       bool
+	LockReason string
+}
+
+// IsMain tells if the work-tree is the main one of the repo.
+func (wt *Worktree) IsMain() bool {
+	return wt.ID == ""
+}
+
+// Repo returns the repo as seen from the work-tree.
+func (wt *Worktree) Repo(r *Repo) *Repo {
+	workTree := wt.Path
+	if wt.Bare {
+		workTree = ""
+	}
+	return &Repo{GitDir: wt.GitDir, WorkTree: workTree, CommonDir: r.commonDir()}
+}
+
+// commonDir returns the git directory shared by all the work-trees.
+func (r *Repo) commonDir() string {
+	if r.CommonDir == "" {
+		return r.GitDir
+	}
+	return r.CommonDir
+}
+
+// Worktrees lists the main work-tree followed by the linked ones, sorted by
+// their IDs. The linked work-trees whose "gitdir" file is missing are left
+// out, and are removed by PruneWorktrees.
+func (r *Repo) Worktrees() ([]*Worktree, error) {
+	main, err := r.mainWorktree()
+	if err != nil {
+		return nil, err
+	}
+	worktrees := []*Worktree{main}
+
+	adminDir := filepath.Join(r.commonDir(), "worktrees")
+	infos, err := ioutil.ReadDir(adminDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	for _, info := range infos {
+		wt, err := r.linkedWorktree(info.Name())
+		if err != nil {
+			return nil, err
+		}
+		if wt != nil {
+			worktrees = append(worktrees, wt)
+		}
+	}
+	return worktrees, nil
+}
+
+// mainWorktree returns the work-tree of the common git directory, which is
+// the directory having ".git", or the git directory itself for a bare repo.
+func (r *Repo) mainWorktree() (*Worktree, error) {
+	commonDir := r.commonDir()
+	wt := &Worktree{GitDir: commonDir, Path: commonDir}
+
+	config := NewConfig()
+	if err := config.ParseFile(filepath.Join(commonDir, "config")); err != nil {
+		return nil, err
+	}
+	if config.GetBool("core.bare", false) {
+		wt.Bare = true
+	} else {
+		wt.Path = filepath.Dir(commonDir)
+	}
+	if err := wt.readHead(r); err != nil {
+		return nil, err
+	}
+	return wt, nil
+}
+
+// linkedWorktree returns the linked work-tree of an administrative
+// directory, or nil if its "gitdir" file can't be read.
+func (r *Repo) linkedWorktree(id string) (*Worktree, error) {
+	gitDir := filepath.Join(r.commonDir(), "worktrees", id)
+	data, err := ioutil.ReadFile(filepath.Join(gitDir, "gitdir"))
+	if err != nil {
+		return nil, nil
+	}
+	gitFile := strings.TrimSpace(string(data))
+	if gitFile == "" {
+		return nil, nil
+	}
+
+	wt := &Worktree{ID: id, GitDir: gitDir, Path: filepath.Dir(gitFile)}
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "locked")); err == nil {
+		wt.Locked = true
+		wt.LockReason = strings.TrimSpace(string(data))
+	}
+	if !wt.Locked && !util.IsPathPresent(gitFile) {
+		wt.PruneReason = "gitdir file points to non-existent location"
+	}
+	if err := wt.readHead(r); err != nil {
+		return nil, err
+	}
+	return wt, nil
+}
+
+// readHead finds the branch and the commit checked out in the work-tree.
+func (wt *Worktree) readHead(r *Repo) error {
+	branch, hash, err := wt.Repo(r).Head()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	wt.Branch, wt.Head = branch, hash
+	return nil
+}
+
+// FindWorktree finds a work-tree by its path, or by a unique suffix of its
+// path made of whole components (such as "topic" for "/src/topic").
+func (r *Repo) FindWorktree(name string) (*Worktree, error) {
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	path := realPath(name)
+	for _, wt := range worktrees {
+		if realPath(wt.Path) == path {
+			return wt, nil
+		}
+	}
+
+	var found *Worktree
+	suffix := filepath.Clean(name)
+	for _, wt := range worktrees {
+		if wt.Path == suffix || strings.HasSuffix(wt.Path, string(filepath.Separator)+suffix) {
+			if found != nil {
+				found = nil
+				break
+			}
+			found = wt
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("fatal: '%s' is not a working tree", name)
+	}
+	return found, nil
+}
+
+// realPath returns the absolute path with the symbolic links resolved, so
+// that the paths can be compared.
+func realPath(path string) string {
+	path, _ = filepath.Abs(path)
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return path
+}
+
+// BranchCheckedOut returns the work-tree which has a branch (such as
+// "refs/heads/topic") checked out, or nil if there is none.
+func (r *Repo) BranchCheckedOut(branch string) (*Worktree, error) {
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range worktrees {
+		if wt.Branch == branch && !wt.Bare {
+			return wt, nil
+		}
+	}
+	return nil, nil
+}
+
+// AddWorktree creates a linked work-tree at the given path, which must be
+// missing or an empty directory, and checks out a branch or a commit in it.
+func (r *Repo) AddWorktree(path string, opts *WorktreeAddOptions) (*Worktree, error) {
+	fullPath, _ := filepath.Abs(path)
+	if util.IsPathPresent(fullPath) {
+		if empty, _ := util.IsDirEmpty(fullPath); !empty {
+			return nil, fmt.Errorf("fatal: '%s' already exists", path)
+		}
+	}
+
+	name := strings.TrimPrefix(opts.Branch, "refs/heads/")
+	if opts.NewBranch {
+		if _, _, err := r.RefResolve(opts.Branch); err == nil {
+			return nil, fmt.Errorf("fatal: a branch named '%s' already exists", name)
+		}
+		if !checkRefFormat(opts.Branch) {
+			return nil, fmt.Errorf("fatal: '%s' is not a valid branch name", name)
+		}
+	} else if opts.Branch != "" && !opts.Force {
+		wt, err := r.BranchCheckedOut(opts.Branch)
+		if err != nil {
+			return nil, err
+		}
+		if wt != nil {
+			return nil, fmt.Errorf("fatal: '%s' is already checked out at '%s'", name, wt.Path)
+		}
+	}
+
+	// The ID is the name of the work-tree, with a number added if it is
+	// taken already.
+	base := filepath.Base(fullPath)
+	id := base
+	for counter := 1; util.IsPathPresent(filepath.Join(r.commonDir(), "worktrees", id)); counter++ {
+		id = base + strconv.Itoa(counter)
+	}
+
+	wt := &Worktree{
+		ID:     id,
+		Path:   fullPath,
+		GitDir: filepath.Join(r.commonDir(), "worktrees", id),
+	}
+	created := !util.IsPathPresent(wt.Path)
+	if err := r.setupWorktree(wt, opts); err != nil {
+		// Nothing is left behind, so that the work-tree can be added again.
+		r.abortWorktree(wt, opts, created)
+		return nil, err
+	}
+
+	wt.Branch, wt.Head = opts.Branch, opts.Commit
+	return wt, nil
+}
+
+// setupWorktree writes the files of a new linked work-tree, and of its
+// administrative directory, and checks out the branch or the commit in it.
+func (r *Repo) setupWorktree(wt *Worktree, opts *WorktreeAddOptions) error {
+	if err := os.MkdirAll(wt.Path, os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(wt.GitDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	gitFile := filepath.Join(wt.Path, ".git")
+	files := map[string]string{
+		gitFile:                               "gitdir: " + wt.GitDir + "\n",
+		filepath.Join(wt.GitDir, "gitdir"):    gitFile + "\n",
+		filepath.Join(wt.GitDir, "commondir"): "../..\n",
+		filepath.Join(wt.GitDir, "HEAD"):      opts.Commit + "\n",
+	}
+	if opts.Lock {
+		files[filepath.Join(wt.GitDir, "locked")] = opts.LockReason
+		wt.Locked, wt.LockReason = true, opts.LockReason
+	}
+	for file, data := range files {
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+
+	if opts.NewBranch {
+		if err := r.UpdateRef(opts.Branch, opts.Commit); err != nil {
+			return err
+		}
+	}
+
+	repo := wt.Repo(r)
+	tree, err := repo.TreeResolve(opts.Commit)
+	if err != nil {
+		return err
+	}
+	if err := repo.CheckoutTree("", tree, &CheckoutOptions{Command: "checkout"}); err != nil {
+		return err
+	}
+	if opts.Branch != "" {
+		return repo.SetHead(opts.Branch)
+	}
+	return nil
+}
+
+// abortWorktree removes what a failed setupWorktree has created: the work-tree
+// (or only its contents if its directory was there already), the
+// administrative directory and the new branch, if any.
+func (r *Repo) abortWorktree(wt *Worktree, opts *WorktreeAddOptions, created bool) {
+	if created {
+		os.RemoveAll(wt.Path)
+	} else if infos, err := ioutil.ReadDir(wt.Path); err == nil {
+		for _, info := range infos {
+			os.RemoveAll(filepath.Join(wt.Path, info.Name()))
+		}
+	}
+	os.RemoveAll(wt.GitDir)
+	r.removeWorktreesDir()
+
+	if opts.NewBranch {
+		r.refStore().DeleteRef(opts.Branch)
+		r.removeReflog(opts.Branch)
+	}
+}
+
+// WorktreeClean tells if a work-tree doesn't have any local changes or
+// untracked files, so that it can be removed without losing anything.
+func (r *Repo) WorktreeClean(wt *Worktree) (bool, error) {
+	if !util.IsPathPresent(wt.Path) {
+		return true, nil
+	}
+	status, err := wt.Repo(r).Status(&StatusOptions{Untracked: "normal"})
+	if err != nil {
+		return false, err
+	}
+	return len(status.Entries) == 0 && len(status.Untracked) == 0, nil
+}
+
+// RemoveWorktree deletes a linked work-tree along with its administrative
+// directory.
+func (r *Repo) RemoveWorktree(wt *Worktree) error {
+	if err := os.RemoveAll(wt.Path); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(wt.GitDir); err != nil {
+		return err
+	}
+	r.removeWorktreesDir()
+	return nil
+}
+
+// removeWorktreesDir removes the directory of the administrative directories
+// once all the linked work-trees are gone.
+func (r *Repo) removeWorktreesDir() {
+	os.Remove(filepath.Join(r.commonDir(), "worktrees"))
+}
+
+// LockWorktree keeps a linked work-tree from being pruned, such as when it
+// is on a removable disk. The reason can be empty.
+func (r *Repo) LockWorktree(wt *Worktree, reason string) error {
+	return ioutil.WriteFile(filepath.Join(wt.GitDir, "locked"), []byte(reason), 0644)
+}
+
+// UnlockWorktree allows a linked work-tree to be pruned again.
+func (r *Repo) UnlockWorktree(wt *Worktree) error {
+	return os.Remove(filepath.Join(wt.GitDir, "locked"))
+}
+
+// PruneWorktrees removes the administrative directories of the linked
+// work-trees which are stale, unless they are locked. The pruned work-trees
+// are returned along with the reasons, sorted by their IDs.
+func (r *Repo) PruneWorktrees(dryRun bool) ([]*Worktree, error) {
+	adminDir := filepath.Join(r.commonDir(), "worktrees")
+	infos, err := ioutil.ReadDir(adminDir)
+	if os.IsNotExist(err) {
+		return []*Worktree{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	pruned := []*Worktree{}
+	for _, info := range infos {
+		wt := &Worktree{ID: info.Name(), GitDir: filepath.Join(adminDir, info.Name())}
+		if util.IsPathPresent(filepath.Join(wt.GitDir, "locked")) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(wt.GitDir, "gitdir"))
+		gitFile := strings.TrimSpace(string(data))
+		switch {
+		case !info.IsDir():
+			wt.PruneReason = "not a valid directory"
+		case os.IsNotExist(err):
+			wt.PruneReason = "gitdir file does not exist"
+		case err != nil:
+			wt.PruneReason = fmt.Sprintf("unable to read gitdir file (%v)", err)
+		case gitFile == "":
+			wt.PruneReason = "invalid gitdir file"
+		case !util.IsPathPresent(gitFile):
+			wt.PruneReason = "gitdir file points to non-existent location"
+		default:
+			continue
+		}
+
+		pruned = append(pruned, wt)
+		if !dryRun {
+			if err := os.RemoveAll(wt.GitDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !dryRun {
+		r.removeWorktreesDir()
+	}
+	return pruned, nil
+}
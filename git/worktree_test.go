@@ -0,0 +1,168 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ssrathi/gogit/util"
+)
+
+func TestWorktrees(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "testGoGitWorktree")
+	assertEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	repo, err := NewRepo(filepath.Join(dir, "main"))
+	assertEqual(t, err, nil)
+	tree, commit := commitTestFiles(t, repo, map[string]string{"a": "a\n"})
+
+	topicPath := filepath.Join(dir, "topic")
+	t.Run("Validate adding a work-tree", func(t *testing.T) {
+		opts := &WorktreeAddOptions{Branch: "refs/heads/topic", NewBranch: true, Commit: commit}
+		wt, err := repo.AddWorktree(topicPath, opts)
+		assertEqual(t, err, nil)
+		assertEqual(t, wt.ID, "topic")
+
+		found, err := GetRepo(topicPath)
+		assertEqual(t, err, nil)
+		assertEqual(t, found.GitDir, filepath.Join(repo.GitDir, "worktrees", "topic"))
+		assertEqual(t, found.CommonDir, repo.GitDir)
+		branch, hash, err := found.Head()
+		assertEqual(t, err, nil)
+		assertEqual(t, branch, "refs/heads/topic")
+		assertEqual(t, hash, commit)
+		data, err := ioutil.ReadFile(filepath.Join(topicPath, "a"))
+		assertEqual(t, err, nil)
+		assertEqual(t, string(data), "a\n")
+
+		// The refs are shared, while HEAD and the index are not.
+		newCommit := writeTestCommit(t, found, tree)
+		assertEqual(t, found.UpdateRef("HEAD", newCommit), nil)
+		hash, _, err = repo.RefResolve("refs/heads/topic")
+		assertEqual(t, err, nil)
+		assertEqual(t, hash, newCommit)
+		branch, _, err = repo.Head()
+		assertEqual(t, err, nil)
+		assertEqual(t, branch, "refs/heads/master")
+	})
+
+	// The log of HEAD belongs to each work-tree, while the other logs are
+	// shared.
+	t.Run("Validate the reflogs of a work-tree", func(t *testing.T) {
+		found, err := GetRepo(topicPath)
+		assertEqual(t, err, nil)
+		mainLog, err := repo.ReadReflog("HEAD")
+		assertEqual(t, err, nil)
+
+		assertEqual(t, found.WriteReflog("HEAD", commit, commit, "topic: test"), nil)
+		assertEqual(t, found.WriteReflog("refs/heads/topic", commit, commit, "topic: test"), nil)
+		logFile := filepath.Join(repo.GitDir, "worktrees", "topic", "logs", "HEAD")
+		assertEqual(t, util.IsPathPresent(logFile), true)
+		entries, err := found.ReadReflog("HEAD")
+		assertEqual(t, err, nil)
+		assertEqual(t, entries[len(entries)-1].Message, "topic: test")
+		logFile = filepath.Join(repo.GitDir, "logs", "refs", "heads", "topic")
+		assertEqual(t, util.IsPathPresent(logFile), true)
+
+		entries, err = repo.ReadReflog("HEAD")
+		assertEqual(t, err, nil)
+		assertEqual(t, entries, mainLog)
+	})
+
+	t.Run("Validate refusing a branch in use", func(t *testing.T) {
+		_, err := repo.AddWorktree(filepath.Join(dir, "other"),
+			&WorktreeAddOptions{Branch: "refs/heads/master", Commit: commit})
+		assertEqual(t, err.Error(), "fatal: 'master' is already checked out at '"+
+			repo.WorkTree+"'")
+		_, err = repo.AddWorktree(filepath.Join(dir, "other"),
+			&WorktreeAddOptions{Branch: "refs/heads/topic", NewBranch: true, Commit: commit})
+		assertEqual(t, err.Error(), "fatal: a branch named 'topic' already exists")
+		_, err = repo.AddWorktree(topicPath, &WorktreeAddOptions{Commit: commit})
+		assertEqual(t, err.Error(), "fatal: '"+topicPath+"' already exists")
+		assertEqual(t, util.IsPathPresent(filepath.Join(dir, "other")), false)
+	})
+
+	t.Run("Validate cleaning up a failed add", func(t *testing.T) {
+		brokenPath := filepath.Join(dir, "broken")
+		opts := &WorktreeAddOptions{Branch: "refs/heads/broken", NewBranch: true,
+			Commit: strings.Repeat("1", 40)}
+		_, err := repo.AddWorktree(brokenPath, opts)
+		assertEqual(t, err != nil, true)
+		assertEqual(t, util.IsPathPresent(brokenPath), false)
+		assertEqual(t, util.IsPathPresent(filepath.Join(repo.GitDir, "worktrees", "broken")), false)
+		_, _, err = repo.RefResolve("refs/heads/broken")
+		assertEqual(t, os.IsNotExist(err), true)
+
+		// An empty directory given for the work-tree is kept.
+		assertEqual(t, os.Mkdir(brokenPath, 0755), nil)
+		_, err = repo.AddWorktree(brokenPath, opts)
+		assertEqual(t, err != nil, true)
+		empty, err := util.IsDirEmpty(brokenPath)
+		assertEqual(t, err, nil)
+		assertEqual(t, empty, true)
+		assertEqual(t, os.Remove(brokenPath), nil)
+	})
+
+	t.Run("Validate listing the work-trees", func(t *testing.T) {
+		_, err := repo.AddWorktree(filepath.Join(dir, "detached"), &WorktreeAddOptions{Commit: commit})
+		assertEqual(t, err, nil)
+
+		worktrees, err := repo.Worktrees()
+		assertEqual(t, err, nil)
+		assertEqual(t, len(worktrees), 3)
+		assertEqual(t, worktrees[0].IsMain(), true)
+		assertEqual(t, worktrees[0].Path, repo.WorkTree)
+		assertEqual(t, worktrees[1].ID, "detached")
+		assertEqual(t, worktrees[1].Branch, "")
+		assertEqual(t, worktrees[1].Head, commit)
+		assertEqual(t, worktrees[2].Path, topicPath)
+
+		wt, err := repo.FindWorktree("detached")
+		assertEqual(t, err, nil)
+		assertEqual(t, wt.ID, "detached")
+		_, err = repo.FindWorktree("nope")
+		assertEqual(t, err.Error(), "fatal: 'nope' is not a working tree")
+
+		// A bare repo is told by its configuration, whatever its name.
+		bare, err := NewBareRepo(filepath.Join(dir, "bare"))
+		assertEqual(t, err, nil)
+		worktrees, err = bare.Worktrees()
+		assertEqual(t, err, nil)
+		assertEqual(t, worktrees[0].Bare, true)
+	})
+
+	t.Run("Validate pruning the stale work-trees", func(t *testing.T) {
+		wt, err := repo.FindWorktree("detached")
+		assertEqual(t, err, nil)
+		assertEqual(t, repo.LockWorktree(wt, "on usb"), nil)
+		assertEqual(t, os.RemoveAll(wt.Path), nil)
+
+		pruned, err := repo.PruneWorktrees(false)
+		assertEqual(t, err, nil)
+		assertEqual(t, len(pruned), 0)
+
+		assertEqual(t, repo.UnlockWorktree(wt), nil)
+		pruned, err = repo.PruneWorktrees(false)
+		assertEqual(t, err, nil)
+		assertEqual(t, len(pruned), 1)
+		assertEqual(t, pruned[0].PruneReason, "gitdir file points to non-existent location")
+		assertEqual(t, util.IsPathPresent(wt.GitDir), false)
+	})
+
+	t.Run("Validate removing a work-tree", func(t *testing.T) {
+		wt, err := repo.FindWorktree(topicPath)
+		assertEqual(t, err, nil)
+		assertEqual(t, ioutil.WriteFile(filepath.Join(topicPath, "u"), []byte("u\n"), 0644), nil)
+		clean, err := repo.WorktreeClean(wt)
+		assertEqual(t, err, nil)
+		assertEqual(t, clean, false)
+
+		assertEqual(t, repo.RemoveWorktree(wt), nil)
+		assertEqual(t, util.IsPathPresent(topicPath), false)
+		assertEqual(t, util.IsPathPresent(filepath.Join(repo.GitDir, "worktrees")), false)
+	})
+}